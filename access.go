@@ -0,0 +1,94 @@
+package kvite
+
+import (
+	"fmt"
+	"path"
+)
+
+// BucketAccess is the level of access a DB handle has to a bucket.
+type BucketAccess int
+
+// Bucket access levels.
+const (
+	// BucketReadWrite allows reads and writes. It is the default for every bucket.
+	BucketReadWrite BucketAccess = iota
+	// BucketReadOnly allows reads. Put and Delete fail with a *BucketAccessError.
+	BucketReadOnly
+	// BucketRestricted hides the bucket: it is left out of Buckets, and every access fails with a *BucketAccessError.
+	BucketRestricted
+)
+
+func (a BucketAccess) String() string {
+	switch a {
+	case BucketReadWrite:
+		return "read-write"
+	case BucketReadOnly:
+		return "read-only"
+	case BucketRestricted:
+		return "restricted"
+	default:
+		return fmt.Sprintf("BucketAccess(%d)", int(a))
+	}
+}
+
+// BucketAccessError is returned when an operation is not allowed by a bucket's access level.
+type BucketAccessError struct {
+	Bucket string
+	Access BucketAccess
+	// Op is the operation that was refused, such as "put" or "get".
+	Op string
+}
+
+func (e *BucketAccessError) Error() string {
+	return fmt.Sprintf("%s not allowed on %s bucket %q", e.Op, e.Access, e.Bucket)
+}
+
+type bucketRule struct {
+	pattern string
+	access  BucketAccess
+}
+
+// WithBucketAccess sets the access level of the buckets matching pattern, which uses the syntax of path.Match,
+// such as "system" or "system/*". When several patterns match a bucket, the one added last applies.
+func WithBucketAccess(pattern string, access BucketAccess) Option {
+	return func(db *DB) error {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return err
+		}
+		db.bucketRules = append(db.bucketRules, bucketRule{pattern, access})
+		return nil
+	}
+}
+
+// Restrict returns a handle to the same database with an additional bucket access rule, as for WithBucketAccess.
+// The handle shares db's connections, so it is cheap to create one per plugin or component. Closing either handle
+// closes both.
+func (db *DB) Restrict(pattern string, access BucketAccess) (*DB, error) {
+	r := *db
+	r.bucketRules = append([]bucketRule(nil), db.bucketRules...)
+	r.batcher = &batcher{maxSize: db.batcher.maxSize, maxDelay: db.batcher.maxDelay}
+	if err := WithBucketAccess(pattern, access)(&r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// bucketAccess returns the access level of a bucket.
+func (db *DB) bucketAccess(name string) BucketAccess {
+	for i := len(db.bucketRules) - 1; i >= 0; i-- {
+		if ok, _ := path.Match(db.bucketRules[i].pattern, name); ok {
+			return db.bucketRules[i].access
+		}
+	}
+	return BucketReadWrite
+}
+
+// checkAccess returns a *BucketAccessError if op is not allowed on the bucket.
+// Operations that modify the bucket need BucketReadWrite; all others need at least BucketReadOnly.
+func (b *Bucket) checkAccess(op string, write bool) error {
+	access := b.tx.db.bucketAccess(b.name)
+	if access == BucketRestricted || write && access == BucketReadOnly {
+		return &BucketAccessError{Bucket: b.name, Access: access, Op: op}
+	}
+	return nil
+}
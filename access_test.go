@@ -0,0 +1,60 @@
+package kvite
+
+func (s *KViteTestSuite) TestBucketAccess() {
+	db := s.openDB("access.db", WithBucketAccess("system", BucketReadOnly))
+	defer func() { _ = db.Close() }()
+
+	err := db.Transaction(func(tx *Tx) error {
+		_, err := tx.CreateBucketIfNotExists("system")
+		return err
+	})
+	s.Equal(&BucketAccessError{Bucket: "system", Access: BucketReadOnly, Op: "create"}, err)
+
+	// A restricted handle shares the database but not its rules
+	plugin, err := s.DB.Restrict("secret/*", BucketRestricted)
+	s.Require().NoError(err)
+	plugin, err = plugin.Restrict("config", BucketReadOnly)
+	s.Require().NoError(err)
+	_, err = s.DB.Restrict("[", BucketReadOnly)
+	s.Error(err)
+
+	s.NoError(s.DB.Transaction(func(tx *Tx) error {
+		for _, name := range []string{"secret/keys", "config", "plugin"} {
+			b, _ := tx.CreateBucketIfNotExists(name)
+			if err := b.Put("foo", []byte("bar")); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+
+	s.NoError(plugin.Transaction(func(tx *Tx) error {
+		_, err := tx.CreateBucketIfNotExists("secret/keys")
+		s.IsType(&BucketAccessError{}, err)
+		secret, err := tx.Bucket("secret/keys")
+		s.Require().NoError(err)
+		_, err = secret.Get("foo")
+		s.IsType(&BucketAccessError{}, err)
+		s.IsType(&BucketAccessError{}, secret.ForEach(func(string, []byte) error { return nil }))
+		s.IsType(&BucketAccessError{}, secret.Delete("foo"))
+
+		_, err = tx.CreateBucketIfNotExists("config")
+		s.IsType(&BucketAccessError{}, err)
+		config, err := tx.Bucket("config")
+		s.Require().NoError(err)
+		value, err := config.Get("foo")
+		s.NoError(err)
+		s.Equal([]byte("bar"), value)
+		s.IsType(&BucketAccessError{}, config.Delete("foo"))
+
+		b, _ := tx.CreateBucketIfNotExists("plugin")
+		return b.Put("foo", []byte("baz"))
+	}))
+
+	buckets, err := plugin.Buckets()
+	s.NoError(err)
+	s.ElementsMatch([]string{"config", "plugin"}, buckets)
+	buckets, err = s.DB.Buckets()
+	s.NoError(err)
+	s.Len(buckets, 3)
+}
@@ -0,0 +1,179 @@
+package kvite
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ArchiveManifestName is the name of the manifest in archives written by ExportArchive. Escaped path segments
+// never start with a dot, so it cannot collide with a bucket.
+const ArchiveManifestName = ".kvite-manifest.json"
+
+// ArchiveManifest describes the contents of an archive written by ExportArchive.
+type ArchiveManifest struct {
+	Created time.Time       `json:"created"`
+	Table   string          `json:"table"`
+	Buckets []ArchiveBucket `json:"buckets"`
+}
+
+// ArchiveBucket is a bucket in an ArchiveManifest.
+type ArchiveBucket struct {
+	Name   string        `json:"name"`
+	Config *BucketConfig `json:"config,omitempty"`
+	Keys   []ArchiveKey  `json:"keys"`
+}
+
+// ArchiveKey is a key in an ArchiveManifest, with the path of the file holding its value.
+type ArchiveKey struct {
+	Key  string `json:"key"`
+	Path string `json:"path"`
+	Size int    `json:"size"`
+}
+
+// ExportArchive writes the buckets as a tar stream with one file per key, at the path bucket/key, followed by a
+// manifest named ArchiveManifestName listing the buckets, their configuration and their keys. Slashes in keys
+// make directories; every other character that is not safe in a path is escaped as in a URL, and so are empty
+// path segments, which are written as "%", and a leading dot. A key that is also the prefix of another, such as
+// "a" and "a/b", produces an archive that can be listed but not extracted by most tools.
+// Values are written decoded, as Get returns them. ExportArchive reads a consistent view of the database and
+// skips the buckets the handle is not allowed to read.
+func (db *DB) ExportArchive(w io.Writer) error {
+	if err := db.life.check(); err != nil {
+		return err
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	names, err := db.buckets(tx.tx)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	manifest := ArchiveManifest{Created: now, Table: db.table, Buckets: make([]ArchiveBucket, 0, len(names))}
+	tw := tar.NewWriter(w)
+	for _, name := range names {
+		entry := ArchiveBucket{Name: name, Keys: []ArchiveKey{}}
+		if cfg := tx.BucketConfig(name); cfg != (BucketConfig{}) {
+			entry.Config = &cfg
+		}
+		err := tx.newBucket(name).ForEach(func(k string, v []byte) error {
+			path := archivePath(name, k)
+			hdr := &tar.Header{Name: path, Mode: 0644, Size: int64(len(v)), ModTime: now, Typeflag: tar.TypeReg}
+			if err := tw.WriteHeader(hdr); err != nil {
+				return err
+			}
+			if _, err := tw.Write(v); err != nil {
+				return err
+			}
+			entry.Keys = append(entry.Keys, ArchiveKey{Key: k, Path: path, Size: len(v)})
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		manifest.Buckets = append(manifest.Buckets, entry)
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	hdr := &tar.Header{Name: ArchiveManifestName, Mode: 0644, Size: int64(len(data)), ModTime: now, Typeflag: tar.TypeReg}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	if _, err := tw.Write(data); err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+// ImportArchive writes the keys of an archive written by ExportArchive with Put, in a single transaction, and
+// returns the number of keys written. If filter is not nil, only the keys it returns true for are written, so
+// that a bucket or a single key can be restored. Bucket configurations in the manifest are not applied; values
+// are encoded with the configuration of the buckets they are written to.
+func (db *DB) ImportArchive(r io.Reader, filter func(bucket, key string) bool) (int, error) {
+	var n int
+	err := db.Transaction(func(tx *Tx) error {
+		n = 0
+		tr := tar.NewReader(r)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if hdr.Typeflag != tar.TypeReg || hdr.Name == ArchiveManifestName {
+				continue
+			}
+			bucket, key, err := parseArchivePath(hdr.Name)
+			if err != nil {
+				return err
+			}
+			if filter != nil && !filter(bucket, key) {
+				continue
+			}
+			value, err := io.ReadAll(tr)
+			if err != nil {
+				return err
+			}
+			b, err := tx.CreateBucketIfNotExists(bucket)
+			if err != nil {
+				return err
+			}
+			if err := b.Put(key, value); err != nil {
+				return err
+			}
+			n++
+		}
+	})
+	return n, err
+}
+
+// archivePath returns the path of a key in an archive.
+func archivePath(bucket, key string) string {
+	segments := strings.Split(key, "/")
+	for i, s := range segments {
+		segments[i] = escapeArchiveSegment(s)
+	}
+	return escapeArchiveSegment(bucket) + "/" + strings.Join(segments, "/")
+}
+
+func escapeArchiveSegment(s string) string {
+	if s == "" {
+		return "%"
+	}
+	s = url.PathEscape(s)
+	if s[0] == '.' {
+		s = "%2E" + s[1:]
+	}
+	return s
+}
+
+// parseArchivePath reverses archivePath.
+func parseArchivePath(path string) (bucket, key string, err error) {
+	segments := strings.Split(path, "/")
+	if len(segments) < 2 {
+		return "", "", fmt.Errorf("archive entry %q is not in a bucket", path)
+	}
+	for i, s := range segments {
+		if s == "%" {
+			segments[i] = ""
+			continue
+		}
+		if segments[i], err = url.PathUnescape(s); err != nil {
+			return "", "", fmt.Errorf("archive entry %q: %v", path, err)
+		}
+	}
+	return segments[0], strings.Join(segments[1:], "/"), nil
+}
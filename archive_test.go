@@ -0,0 +1,69 @@
+package kvite
+
+import (
+	"archive/tar"
+	"bytes"
+	"encoding/json"
+	"io"
+)
+
+func (s *KViteTestSuite) TestArchive() {
+	s.NoError(s.DB.Transaction(func(tx *Tx) error {
+		s.NoError(tx.SetBucketConfig("docs", BucketConfig{Compress: true}))
+		b, _ := tx.Bucket("docs")
+		s.NoError(b.Put("a/b c", []byte("nested")))
+		s.NoError(b.Put("../up", []byte("escaped")))
+		s.NoError(b.Put("", []byte("empty key")))
+		b, _ = tx.CreateBucket("cfg/prod")
+		return b.Put("x", []byte("1"))
+	}))
+
+	var buf bytes.Buffer
+	s.Require().NoError(s.DB.ExportArchive(&buf))
+
+	files := map[string]string{}
+	tr := tar.NewReader(bytes.NewReader(buf.Bytes()))
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		s.Require().NoError(err)
+		data, err := io.ReadAll(tr)
+		s.NoError(err)
+		files[hdr.Name] = string(data)
+	}
+	s.Equal("nested", files["docs/a/b%20c"])
+	s.Equal("escaped", files["docs/%2E./up"])
+	s.Equal("empty key", files["docs/%"])
+	s.Equal("1", files["cfg%2Fprod/x"])
+
+	var manifest ArchiveManifest
+	s.Require().NoError(json.Unmarshal([]byte(files[ArchiveManifestName]), &manifest))
+	s.Equal("testing", manifest.Table)
+	s.Require().Len(manifest.Buckets, 2)
+	s.Equal("docs", manifest.Buckets[0].Name)
+	s.Equal(&BucketConfig{Compress: true}, manifest.Buckets[0].Config)
+	s.Len(manifest.Buckets[0].Keys, 3)
+	s.Equal([]ArchiveKey{{Key: "x", Path: "cfg%2Fprod/x", Size: 1}}, manifest.Buckets[1].Keys)
+
+	// Keys can be restored selectively
+	db := s.openDB("imported.db")
+	defer func() { _ = db.Close() }()
+	n, err := db.ImportArchive(bytes.NewReader(buf.Bytes()), func(bucket, key string) bool {
+		return bucket == "docs" && key != ""
+	})
+	s.NoError(err)
+	s.Equal(2, n)
+	s.NoError(db.Transaction(func(tx *Tx) error {
+		b, err := tx.Bucket("docs")
+		s.Require().NoError(err)
+		value, err := b.Get("../up")
+		s.NoError(err)
+		s.Equal([]byte("escaped"), value)
+		exists, err := tx.BucketExists("cfg/prod")
+		s.NoError(err)
+		s.False(exists)
+		return nil
+	}))
+}
@@ -0,0 +1,134 @@
+package kvite
+
+import "sync"
+
+// PutAsync sets the value for a key without waiting for the write to commit. Writes are queued in order and
+// written by a background goroutine, which groups the writes that queue up while it commits into a single
+// transaction. done, if not nil, is called from that goroutine with the result of the write once it has
+// committed or failed; it must not block for long, as it holds up the writes behind it. Close waits for queued
+// writes to finish.
+func (db *DB) PutAsync(bucket, key string, value []byte, done func(error)) {
+	if value == nil {
+		value = []byte{}
+	}
+	if err := db.checkWrite(Change{Bucket: bucket, Key: key, Type: ChangePut, Value: value}); err != nil {
+		if done != nil {
+			done(err)
+		}
+		return
+	}
+	db.async.add(asyncWrite{bucket: bucket, key: key, value: value, done: done})
+}
+
+// checkWrite applies the checks a transaction would make to a write that is carried out later, outside of it,
+// including encoding the value of a put with the configuration of its bucket.
+func (db *DB) checkWrite(c Change) error {
+	op := "put"
+	if c.Type == ChangeDelete {
+		op = "delete"
+	}
+	if db.readOnly {
+		return ErrTxReadOnly
+	}
+	if access := db.bucketAccess(c.Bucket); access != BucketReadWrite {
+		return &BucketAccessError{Bucket: c.Bucket, Access: access, Op: op}
+	}
+	if c.Type == ChangePut {
+		if _, err := db.encodeValue(db.BucketConfig(c.Bucket), c.Bucket, c.Key, c.Value); err != nil {
+			return err
+		}
+	}
+	return db.allowWrite(c.Bucket)
+}
+
+// asyncWriter holds the queue of DB.PutAsync. It is shared by handles returned from Restrict, and writes through
+// the handle that opened the database once each write has been checked against the handle it was made through.
+type asyncWriter struct {
+	db *DB
+
+	mu      sync.Mutex
+	idle    *sync.Cond
+	pending []asyncWrite
+	running bool
+}
+
+type asyncWrite struct {
+	bucket, key string
+	value       []byte
+	done        func(error)
+}
+
+func (w *asyncWriter) add(a asyncWrite) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.pending = append(w.pending, a)
+	if !w.running {
+		w.running = true
+		go w.run()
+	}
+}
+
+// run writes batches from the queue until it is empty.
+func (w *asyncWriter) run() {
+	for {
+		w.mu.Lock()
+		n := len(w.pending)
+		if n > w.db.batcher.maxSize {
+			n = w.db.batcher.maxSize
+		}
+		writes := w.pending[:n:n]
+		w.pending = w.pending[n:]
+		if n == 0 {
+			w.running = false
+			w.idle.Broadcast()
+		}
+		w.mu.Unlock()
+		if n == 0 {
+			return
+		}
+		w.write(writes)
+	}
+}
+
+// write applies a batch in one transaction. If that fails, each write is retried in a transaction of its own so
+// that only the failing writes report an error.
+func (w *asyncWriter) write(writes []asyncWrite) {
+	err := w.db.Transaction(func(tx *Tx) error {
+		for _, a := range writes {
+			b, err := tx.CreateBucketIfNotExists(a.bucket)
+			if err != nil {
+				return err
+			}
+			if err := b.Put(a.key, a.value); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil && len(writes) > 1 {
+		for _, a := range writes {
+			w.write([]asyncWrite{a})
+		}
+		return
+	}
+	for _, a := range writes {
+		if a.done != nil {
+			a.done(err)
+		}
+	}
+}
+
+// wait blocks until the queue is empty.
+func (w *asyncWriter) wait() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for w.running {
+		w.idle.Wait()
+	}
+}
+
+func newAsyncWriter(db *DB) *asyncWriter {
+	w := &asyncWriter{db: db}
+	w.idle = sync.NewCond(&w.mu)
+	return w
+}
@@ -0,0 +1,53 @@
+package kvite
+
+import (
+	"strconv"
+	"sync"
+)
+
+func (s *KViteTestSuite) TestPutAsync() {
+	db := s.openDB("async.db")
+	restricted, err := db.Restrict("private", BucketReadOnly)
+	s.Require().NoError(err)
+
+	var (
+		mu      sync.Mutex
+		results = map[string]error{}
+	)
+	done := func(key string) func(error) {
+		return func(err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			results[key] = err
+		}
+	}
+	for i := 0; i < 100; i++ {
+		key := strconv.Itoa(i)
+		db.PutAsync("async", key, []byte(key), done(key))
+		// Later writes to a key win.
+		db.PutAsync("async", "last", []byte(key), nil)
+	}
+	restricted.PutAsync("private", "k", []byte("v"), done("private"))
+	s.NoError(db.Close())
+
+	s.Len(results, 101)
+	for i := 0; i < 100; i++ {
+		s.NoError(results[strconv.Itoa(i)])
+	}
+	s.IsType(&BucketAccessError{}, results["private"])
+
+	db = s.openDB("async.db")
+	defer func() { _ = db.Close() }()
+	s.Equal(101, s.countKeys(db, "async"))
+	s.NoError(db.Transaction(func(tx *Tx) error {
+		b, _ := tx.CreateBucketIfNotExists("async")
+		value, err := b.Get("last")
+		s.Equal([]byte("99"), value)
+		return err
+	}))
+
+	s.NoError(db.Close())
+	db.PutAsync("async", "closed", nil, done("closed"))
+	db.async.wait()
+	s.Equal(ErrDBClosed, results["closed"])
+}
@@ -0,0 +1,352 @@
+package kvite
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ErrBackupKey is returned when restoring an encrypted backup without the right key.
+var ErrBackupKey = errors.New("backup is encrypted and cannot be decrypted with the given key")
+
+// BackupOption configures BackupToObjectStore and RestoreFromObjectStore.
+type BackupOption func(*backupOptions) error
+
+type backupOptions struct {
+	compress bool
+	aead     cipher.AEAD
+	name     string
+}
+
+// BackupCompressed gzips the backup.
+func BackupCompressed() BackupOption {
+	return func(o *backupOptions) error {
+		o.compress = true
+		return nil
+	}
+}
+
+// BackupEncrypted encrypts the backup with AES-GCM. The key must be 16, 24 or 32 bytes long, and the same key must
+// be passed to RestoreFromObjectStore.
+func BackupEncrypted(key []byte) BackupOption {
+	return func(o *backupOptions) error {
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return err
+		}
+		o.aead, err = cipher.NewGCM(block)
+		return err
+	}
+}
+
+// BackupName sets the object name of the backup. By default it is backups/<table>-<UTC time>.db, with .gz and .enc
+// appended when the backup is compressed or encrypted.
+func BackupName(name string) BackupOption {
+	return func(o *backupOptions) error {
+		o.name = name
+		return nil
+	}
+}
+
+// BackupToObjectStore writes a consistent copy of the database to an object store and returns the name of the
+// object. bucketURL takes any target accepted by NewReplicaClient, typically s3://bucket/prefix.
+// The copy is taken with VACUUM INTO, so it does not block writers for longer than a read transaction would.
+func (db *DB) BackupToObjectStore(ctx context.Context, bucketURL string, opts ...BackupOption) (string, error) {
+	if err := db.life.check(); err != nil {
+		return "", err
+	}
+	var o backupOptions
+	for _, opt := range opts {
+		if err := opt(&o); err != nil {
+			return "", err
+		}
+	}
+
+	client, err := NewReplicaClient(bucketURL)
+	if err != nil {
+		return "", err
+	}
+
+	name := o.name
+	if name == "" {
+		name = fmt.Sprintf("backups/%s-%s.db", db.table, time.Now().UTC().Format("20060102T150405Z"))
+		if o.compress {
+			name += ".gz"
+		}
+		if o.aead != nil {
+			name += ".enc"
+		}
+	}
+
+	dir, err := os.MkdirTemp("", "kvite-backup-")
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = os.RemoveAll(dir) }()
+
+	path := filepath.Join(dir, "backup.db")
+	if _, err := db.db.ExecContext(ctx, "VACUUM INTO ?", path); err != nil {
+		return "", err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = f.Close() }()
+
+	pr, pw := io.Pipe()
+	go func() {
+		_ = pw.CloseWithError(encodeBackup(pw, f, &o))
+	}()
+
+	err = client.Put(ctx, name, pr)
+	_ = pr.CloseWithError(err)
+	return name, err
+}
+
+// RestoreFromObjectStore downloads a backup written by BackupToObjectStore to dstPath, replacing any existing file.
+// Compression is detected automatically; encrypted backups need BackupEncrypted with the key they were written with.
+func RestoreFromObjectStore(ctx context.Context, bucketURL, name, dstPath string, opts ...BackupOption) error {
+	var o backupOptions
+	for _, opt := range opts {
+		if err := opt(&o); err != nil {
+			return err
+		}
+	}
+
+	client, err := NewReplicaClient(bucketURL)
+	if err != nil {
+		return err
+	}
+	rc, err := client.Get(ctx, name)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = rc.Close() }()
+
+	f, err := os.CreateTemp(filepath.Dir(dstPath), ".kvite-restore-")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+	}()
+
+	if err := decodeBackup(f, rc, &o); err != nil {
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), dstPath)
+}
+
+func encodeBackup(w io.Writer, r io.Reader, o *backupOptions) error {
+	if o.aead == nil {
+		return compressBackup(w, r, o.compress)
+	}
+
+	ew, err := newEncryptWriter(w, o.aead)
+	if err != nil {
+		return err
+	}
+	if err := compressBackup(ew, r, o.compress); err != nil {
+		return err
+	}
+	return ew.Close()
+}
+
+func compressBackup(w io.Writer, r io.Reader, compress bool) error {
+	if !compress {
+		_, err := io.Copy(w, r)
+		return err
+	}
+
+	zw := gzip.NewWriter(w)
+	if _, err := io.Copy(zw, r); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+func decodeBackup(w io.Writer, r io.Reader, o *backupOptions) error {
+	br := bufio.NewReader(r)
+	if magic, _ := br.Peek(len(encryptedMagic)); bytes.Equal(magic, encryptedMagic) {
+		if o.aead == nil {
+			return ErrBackupKey
+		}
+		dr, err := newDecryptReader(br, o.aead)
+		if err != nil {
+			return err
+		}
+		br = bufio.NewReader(dr)
+	}
+
+	if magic, _ := br.Peek(2); bytes.Equal(magic, []byte{0x1f, 0x8b}) {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(w, zr); err != nil {
+			return err
+		}
+		return zr.Close()
+	}
+	_, err := io.Copy(w, br)
+	return err
+}
+
+// Encrypted backups are a magic string and a random nonce prefix followed by AES-GCM sealed chunks. Each chunk is
+// preceded by its sealed length, with the high bit set on the final chunk. The chunk number and final flag are part
+// of the nonce and additional data, so chunks cannot be reordered, dropped or truncated without detection.
+var encryptedMagic = []byte("KVITEENC1")
+
+const (
+	encryptChunkSize = 64 << 10
+	finalChunkFlag   = 1 << 31
+)
+
+type encryptWriter struct {
+	w      io.Writer
+	aead   cipher.AEAD
+	prefix []byte
+	n      uint32
+	buf    []byte
+}
+
+func newEncryptWriter(w io.Writer, aead cipher.AEAD) (*encryptWriter, error) {
+	prefix := make([]byte, aead.NonceSize()-4)
+	if _, err := rand.Read(prefix); err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(encryptedMagic); err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(prefix); err != nil {
+		return nil, err
+	}
+	return &encryptWriter{w: w, aead: aead, prefix: prefix}, nil
+}
+
+func (e *encryptWriter) Write(p []byte) (int, error) {
+	e.buf = append(e.buf, p...)
+	for len(e.buf) > encryptChunkSize {
+		if err := e.seal(e.buf[:encryptChunkSize], false); err != nil {
+			return 0, err
+		}
+		e.buf = e.buf[encryptChunkSize:]
+	}
+	return len(p), nil
+}
+
+// Close writes the final chunk. It does not close the underlying writer.
+func (e *encryptWriter) Close() error {
+	return e.seal(e.buf, true)
+}
+
+func (e *encryptWriter) seal(chunk []byte, final bool) error {
+	nonce, ad := chunkNonce(e.prefix, e.n, final)
+	e.n++
+
+	sealed := e.aead.Seal(nil, nonce, chunk, ad)
+	length := uint32(len(sealed))
+	if final {
+		length |= finalChunkFlag
+	}
+
+	var hdr [4]byte
+	binary.BigEndian.PutUint32(hdr[:], length)
+	if _, err := e.w.Write(hdr[:]); err != nil {
+		return err
+	}
+	_, err := e.w.Write(sealed)
+	return err
+}
+
+type decryptReader struct {
+	r      io.Reader
+	aead   cipher.AEAD
+	prefix []byte
+	n      uint32
+	buf    []byte
+	done   bool
+}
+
+func newDecryptReader(r io.Reader, aead cipher.AEAD) (*decryptReader, error) {
+	hdr := make([]byte, len(encryptedMagic)+aead.NonceSize()-4)
+	if _, err := io.ReadFull(r, hdr); err != nil {
+		return nil, err
+	}
+	return &decryptReader{r: r, aead: aead, prefix: hdr[len(encryptedMagic):]}, nil
+}
+
+func (d *decryptReader) Read(p []byte) (int, error) {
+	for len(d.buf) == 0 {
+		if d.done {
+			return 0, io.EOF
+		}
+		if err := d.open(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, d.buf)
+	d.buf = d.buf[n:]
+	return n, nil
+}
+
+func (d *decryptReader) open() error {
+	var hdr [4]byte
+	if _, err := io.ReadFull(d.r, hdr[:]); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	length := binary.BigEndian.Uint32(hdr[:])
+	final := length&finalChunkFlag != 0
+	length &^= finalChunkFlag
+	if length > encryptChunkSize+uint32(d.aead.Overhead()) {
+		return ErrBackupKey
+	}
+
+	sealed := make([]byte, length)
+	if _, err := io.ReadFull(d.r, sealed); err != nil {
+		return err
+	}
+
+	nonce, ad := chunkNonce(d.prefix, d.n, final)
+	d.n++
+	chunk, err := d.aead.Open(nil, nonce, sealed, ad)
+	if err != nil {
+		return ErrBackupKey
+	}
+	d.buf = chunk
+	d.done = final
+	return nil
+}
+
+func chunkNonce(prefix []byte, n uint32, final bool) ([]byte, []byte) {
+	nonce := make([]byte, len(prefix)+4)
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint32(nonce[len(prefix):], n)
+	if final {
+		return nonce, []byte{1}
+	}
+	return nonce, []byte{0}
+}
@@ -0,0 +1,43 @@
+package kvite
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+)
+
+func (s *KViteTestSuite) TestBackupToObjectStore() {
+	ctx := context.Background()
+	db := s.openDB("backup.db")
+	defer func() { _ = db.Close() }()
+	s.putN(db, "test", 0, 5000)
+
+	target := "file://" + filepath.Join(s.TempDir, "objects")
+	key := []byte("0123456789abcdef0123456789abcdef")
+
+	for _, opts := range [][]BackupOption{
+		nil,
+		{BackupCompressed()},
+		{BackupEncrypted(key)},
+		{BackupCompressed(), BackupEncrypted(key)},
+	} {
+		name, err := db.BackupToObjectStore(ctx, target, opts...)
+		s.Require().NoError(err)
+		s.True(strings.HasPrefix(name, "backups/testing-"))
+
+		dst := filepath.Join(s.TempDir, "restored.db")
+		s.Require().NoError(RestoreFromObjectStore(ctx, target, name, dst, opts...))
+
+		restored, err := Open(dst, "testing")
+		s.Require().NoError(err)
+		s.Equal(5000, s.countKeys(restored, "test"))
+		s.NoError(restored.Close())
+	}
+
+	name, err := db.BackupToObjectStore(ctx, target, BackupEncrypted(key), BackupName("secret.db.enc"))
+	s.Require().NoError(err)
+	s.Equal("secret.db.enc", name)
+	dst := filepath.Join(s.TempDir, "secret.db")
+	s.Equal(ErrBackupKey, RestoreFromObjectStore(ctx, target, name, dst))
+	s.Equal(ErrBackupKey, RestoreFromObjectStore(ctx, target, name, dst, BackupEncrypted([]byte("fedcba9876543210"))))
+}
@@ -0,0 +1,140 @@
+package kvite
+
+import (
+	"errors"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultMaxBatchSize is the default number of calls DB.Batch groups into one transaction.
+	DefaultMaxBatchSize = 1000
+	// DefaultMaxBatchDelay is the default time DB.Batch waits for more calls before starting a transaction.
+	DefaultMaxBatchDelay = 10 * time.Millisecond
+)
+
+// WithBatchLimits sets how many calls DB.Batch groups into a single transaction and how long it waits for more
+// calls to arrive before starting one.
+func WithBatchLimits(maxSize int, maxDelay time.Duration) Option {
+	return func(db *DB) error {
+		if maxSize <= 0 || maxDelay < 0 {
+			return errors.New("batch size must be positive and delay must not be negative")
+		}
+		db.batcher.maxSize = maxSize
+		db.batcher.maxDelay = maxDelay
+		return nil
+	}
+}
+
+// errTrySolo tells a Batch caller to run its function in a transaction of its own.
+var errTrySolo = errors.New("batch function returned an error and should be re-run solo")
+
+// Batch calls fn as part of a write transaction shared with other concurrent Batch calls, which amortizes the cost
+// of committing over many goroutines that each make a small write. It returns once the shared transaction has
+// committed. If fn returns an error, the shared transaction is rolled back and retried without fn, and fn is then
+// run in a transaction of its own, whose error Batch returns. fn may therefore be called more than once and must
+// not have side effects outside the transaction. Shared and solo transactions are both retried according to the
+// database's retry policy.
+//
+// Batch is only useful when it is called from several goroutines at once; a single caller waits for the batch
+// delay for nothing.
+func (db *DB) Batch(fn func(*Tx) error) error {
+	errCh := make(chan error, 1)
+
+	b := db.batcher
+	b.mu.Lock()
+	if b.current == nil || len(b.current.calls) >= b.maxSize {
+		b.current = &batch{db: db, batcher: b}
+		b.current.timer = time.AfterFunc(b.maxDelay, b.current.trigger)
+	}
+	b.current.calls = append(b.current.calls, batchCall{fn: fn, err: errCh})
+	if len(b.current.calls) >= b.maxSize {
+		go b.current.trigger()
+	}
+	b.mu.Unlock()
+
+	err := <-errCh
+	if err == errTrySolo {
+		err = db.Transaction(fn)
+	}
+	return err
+}
+
+// batcher collects the calls of DB.Batch. Each handle returned from Restrict has its own, so that every call runs
+// with the access rules of the handle it was made through.
+type batcher struct {
+	maxSize  int
+	maxDelay time.Duration
+
+	mu      sync.Mutex
+	current *batch
+}
+
+type batch struct {
+	db      *DB
+	batcher *batcher
+	timer   *time.Timer
+	start   sync.Once
+	calls   []batchCall
+}
+
+type batchCall struct {
+	fn  func(*Tx) error
+	err chan<- error
+}
+
+// trigger runs the batch, once, when it is full or its delay has passed.
+func (b *batch) trigger() {
+	b.start.Do(b.run)
+}
+
+func (b *batch) run() {
+	b.batcher.mu.Lock()
+	b.timer.Stop()
+	if b.batcher.current == b {
+		b.batcher.current = nil
+	}
+	b.batcher.mu.Unlock()
+
+	for len(b.calls) > 0 {
+		var failed int
+		err := b.db.Transaction(func(tx *Tx) error {
+			// Only a failure in the attempt that ends the transaction counts: the retry policy may run it again.
+			failed = -1
+			if err := tx.lockForWrite(); err != nil {
+				return err
+			}
+			for i, c := range b.calls {
+				if err := safelyCall(c.fn, tx); err != nil {
+					failed = i
+					return err
+				}
+			}
+			return nil
+		})
+
+		if failed >= 0 {
+			c := b.calls[failed]
+			b.calls = append(b.calls[:failed], b.calls[failed+1:]...)
+			c.err <- errTrySolo
+			continue
+		}
+
+		for _, c := range b.calls {
+			c.err <- err
+		}
+		return
+	}
+}
+
+// safelyCall turns a panic in fn into an error, so that the batch can carry on and fn panics again when it is re-run
+// by its own caller.
+func safelyCall(fn func(*Tx) error, tx *Tx) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = &PanicError{Value: p, Stack: debug.Stack()}
+		}
+	}()
+	return fn(tx)
+}
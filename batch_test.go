@@ -0,0 +1,121 @@
+package kvite
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+func (s *KViteTestSuite) TestBatch() {
+	db := s.openDB("batch.db", WithBatchLimits(10, 50*time.Millisecond))
+	defer func() { _ = db.Close() }()
+
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		calls = make(map[int]int)
+		errs  = make([]error, 10)
+	)
+	errBad := errors.New("bad")
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = db.Batch(func(tx *Tx) error {
+				mu.Lock()
+				calls[i]++
+				mu.Unlock()
+				if i == 3 {
+					return errBad
+				}
+				b, _ := tx.CreateBucketIfNotExists("batch")
+				return b.Put(fmt.Sprint(i), []byte("v"))
+			})
+		}(i)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if i == 3 {
+			s.Equal(errBad, err)
+		} else {
+			s.NoError(err)
+		}
+	}
+	s.Equal(9, s.countKeys(db, "batch"))
+	// The failing call ran in the shared transaction and again on its own.
+	s.Equal(2, calls[3])
+	s.Equal(int64(2), db.TxMetrics().Rollbacks)
+}
+
+func (s *KViteTestSuite) TestBatchRetry() {
+	errTransient := errors.New("transient")
+	db := s.openDB("batch-retry.db", WithBatchLimits(2, time.Second), WithRetryPolicy(RetryPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   time.Millisecond,
+		Retryable:   func(err error) bool { return err == errTransient },
+	}))
+	defer func() { _ = db.Close() }()
+
+	// One call fails once with a retryable error. Once the retried batch commits, neither call runs again.
+	var (
+		wg   sync.WaitGroup
+		once sync.Once
+		errs = make([]error, 2)
+	)
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = db.Batch(func(tx *Tx) error {
+				b, err := tx.CreateBucketIfNotExists("batch")
+				if err != nil {
+					return err
+				}
+				value, err := b.Get(fmt.Sprint(i))
+				if err != nil {
+					return err
+				}
+				if err := b.Put(fmt.Sprint(i), append(value, 'x')); err != nil {
+					return err
+				}
+				if i == 1 {
+					failed := false
+					once.Do(func() { failed = true })
+					if failed {
+						return errTransient
+					}
+				}
+				return nil
+			})
+		}(i)
+	}
+	wg.Wait()
+
+	s.NoError(errs[0])
+	s.NoError(errs[1])
+	for i := 0; i < 2; i++ {
+		value, err := db.Get("batch", fmt.Sprint(i))
+		s.NoError(err)
+		s.Equal("x", string(value))
+	}
+	s.Equal(int64(1), db.TxMetrics().Retries)
+}
+
+func (s *KViteTestSuite) TestBatchPanic() {
+	err := s.DB.Batch(func(tx *Tx) error {
+		panic("boom")
+	})
+	var perr *PanicError
+	s.Require().True(errors.As(err, &perr))
+	s.Equal("boom", perr.Value)
+
+	db := s.openDB("batch-repanic.db", WithRepanic())
+	defer func() { _ = db.Close() }()
+	s.Panics(func() {
+		_ = db.Batch(func(tx *Tx) error {
+			panic("boom")
+		})
+	})
+}
@@ -0,0 +1,178 @@
+package kvite
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"path"
+	"sync"
+)
+
+// WithBloomFilter keeps a bloom filter of the keys of each bucket matching pattern in memory, so that Get and Has
+// can answer for keys that certainly do not exist without querying SQLite. pattern uses the syntax of path.Match,
+// and when several patterns match a bucket, the one added last applies; a capacity of 0 turns the filter off for
+// matching buckets. Filters are sized for capacity keys with a false positive rate of 1%, which rises as buckets
+// grow beyond it.
+//
+// Filters are built from the keys in the database when it is opened, and keys written through this process are
+// added as they are written. Deleted keys stay in the filter until the database is opened again, which only costs
+// lookups. Writes made by other processes sharing the database file are not seen, so the filter must only be used
+// when this process is the only writer. ExecRaw and Load suspend the filters until their transaction has finished
+// and the keys it wrote have been added.
+func WithBloomFilter(pattern string, capacity int) Option {
+	return func(db *DB) error {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return err
+		}
+		if capacity < 0 {
+			return errors.New("bloom filter capacity must not be negative")
+		}
+		if db.bloom == nil {
+			db.bloom = &bloomFilters{buckets: make(map[string]*bloom)}
+		}
+		db.bloom.rules = append(db.bloom.rules, cacheRule{pattern: pattern, entries: capacity})
+		return nil
+	}
+}
+
+// bloomFilters holds the bloom filters of a database. It is shared by handles returned from Restrict.
+type bloomFilters struct {
+	rules []cacheRule
+
+	mu        sync.Mutex
+	buckets   map[string]*bloom
+	suspended int
+}
+
+// bloom is the filter of a single bucket, with k hash functions over the bits of m.
+type bloom struct {
+	bits []uint64
+	k    int
+}
+
+func newBloom(capacity int) *bloom {
+	n := float64(capacity)
+	m := int(math.Ceil(-n * math.Log(0.01) / (math.Ln2 * math.Ln2)))
+	return &bloom{bits: make([]uint64, (m+63)/64), k: 7}
+}
+
+// positions calls fn with the bits of a key, derived from two halves of its FNV-1a hash.
+func (b *bloom) positions(key string, fn func(i uint64)) {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(key))
+	sum := h.Sum64()
+	h1, h2 := sum&0xffffffff, sum>>32|1
+	m := uint64(len(b.bits) * 64)
+	for i := 0; i < b.k; i++ {
+		fn((h1 + uint64(i)*h2) % m)
+	}
+}
+
+func (b *bloom) add(key string) {
+	b.positions(key, func(i uint64) { b.bits[i/64] |= 1 << (i % 64) })
+}
+
+func (b *bloom) has(key string) bool {
+	found := true
+	b.positions(key, func(i uint64) { found = found && b.bits[i/64]&(1<<(i%64)) != 0 })
+	return found
+}
+
+// bucket returns the filter of a bucket, or nil if the bucket is not filtered. f.mu must be held.
+func (f *bloomFilters) bucket(name string) *bloom {
+	if b, ok := f.buckets[name]; ok {
+		return b
+	}
+	var capacity int
+	for i := len(f.rules) - 1; i >= 0; i-- {
+		if ok, _ := path.Match(f.rules[i].pattern, name); ok {
+			capacity = f.rules[i].entries
+			break
+		}
+	}
+	var b *bloom
+	if capacity > 0 {
+		b = newBloom(capacity)
+	}
+	f.buckets[name] = b
+	return b
+}
+
+// add records a key written to a bucket.
+func (f *bloomFilters) add(bucket, key string) {
+	if f == nil {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if b := f.bucket(bucket); b != nil {
+		b.add(key)
+	}
+}
+
+// mayContain reports whether a key may exist in a bucket, as far as tx can tell.
+func (f *bloomFilters) mayContain(tx *Tx, bucket, key string) bool {
+	if f == nil || tx.bloomSuspended {
+		return true
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.suspended > 0 {
+		return true
+	}
+	b := f.bucket(bucket)
+	return b == nil || b.has(key)
+}
+
+// load adds every key in the database to the filters. It is called when the database is opened, and after a
+// transaction that wrote rows behind kvite's back has committed.
+func (f *bloomFilters) load(db *DB) error {
+	if f == nil {
+		return nil
+	}
+	rows, err := db.db.Query(fmt.Sprintf("SELECT bucket, key FROM '%s'", db.table))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var bucket, key string
+		if err := rows.Scan(&bucket, &key); err != nil {
+			return err
+		}
+		f.add(bucket, key)
+	}
+	return rows.Err()
+}
+
+// suspendBloom stops the filters from answering until tx has finished, because it writes rows without adding
+// their keys.
+func (tx *Tx) suspendBloom() {
+	f := tx.db.bloom
+	if f == nil || tx.bloomSuspended {
+		return
+	}
+	f.mu.Lock()
+	f.suspended++
+	f.mu.Unlock()
+	tx.bloomSuspended = true
+}
+
+// resumeBloom lets the filters answer again once a transaction that suspended them has finished, after adding the
+// keys it may have written. If they cannot be read, the filters stay suspended.
+func (tx *Tx) resumeBloom(committed bool) {
+	if !tx.bloomSuspended {
+		return
+	}
+	f := tx.db.bloom
+	if committed {
+		if err := f.load(tx.db); err != nil {
+			return
+		}
+	}
+	f.mu.Lock()
+	f.suspended--
+	f.mu.Unlock()
+}
@@ -0,0 +1,76 @@
+package kvite
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+func (s *KViteTestSuite) TestBloomFilter() {
+	db := s.openDB("bloom.db")
+	s.NoError(db.Transaction(func(tx *Tx) error {
+		b, _ := tx.CreateBucket("test")
+		return b.Put("existing", []byte("1"))
+	}))
+	s.NoError(db.Close())
+
+	db = s.openDB("bloom.db", WithBloomFilter("*", 1000), WithBloomFilter("unfiltered", 0))
+	defer func() { _ = db.Close() }()
+	get := func(bucket, key string) []byte {
+		var value []byte
+		s.NoError(db.Transaction(func(tx *Tx) (err error) {
+			value, err = tx.newBucket(bucket).Get(key)
+			return err
+		}))
+		return value
+	}
+	// lookups returns the number of statements Get and Has run for a missing key
+	lookups := func() int {
+		var n int
+		s.NoError(db.Transaction(func(tx *Tx) error {
+			b := tx.newBucket("test")
+			_, _ = b.Get("missing")
+			_, _ = b.Has("missing")
+			n = tx.Stats().Statements
+			return nil
+		}))
+		return n
+	}
+
+	s.Equal([]byte("1"), get("test", "existing"), "keys in the database at open are in the filter")
+	s.Equal(0, lookups(), "missing keys are answered without querying SQLite")
+
+	s.NoError(db.Transaction(func(tx *Tx) error {
+		b := tx.newBucket("test")
+		for i := 0; i < 100; i++ {
+			if err := b.Put(fmt.Sprint(i), []byte("v")); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+	for i := 0; i < 100; i++ {
+		s.Equal([]byte("v"), get("test", fmt.Sprint(i)))
+	}
+
+	// Rows written behind kvite's back are added once their transaction commits
+	s.NoError(db.Transaction(func(tx *Tx) error {
+		_, err := tx.ExecRaw("INSERT INTO {table} (bucket, key, value) VALUES ('test', 'raw', x'32')")
+		if err != nil {
+			return err
+		}
+		value, err := tx.newBucket("test").Get("raw")
+		s.Equal([]byte("2"), value, "the filter is suspended while the transaction runs")
+		return err
+	}))
+	s.Equal([]byte("2"), get("test", "raw"))
+	s.Equal(0, lookups())
+
+	s.NoError(db.Transaction(func(tx *Tx) error {
+		return tx.newBucket("unfiltered").Put("k", []byte("3"))
+	}))
+	s.Equal([]byte("3"), get("unfiltered", "k"))
+	s.Nil(get("unfiltered", "missing"))
+
+	_, err := Open(filepath.Join(s.TempDir, "bad-bloom.db"), "testing", WithBloomFilter("*", -1))
+	s.Error(err)
+}
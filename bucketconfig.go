@@ -0,0 +1,286 @@
+package kvite
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// BucketConfig holds the options of a bucket. It is stored in the bucket registry, so every handle and process
+// that opens the database applies it, and Put and Get apply it without their callers having to.
+type BucketConfig struct {
+	// DefaultTTL, if positive, makes keys expire this long after they are written. Expired keys are removed by
+	// DB.ExpireKeys.
+	DefaultTTL time.Duration `json:"ttl,omitempty"`
+	// SlidingTTL makes Get push back the expiry of the keys it reads to DefaultTTL from then, so that keys expire
+	// once they have been neither read nor written for about DefaultTTL. To spare most reads a write, the expiry is
+	// only pushed back once a quarter of DefaultTTL has passed since it was last set, so a key may expire up to a
+	// quarter of DefaultTTL sooner than its last read suggests. A Get that pushes back the expiry writes, and takes
+	// the write lock like Put, except in read-only transactions and through handles that may not write to the bucket.
+	SlidingTTL bool `json:"sliding_ttl,omitempty"`
+	// Codec is the name of a codec registered with WithCodec. Values are encoded with it before they are stored
+	// and decoded when they are read.
+	Codec string `json:"codec,omitempty"`
+	// Compress compresses values with DEFLATE after they are encoded. Values that do not get smaller are stored
+	// uncompressed. It is the same as setting Compression to CompressionDeflate.
+	Compress bool `json:"compress,omitempty"`
+	// Compression selects the algorithm values are compressed with, such as CompressionGzip, or CompressionNone to
+	// store new values uncompressed. Each value records how it was stored, so values written with an earlier
+	// algorithm stay readable when it is changed.
+	Compression string `json:"compression,omitempty"`
+	// Pipeline is a comma-separated list of stages that values pass through in order when they are stored, and in
+	// reverse when they are read, such as "gzip,cipher,sign" to compress, encrypt and sign values. Each stage is a
+	// compression algorithm or the name of a codec registered with WithCodec. Every value records the stages it
+	// was stored with, so that values written before the pipeline was changed stay readable as long as their
+	// codecs are registered. It cannot be combined with Codec or compression, and values stored before the
+	// bucket had a pipeline must be migrated with DB.Rewrite.
+	Pipeline string `json:"pipeline,omitempty"`
+	// CompressThreshold is the length below which values are stored uncompressed.
+	CompressThreshold int `json:"compress_threshold,omitempty"`
+	// MaxValueSize, if positive, makes Put fail with a *ValueTooLargeError for values longer than this many bytes.
+	MaxValueSize int `json:"max_value_size,omitempty"`
+	// Versioning keeps the values replaced by Put and Delete, so that they can be read with Bucket.GetAt.
+	Versioning bool `json:"versioning,omitempty"`
+}
+
+// Codec encodes values before they are stored and decodes them when they are read, such as a serialization
+// format or an encryption layer.
+type Codec interface {
+	Encode(value []byte) ([]byte, error)
+	Decode(stored []byte) ([]byte, error)
+}
+
+// WithCodec registers a codec under a name, so that buckets can select it with BucketConfig.Codec. Every process
+// that opens the database must register the codecs its buckets use, otherwise Open fails.
+func WithCodec(name string, c Codec) Option {
+	return func(db *DB) error {
+		if name == "" || c == nil {
+			return errors.New("codec needs a name and an implementation")
+		}
+		if db.codecs == nil {
+			db.codecs = make(map[string]Codec)
+		}
+		db.codecs[name] = c
+		return nil
+	}
+}
+
+// ValueTooLargeError is returned by Put when a value is longer than the MaxValueSize of its bucket.
+type ValueTooLargeError struct {
+	Bucket string
+	Key    string
+	Size   int
+	Max    int
+}
+
+func (e *ValueTooLargeError) Error() string {
+	return fmt.Sprintf("value of %d bytes for key %q in bucket %q exceeds the maximum of %d", e.Size, e.Key, e.Bucket, e.Max)
+}
+
+// bucketConfigs holds the configuration of the buckets that have one. It is loaded when the database is opened
+// and shared by handles returned from Restrict.
+type bucketConfigs struct {
+	mu sync.RWMutex
+	m  map[string]BucketConfig
+}
+
+func (c *bucketConfigs) get(name string) BucketConfig {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.m[name]
+}
+
+// set applies configuration committed by a transaction. A zero configuration removes the bucket's entry.
+func (c *bucketConfigs) set(changes map[string]BucketConfig) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for name, cfg := range changes {
+		if cfg == (BucketConfig{}) {
+			delete(c.m, name)
+		} else {
+			c.m[name] = cfg
+		}
+	}
+}
+
+// loadBucketConfigs reads the configuration of the buckets from the registry.
+func (db *DB) loadBucketConfigs() error {
+	if !db.registry {
+		return nil
+	}
+	rows, err := db.db.Query(fmt.Sprintf("SELECT name, config FROM '%s' WHERE config IS NOT NULL", db.bucketsTable()))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			name string
+			data []byte
+		)
+		if err := rows.Scan(&name, &data); err != nil {
+			return err
+		}
+		cfg, err := db.parseBucketConfig(name, data)
+		if err != nil {
+			return err
+		}
+		db.configs.m[name] = cfg
+	}
+	return rows.Err()
+}
+
+// parseBucketConfig decodes and validates the configuration of a bucket as stored in the registry.
+func (db *DB) parseBucketConfig(name string, data []byte) (BucketConfig, error) {
+	var cfg BucketConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("bucket %q has an invalid configuration: %v", name, err)
+	}
+	if err := db.validateBucketConfig(cfg); err != nil {
+		return cfg, fmt.Errorf("bucket %q: %v", name, err)
+	}
+	return cfg, nil
+}
+
+func (db *DB) validateBucketConfig(cfg BucketConfig) error {
+	if cfg.DefaultTTL < 0 || cfg.MaxValueSize < 0 || cfg.CompressThreshold < 0 {
+		return errors.New("bucket TTL, maximum value size and compression threshold must not be negative")
+	}
+	if _, ok := compressors[cfg.Compression]; cfg.Compression != "" && !ok {
+		return fmt.Errorf("unknown compression %q", cfg.Compression)
+	}
+	if _, ok := db.codecs[cfg.Codec]; cfg.Codec != "" && !ok {
+		return fmt.Errorf("codec %q is not registered", cfg.Codec)
+	}
+	return db.validatePipeline(cfg)
+}
+
+// SetBucketConfig stores the configuration of a bucket, creating the bucket if needed. It applies to the rest of
+// the transaction straight away, and to the other transactions of the database once it commits. Other processes
+// see it when they next open the database.
+// Values already in the bucket are not rewritten, so changing the codec of a bucket that has keys, or turning its
+// compression on or off, leaves them unreadable until they are migrated with DB.Rewrite. Changing the compression
+// algorithm is safe.
+func (tx *Tx) SetBucketConfig(name string, cfg BucketConfig) error {
+	if tx.readOnly {
+		return ErrTxReadOnly
+	}
+	if err := tx.newBucket(name).checkAccess("configure", true); err != nil {
+		return err
+	}
+	if !tx.db.registry {
+		return errors.New("bucket configuration needs the bucket registry, which is not created with SkipSchema")
+	}
+	if err := tx.db.validateBucketConfig(cfg); err != nil {
+		return err
+	}
+	if _, err := tx.createBucket(name); err != nil {
+		return err
+	}
+
+	var data interface{}
+	if cfg != (BucketConfig{}) {
+		b, err := json.Marshal(cfg)
+		if err != nil {
+			return err
+		}
+		data = b
+	}
+	query := fmt.Sprintf("UPDATE '%s' SET config = ? WHERE name = ?", tx.db.bucketsTable())
+	if _, err := tx.exec(query, data, name); err != nil {
+		return err
+	}
+	tx.setConfig(name, cfg)
+	return nil
+}
+
+// BucketConfig returns the configuration of a bucket, including changes made earlier in the transaction.
+func (tx *Tx) BucketConfig(name string) BucketConfig {
+	if cfg, ok := tx.configs[name]; ok {
+		return cfg
+	}
+	return tx.db.configs.get(name)
+}
+
+// BucketConfig returns the configuration of a bucket.
+func (db *DB) BucketConfig(name string) BucketConfig {
+	return db.configs.get(name)
+}
+
+// setConfig records a configuration change to apply to the database when the transaction commits.
+func (tx *Tx) setConfig(name string, cfg BucketConfig) {
+	if tx.configs == nil {
+		tx.configs = make(map[string]BucketConfig)
+	}
+	tx.configs[name] = cfg
+}
+
+// encodeValue checks a value against the configuration of its bucket and returns the bytes to store for it.
+func (tx *Tx) encodeValue(bucket, key string, value []byte) ([]byte, error) {
+	return tx.db.encodeValue(tx.BucketConfig(bucket), bucket, key, value)
+}
+
+// encodeValue checks a value against the configuration cfg of its bucket and returns the bytes to store for it.
+func (db *DB) encodeValue(cfg BucketConfig, bucket, key string, value []byte) ([]byte, error) {
+	if cfg.MaxValueSize > 0 && len(value) > cfg.MaxValueSize {
+		return nil, &ValueTooLargeError{Bucket: bucket, Key: key, Size: len(value), Max: cfg.MaxValueSize}
+	}
+	if cfg.Pipeline != "" {
+		return db.encodePipeline(cfg.Pipeline, cfg.CompressThreshold, value)
+	}
+	var err error
+	if cfg.Codec != "" {
+		if value, err = db.codecs[cfg.Codec].Encode(value); err != nil {
+			return nil, err
+		}
+	}
+	if c := cfg.compression(); c != "" {
+		if value, err = compressValue(c, cfg.CompressThreshold, value); err != nil {
+			return nil, err
+		}
+	}
+	if value == nil {
+		value = []byte{}
+	}
+	return value, nil
+}
+
+// decodeValue reverses encodeValue for a value read from a bucket.
+func (tx *Tx) decodeValue(bucket string, stored []byte) ([]byte, error) {
+	cfg := tx.BucketConfig(bucket)
+	if cfg.Pipeline != "" {
+		return tx.db.decodePipeline(stored)
+	}
+	var err error
+	if cfg.compression() != "" {
+		if stored, err = decompressValue(stored); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.Codec != "" {
+		if stored, err = tx.db.codecs[cfg.Codec].Decode(stored); err != nil {
+			return nil, err
+		}
+	}
+	return stored, nil
+}
+
+// scanValue reads a value and its checksum from a row, verifies it, and decodes it for the bucket. Empty values
+// are returned as non-nil, zero-length slices.
+func (b *Bucket) scanValue(key string, value []byte, sum sql.NullInt64) ([]byte, error) {
+	if err := b.verify(key, value, sum); err != nil {
+		return nil, err
+	}
+	value, err := b.tx.decodeValue(b.name, value)
+	if err != nil {
+		return nil, err
+	}
+	if value == nil {
+		value = []byte{}
+	}
+	return value, nil
+}
@@ -0,0 +1,191 @@
+package kvite
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"time"
+)
+
+// reverseCodec stores values backwards, so that tests can tell encoded values from plain ones.
+type reverseCodec struct{}
+
+func (reverseCodec) Encode(value []byte) ([]byte, error) { return reverse(value), nil }
+
+func (reverseCodec) Decode(stored []byte) ([]byte, error) { return reverse(stored), nil }
+
+func reverse(b []byte) []byte {
+	r := make([]byte, len(b))
+	for i := range b {
+		r[len(b)-1-i] = b[i]
+	}
+	return r
+}
+
+func (s *KViteTestSuite) storedValue(db *DB, bucket, key string) []byte {
+	var value []byte
+	s.NoError(db.db.QueryRow("SELECT value FROM 'testing' WHERE bucket = ? AND key = ?", bucket, key).Scan(&value))
+	return value
+}
+
+func (s *KViteTestSuite) TestBucketConfig() {
+	db := s.openDB("config.db", WithCodec("reverse", reverseCodec{}))
+	long := bytes.Repeat([]byte("abc"), 100)
+
+	s.NoError(db.Transaction(func(tx *Tx) error {
+		s.Error(tx.SetBucketConfig("bad", BucketConfig{Codec: "missing"}))
+		s.NoError(tx.SetBucketConfig("docs", BucketConfig{Codec: "reverse", Compress: true, MaxValueSize: 1000}))
+		b, err := tx.Bucket("docs")
+		s.Require().NoError(err)
+		s.NoError(b.Put("short", []byte("hello")))
+		s.NoError(b.Put("long", long))
+		s.NoError(b.Put("empty", nil))
+
+		var tooLarge *ValueTooLargeError
+		s.True(errors.As(b.Put("huge", make([]byte, 1001)), &tooLarge))
+		s.Equal(1001, tooLarge.Size)
+		return nil
+	}))
+	s.Equal(BucketConfig{Codec: "reverse", Compress: true, MaxValueSize: 1000}, db.BucketConfig("docs"))
+
+	// Short values are not worth compressing and long ones are
+	s.Equal(append([]byte{valueRaw}, "olleh"...), s.storedValue(db, "docs", "short"))
+	s.Less(len(s.storedValue(db, "docs", "long")), len(long))
+	s.NoError(db.Close())
+
+	// The configuration is loaded again on open, and needs its codec
+	_, err := Open(filepath.Join(s.TempDir, "config.db"), "testing")
+	s.Error(err)
+
+	db = s.openDB("config.db", WithCodec("reverse", reverseCodec{}))
+	defer func() { _ = db.Close() }()
+	s.NoError(db.Transaction(func(tx *Tx) error {
+		b, err := tx.Bucket("docs")
+		s.Require().NoError(err)
+		value, err := b.Get("short")
+		s.NoError(err)
+		s.Equal([]byte("hello"), value)
+		value, err = b.Get("empty")
+		s.NoError(err)
+		s.Equal([]byte{}, value)
+
+		values := map[string][]byte{}
+		s.NoError(b.ForEach(func(k string, v []byte) error {
+			values[k] = v
+			return nil
+		}))
+		s.Equal(map[string][]byte{"short": []byte("hello"), "long": long, "empty": {}}, values)
+		return nil
+	}))
+
+	// Deleting the bucket removes its configuration
+	s.NoError(db.Transaction(func(tx *Tx) error {
+		return tx.DeleteBucket("docs")
+	}))
+	s.Equal(BucketConfig{}, db.BucketConfig("docs"))
+}
+
+func (s *KViteTestSuite) TestBucketDefaultTTL() {
+	s.NoError(s.DB.Transaction(func(tx *Tx) error {
+		s.NoError(tx.SetBucketConfig("short", BucketConfig{DefaultTTL: time.Millisecond}))
+		b, _ := tx.Bucket("short")
+		s.NoError(b.Put("a", []byte("1")))
+		s.NoError(b.Put("b", []byte("2")))
+		b, _ = tx.CreateBucket("forever")
+		return b.Put("a", []byte("1"))
+	}))
+
+	time.Sleep(5 * time.Millisecond)
+	n, err := s.DB.ExpireKeys()
+	s.NoError(err)
+	s.Equal(int64(2), n)
+	s.Equal(0, s.countKeys(s.DB, "short"))
+	s.Equal(1, s.countKeys(s.DB, "forever"))
+}
+
+func (s *KViteTestSuite) TestBucketVersioning() {
+	s.NoError(s.DB.Transaction(func(tx *Tx) error {
+		s.NoError(tx.SetBucketConfig("test", BucketConfig{Versioning: true}))
+		b, _ := tx.Bucket("test")
+		s.NoError(b.Put("foo", []byte("one")))
+		s.NoError(b.Put("foo", []byte("two")))
+		s.NoError(b.Delete("foo"))
+		return b.Put("foo", []byte("three"))
+	}))
+
+	s.NoError(s.DB.Transaction(func(tx *Tx) error {
+		b, _ := tx.Bucket("test")
+		value, version, err := b.GetVersion("foo")
+		s.NoError(err)
+		s.Equal([]byte("three"), value)
+		s.Equal(int64(3), version, "versions are not reused after a delete")
+
+		for v, want := range map[int64][]byte{1: []byte("one"), 2: []byte("two"), 3: []byte("three"), 4: nil} {
+			value, err := b.GetAt("foo", v)
+			s.NoError(err)
+			s.Equal(want, value, "version %d", v)
+		}
+		return nil
+	}))
+}
+
+func (s *KViteTestSuite) TestBucketSlidingTTL() {
+	const ttl = 200 * time.Millisecond
+	s.NoError(s.DB.Transaction(func(tx *Tx) error {
+		s.NoError(tx.SetBucketConfig("sessions", BucketConfig{DefaultTTL: ttl, SlidingTTL: true}))
+		b, _ := tx.Bucket("sessions")
+		s.NoError(b.Put("used", []byte("1")))
+		return b.Put("idle", []byte("2"))
+	}))
+	get := func() {
+		s.NoError(s.DB.Transaction(func(tx *Tx) error {
+			b, _ := tx.Bucket("sessions")
+			value, err := b.Get("used")
+			s.Equal([]byte("1"), value)
+			return err
+		}))
+	}
+
+	// Reading a key keeps it alive past its original expiry
+	time.Sleep(ttl * 2 / 3)
+	get()
+	time.Sleep(ttl * 2 / 3)
+	get()
+	n, err := s.DB.ExpireKeys()
+	s.NoError(err)
+	s.Equal(int64(1), n)
+	s.Equal(1, s.countKeys(s.DB, "sessions"))
+
+	time.Sleep(ttl + 10*time.Millisecond)
+	n, err = s.DB.ExpireKeys()
+	s.NoError(err)
+	s.Equal(int64(1), n)
+}
+
+func (s *KViteTestSuite) TestBucketSlidingTTLSkipsRecentRefresh() {
+	const ttl = 400 * time.Millisecond
+	s.NoError(s.DB.Transaction(func(tx *Tx) error {
+		s.NoError(tx.SetBucketConfig("sessions", BucketConfig{DefaultTTL: ttl, SlidingTTL: true}))
+		b, _ := tx.Bucket("sessions")
+		return b.Put("used", []byte("1"))
+	}))
+	get := func() (wrote bool) {
+		s.NoError(s.DB.Transaction(func(tx *Tx) error {
+			b, _ := tx.Bucket("sessions")
+			value, err := b.Get("used")
+			s.Equal([]byte("1"), value)
+			wrote = tx.wrote
+			return err
+		}))
+		return wrote
+	}
+
+	// Reads soon after the expiry was set leave it alone, from the database and from the read cache
+	s.False(get())
+	s.False(get())
+
+	// Once a quarter of the TTL has passed, a read pushes the expiry back once
+	time.Sleep(ttl / 2)
+	s.True(get())
+	s.False(get())
+}
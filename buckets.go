@@ -0,0 +1,128 @@
+package kvite
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// bucketsColumns are the columns added to the bucket registry since it was introduced.
+var bucketsColumns = []column{
+	{name: "config", upgrade: "text"},
+}
+
+func (db *DB) bucketsTable() string {
+	return db.table + "_kvite_buckets"
+}
+
+// createBucketsTable creates the bucket registry, which records the buckets created with CreateBucket so that they
+// exist before their first key is written and after their last key is deleted.
+// Buckets that already have keys when the registry is created are registered, so that checking for them does not
+// need to look through the kvite table.
+func (db *DB) createBucketsTable(tx *sql.Tx) error {
+	exists, err := tableExists(tx, db.bucketsTable())
+	if err != nil {
+		return err
+	}
+	if exists {
+		return addMissingColumns(tx, db.bucketsTable(), bucketsColumns)
+	}
+	query := fmt.Sprintf("create TABLE '%s' (name text not null primary key, config text)", db.bucketsTable())
+	if _, err := tx.Exec(query); err != nil {
+		return err
+	}
+	query = fmt.Sprintf("INSERT INTO '%s' (name) SELECT DISTINCT bucket FROM '%s'", db.bucketsTable(), db.table)
+	_, err = tx.Exec(query)
+	return err
+}
+
+// initBucketsQuery lists registered buckets in the order they were created, followed by buckets that have keys but
+// were never registered, such as those written before the registry existed. Databases without a registry, which
+// are opened read-only or with SkipSchema, list the buckets that have keys.
+func (db *DB) initBucketsQuery() error {
+	exists, err := tableExists(db.db, db.bucketsTable())
+	if err != nil {
+		return err
+	}
+	db.registry = exists
+	if !exists {
+		db.bucketsQuery = fmt.Sprintf("SELECT DISTINCT bucket from '%s'", db.table)
+		db.existsQuery = fmt.Sprintf("SELECT EXISTS (SELECT 1 FROM '%s' WHERE bucket = ?)", db.table)
+		return nil
+	}
+
+	r := db.bucketsTable()
+	db.existsQuery = fmt.Sprintf("SELECT EXISTS (SELECT 1 FROM '%s' WHERE name = ?) OR EXISTS (SELECT 1 FROM '%s' WHERE bucket = ?)", r, db.table)
+	db.bucketsQuery = fmt.Sprintf(`SELECT name FROM (SELECT name, 0 AS g, rowid AS o FROM '%s'
+		UNION ALL SELECT DISTINCT bucket, 1, 0 FROM '%s' WHERE bucket NOT IN (SELECT name FROM '%s'))
+		ORDER BY g, o, name`, r, db.table, r)
+	return nil
+}
+
+// createBucket registers a bucket. Registering takes the write lock, even if the bucket is registered already:
+// checking first would leave the transaction holding a read lock that it could fail to upgrade.
+// Read-only transactions use the bucket without registering it.
+func (tx *Tx) createBucket(name string) (*Bucket, error) {
+	b := tx.newBucket(name)
+	if tx.readOnly || !tx.db.registry {
+		return b, nil
+	}
+	if err := b.checkAccess("create", true); err != nil {
+		return nil, err
+	}
+	query := fmt.Sprintf("INSERT OR IGNORE INTO '%s' (name) VALUES (?)", tx.db.bucketsTable())
+	if _, err := tx.exec(query, name); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// BucketExists reports whether a bucket has been created or has keys.
+func (tx *Tx) BucketExists(name string) (bool, error) {
+	args := []interface{}{name}
+	if tx.db.registry {
+		args = append(args, name)
+	}
+	var exists bool
+	if err := tx.queryRow(tx.db.existsQuery, args...).Scan(&exists); err != nil {
+		return false, sqliteError(err)
+	}
+	return exists, nil
+}
+
+// DeleteBucket deletes every key in a bucket, along with its configuration and history, and removes it from the
+// registry. It returns ErrBucketNotFound if the bucket neither is registered nor has keys.
+func (tx *Tx) DeleteBucket(name string) error {
+	if tx.readOnly {
+		return ErrTxReadOnly
+	}
+	b := tx.newBucket(name)
+	if err := b.checkAccess("delete", true); err != nil {
+		return err
+	}
+
+	registered := int64(0)
+	if tx.db.registry {
+		res, err := tx.exec(fmt.Sprintf("DELETE FROM '%s' WHERE name = ?", tx.db.bucketsTable()), name)
+		if err != nil {
+			return err
+		}
+		if registered, err = res.RowsAffected(); err != nil {
+			return err
+		}
+	}
+
+	n, err := b.deleteRows("delete", "bucket = ?", name)
+	if err != nil {
+		return err
+	}
+	if tx.db.history {
+		if _, err := tx.exec(fmt.Sprintf("DELETE FROM '%s' WHERE bucket = ?", tx.db.historyTable()), name); err != nil {
+			return err
+		}
+	}
+	tx.setConfig(name, BucketConfig{})
+	if n == 0 && registered == 0 {
+		return ErrBucketNotFound
+	}
+	return nil
+}
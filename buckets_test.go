@@ -0,0 +1,73 @@
+package kvite
+
+func (s *KViteTestSuite) TestBucketRegistry() {
+	s.NoError(s.DB.Transaction(func(tx *Tx) error {
+		_, err := tx.CreateBucket("empty")
+		s.NoError(err)
+		_, err = tx.CreateBucketIfNotExists("empty")
+		s.NoError(err)
+		// Buckets written without being created are still listed, after the registered ones.
+		b := tx.newBucket("unregistered")
+		s.NoError(b.Put("k", []byte("v")))
+		b, err = tx.CreateBucket("full")
+		s.NoError(err)
+		return b.Put("k", []byte("v"))
+	}))
+
+	names, err := s.DB.Buckets()
+	s.NoError(err)
+	s.Equal([]string{"empty", "full", "unregistered"}, names)
+
+	// Registered buckets survive losing their last key.
+	s.NoError(s.DB.Delete("full", "k"))
+	names, err = s.DB.Buckets()
+	s.NoError(err)
+	s.Equal([]string{"empty", "full", "unregistered"}, names)
+
+	s.NoError(s.DB.Transaction(func(tx *Tx) error {
+		s.NoError(tx.DeleteBucket("empty"))
+		s.Equal(ErrBucketNotFound, tx.DeleteBucket("empty"))
+		s.Equal(ErrBucketNotFound, tx.DeleteBucket("missing"))
+		return nil
+	}))
+	names, err = s.DB.Buckets()
+	s.NoError(err)
+	s.Equal([]string{"full", "unregistered"}, names)
+
+	tx, err := s.DB.Begin()
+	s.Require().NoError(err)
+	b, _ := tx.CreateBucket("doomed")
+	s.NoError(b.Put("a", []byte("1")))
+	s.NoError(b.Put("b", []byte("2")))
+	s.NoError(tx.DeleteBucket("doomed"))
+	value, err := b.Get("a")
+	s.NoError(err)
+	s.Nil(value)
+	s.NoError(tx.Commit())
+	s.Equal(0, s.countKeys(s.DB, "doomed"))
+}
+
+func (s *KViteTestSuite) TestBucketNotFound() {
+	s.NoError(s.DB.Put("written", "k", []byte("v")))
+	s.NoError(s.DB.Transaction(func(tx *Tx) error {
+		_, err := tx.Bucket("missing")
+		s.Equal(ErrBucketNotFound, err)
+		ok, err := tx.BucketExists("missing")
+		s.NoError(err)
+		s.False(ok)
+
+		b, err := tx.Bucket("written")
+		s.NoError(err)
+		value, err := b.Get("k")
+		s.NoError(err)
+		s.Equal([]byte("v"), value)
+
+		_, err = tx.CreateBucket("created")
+		s.NoError(err)
+		ok, err = tx.BucketExists("created")
+		s.NoError(err)
+		s.True(ok)
+		_, err = tx.Bucket("created")
+		return err
+	}))
+}
@@ -0,0 +1,142 @@
+package kvite
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Truncate deletes every key in the bucket with a single statement and returns the number of keys removed.
+func (b *Bucket) Truncate() (int64, error) {
+	return b.deleteRows("truncate", "bucket = ?", b.name)
+}
+
+// DeletePrefix deletes every key in the bucket that begins with prefix, with a single statement, and returns the
+// number of keys removed. Keys are compared byte by byte, so the match is case sensitive.
+func (b *Bucket) DeletePrefix(prefix string) (int64, error) {
+	if prefix == "" {
+		return b.deleteRows("delete", "bucket = ?", b.name)
+	}
+	if end, ok := prefixEnd(prefix); ok {
+		return b.deleteRows("delete", "bucket = ? AND key >= ? AND key < ?", b.name, prefix, end)
+	}
+	return b.deleteRows("delete", "bucket = ? AND key >= ?", b.name, prefix)
+}
+
+// prefixEnd returns the smallest string greater than every string beginning with prefix. It returns false if
+// there is none, which is the case when prefix consists only of 0xff bytes.
+func prefixEnd(prefix string) (string, bool) {
+	end := []byte(prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return string(end[:i+1]), true
+		}
+	}
+	return "", false
+}
+
+// deleteBatchSize is the number of keys DeleteWhere removes per statement.
+const deleteBatchSize = 500
+
+// DeleteWhere deletes every key in the bucket for which fn returns true and returns the number of keys removed.
+// The bucket is read in full before anything is deleted, and matching keys are then removed in batches.
+func (b *Bucket) DeleteWhere(fn func(k string, v []byte) bool) (int64, error) {
+	var keys []interface{}
+	err := b.ForEach(func(k string, v []byte) error {
+		if fn(k, v) {
+			keys = append(keys, k)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	var total int64
+	for len(keys) > 0 {
+		batch := keys
+		if len(batch) > deleteBatchSize {
+			batch = batch[:deleteBatchSize]
+		}
+		keys = keys[len(batch):]
+
+		cond := "bucket = ? AND key IN (?" + strings.Repeat(", ?", len(batch)-1) + ")"
+		n, err := b.deleteRows("delete", cond, append([]interface{}{b.name}, batch...)...)
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+// deleteRows deletes the rows of the bucket matching cond, which must restrict the bucket itself. When the change
+// feed is enabled, a delete is recorded for each row first, in one statement.
+func (b *Bucket) deleteRows(op, cond string, args ...interface{}) (int64, error) {
+	if b.tx.readOnly {
+		return 0, ErrTxReadOnly
+	}
+	if err := b.checkAccess(op, true); err != nil {
+		return 0, err
+	}
+	if err := b.tx.db.allowWrite(b.name); err != nil {
+		return 0, err
+	}
+
+	typ := ChangeDelete
+	if op == "expire" {
+		typ = ChangeExpire
+	}
+	db := b.tx.db
+	if db.changeFeed {
+		c := &Change{Type: typ}
+		b.tx.stamp(c)
+		var ts interface{}
+		if c.Timestamp != 0 {
+			ts = int64(c.Timestamp)
+		}
+		query := fmt.Sprintf("INSERT INTO '%s' (bucket, key, op, value, time, origin, hlc) SELECT bucket, key, ?, NULL, ?, ?, ? FROM '%s' WHERE %s",
+			db.changesTable(), db.table, cond)
+		if _, err := b.tx.exec(query, append([]interface{}{int(typ), time.Now().UnixNano(), c.Origin, ts}, args...)...); err != nil {
+			return 0, err
+		}
+	}
+
+	if b.tx.recordsChanges() {
+		if err := b.notifyDeletes(typ, cond, args...); err != nil {
+			return 0, err
+		}
+	}
+
+	res, err := b.tx.exec(fmt.Sprintf("DELETE FROM '%s' WHERE %s", db.table, cond), args...)
+	if err != nil {
+		return 0, err
+	}
+	b.tx.invalidateCache(cacheKey{bucket: b.name})
+	n, err := res.RowsAffected()
+	if err == nil {
+		b.tx.journalWrite(op, b.name, "", 0, n)
+		b.tx.countWrite(b.name, n, 0)
+	}
+	return n, err
+}
+
+// notifyDeletes passes a delete of type typ for each row matching cond on to watchers.
+func (b *Bucket) notifyDeletes(typ ChangeType, cond string, args ...interface{}) error {
+	rows, err := b.tx.query(fmt.Sprintf("SELECT key FROM '%s' WHERE %s", b.tx.db.table, cond), args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	now := time.Now()
+	for rows.Next() {
+		c := Change{Bucket: b.name, Type: typ, Origin: b.tx.db.nodeID, Time: now}
+		if err := rows.Scan(&c.Key); err != nil {
+			return err
+		}
+		b.tx.notify(&c)
+	}
+	return rows.Err()
+}
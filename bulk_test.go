@@ -0,0 +1,80 @@
+package kvite
+
+func (s *KViteTestSuite) TestBucketTruncate() {
+	db := s.openDB("truncate.db", WithChangeFeed())
+	defer func() { _ = db.Close() }()
+	s.putN(db, "test", 0, 50)
+	s.putN(db, "other", 0, 5)
+
+	var n int64
+	s.NoError(db.Transaction(func(tx *Tx) error {
+		b, _ := tx.CreateBucketIfNotExists("test")
+		var err error
+		n, err = b.Truncate()
+		return err
+	}))
+	s.Equal(int64(50), n)
+	s.Equal(0, s.countKeys(db, "test"))
+	s.Equal(5, s.countKeys(db, "other"))
+
+	changes, err := db.Changes(55)
+	s.NoError(err)
+	s.Len(changes, 50)
+	s.Equal(ChangeDelete, changes[0].Type)
+	s.Equal("test", changes[0].Bucket)
+}
+
+func (s *KViteTestSuite) TestBucketDeletePrefix() {
+	s.NoError(s.DB.Transaction(func(tx *Tx) error {
+		b, _ := tx.CreateBucketIfNotExists("test")
+		for _, key := range []string{"app/a", "app/b", "app/c/d", "apple", "App/x", "ap", "\xff\xff"} {
+			if err := b.Put(key, []byte("v")); err != nil {
+				return err
+			}
+		}
+
+		n, err := b.DeletePrefix("app/")
+		s.NoError(err)
+		s.Equal(int64(3), n)
+
+		n, err = b.DeletePrefix("\xff")
+		s.NoError(err)
+		s.Equal(int64(1), n)
+
+		var keys []string
+		_ = b.ForEach(func(k string, v []byte) error {
+			keys = append(keys, k)
+			return nil
+		})
+		s.ElementsMatch([]string{"apple", "App/x", "ap"}, keys)
+		return nil
+	}))
+
+	end, ok := prefixEnd("a\xff")
+	s.True(ok)
+	s.Equal("b", end)
+	_, ok = prefixEnd("\xff\xff")
+	s.False(ok)
+}
+
+func (s *KViteTestSuite) TestBucketDeleteWhere() {
+	db := s.openDB("deletewhere.db", WithChangeFeed())
+	defer func() { _ = db.Close() }()
+	s.putN(db, "test", 0, 1200)
+
+	var n int64
+	s.NoError(db.Transaction(func(tx *Tx) error {
+		b, _ := tx.CreateBucketIfNotExists("test")
+		var err error
+		n, err = b.DeleteWhere(func(k string, v []byte) bool {
+			return k[len(k)-1] != '0'
+		})
+		return err
+	}))
+	s.Equal(int64(1080), n)
+	s.Equal(120, s.countKeys(db, "test"))
+
+	changes, err := db.Changes(1200)
+	s.NoError(err)
+	s.Len(changes, 1080)
+}
@@ -0,0 +1,282 @@
+package kvite
+
+import (
+	"container/list"
+	"database/sql"
+	"errors"
+	"path"
+	"sync"
+	"time"
+)
+
+// WithReadCache keeps up to entries recently read values of each bucket matching pattern in memory, so that Get
+// can answer for them without querying SQLite. pattern uses the syntax of path.Match, and when several patterns
+// match a bucket, the one added last applies; an entries of 0 turns caching off for matching buckets.
+//
+// Writes made through this process invalidate cached values. Writes made by other processes sharing the database
+// file do not, so the cache should only be used when this process is the only writer. A transaction never reads
+// a cached value once any write has been made since it began, so transactions see the same values with and
+// without the cache.
+func WithReadCache(pattern string, entries int) Option {
+	return func(db *DB) error {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return err
+		}
+		if entries < 0 {
+			return errors.New("read cache entries must not be negative")
+		}
+		if db.cache == nil {
+			db.cache = &readCache{buckets: make(map[string]*lru)}
+		}
+		db.cache.rules = append(db.cache.rules, cacheRule{pattern: pattern, entries: entries})
+		return nil
+	}
+}
+
+// CacheStats reports the effectiveness of the read cache.
+type CacheStats struct {
+	// Hits and Misses count Gets in cached buckets that were and were not answered from the cache.
+	Hits   int64
+	Misses int64
+	// Entries is the number of values currently cached.
+	Entries int
+}
+
+// CacheStats returns the read cache statistics. They are zero unless the database was opened with WithReadCache.
+func (db *DB) CacheStats() CacheStats {
+	c := db.cache
+	if c == nil {
+		return CacheStats{}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := CacheStats{Hits: c.hits, Misses: c.misses}
+	for _, l := range c.buckets {
+		if l != nil {
+			stats.Entries += l.order.Len()
+		}
+	}
+	return stats
+}
+
+type cacheRule struct {
+	pattern string
+	entries int
+}
+
+// readCache holds the cached values of a database. It is shared by handles returned from Restrict.
+// Every invalidation advances gen. A transaction records gen when it begins and only uses the cache while gen is
+// unchanged, which keeps a transaction from reading a value newer than its snapshot or from caching a value older
+// than the latest commit.
+type readCache struct {
+	rules []cacheRule
+
+	mu      sync.Mutex
+	gen     uint64
+	buckets map[string]*lru
+	hits    int64
+	misses  int64
+}
+
+// lru is the cache of a single bucket.
+type lru struct {
+	size  int
+	order *list.List
+	items map[string]*list.Element
+}
+
+type cacheEntry struct {
+	key   string
+	value []byte
+	// expires is the expiry time of the key in Unix nanoseconds, or 0 if it does not expire.
+	expires int64
+}
+
+// cacheKey records a write that must invalidate the cache again when its transaction commits. An empty key stands
+// for the whole bucket, and all for every bucket.
+type cacheKey struct {
+	bucket string
+	key    string
+	all    bool
+}
+
+func (c *readCache) generation() uint64 {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.gen
+}
+
+// bucket returns the cache of a bucket, or nil if the bucket is not cached. c.mu must be held.
+func (c *readCache) bucket(name string) *lru {
+	if l, ok := c.buckets[name]; ok {
+		return l
+	}
+	var size int
+	for i := len(c.rules) - 1; i >= 0; i-- {
+		if ok, _ := path.Match(c.rules[i].pattern, name); ok {
+			size = c.rules[i].entries
+			break
+		}
+	}
+	var l *lru
+	if size > 0 {
+		l = &lru{size: size, order: list.New(), items: make(map[string]*list.Element)}
+	}
+	c.buckets[name] = l
+	return l
+}
+
+// get returns a copy of the cached value of a key, and when it expires.
+func (c *readCache) get(tx *Tx, bucket, key string) ([]byte, int64, bool) {
+	if c == nil {
+		return nil, 0, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	l := c.bucket(bucket)
+	if l == nil {
+		return nil, 0, false
+	}
+	e, ok := l.items[key]
+	if !ok || c.gen != tx.cacheGen {
+		c.misses++
+		return nil, 0, false
+	}
+	entry := e.Value.(*cacheEntry)
+	if entry.expires != 0 && time.Now().UnixNano() >= entry.expires {
+		c.misses++
+		l.order.Remove(e)
+		delete(l.items, key)
+		return nil, 0, false
+	}
+	c.hits++
+	l.order.MoveToFront(e)
+	return append([]byte{}, entry.value...), entry.expires, true
+}
+
+// add caches a value read by tx, unless the cache has been invalidated since tx began. The value is dropped once
+// expires, in Unix nanoseconds, has passed, unless it is 0.
+func (c *readCache) add(tx *Tx, bucket, key string, value []byte, expires int64) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	l := c.bucket(bucket)
+	if l == nil || c.gen != tx.cacheGen {
+		return
+	}
+	value = append([]byte{}, value...)
+	if e, ok := l.items[key]; ok {
+		entry := e.Value.(*cacheEntry)
+		entry.value, entry.expires = value, expires
+		l.order.MoveToFront(e)
+		return
+	}
+	l.items[key] = l.order.PushFront(&cacheEntry{key: key, value: value, expires: expires})
+	if l.order.Len() > l.size {
+		oldest := l.order.Back()
+		l.order.Remove(oldest)
+		delete(l.items, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+// invalidate drops the cached values covered by k.
+func (c *readCache) invalidate(k cacheKey) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.gen++
+	if k.all {
+		c.buckets = make(map[string]*lru)
+		return
+	}
+	l := c.buckets[k.bucket]
+	if l == nil {
+		return
+	}
+	if k.key == "" {
+		delete(c.buckets, k.bucket)
+		return
+	}
+	if e, ok := l.items[k.key]; ok {
+		l.order.Remove(e)
+		delete(l.items, k.key)
+	}
+}
+
+// invalidateCache drops a cached value when tx writes it and remembers the write so that it is dropped again when
+// tx commits, since other transactions may cache the old value in between.
+func (tx *Tx) invalidateCache(k cacheKey) {
+	if k.all {
+		tx.suspendBloom()
+	}
+	tx.markKeys(k)
+	if tx.db.cache == nil {
+		return
+	}
+	tx.db.cache.invalidate(k)
+	tx.cacheKeys = append(tx.cacheKeys, k)
+}
+
+// capacity returns the number of values of a bucket the cache holds, or 0 if the bucket is not cached.
+func (c *readCache) capacity(bucket string) int {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if l := c.bucket(bucket); l != nil {
+		return l.size
+	}
+	return 0
+}
+
+// Preload reads the keys of the bucket beginning with prefix into the read cache in a single scan, in key order,
+// so that a service can warm the cache when it starts instead of on its first reads. It stops once the bucket's
+// cache is full, and returns the number of values read. It does nothing unless the bucket is cached by
+// WithReadCache, nor once the cache has been invalidated since the transaction began, by its own writes or those
+// of another, since such a transaction cannot fill the cache.
+func (b *Bucket) Preload(prefix string) (int, error) {
+	if err := b.checkAccess("get", false); err != nil {
+		return 0, err
+	}
+	c := b.tx.db.cache
+	size := c.capacity(b.name)
+	if size == 0 || c.generation() != b.tx.cacheGen {
+		return 0, nil
+	}
+
+	cols := "t.key, t.value, t.checksum, t.expires"
+	if b.tx.db.dedup {
+		cols = "t.key, coalesce(v.value, t.value), t.checksum, t.expires"
+	}
+	var n int
+	err := b.Query().Prefix(prefix).Limit(size).run(cols, func(rows *sql.Rows) error {
+		var (
+			key          string
+			value        []byte
+			sum, expires sql.NullInt64
+		)
+		if err := rows.Scan(&key, &value, &sum, &expires); err != nil {
+			return err
+		}
+		value, err := b.scanValue(key, value, sum)
+		if err != nil {
+			return err
+		}
+		c.add(b.tx, b.name, key, value, expires.Int64)
+		b.tx.db.bucketStats.read(b.name, len(value))
+		n++
+		return nil
+	})
+	return n, err
+}
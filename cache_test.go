@@ -0,0 +1,116 @@
+package kvite
+
+func (s *KViteTestSuite) TestReadCache() {
+	db := s.openDB("cache.db", WithReadCache("*", 2), WithReadCache("nocache", 0))
+	defer func() { _ = db.Close() }()
+
+	get := func(bucket, key string) []byte {
+		var value []byte
+		s.NoError(db.Transaction(func(tx *Tx) error {
+			b, _ := tx.CreateBucketIfNotExists(bucket)
+			var err error
+			value, err = b.Get(key)
+			return err
+		}))
+		return value
+	}
+	put := func(bucket, key, value string) {
+		s.NoError(db.Transaction(func(tx *Tx) error {
+			b, _ := tx.CreateBucketIfNotExists(bucket)
+			return b.Put(key, []byte(value))
+		}))
+	}
+
+	put("a", "k", "1")
+	put("nocache", "k", "1")
+	s.Equal([]byte("1"), get("a", "k"))
+	s.Equal([]byte("1"), get("a", "k"))
+	s.Equal([]byte("1"), get("nocache", "k"))
+	s.Equal(CacheStats{Hits: 1, Misses: 1, Entries: 1}, db.CacheStats())
+
+	// Writes invalidate the cached value.
+	put("a", "k", "2")
+	s.Equal([]byte("2"), get("a", "k"))
+	s.Equal([]byte("2"), get("a", "k"))
+	s.Equal(CacheStats{Hits: 2, Misses: 2, Entries: 1}, db.CacheStats())
+
+	// A transaction sees its own writes rather than the cache.
+	s.NoError(db.Transaction(func(tx *Tx) error {
+		b, _ := tx.CreateBucketIfNotExists("a")
+		s.NoError(b.Put("k", []byte("3")))
+		value, err := b.Get("k")
+		s.Equal([]byte("3"), value)
+		return err
+	}))
+	s.Equal([]byte("3"), get("a", "k"))
+
+	// The least recently used value is evicted.
+	put("a", "x", "x")
+	put("a", "y", "y")
+	get("a", "x")
+	get("a", "y")
+	s.Equal(2, db.CacheStats().Entries)
+
+	s.NoError(db.Transaction(func(tx *Tx) error {
+		b, _ := tx.CreateBucketIfNotExists("a")
+		_, err := b.Truncate()
+		return err
+	}))
+	s.Nil(get("a", "x"))
+	s.Equal(0, db.CacheStats().Entries)
+
+	s.Equal(CacheStats{}, s.DB.CacheStats())
+}
+
+func (s *KViteTestSuite) TestPreload() {
+	db := s.openDB("preload.db", WithReadCache("a", 3))
+	defer func() { _ = db.Close() }()
+
+	s.NoError(db.Transaction(func(tx *Tx) error {
+		for _, name := range []string{"a", "b"} {
+			b, _ := tx.CreateBucketIfNotExists(name)
+			for _, key := range []string{"k1", "k2", "x1", "x2", "x3", "x4"} {
+				s.NoError(b.Put(key, []byte(key)))
+			}
+		}
+		return nil
+	}))
+
+	preload := func(bucket, prefix string) int {
+		var n int
+		s.NoError(db.Transaction(func(tx *Tx) error {
+			b, _ := tx.CreateBucketIfNotExists(bucket)
+			var err error
+			n, err = b.Preload(prefix)
+			return err
+		}))
+		return n
+	}
+	s.Equal(0, preload("b", ""))
+	s.Equal(2, preload("a", "k"))
+	s.Equal(CacheStats{Entries: 2}, db.CacheStats())
+
+	s.NoError(db.Transaction(func(tx *Tx) error {
+		b, _ := tx.CreateBucketIfNotExists("a")
+		for _, key := range []string{"k1", "k2"} {
+			value, err := b.Get(key)
+			s.NoError(err)
+			s.Equal([]byte(key), value)
+		}
+		return nil
+	}))
+	s.Equal(CacheStats{Hits: 2, Entries: 2}, db.CacheStats())
+
+	// Preloading stops once the bucket's cache is full.
+	s.Equal(3, preload("a", ""))
+	s.Equal(3, db.CacheStats().Entries)
+
+	// A transaction that has written cannot fill the cache.
+	s.NoError(db.Transaction(func(tx *Tx) error {
+		b, _ := tx.CreateBucketIfNotExists("a")
+		s.NoError(b.Put("k1", []byte("v")))
+		n, err := b.Preload("")
+		s.Equal(0, n)
+		return err
+	}))
+}
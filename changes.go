@@ -0,0 +1,174 @@
+package kvite
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// ChangeType identifies the kind of modification recorded in the change feed.
+type ChangeType int
+
+// Change types.
+const (
+	ChangePut ChangeType = iota + 1
+	ChangeDelete
+	// ChangeExpire is the delete of a key that expired, by DB.ExpireKeys or by a read that came across it. Consumers
+	// that only look for ChangeDelete miss these deletes.
+	ChangeExpire
+)
+
+func (t ChangeType) String() string {
+	switch t {
+	case ChangePut:
+		return "put"
+	case ChangeDelete:
+		return "delete"
+	case ChangeExpire:
+		return "expire"
+	default:
+		return fmt.Sprintf("ChangeType(%d)", int(t))
+	}
+}
+
+// Change is a committed modification of a key.
+type Change struct {
+	// Seq is the position of the change in the feed. Sequence numbers increase monotonically and are never reused.
+	Seq    int64
+	Bucket string
+	Key    string
+	Type   ChangeType
+	// Value is the value that was written. It is nil for deletes and expirations.
+	Value []byte
+	Time  time.Time
+	// Origin identifies the database where the change was first made (see DB.NodeID).
+	Origin string
+	// Timestamp is the hybrid logical clock reading of the change. It is zero unless the database where the change
+	// was made uses WithTimestamps.
+	Timestamp Timestamp
+}
+
+// WithChangeFeed records every Put and Delete in a changes table, in the same transaction as the modification
+// itself, so that only committed changes appear in the feed. Use Changes to consume the feed.
+func WithChangeFeed() Option {
+	return func(db *DB) error {
+		db.changeFeed = true
+		return nil
+	}
+}
+
+func (db *DB) changesTable() string {
+	return db.table + "_kvite_changes"
+}
+
+// changesColumns are the columns of the changes table that were added after it was introduced.
+var changesColumns = []column{
+	{name: "origin", upgrade: "text not null default ''"},
+	{name: "hlc", upgrade: "integer"},
+}
+
+func (db *DB) createChangesTable(tx *sql.Tx) error {
+	query := fmt.Sprintf("create TABLE IF NOT EXISTS '%s' (seq integer primary key autoincrement, bucket text not null, key text not null, op integer not null, value blob, time integer not null, origin text not null default '', hlc integer)", db.changesTable())
+	if _, err := tx.Exec(query); err != nil {
+		return err
+	}
+	return addMissingColumns(tx, db.changesTable(), changesColumns)
+}
+
+// recordChange appends a change to the feed if the feed is enabled, and passes it on to watchers.
+// Changes without an origin or time are attributed to this database and the current time.
+func (tx *Tx) recordChange(c *Change) error {
+	if c.Origin == "" {
+		c.Origin = tx.db.nodeID
+	}
+	if c.Time.IsZero() {
+		c.Time = time.Now()
+	}
+	if !tx.db.changeFeed {
+		tx.notify(c)
+		return nil
+	}
+
+	var ts interface{}
+	if c.Timestamp != 0 {
+		ts = int64(c.Timestamp)
+	}
+	query := fmt.Sprintf("INSERT INTO '%s' (bucket, key, op, value, time, origin, hlc) VALUES (?, ?, ?, ?, ?, ?, ?)", tx.db.changesTable())
+	res, err := tx.exec(query, c.Bucket, c.Key, int(c.Type), c.Value, c.Time.UnixNano(), c.Origin, ts)
+	if err != nil {
+		return err
+	}
+	if c.Seq, err = res.LastInsertId(); err != nil {
+		return err
+	}
+	tx.notify(c)
+	return nil
+}
+
+// Changes returns the changes recorded after sinceSeq, in order. Pass 0 to read the whole feed.
+// Consumers typically remember the Seq of the last change they processed and pass it to the next call.
+func (db *DB) Changes(sinceSeq int64) ([]Change, error) {
+	if !db.changeFeed {
+		return nil, ErrNoChangeFeed
+	}
+	if err := db.life.check(); err != nil {
+		return nil, err
+	}
+	return db.changes(db.db, sinceSeq)
+}
+
+func (db *DB) changes(q querier, sinceSeq int64) ([]Change, error) {
+	query := fmt.Sprintf("SELECT seq, bucket, key, op, value, time, origin, hlc FROM '%s' WHERE seq > ? ORDER BY seq", db.changesTable())
+	rows, err := q.Query(query, sinceSeq)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var changes []Change
+	for rows.Next() {
+		var (
+			c    Change
+			op   int
+			nsec int64
+			ts   sql.NullInt64
+		)
+		if err := rows.Scan(&c.Seq, &c.Bucket, &c.Key, &op, &c.Value, &nsec, &c.Origin, &ts); err != nil {
+			return nil, err
+		}
+		c.Timestamp = Timestamp(ts.Int64)
+		c.Type = ChangeType(op)
+		c.Time = time.Unix(0, nsec)
+		changes = append(changes, c)
+	}
+	return changes, rows.Err()
+}
+
+// LastChangeSeq returns the sequence number of the most recent change recorded in the feed, or 0 if none has
+// been. It does not go down when the feed is pruned.
+func (db *DB) LastChangeSeq() (int64, error) {
+	if !db.changeFeed {
+		return 0, ErrNoChangeFeed
+	}
+	if err := db.life.check(); err != nil {
+		return 0, err
+	}
+	var seq int64
+	err := db.db.QueryRow("SELECT seq FROM sqlite_sequence WHERE name = ?", db.changesTable()).Scan(&seq)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return seq, err
+}
+
+// PruneChanges removes changes up to and including seq from the feed, once every consumer has processed them.
+func (db *DB) PruneChanges(seq int64) error {
+	if !db.changeFeed {
+		return ErrNoChangeFeed
+	}
+	if err := db.life.check(); err != nil {
+		return err
+	}
+	_, err := db.db.Exec(fmt.Sprintf("DELETE FROM '%s' WHERE seq <= ?", db.changesTable()), seq)
+	return err
+}
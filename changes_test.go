@@ -0,0 +1,58 @@
+package kvite
+
+import "errors"
+
+func (s *KViteTestSuite) TestDBChanges() {
+	_, err := s.DB.Changes(0)
+	s.Equal(ErrNoChangeFeed, err)
+
+	db := s.openDB("changes.db", WithChangeFeed())
+	defer func() { _ = db.Close() }()
+
+	err = db.Transaction(func(tx *Tx) error {
+		b, _ := tx.CreateBucket("test")
+		_ = b.Put("foo", []byte("bar"))
+		_ = b.Put("baz", []byte("stuff"))
+		_ = b.Delete("foo")
+		// Deleting a missing key is not a change
+		return b.Delete("missing")
+	})
+	s.Require().NoError(err)
+
+	// Rolled back changes are not recorded
+	err = db.Transaction(func(tx *Tx) error {
+		b, _ := tx.CreateBucket("test")
+		_ = b.Put("rolled", []byte("back"))
+		return errors.New("an error")
+	})
+	s.Error(err)
+
+	changes, err := db.Changes(0)
+	s.NoError(err)
+	s.Require().Len(changes, 3)
+	s.Equal(ChangePut, changes[0].Type)
+	s.Equal("foo", changes[0].Key)
+	s.Equal([]byte("bar"), changes[0].Value)
+	s.Equal(ChangePut, changes[1].Type)
+	s.Equal(ChangeDelete, changes[2].Type)
+	s.Nil(changes[2].Value)
+	s.True(changes[0].Seq < changes[1].Seq && changes[1].Seq < changes[2].Seq)
+
+	// Incremental consumption
+	changes, err = db.Changes(changes[1].Seq)
+	s.NoError(err)
+	s.Len(changes, 1)
+
+	s.NoError(db.PruneChanges(changes[0].Seq))
+	last := changes[0].Seq
+	changes, err = db.Changes(0)
+	s.NoError(err)
+	s.Len(changes, 0)
+
+	// The last sequence number survives pruning
+	seq, err := db.LastChangeSeq()
+	s.NoError(err)
+	s.Equal(last, seq)
+	_, err = s.DB.LastChangeSeq()
+	s.Equal(ErrNoChangeFeed, err)
+}
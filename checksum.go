@@ -0,0 +1,79 @@
+package kvite
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+)
+
+var checksumTable = crc32.MakeTable(crc32.Castagnoli)
+
+func checksum(value []byte) int64 {
+	return int64(crc32.Checksum(value, checksumTable))
+}
+
+// macFlag is set in every MAC stored by WithValueMAC. CRC32 checksums never reach it, so the two can be told apart.
+const macFlag = 1 << 62
+
+// WithValueMAC makes Put store an HMAC-SHA256 of each value, its bucket and its key, keyed by secret and truncated
+// to 62 bits, in place of the checksum. Get and ForEach verify it and report a value without a valid MAC as a
+// *TamperError, so that values changed or moved between keys by someone who can write the database file, but does
+// not know the secret, are detected. Values written before the option was used have no MAC and fail too, so it
+// should be enabled on an empty database, or existing values rewritten. Earlier versions read with GetAt are not
+// verified. The secret must be at least 16 bytes long and the same each time the database is opened.
+func WithValueMAC(secret []byte) Option {
+	return func(db *DB) error {
+		if len(secret) < 16 {
+			return errors.New("value MAC secret must be at least 16 bytes long")
+		}
+		db.macKey = append([]byte(nil), secret...)
+		return nil
+	}
+}
+
+// valueMAC returns the MAC of a value stored under a key.
+func (db *DB) valueMAC(bucket, key string, value []byte) int64 {
+	h := hmac.New(sha256.New, db.macKey)
+	var n [binary.MaxVarintLen64]byte
+	h.Write(n[:binary.PutUvarint(n[:], uint64(len(bucket)))])
+	h.Write([]byte(bucket))
+	h.Write(n[:binary.PutUvarint(n[:], uint64(len(key)))])
+	h.Write([]byte(key))
+	h.Write(value)
+	return int64(binary.BigEndian.Uint64(h.Sum(nil))>>2) | macFlag
+}
+
+// checksumFor returns the checksum or MAC to store with a value, or nil if both are disabled.
+func (db *DB) checksumFor(bucket, key string, value []byte) interface{} {
+	if db.macKey != nil {
+		return db.valueMAC(bucket, key, value)
+	}
+	if !db.checksums {
+		return nil
+	}
+	return checksum(value)
+}
+
+// verify checks a value read from the bucket against its stored checksum, if it has one, or its MAC. Without
+// WithValueMAC, MACs cannot be checked and are ignored.
+func (b *Bucket) verify(key string, value []byte, sum sql.NullInt64) error {
+	if b.tx.db.macKey != nil {
+		if !sum.Valid || sum.Int64 != b.tx.db.valueMAC(b.name, key, value) {
+			return &TamperError{Bucket: b.name, Key: key}
+		}
+		return nil
+	}
+	if sum.Valid && sum.Int64&macFlag == 0 && sum.Int64 != checksum(value) {
+		return &ChecksumError{Bucket: b.name, Key: key}
+	}
+	return nil
+}
+
+// checksumValid reports whether a stored checksum matches its value. MACs are assumed to, since they cannot be
+// checked without the secret.
+func checksumValid(value []byte, sum sql.NullInt64) bool {
+	return !sum.Valid || sum.Int64&macFlag != 0 || sum.Int64 == checksum(value)
+}
@@ -0,0 +1,89 @@
+package kvite
+
+import "path/filepath"
+
+func (s *KViteTestSuite) TestBucketChecksums() {
+	db := s.openDB("checksums.db", WithChecksums())
+	defer func() { _ = db.Close() }()
+
+	err := db.Transaction(func(tx *Tx) error {
+		b, _ := tx.CreateBucket("test")
+		_ = b.Put("foo", []byte("bar"))
+		_ = b.Put("baz", []byte("stuff"))
+		return nil
+	})
+	s.Require().NoError(err)
+
+	// Intact values verify
+	err = db.Transaction(func(tx *Tx) error {
+		b, _ := tx.CreateBucket("test")
+		value, err := b.Get("foo")
+		s.Equal([]byte("bar"), value)
+		return err
+	})
+	s.NoError(err)
+
+	// Corrupt a value behind kvite's back
+	_, err = db.db.Exec("UPDATE 'testing' SET value = 'bad' WHERE key = 'foo'")
+	s.Require().NoError(err)
+
+	tx, _ := db.Begin()
+	defer func() { _ = tx.Rollback() }()
+	b, _ := tx.CreateBucket("test")
+
+	_, err = b.Get("foo")
+	s.IsType(&ChecksumError{}, err)
+
+	err = b.ForEach(func(k string, v []byte) error { return nil })
+	s.IsType(&ChecksumError{}, err)
+
+}
+
+func (s *KViteTestSuite) TestValueMAC() {
+	_, err := Open(filepath.Join(s.TempDir, "mac.db"), "testing", WithValueMAC([]byte("short")))
+	s.Error(err)
+
+	secret := []byte("0123456789abcdef")
+	db := s.openDB("mac.db", WithValueMAC(secret))
+	s.NoError(db.Transaction(func(tx *Tx) error {
+		b, _ := tx.CreateBucket("test")
+		s.NoError(b.Put("foo", []byte("bar")))
+		return b.Put("baz", []byte("stuff"))
+	}))
+	s.NoError(db.Transaction(func(tx *Tx) error {
+		b, _ := tx.Bucket("test")
+		value, err := b.Get("foo")
+		s.NoError(err)
+		s.Equal([]byte("bar"), value)
+		return nil
+	}))
+
+	// Values swapped between keys, changed, or stripped of their MAC are all detected
+	for _, stmt := range []string{
+		"UPDATE 'testing' SET value = (SELECT value FROM 'testing' WHERE key = 'baz'), checksum = (SELECT checksum FROM 'testing' WHERE key = 'baz') WHERE key = 'foo'",
+		"UPDATE 'testing' SET value = 'bad' WHERE key = 'foo'",
+		"UPDATE 'testing' SET value = 'bad', checksum = NULL WHERE key = 'foo'",
+	} {
+		_, err := db.db.Exec(stmt)
+		s.Require().NoError(err)
+		s.NoError(db.Transaction(func(tx *Tx) error {
+			b, _ := tx.Bucket("test")
+			_, err := b.Get("foo")
+			s.IsType(&TamperError{}, err, stmt)
+			s.IsType(&TamperError{}, b.ForEach(func(k string, v []byte) error { return nil }), stmt)
+			return nil
+		}))
+	}
+	s.NoError(db.Close())
+
+	// Without the secret, MACs cannot be checked and values are read as they are
+	db = s.openDB("mac.db", WithChecksums())
+	defer func() { _ = db.Close() }()
+	s.NoError(db.Transaction(func(tx *Tx) error {
+		b, _ := tx.Bucket("test")
+		value, err := b.Get("baz")
+		s.NoError(err)
+		s.Equal([]byte("stuff"), value)
+		return nil
+	}))
+}
@@ -0,0 +1,148 @@
+package kvite
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"hash"
+	"io"
+)
+
+// PassphraseIterations is the number of PBKDF2 iterations NewPassphraseCodec derives its key with.
+const PassphraseIterations = 600000
+
+// cipherVersion is the first byte of values encrypted by a cipher codec, so that the format can change.
+const cipherVersion byte = 1
+
+// cipherCodec encrypts values with AES-256-GCM. Each value is stored as the format version, a random nonce and the
+// sealed value, which includes the authentication tag.
+type cipherCodec struct {
+	aead cipher.AEAD
+}
+
+// NewCipherCodec returns a Codec that encrypts values with AES-256-GCM under a 32-byte key, for use with
+// WithCodec. Values are authenticated, so reading a value that was modified or encrypted with another key fails
+// with ErrDecrypt. The codec only sees values, not the keys they are stored under, so it does not detect a value
+// moved to another key.
+// It is built on the standard library, which has AES-GCM but neither NaCl secretbox nor age.
+func NewCipherCodec(key []byte) (Codec, error) {
+	if len(key) != 32 {
+		return nil, errors.New("cipher codec needs a 32-byte key")
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &cipherCodec{aead: aead}, nil
+}
+
+// NewPassphraseCodec returns a codec like NewCipherCodec, with the key derived from a passphrase and a salt with
+// PBKDF2-HMAC-SHA256 and PassphraseIterations iterations. The salt need not be secret but must be the same
+// whenever the database is opened: generate at least 16 random bytes once and keep them with the rest of the
+// database's configuration. Deriving the key is slow on purpose, so it should be done once per process.
+func NewPassphraseCodec(passphrase string, salt []byte) (Codec, error) {
+	if passphrase == "" {
+		return nil, errors.New("passphrase codec needs a passphrase")
+	}
+	if len(salt) < 16 {
+		return nil, errors.New("passphrase codec needs a salt of at least 16 bytes")
+	}
+	return NewCipherCodec(pbkdf2SHA256([]byte(passphrase), salt, PassphraseIterations, 32))
+}
+
+func (c *cipherCodec) Encode(value []byte) ([]byte, error) {
+	n := c.aead.NonceSize()
+	out := make([]byte, 1+n, 1+n+len(value)+c.aead.Overhead())
+	out[0] = cipherVersion
+	if _, err := io.ReadFull(rand.Reader, out[1:]); err != nil {
+		return nil, err
+	}
+	return c.aead.Seal(out, out[1:], value, out[:1]), nil
+}
+
+func (c *cipherCodec) Decode(stored []byte) ([]byte, error) {
+	n := c.aead.NonceSize()
+	if len(stored) < 1+n || stored[0] != cipherVersion {
+		return nil, ErrDecrypt
+	}
+	value, err := c.aead.Open(nil, stored[1:1+n], stored[1+n:], stored[:1])
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return value, nil
+}
+
+// signingCodec appends an HMAC-SHA256 to values.
+type signingCodec struct {
+	key []byte
+}
+
+// NewSigningCodec returns a Codec that appends an HMAC-SHA256 of each value under key, and checks it when the
+// value is read, failing with ErrSignature if the value was modified or signed with another key. It is meant as
+// the last stage of a BucketConfig.Pipeline; to authenticate every value of the database together with its key,
+// use WithValueMAC instead.
+func NewSigningCodec(key []byte) (Codec, error) {
+	if len(key) < 16 {
+		return nil, errors.New("signing codec needs a key of at least 16 bytes")
+	}
+	return &signingCodec{key: append([]byte(nil), key...)}, nil
+}
+
+func (c *signingCodec) Encode(value []byte) ([]byte, error) {
+	mac := hmac.New(sha256.New, c.key)
+	mac.Write(value)
+	return mac.Sum(append([]byte(nil), value...)), nil
+}
+
+func (c *signingCodec) Decode(stored []byte) ([]byte, error) {
+	if len(stored) < sha256.Size {
+		return nil, ErrSignature
+	}
+	value, sum := stored[:len(stored)-sha256.Size], stored[len(stored)-sha256.Size:]
+	mac := hmac.New(sha256.New, c.key)
+	mac.Write(value)
+	if !hmac.Equal(mac.Sum(nil), sum) {
+		return nil, ErrSignature
+	}
+	return value, nil
+}
+
+// pbkdf2SHA256 derives a key from a password as described in RFC 8018, with HMAC-SHA256 as the pseudorandom
+// function.
+func pbkdf2SHA256(password, salt []byte, iterations, keyLen int) []byte {
+	prf := hmac.New(sha256.New, password)
+	key := make([]byte, 0, keyLen+prf.Size())
+	var (
+		block [4]byte
+		u     []byte
+	)
+	for i := uint32(1); len(key) < keyLen; i++ {
+		binary.BigEndian.PutUint32(block[:], i)
+		u = pbkdf2Round(prf, u[:0], salt, block[:])
+		t := append([]byte(nil), u...)
+		for n := 1; n < iterations; n++ {
+			u = pbkdf2Round(prf, u[:0], u)
+			for j := range t {
+				t[j] ^= u[j]
+			}
+		}
+		key = append(key, t...)
+	}
+	return key[:keyLen]
+}
+
+func pbkdf2Round(prf hash.Hash, dst []byte, data ...[]byte) []byte {
+	prf.Reset()
+	for _, d := range data {
+		prf.Write(d)
+	}
+	return prf.Sum(dst)
+}
@@ -0,0 +1,51 @@
+package kvite
+
+import (
+	"bytes"
+	"encoding/hex"
+)
+
+func (s *KViteTestSuite) TestPBKDF2() {
+	// Test vectors from RFC 7914
+	key := pbkdf2SHA256([]byte("passwd"), []byte("salt"), 1, 64)
+	s.Equal("55ac046e56e3089fec1691c22544b605f94185216dde0465e68b9d57c20dacbc49ca9cccf179b645991664b39d77ef317c71b845b1e30bd509112041d3a19783", hex.EncodeToString(key))
+	key = pbkdf2SHA256([]byte("Password"), []byte("NaCl"), 80000, 64)
+	s.Equal("4ddcd8f60b98be21830cee5ef22701f9641a4418d04c0414aeff08876b34ab56a1d425a1225833549adb841b51c9b3176a272bdebba1d078478f62b397f33c8d", hex.EncodeToString(key))
+}
+
+func (s *KViteTestSuite) TestCipherCodec() {
+	_, err := NewCipherCodec([]byte("short"))
+	s.Error(err)
+	_, err = NewPassphraseCodec("secret", []byte("salt"))
+	s.Error(err)
+
+	salt := bytes.Repeat([]byte{7}, 16)
+	codec, err := NewPassphraseCodec("correct horse", salt)
+	s.Require().NoError(err)
+	db := s.openDB("cipher.db", WithCodec("secret", codec))
+	s.NoError(db.Transaction(func(tx *Tx) error {
+		s.NoError(tx.SetBucketConfig("secrets", BucketConfig{Codec: "secret"}))
+		b, _ := tx.Bucket("secrets")
+		return b.Put("token", []byte("hunter2"))
+	}))
+
+	stored := s.storedValue(db, "secrets", "token")
+	s.NotContains(string(stored), "hunter2")
+	s.NoError(db.Transaction(func(tx *Tx) error {
+		b, _ := tx.Bucket("secrets")
+		value, err := b.Get("token")
+		s.NoError(err)
+		s.Equal([]byte("hunter2"), value)
+		return nil
+	}))
+	s.NoError(db.Close())
+
+	// Another passphrase cannot read the value, and neither can the right one once it was modified
+	other, err := NewPassphraseCodec("wrong horse", salt)
+	s.Require().NoError(err)
+	_, err = other.Decode(stored)
+	s.Equal(ErrDecrypt, err)
+	stored[len(stored)-1] ^= 1
+	_, err = codec.Decode(stored)
+	s.Equal(ErrDecrypt, err)
+}
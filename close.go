@@ -0,0 +1,92 @@
+package kvite
+
+import (
+	"context"
+	"sync"
+)
+
+// lifecycle tracks the open transactions of a database so that it can be closed gracefully.
+// It is shared by handles returned from Restrict.
+type lifecycle struct {
+	mu      sync.Mutex
+	closed  bool
+	active  int
+	drained chan struct{}
+}
+
+// acquire registers a new transaction. It fails with ErrDBClosed once the database is closing.
+func (l *lifecycle) acquire() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return ErrDBClosed
+	}
+	l.active++
+	return nil
+}
+
+// check fails with ErrDBClosed once the database is closing.
+func (l *lifecycle) check() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.closed {
+		return ErrDBClosed
+	}
+	return nil
+}
+
+// idle reports whether no transactions are open.
+func (l *lifecycle) idle() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.active == 0
+}
+
+// release records that a transaction has finished.
+func (l *lifecycle) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.active--
+	if l.closed && l.active == 0 && l.drained != nil {
+		close(l.drained)
+		l.drained = nil
+	}
+}
+
+// close stops new transactions and returns a channel that is closed when the open ones have finished.
+func (l *lifecycle) close() <-chan struct{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.closed = true
+
+	ch := make(chan struct{})
+	if l.active == 0 {
+		close(ch)
+	} else {
+		l.drained = ch
+	}
+	return ch
+}
+
+// CloseContext closes the database gracefully. New transactions are refused with ErrDBClosed straight away, and
+// the connections are closed once the open transactions have committed or rolled back. If ctx is done first,
+// the connections are closed regardless and ctx's error is returned. Writes queued by PutAsync, and with
+// WithWriteBehind the journal, are written before new transactions are refused.
+func (db *DB) CloseContext(ctx context.Context) error {
+	db.async.wait()
+	err := db.stopWriteBehind()
+	drained := db.life.close()
+
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		if err == nil {
+			err = ctx.Err()
+		}
+	}
+
+	if cerr := db.closeDB(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,68 @@
+package kvite
+
+import (
+	"context"
+	"time"
+)
+
+func (s *KViteTestSuite) TestCloseContext() {
+	db := s.openDB("close.db")
+
+	tx, err := db.Begin()
+	s.Require().NoError(err)
+	b, _ := tx.CreateBucketIfNotExists("test")
+	s.Require().NoError(b.Put("foo", []byte("bar")))
+
+	closed := make(chan error)
+	go func() {
+		closed <- db.CloseContext(context.Background())
+	}()
+
+	// New transactions are refused while the open one finishes
+	s.Eventually(func() bool {
+		_, err := db.Begin()
+		return err == ErrDBClosed
+	}, time.Second, time.Millisecond)
+	select {
+	case <-closed:
+		s.Fail("closed before the transaction finished")
+	default:
+	}
+
+	s.NoError(tx.Commit())
+	s.NoError(<-closed)
+
+	// Open transactions are abandoned when the context expires
+	db = s.openDB("close.db")
+	_, err = db.Begin()
+	s.Require().NoError(err)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	s.Equal(context.DeadlineExceeded, db.CloseContext(ctx))
+
+	db = s.openDB("close.db")
+	s.NoError(db.Close())
+	s.Equal(ErrDBClosed, db.Transaction(func(*Tx) error { return nil }))
+}
+
+func (s *KViteTestSuite) TestUseAfterClose() {
+	db := s.openDB("closed.db", WithChangeFeed())
+	s.NoError(db.Close())
+
+	_, err := db.Begin()
+	s.Equal(ErrDBClosed, err)
+	_, err = db.BeginTx(context.Background(), TxOptions{Durability: DurabilityFull})
+	s.Equal(ErrDBClosed, err)
+	_, err = db.Buckets()
+	s.Equal(ErrDBClosed, err)
+	_, err = db.Changes(0)
+	s.Equal(ErrDBClosed, err)
+	s.Equal(ErrDBClosed, db.PruneChanges(0))
+	s.Equal(ErrDBClosed, db.Ping(context.Background()))
+	_, err = db.LargestKeys(1)
+	s.Equal(ErrDBClosed, err)
+	_, err = db.Snapshot()
+	s.Equal(ErrDBClosed, err)
+	s.Equal(ErrDBClosed, db.Put("b", "k", nil))
+	s.Equal(ErrDBClosed, db.Transaction(func(*Tx) error { return nil }))
+}
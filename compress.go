@@ -0,0 +1,115 @@
+package kvite
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Compression algorithms for BucketConfig.Compression.
+const (
+	// CompressionNone stores values uncompressed, in a bucket that used to compress them.
+	CompressionNone    = "none"
+	CompressionDeflate = "deflate"
+	CompressionGzip    = "gzip"
+	CompressionZlib    = "zlib"
+)
+
+// compressor is a compression algorithm. Its header is the first byte of every value it stores, which tells
+// readers how to decompress the value whatever the bucket is configured with now.
+type compressor struct {
+	header    byte
+	newWriter func(io.Writer) (io.WriteCloser, error)
+	newReader func(io.Reader) (io.ReadCloser, error)
+}
+
+// valueRaw is the header of values stored uncompressed in a compressed bucket.
+const valueRaw byte = 0
+
+var compressors = map[string]compressor{
+	CompressionNone: {header: valueRaw},
+	CompressionDeflate: {
+		header:    1,
+		newWriter: func(w io.Writer) (io.WriteCloser, error) { return flate.NewWriter(w, flate.DefaultCompression) },
+		newReader: func(r io.Reader) (io.ReadCloser, error) { return flate.NewReader(r), nil },
+	},
+	CompressionGzip: {
+		header:    2,
+		newWriter: func(w io.Writer) (io.WriteCloser, error) { return gzip.NewWriter(w), nil },
+		newReader: func(r io.Reader) (io.ReadCloser, error) { return gzip.NewReader(r) },
+	},
+	CompressionZlib: {
+		header:    3,
+		newWriter: func(w io.Writer) (io.WriteCloser, error) { return zlib.NewWriter(w), nil },
+		newReader: func(r io.Reader) (io.ReadCloser, error) { return zlib.NewReader(r) },
+	},
+}
+
+// compression returns the algorithm the bucket compresses values with, or an empty string if it does not.
+func (cfg BucketConfig) compression() string {
+	if cfg.Compression == "" && cfg.Compress {
+		return CompressionDeflate
+	}
+	return cfg.Compression
+}
+
+// compressValue compresses a value with the named algorithm. Values shorter than threshold, and values that do not
+// get smaller, are stored uncompressed.
+func compressValue(name string, threshold int, value []byte) ([]byte, error) {
+	c := compressors[name]
+	if c.newWriter == nil || len(value) < threshold {
+		return append([]byte{valueRaw}, value...), nil
+	}
+	compressed, err := c.compress(value)
+	if err != nil {
+		return nil, err
+	}
+	if len(compressed) < len(value) {
+		return append([]byte{c.header}, compressed...), nil
+	}
+	return append([]byte{valueRaw}, value...), nil
+}
+
+// decompressValue reverses compressValue, using the algorithm recorded in the value's header.
+func decompressValue(stored []byte) ([]byte, error) {
+	if len(stored) == 0 {
+		return nil, errors.New("compressed value has no header")
+	}
+	if stored[0] == valueRaw {
+		return stored[1:], nil
+	}
+	for _, c := range compressors {
+		if c.header == stored[0] {
+			return c.decompress(stored[1:])
+		}
+	}
+	return nil, fmt.Errorf("unknown value encoding %d", stored[0])
+}
+
+func (c compressor) compress(value []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := c.newWriter(&buf)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(value); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (c compressor) decompress(data []byte) ([]byte, error) {
+	r, err := c.newReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
@@ -0,0 +1,42 @@
+package kvite
+
+import "bytes"
+
+func (s *KViteTestSuite) TestBucketCompression() {
+	long := bytes.Repeat([]byte("abc"), 100)
+	put := func(cfg BucketConfig, key string, value []byte) {
+		s.NoError(s.DB.Transaction(func(tx *Tx) error {
+			s.NoError(tx.SetBucketConfig("test", cfg))
+			b, _ := tx.Bucket("test")
+			return b.Put(key, value)
+		}))
+	}
+
+	put(BucketConfig{Compression: CompressionGzip, CompressThreshold: 100}, "gzip", long)
+	put(BucketConfig{Compression: CompressionGzip, CompressThreshold: 100}, "small", []byte("abcabc"))
+	put(BucketConfig{Compression: CompressionZlib}, "zlib", long)
+	put(BucketConfig{Compress: true}, "deflate", long)
+	put(BucketConfig{Compression: CompressionNone}, "none", long)
+
+	s.Equal(compressors[CompressionGzip].header, s.storedValue(s.DB, "test", "gzip")[0])
+	s.Equal(valueRaw, s.storedValue(s.DB, "test", "small")[0], "values below the threshold are not compressed")
+	s.Equal(compressors[CompressionZlib].header, s.storedValue(s.DB, "test", "zlib")[0])
+	s.Equal(compressors[CompressionDeflate].header, s.storedValue(s.DB, "test", "deflate")[0])
+	s.Equal(valueRaw, s.storedValue(s.DB, "test", "none")[0])
+
+	// Every value is read with the algorithm it was written with
+	s.NoError(s.DB.Transaction(func(tx *Tx) error {
+		b, _ := tx.Bucket("test")
+		for _, key := range []string{"gzip", "zlib", "deflate", "none"} {
+			value, err := b.Get(key)
+			s.NoError(err)
+			s.Equal(long, value, key)
+		}
+		value, err := b.Get("small")
+		s.NoError(err)
+		s.Equal([]byte("abcabc"), value)
+
+		s.Error(tx.SetBucketConfig("test", BucketConfig{Compression: "zstd"}))
+		return nil
+	}))
+}
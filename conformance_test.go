@@ -0,0 +1,21 @@
+package kvite
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/mistifyio/kvite/internal/conformance"
+)
+
+func (s *KViteTestSuite) TestConformance() {
+	// kvitemem isolates transactions as SQLite does in WAL mode.
+	n := 0
+	conformance.Run(s.T(), func() (Store, error) {
+		n++
+		db, err := Open(filepath.Join(s.TempDir, fmt.Sprintf("conformance-%d.db", n)), "testing", WithWAL())
+		if err != nil {
+			return nil, err
+		}
+		return NewStore(db), nil
+	})
+}
@@ -0,0 +1,132 @@
+package kvite
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"fmt"
+)
+
+// WithDeduplication stores each distinct value once, in a values table keyed by its SHA-256 hash, and has the kvite
+// table refer to it. Values are reference counted and removed when the last key referring to them is deleted or
+// overwritten. This saves space when the same value is stored under many keys, at the cost of a hash and an extra
+// write per Put and a join per read.
+// Once a database has been opened with deduplication it stays deduplicated, whether or not later opens pass this
+// option.
+func WithDeduplication() Option {
+	return func(db *DB) error {
+		db.dedup = true
+		return nil
+	}
+}
+
+func (db *DB) valuesTable() string {
+	return db.table + "_kvite_values"
+}
+
+// createValuesTable creates the values table and the triggers that keep its reference counts. Counting in triggers
+// means every way of removing rows, including bulk deletes, releases the values they referred to.
+func (db *DB) createValuesTable(tx *sql.Tx) error {
+	t, v := db.table, db.valuesTable()
+	queries := []string{
+		fmt.Sprintf("create TABLE IF NOT EXISTS '%s' (hash blob primary key, value blob not null, refs integer not null) WITHOUT ROWID", v),
+		fmt.Sprintf(`create TRIGGER IF NOT EXISTS '%s_kvite_values_insert' AFTER INSERT ON '%s' WHEN new.value_ref IS NOT NULL BEGIN
+			UPDATE '%s' SET refs = refs + 1 WHERE hash = new.value_ref;
+		END`, t, t, v),
+		fmt.Sprintf(`create TRIGGER IF NOT EXISTS '%s_kvite_values_update' AFTER UPDATE OF value_ref ON '%s' BEGIN
+			UPDATE '%s' SET refs = refs + 1 WHERE hash = new.value_ref;
+			UPDATE '%s' SET refs = refs - 1 WHERE hash = old.value_ref;
+			DELETE FROM '%s' WHERE hash = old.value_ref AND refs <= 0;
+		END`, t, t, v, v, v),
+		fmt.Sprintf(`create TRIGGER IF NOT EXISTS '%s_kvite_values_delete' AFTER DELETE ON '%s' WHEN old.value_ref IS NOT NULL BEGIN
+			UPDATE '%s' SET refs = refs - 1 WHERE hash = old.value_ref;
+			DELETE FROM '%s' WHERE hash = old.value_ref AND refs <= 0;
+		END`, t, t, v, v),
+	}
+	for _, query := range queries {
+		if _, err := tx.Exec(query); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// initQueries prepares the statements used to read and write rows. Deduplicated databases read values through
+// the values table and write rows with an upsert, since the delete done by INSERT OR REPLACE does not fire triggers.
+func (db *DB) initQueries() error {
+	t := db.table
+	if err := db.initHistoryQuery(); err != nil {
+		return err
+	}
+	// The put queries take the key and bucket a second time to look up the version being replaced. Versions kept
+	// in the history table are counted too, so that a key that is deleted and written again does not reuse them.
+	nextVersion := fmt.Sprintf("(SELECT coalesce(max(version), 0) + 1 FROM '%s' WHERE key = ? AND bucket = ?)", t)
+	if db.history {
+		nextVersion = fmt.Sprintf(`(SELECT max((SELECT coalesce(max(version), 0) FROM '%s' WHERE key = ?10 AND bucket = ?11),
+			(SELECT coalesce(max(version), 0) FROM '%s' WHERE bucket = ?11 AND key = ?10)) + 1)`, t, db.historyTable())
+	}
+	db.revisionQuery = fmt.Sprintf("SELECT version FROM '%s' WHERE key = ? and bucket = ?", t)
+
+	if !db.dedup {
+		exists, err := tableExists(db.db, db.valuesTable())
+		if err != nil {
+			return err
+		}
+		db.dedup = exists
+	}
+
+	db.hasQuery = fmt.Sprintf("SELECT expires FROM '%s' WHERE key = ? and bucket = ?", t)
+	db.deleteQuery = fmt.Sprintf("DELETE FROM '%s' WHERE key = ? AND bucket = ?", t)
+	db.versionQuery = fmt.Sprintf("SELECT hlc, origin FROM '%s' WHERE key = ? and bucket = ?", t)
+	if err := db.initBucketsQuery(); err != nil {
+		return err
+	}
+
+	if !db.dedup {
+		db.getQuery = fmt.Sprintf("SELECT value, checksum, expires FROM '%s' WHERE key = ? and bucket = ?", t)
+		db.putQuery = fmt.Sprintf("INSERT OR REPLACE INTO '%s' (key, value, bucket, checksum, hlc, origin, value_ref, expires, content_type, version) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, %s)", t, nextVersion)
+		db.foreachQuery = fmt.Sprintf("SELECT key, value, checksum, expires FROM '%s' WHERE bucket = ?", t)
+		db.sizesQuery = fmt.Sprintf("SELECT key, length(value) FROM '%s' WHERE bucket = ?", t)
+		db.largestQuery = fmt.Sprintf("SELECT bucket, key, length(value) AS size FROM '%s' ORDER BY size DESC, bucket, key", t)
+		return nil
+	}
+
+	v := db.valuesTable()
+	db.getQuery = fmt.Sprintf("SELECT coalesce(v.value, t.value), t.checksum, t.expires FROM '%s' t LEFT JOIN '%s' v ON v.hash = t.value_ref WHERE t.key = ? and t.bucket = ?", t, v)
+	db.putQuery = fmt.Sprintf(`INSERT INTO '%s' (key, value, bucket, checksum, hlc, origin, value_ref, expires, content_type, version) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, %s)
+		ON CONFLICT (key, bucket) DO UPDATE SET value = excluded.value, checksum = excluded.checksum, hlc = excluded.hlc,
+		origin = excluded.origin, value_ref = excluded.value_ref, expires = excluded.expires, content_type = excluded.content_type,
+		version = excluded.version`, t, nextVersion)
+	db.foreachQuery = fmt.Sprintf("SELECT t.key, coalesce(v.value, t.value), t.checksum, t.expires FROM '%s' t LEFT JOIN '%s' v ON v.hash = t.value_ref WHERE t.bucket = ?", t, v)
+	db.sizesQuery = fmt.Sprintf("SELECT t.key, length(coalesce(v.value, t.value)) FROM '%s' t LEFT JOIN '%s' v ON v.hash = t.value_ref WHERE t.bucket = ?", t, v)
+	db.largestQuery = fmt.Sprintf("SELECT t.bucket, t.key, length(coalesce(v.value, t.value)) AS size FROM '%s' t LEFT JOIN '%s' v ON v.hash = t.value_ref ORDER BY size DESC, t.bucket, t.key", t, v)
+	db.valueQuery = fmt.Sprintf("INSERT INTO '%s' (hash, value, refs) VALUES (?, ?, 0) ON CONFLICT (hash) DO NOTHING", v)
+	return nil
+}
+
+// writeRow writes a key's row. Deduplicated values are stored in the values table and the row refers to them by hash.
+// The value is encoded as the bucket's BucketConfig says, and checksums and deduplication apply to the stored bytes.
+func (tx *Tx) writeRow(bucket, key string, value []byte, ts, origin interface{}, meta ValueMeta) error {
+	value, err := tx.encodeValue(bucket, key, value)
+	if err != nil {
+		return err
+	}
+	sum := tx.db.checksumFor(bucket, key, value)
+	expires := tx.expiresFor(bucket)
+	if !tx.db.dedup {
+		_, err := tx.exec(tx.db.putQuery, key, value, bucket, sum, ts, origin, nil, expires, meta.contentType(), key, bucket)
+		return err
+	}
+
+	hash := sha256.Sum256(value)
+	if _, err := tx.exec(tx.db.valueQuery, hash[:], value); err != nil {
+		return err
+	}
+	_, err = tx.exec(tx.db.putQuery, key, []byte{}, bucket, sum, ts, origin, hash[:], expires, meta.contentType(), key, bucket)
+	return err
+}
+
+func tableExists(q rowQuerier, name string) (bool, error) {
+	var n int
+	err := q.QueryRow("SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name = ?", name).Scan(&n)
+	return n > 0, err
+}
@@ -0,0 +1,70 @@
+package kvite
+
+import "path/filepath"
+
+func (s *KViteTestSuite) TestDeduplication() {
+	db := s.openDB("dedup.db", WithDeduplication(), WithChecksums())
+
+	values := func() (rows, refs int) {
+		s.Require().NoError(db.db.QueryRow("SELECT count(*), coalesce(sum(refs), 0) FROM testing_kvite_values").Scan(&rows, &refs))
+		return rows, refs
+	}
+
+	blob := []byte("the same config blob")
+	s.NoError(db.Transaction(func(tx *Tx) error {
+		b, _ := tx.CreateBucket("test")
+		for _, key := range []string{"a", "b", "c"} {
+			if err := b.Put(key, blob); err != nil {
+				return err
+			}
+		}
+		// Rewriting the same value does not add a reference
+		if err := b.Put("a", blob); err != nil {
+			return err
+		}
+		return b.Put("d", []byte("other"))
+	}))
+	rows, refs := values()
+	s.Equal(2, rows)
+	s.Equal(4, refs)
+
+	s.NoError(db.Transaction(func(tx *Tx) error {
+		b, _ := tx.CreateBucketIfNotExists("test")
+		value, err := b.Get("b")
+		s.Equal(blob, value)
+		n := 0
+		_ = b.ForEach(func(k string, v []byte) error {
+			n++
+			return nil
+		})
+		s.Equal(4, n)
+		return err
+	}))
+
+	s.NoError(db.Transaction(func(tx *Tx) error {
+		b, _ := tx.CreateBucketIfNotExists("test")
+		_ = b.Delete("d")
+		_ = b.Delete("a")
+		return b.Put("b", []byte("changed"))
+	}))
+	rows, refs = values()
+	s.Equal(2, rows)
+	s.Equal(2, refs)
+	s.NoError(db.Close())
+
+	// Deduplication stays on without the option
+	db = s.openDB("dedup.db")
+	s.True(db.dedup)
+	s.NoError(db.Transaction(func(tx *Tx) error {
+		b, _ := tx.CreateBucketIfNotExists("test")
+		value, err := b.Get("c")
+		s.Equal(blob, value)
+		return err
+	}))
+	s.NoError(db.Close())
+
+	result, err := Recover(filepath.Join(s.TempDir, "dedup.db"), filepath.Join(s.TempDir, "recovered.db"))
+	s.Require().NoError(err)
+	s.Equal(2, result.Recovered)
+	s.Equal(0, result.Skipped)
+}
@@ -0,0 +1,33 @@
+package kvite
+
+import "errors"
+
+// errDryRun rolls back the transaction of DryRun once fn has succeeded.
+var errDryRun = errors.New("dry run transaction rolled back")
+
+// DryRun runs fn in a transaction like Transaction does, but always rolls the transaction back, and returns the
+// changes it would have made, in order, for tools that show a plan before applying it. Within fn the transaction
+// behaves as usual, so reads see its earlier writes. If fn returns an error, DryRun returns it and no changes.
+// The changes are those the change feed would record, without sequence numbers; writes made through ExecRaw or
+// Unwrap are not reported. Nothing is passed on to watchers, and bucket configuration set by fn is discarded.
+func (db *DB) DryRun(fn func(*Tx) error) ([]Change, error) {
+	var planned []Change
+	err := db.Transaction(func(tx *Tx) error {
+		tx.dryRun = true
+		if err := fn(tx); err != nil {
+			return err
+		}
+		planned = tx.planned
+		return errDryRun
+	})
+	if err != errDryRun {
+		return nil, err
+	}
+	return planned, nil
+}
+
+// DryRun reports whether the transaction is run by DB.DryRun, and so will be rolled back, so that fn can skip
+// side effects outside the database.
+func (tx *Tx) DryRun() bool {
+	return tx.dryRun
+}
@@ -0,0 +1,50 @@
+package kvite
+
+import "errors"
+
+func (s *KViteTestSuite) TestDryRun() {
+	s.NoError(s.DB.Put("plan", "keep", []byte("v")))
+	s.NoError(s.DB.Put("plan", "old", []byte("v")))
+
+	changes, err := s.DB.DryRun(func(tx *Tx) error {
+		s.True(tx.DryRun())
+		b, _ := tx.CreateBucketIfNotExists("plan")
+		s.NoError(b.Put("new", []byte("value")))
+		s.NoError(b.Delete("old"))
+		s.NoError(b.Delete("missing"))
+		value, err := b.Get("new")
+		s.Equal([]byte("value"), value)
+		return err
+	})
+	s.NoError(err)
+	s.Require().Len(changes, 2)
+	s.Equal(ChangePut, changes[0].Type)
+	s.Equal("new", changes[0].Key)
+	s.Equal([]byte("value"), changes[0].Value)
+	s.Equal(ChangeDelete, changes[1].Type)
+	s.Equal("old", changes[1].Key)
+
+	s.testStoredValue("plan", "new", nil)
+	s.testStoredValue("plan", "old", []byte("v"))
+
+	changes, err = s.DB.DryRun(func(tx *Tx) error {
+		b, _ := tx.CreateBucketIfNotExists("plan")
+		n, err := b.Truncate()
+		s.Equal(int64(2), n)
+		return err
+	})
+	s.NoError(err)
+	s.Len(changes, 2)
+	s.Equal(2, s.countKeys(s.DB, "plan"))
+
+	changes, err = s.DB.DryRun(func(tx *Tx) error {
+		return errors.New("failed")
+	})
+	s.EqualError(err, "failed")
+	s.Nil(changes)
+
+	s.NoError(s.DB.Transaction(func(tx *Tx) error {
+		s.False(tx.DryRun())
+		return nil
+	}))
+}
@@ -0,0 +1,158 @@
+package kvite
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// createPrefix matches the start of the statements SQLite records in sqlite_master.
+var createPrefix = regexp.MustCompile(`(?i)^\s*create\s+(unique\s+)?(table|index)\s+(if\s+not\s+exists\s+)?`)
+
+// Dump writes the kvite table as SQL text: the statements creating it and its indexes, followed by an INSERT for
+// each key in bucket and key order, so that dumps can be loaded with the sqlite3 shell or Load, and diffed in
+// version control. The bucket registry and the meta table are dumped too, except for the node ID, so that a
+// database loaded from a dump is not mistaken for the original by Sync.
+// Deduplicated values are written inline, and the change feed, version history and spatial index are left out.
+// Statements are written with IF NOT EXISTS and INSERT OR REPLACE, so a dump can be loaded over an existing
+// database. Dump reads a consistent view of the database and returns ErrRawRestricted on a handle with bucket
+// access rules.
+func (db *DB) Dump(w io.Writer) error {
+	if len(db.bucketRules) > 0 {
+		return ErrRawRestricted
+	}
+	if err := db.life.check(); err != nil {
+		return err
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, "PRAGMA foreign_keys=OFF;")
+	fmt.Fprintln(bw, "BEGIN TRANSACTION;")
+
+	tables := []struct {
+		name, where, order string
+	}{
+		{name: db.metaTable(), where: "WHERE name != 'node_id'", order: "ORDER BY name"},
+		{name: db.table, order: "ORDER BY bucket, key"},
+		{name: db.bucketsTable(), order: "ORDER BY rowid"},
+	}
+	for _, t := range tables {
+		if err := tx.dumpTable(bw, t.name, t.where, t.order); err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintln(bw, "COMMIT;")
+	return bw.Flush()
+}
+
+// dumpTable writes the statements creating a table and its indexes, and inserting its rows. Tables that do not
+// exist are skipped.
+func (tx *Tx) dumpTable(w io.Writer, table, where, order string) error {
+	rows, err := tx.query("SELECT type, sql FROM sqlite_master WHERE tbl_name = ? AND sql IS NOT NULL AND type IN ('table', 'index') ORDER BY type DESC", table)
+	if err != nil {
+		return err
+	}
+	var creates []string
+	for rows.Next() {
+		var typ, sql string
+		if err := rows.Scan(&typ, &sql); err != nil {
+			_ = rows.Close()
+			return err
+		}
+		m := createPrefix.FindStringSubmatch(sql)
+		if m == nil {
+			continue
+		}
+		unique := ""
+		if m[1] != "" {
+			unique = "UNIQUE "
+		}
+		creates = append(creates, "CREATE "+unique+strings.ToUpper(typ)+" IF NOT EXISTS "+sql[len(m[0]):])
+	}
+	if err := rows.Close(); err != nil {
+		return err
+	}
+	if len(creates) == 0 {
+		return nil
+	}
+
+	cols, err := tx.columnNames(table)
+	if err != nil {
+		return err
+	}
+
+	// The values of a deduplicated table are written inline
+	from := fmt.Sprintf("'%s' t", table)
+	exprs := make([]string, len(cols))
+	for i, c := range cols {
+		exprs[i] = "quote(t." + c + ")"
+		if table == tx.db.table && tx.db.dedup {
+			switch c {
+			case "value":
+				exprs[i] = "quote(coalesce(v.value, t.value))"
+			case "value_ref":
+				exprs[i] = "'NULL'"
+			}
+		}
+	}
+	if table == tx.db.table && tx.db.dedup {
+		from += fmt.Sprintf(" LEFT JOIN '%s' v ON v.hash = t.value_ref", tx.db.valuesTable())
+	}
+
+	fmt.Fprintln(w, creates[0]+";")
+	insert := fmt.Sprintf("INSERT OR REPLACE INTO %s (%s) VALUES (", quoteIdent(table), strings.Join(cols, ", "))
+	rows, err = tx.query(fmt.Sprintf("SELECT %s FROM %s %s %s", strings.Join(exprs, " || ',' || "), from, where, order))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var values string
+		if err := rows.Scan(&values); err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintln(w, insert+values+");"); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, c := range creates[1:] {
+		fmt.Fprintln(w, c+";")
+	}
+	return nil
+}
+
+// columnNames returns the columns of a table in order.
+func (tx *Tx) columnNames(table string) ([]string, error) {
+	rows, err := tx.query(fmt.Sprintf("SELECT name FROM pragma_table_info('%s') ORDER BY cid", table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var cols []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		cols = append(cols, name)
+	}
+	return cols, rows.Err()
+}
+
+// quoteIdent quotes a table name for SQL.
+func quoteIdent(name string) string {
+	return `"` + strings.Replace(name, `"`, `""`, -1) + `"`
+}
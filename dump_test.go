@@ -0,0 +1,115 @@
+package kvite
+
+import (
+	"bytes"
+	"database/sql"
+	"path/filepath"
+	"strings"
+)
+
+func (s *KViteTestSuite) TestDump() {
+	db := s.openDB("dump.db", WithDeduplication())
+	s.NoError(db.Transaction(func(tx *Tx) error {
+		b, _ := tx.CreateBucket("test")
+		s.NoError(b.Put("quote's", []byte("it's; here")))
+		s.NoError(b.Put("binary", []byte{0, 1, 2}))
+		_, err := tx.CreateBucket("empty")
+		return err
+	}))
+
+	var buf bytes.Buffer
+	s.Require().NoError(db.Dump(&buf))
+	s.NoError(db.Close())
+	dump := buf.String()
+	s.Contains(dump, `INSERT OR REPLACE INTO "testing" (key, bucket, value,`)
+	s.Contains(dump, "X'000102'")
+	s.NotContains(dump, "node_id")
+	s.Less(strings.Index(dump, "'binary'"), strings.Index(dump, "'quote''s'"), "rows are in key order")
+
+	// The dump loads into an empty database with plain SQLite
+	raw, err := sql.Open("sqlite3", filepath.Join(s.TempDir, "loaded.db"))
+	s.Require().NoError(err)
+	_, err = raw.Exec(dump)
+	s.Require().NoError(err)
+	s.NoError(raw.Close())
+
+	loaded := s.openDB("loaded.db")
+	defer func() { _ = loaded.Close() }()
+	s.NotEqual(db.NodeID(), loaded.NodeID())
+	names, err := loaded.Buckets()
+	s.NoError(err)
+	s.Equal([]string{"test", "empty"}, names)
+	s.NoError(loaded.Transaction(func(tx *Tx) error {
+		b, err := tx.Bucket("test")
+		s.Require().NoError(err)
+		value, err := b.Get("quote's")
+		s.NoError(err)
+		s.Equal([]byte("it's; here"), value)
+		return nil
+	}))
+
+	restricted, err := loaded.Restrict("test", BucketReadOnly)
+	s.Require().NoError(err)
+	s.Equal(ErrRawRestricted, restricted.Dump(&buf))
+}
+
+func (s *KViteTestSuite) TestLoad() {
+	src := s.openDB("source.db")
+	s.NoError(src.Transaction(func(tx *Tx) error {
+		s.NoError(tx.SetBucketConfig("docs", BucketConfig{Compress: true}))
+		b, _ := tx.Bucket("docs")
+		s.NoError(b.Put("long", bytes.Repeat([]byte("abc"), 100)))
+		s.NoError(b.Put("quote's", []byte("it's; here")))
+		return nil
+	}))
+	var dump bytes.Buffer
+	s.Require().NoError(src.Dump(&dump))
+	s.NoError(src.Close())
+
+	db := s.openDB("target.db", WithDeduplication())
+	defer func() { _ = db.Close() }()
+	s.NoError(db.Transaction(func(tx *Tx) error {
+		b, _ := tx.CreateBucket("docs")
+		s.NoError(b.Put("quote's", []byte("old")))
+		b, _ = tx.CreateBucket("other")
+		return b.Put("kept", []byte("yes"))
+	}))
+
+	// Statements other than those Dump writes are rejected before anything is written
+	for _, bad := range []string{
+		"DROP TABLE testing;",
+		"INSERT INTO testing (key, bucket, value) VALUES ('a', 'b', randomblob(4));",
+		"INSERT INTO sqlite_master VALUES ('a');",
+		"CREATE TABLE IF NOT EXISTS other (a);",
+		"INSERT INTO testing (key, bucket, value) VALUES ('a', 'b', X'00'); DELETE FROM testing;",
+		"INSERT INTO testing (key, bucket, value) VALUES ('a', 'b', 'unterminated);",
+	} {
+		s.Error(db.Load(strings.NewReader(dump.String()+bad)), bad)
+	}
+	s.Equal(BucketConfig{}, db.BucketConfig("docs"))
+
+	s.NoError(db.Load(&dump))
+	s.Equal(BucketConfig{Compress: true}, db.BucketConfig("docs"))
+	s.NoError(db.Transaction(func(tx *Tx) error {
+		b, err := tx.Bucket("docs")
+		s.Require().NoError(err)
+		value, err := b.Get("quote's")
+		s.NoError(err)
+		s.Equal([]byte("it's; here"), value)
+		value, err = b.Get("long")
+		s.NoError(err)
+		s.Equal(bytes.Repeat([]byte("abc"), 100), value)
+
+		b, err = tx.Bucket("other")
+		s.Require().NoError(err)
+		value, err = b.Get("kept")
+		s.NoError(err)
+		s.Equal([]byte("yes"), value)
+		return nil
+	}))
+
+	// The value replaced by the load is no longer referenced
+	var refs int
+	s.NoError(db.db.QueryRow("SELECT count(*) FROM 'testing_kvite_values'").Scan(&refs))
+	s.Equal(1, refs)
+}
@@ -0,0 +1,72 @@
+package kvite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// Durability selects how hard SQLite works to make a commit survive a crash, through PRAGMA synchronous.
+type Durability int
+
+const (
+	// DurabilityDefault leaves the driver's setting, which is DurabilityNormal.
+	DurabilityDefault Durability = iota
+	// DurabilityOff hands writes to the operating system without syncing. Committed transactions can be lost, and
+	// the database corrupted, if the machine loses power, but not if only the process crashes.
+	DurabilityOff
+	// DurabilityNormal syncs less often than DurabilityFull. In WAL mode, committed transactions can be rolled
+	// back by a power loss but the database stays consistent.
+	DurabilityNormal
+	// DurabilityFull syncs on every commit.
+	DurabilityFull
+)
+
+func (d Durability) pragma() string {
+	switch d {
+	case DurabilityOff:
+		return "OFF"
+	case DurabilityNormal:
+		return "NORMAL"
+	case DurabilityFull:
+		return "FULL"
+	}
+	return ""
+}
+
+// WithDurability sets the durability of every connection to the database. It can be overridden per transaction
+// with BeginTx.
+func WithDurability(d Durability) Option {
+	return func(db *DB) error {
+		if d < DurabilityDefault || d > DurabilityFull {
+			return fmt.Errorf("invalid durability %d", d)
+		}
+		db.durability = d
+		return nil
+	}
+}
+
+// setSynchronous takes a connection from pool and sets its durability. It returns the previous setting, to be put
+// back by restoreSynchronous.
+func setSynchronous(ctx context.Context, pool *sql.DB, d Durability) (*sql.Conn, int, error) {
+	conn, err := pool.Conn(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	var previous int
+	if err := conn.QueryRowContext(ctx, "PRAGMA synchronous").Scan(&previous); err != nil {
+		_ = conn.Close()
+		return nil, 0, err
+	}
+	if _, err := conn.ExecContext(ctx, "PRAGMA synchronous = "+d.pragma()); err != nil {
+		_ = conn.Close()
+		return nil, 0, err
+	}
+	return conn, previous, nil
+}
+
+// restoreSynchronous puts back the synchronous setting of a connection and returns it to the pool.
+func restoreSynchronous(conn *sql.Conn, previous int) {
+	_, _ = conn.ExecContext(context.Background(), fmt.Sprintf("PRAGMA synchronous = %d", previous))
+	_ = conn.Close()
+}
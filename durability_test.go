@@ -0,0 +1,34 @@
+package kvite
+
+import "context"
+
+func (s *KViteTestSuite) TestDurability() {
+	db := s.openDB("durability.db", WithDurability(DurabilityNormal))
+	defer func() { _ = db.Close() }()
+	db.db.SetMaxOpenConns(1)
+
+	synchronous := func(q rowQuerier) int {
+		var n int
+		s.NoError(q.QueryRow("PRAGMA synchronous").Scan(&n))
+		return n
+	}
+	s.Equal(1, synchronous(db.db))
+
+	tx, err := db.BeginTx(context.Background(), TxOptions{Durability: DurabilityOff})
+	s.NoError(err)
+	s.Equal(0, synchronous(tx.tx))
+	b, _ := tx.CreateBucketIfNotExists("durability")
+	s.NoError(b.Put("k", []byte("v")))
+	s.NoError(tx.Commit())
+	s.Equal(1, synchronous(db.db))
+
+	tx, err = db.BeginTx(context.Background(), TxOptions{})
+	s.NoError(err)
+	s.Equal(1, synchronous(tx.tx))
+	s.NoError(tx.Rollback())
+
+	_, err = db.BeginTx(context.Background(), TxOptions{Durability: 42})
+	s.Error(err)
+	_, err = Open(":memory:", "", WithDurability(-1))
+	s.Error(err)
+}
@@ -0,0 +1,139 @@
+package kvite
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// LeaderElector picks a single leader among the processes sharing a database file. Each candidate campaigns for
+// an election under a common name; the winner holds an expiring lock that the elector renews in the background
+// until it resigns or fails to renew in time.
+type LeaderElector struct {
+	db       *DB
+	name     string
+	id       string
+	ttl      time.Duration
+	onChange func(leader bool)
+
+	mu     sync.Mutex
+	lock   *Lock
+	stop   chan struct{}
+	done   chan struct{}
+	leader bool
+}
+
+// LeaderElector returns an elector for the election called name. id identifies this candidate and is what
+// Leader reports while it leads. A leader that stops renewing is replaced once ttl has elapsed.
+func (db *DB) LeaderElector(name, id string, ttl time.Duration) *LeaderElector {
+	return &LeaderElector{db: db, name: "election:" + name, id: id, ttl: ttl}
+}
+
+// OnChange sets a function that is called with true when this candidate becomes the leader and with false when
+// it stops being the leader, whether by resigning or by losing its lock. It must be set before Campaign.
+func (e *LeaderElector) OnChange(fn func(leader bool)) {
+	e.onChange = fn
+}
+
+// Campaign blocks until this candidate is the leader or ctx is done. Campaigning while already leading returns
+// immediately.
+func (e *LeaderElector) Campaign(ctx context.Context) error {
+	poll := e.ttl / 3
+	for {
+		e.mu.Lock()
+		if e.leader {
+			e.mu.Unlock()
+			return nil
+		}
+		lock, err := e.db.acquireLock(e.name, e.id, e.ttl)
+		if err == nil {
+			e.lock = lock
+			e.leader = true
+			e.stop = make(chan struct{})
+			e.done = make(chan struct{})
+			go e.renew(lock, e.stop, e.done)
+		}
+		e.mu.Unlock()
+
+		if err == nil {
+			e.changed(true)
+			return nil
+		}
+		if err != ErrLockHeld {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(poll):
+		}
+	}
+}
+
+// renew keeps the lock alive until stop is closed or the lock is lost.
+func (e *LeaderElector) renew(lock *Lock, stop, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(e.ttl / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		err := lock.Renew(e.ttl)
+		if err == nil || (err != ErrLockLost && time.Now().Before(lock.Expires())) {
+			continue
+		}
+
+		e.mu.Lock()
+		lost := e.lock == lock
+		if lost {
+			e.lock = nil
+			e.leader = false
+		}
+		e.mu.Unlock()
+		if lost {
+			e.changed(false)
+		}
+		return
+	}
+}
+
+func (e *LeaderElector) changed(leader bool) {
+	if e.onChange != nil {
+		e.onChange(leader)
+	}
+}
+
+// IsLeader reports whether this candidate currently leads.
+func (e *LeaderElector) IsLeader() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.leader
+}
+
+// Leader returns the id of the current leader, or "" if the election has no leader.
+func (e *LeaderElector) Leader() (string, error) {
+	return e.db.lockOwner(e.name)
+}
+
+// Resign gives up leadership so that another candidate can take over without waiting for the lock to expire.
+// Resigning when not leading does nothing.
+func (e *LeaderElector) Resign() error {
+	e.mu.Lock()
+	lock, stop, done := e.lock, e.stop, e.done
+	e.lock = nil
+	e.leader = false
+	e.mu.Unlock()
+	if lock == nil {
+		return nil
+	}
+
+	close(stop)
+	<-done
+	e.changed(false)
+	return lock.Release()
+}
@@ -0,0 +1,40 @@
+package kvite
+
+import (
+	"context"
+	"time"
+)
+
+func (s *KViteTestSuite) TestLeaderElector() {
+	var changes []bool
+	a := s.DB.LeaderElector("workers", "a", 50*time.Millisecond)
+	a.OnChange(func(leader bool) { changes = append(changes, leader) })
+	b := s.DB.LeaderElector("workers", "b", 50*time.Millisecond)
+
+	leader, err := a.Leader()
+	s.NoError(err)
+	s.Equal("", leader)
+
+	s.NoError(a.Campaign(context.Background()))
+	s.True(a.IsLeader())
+
+	// The leader keeps its lock past the TTL by renewing it.
+	ctx, cancel := context.WithTimeout(context.Background(), 150*time.Millisecond)
+	defer cancel()
+	s.Equal(context.DeadlineExceeded, b.Campaign(ctx))
+	s.False(b.IsLeader())
+
+	leader, err = b.Leader()
+	s.NoError(err)
+	s.Equal("a", leader)
+
+	s.NoError(a.Resign())
+	s.False(a.IsLeader())
+	s.Equal([]bool{true, false}, changes)
+
+	s.NoError(b.Campaign(context.Background()))
+	leader, err = a.Leader()
+	s.NoError(err)
+	s.Equal("b", leader)
+	s.NoError(b.Resign())
+}
@@ -0,0 +1,172 @@
+package kvite
+
+import (
+	"errors"
+	"fmt"
+
+	sqlite3 "github.com/mattn/go-sqlite3"
+	"github.com/mistifyio/kvite/kv"
+)
+
+var (
+	// ErrTxReadOnly is returned when writing through a read-only transaction, such as a Snapshot.
+	ErrTxReadOnly = errors.New("transaction is read-only")
+	// ErrNotWAL is returned by operations that require the database to be in write-ahead logging mode.
+	ErrNotWAL = errors.New("database is not in WAL mode")
+	// ErrNoChangeFeed is returned when reading the change feed of a database opened without WithChangeFeed.
+	ErrNoChangeFeed = errors.New("change feed is not enabled")
+	// ErrNoLockHeartbeat is returned by ProcessLockHolder for a database opened without WithLockHeartbeat.
+	ErrNoLockHeartbeat = errors.New("lock heartbeat is not enabled")
+	// ErrNoSpatialIndex is returned by the box methods of a database opened without WithSpatialIndex.
+	ErrNoSpatialIndex = errors.New("spatial index is not enabled")
+	// ErrSameNode is returned by Sync when both databases have the same node ID, such as a database and its copy.
+	ErrSameNode = errors.New("databases have the same node ID")
+	// ErrRateLimited is returned by Put and Delete when a write limit set with WithWriteLimit or
+	// WithBucketWriteLimit has been exceeded.
+	ErrRateLimited = errors.New("write rate limit exceeded")
+	// ErrTxFinished is returned by Commit and Rollback on a transaction that has already been committed or rolled
+	// back.
+	ErrTxFinished = kv.ErrTxFinished
+	// ErrBucketNotFound is returned by Bucket and DeleteBucket when the bucket does not exist.
+	ErrBucketNotFound = kv.ErrBucketNotFound
+	// ErrDBClosed is returned when using a database that has been closed.
+	ErrDBClosed = kv.ErrDBClosed
+	// ErrNoMergeOperator is returned by Merge on a bucket without a merge operator.
+	ErrNoMergeOperator = errors.New("bucket has no merge operator")
+	// ErrVersionMismatch is returned by PutVersion when the key's version is not the expected one.
+	ErrVersionMismatch = errors.New("key version does not match the expected version")
+	// ErrWatcherOverflow is reported by a Watcher that was stopped because it fell too far behind.
+	ErrWatcherOverflow = errors.New("watcher fell too far behind")
+	// ErrLockHeld is returned by AcquireLock when another holder has the lock.
+	ErrLockHeld = errors.New("lock is held by another holder")
+	// ErrLockLost is returned by Lock.Renew when the lock was released or taken by another holder.
+	ErrLockLost = errors.New("lock is no longer held")
+	// ErrSessionExpired is returned when using a session that has expired or been revoked.
+	ErrSessionExpired = errors.New("session has expired")
+	// ErrRawRestricted is returned by QueryRaw and ExecRaw on a handle with bucket access rules, which raw SQL would
+	// bypass.
+	ErrRawRestricted = errors.New("raw SQL is not allowed with bucket access rules")
+	// ErrDecrypt is returned when reading a value that a codec returned by NewCipherCodec or NewPassphraseCodec
+	// cannot decrypt, because it was modified or encrypted with another key.
+	ErrDecrypt = errors.New("value cannot be decrypted with the codec's key")
+	// ErrSignature is returned when reading a value whose signature, added by a codec returned by
+	// NewSigningCodec, does not match.
+	ErrSignature = errors.New("value signature does not match")
+)
+
+// Failure classes of SQLite errors. They are matched with errors.Is against the SQLiteError values returned by
+// kvite operations.
+var (
+	// ErrBusy means the database file was locked by another connection for longer than the busy timeout.
+	ErrBusy = kv.ErrBusy
+	// ErrLocked means a table was locked by another transaction on a shared connection.
+	ErrLocked = errors.New("database table is locked")
+	// ErrCorrupt means the database file is damaged or is not an SQLite database.
+	ErrCorrupt = errors.New("database is corrupt")
+	// ErrFull means the disk or the database size limit is full.
+	ErrFull = errors.New("database is full")
+	// ErrReadOnly means the database file cannot be written, such as one opened WithReadOnly. Writes through a
+	// read-only transaction return ErrTxReadOnly instead.
+	ErrReadOnly = errors.New("database is read-only")
+)
+
+// PanicError is returned by DB.Transaction and DB.Batch when their function panics. It is the PanicError of package
+// kv, so that kvitemem returns the same type.
+type PanicError = kv.PanicError
+
+// SQLiteError is an error reported by SQLite. It matches the failure class of its result code with errors.Is,
+// such as errors.Is(err, ErrBusy), and unwraps to the error of the driver.
+type SQLiteError struct {
+	// Code is the primary SQLite result code and ExtendedCode the extended result code, such as 5 (SQLITE_BUSY)
+	// and 261 (SQLITE_BUSY_RECOVERY).
+	Code         int
+	ExtendedCode int
+
+	err sqlite3.Error
+}
+
+func (e *SQLiteError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the error of the driver.
+func (e *SQLiteError) Unwrap() error {
+	return e.err
+}
+
+// Is reports whether the error belongs to the failure class target.
+func (e *SQLiteError) Is(target error) bool {
+	switch sqlite3.ErrNo(e.Code) {
+	case sqlite3.ErrBusy:
+		return target == ErrBusy
+	case sqlite3.ErrLocked:
+		return target == ErrLocked
+	case sqlite3.ErrCorrupt, sqlite3.ErrNotADB:
+		return target == ErrCorrupt
+	case sqlite3.ErrFull:
+		return target == ErrFull
+	case sqlite3.ErrReadonly:
+		return target == ErrReadOnly
+	}
+	return false
+}
+
+// sqliteError turns an error of the driver into an SQLiteError. Other errors are returned unchanged.
+func sqliteError(err error) error {
+	serr, ok := err.(sqlite3.Error)
+	if !ok {
+		return err
+	}
+	return &SQLiteError{Code: int(serr.Code), ExtendedCode: int(serr.ExtendedCode), err: serr}
+}
+
+// DroppedWritesError is returned by DB.Flush and DB.Close when writes in the journal of a database opened with
+// WithWriteBehind failed and were dropped. Errs holds the error of each write in Writes.
+type DroppedWritesError struct {
+	Writes []Change
+	Errs   []error
+}
+
+func (e *DroppedWritesError) Error() string {
+	return fmt.Sprintf("%d write-behind writes dropped, the first with: %v", len(e.Writes), e.Errs[0])
+}
+
+// ReplicaNotReadyError is returned by Replica.View when the replica has no local copy to read, because a sync
+// failed while replacing it. Err is the error of that sync.
+type ReplicaNotReadyError struct {
+	Err error
+}
+
+func (e *ReplicaNotReadyError) Error() string {
+	if e.Err == nil {
+		return "replica not ready"
+	}
+	return fmt.Sprintf("replica not ready: %v", e.Err)
+}
+
+// Unwrap returns the error of the failed sync.
+func (e *ReplicaNotReadyError) Unwrap() error {
+	return e.Err
+}
+
+// ChecksumError is returned when a stored value does not match the checksum that was written with it,
+// which indicates bit rot or a partial write.
+type ChecksumError struct {
+	Bucket string
+	Key    string
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("checksum mismatch for key %q in bucket %q", e.Key, e.Bucket)
+}
+
+// TamperError is returned by a database opened WithValueMAC when a stored value does not have a valid MAC, which
+// indicates it was written without the secret.
+type TamperError struct {
+	Bucket string
+	Key    string
+}
+
+func (e *TamperError) Error() string {
+	return fmt.Sprintf("value for key %q in bucket %q has no valid MAC", e.Key, e.Bucket)
+}
@@ -0,0 +1,38 @@
+package kvite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+
+	sqlite3 "github.com/mattn/go-sqlite3"
+)
+
+func (s *KViteTestSuite) TestSQLiteError() {
+	path := filepath.Join(s.TempDir, "garbage.db")
+	s.Require().NoError(os.WriteFile(path, []byte("this is not an SQLite database, just some text"), 0600))
+	_, err := Open(path, "testing")
+	s.True(errors.Is(err, ErrCorrupt), err)
+	s.False(errors.Is(err, ErrBusy))
+
+	var serr *SQLiteError
+	s.Require().True(errors.As(err, &serr))
+	s.Equal(int(sqlite3.ErrNotADB), serr.Code)
+	var driverErr sqlite3.Error
+	s.True(errors.As(err, &driverErr))
+
+	sqlDB, err := sql.Open("sqlite3", "file:"+filepath.Join(s.TempDir, "busy.db")+"?_busy_timeout=0")
+	s.Require().NoError(err)
+	defer func() { _ = sqlDB.Close() }()
+	db, err := OpenWithDB(sqlDB, "testing")
+	s.Require().NoError(err)
+
+	tx, err := db.BeginTx(context.Background(), TxOptions{Mode: TxImmediate})
+	s.Require().NoError(err)
+	defer func() { _ = tx.Rollback() }()
+	_, err = db.BeginTx(context.Background(), TxOptions{Mode: TxImmediate})
+	s.True(errors.Is(err, ErrBusy), err)
+	s.True(isBusy(err))
+}
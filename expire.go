@@ -0,0 +1,174 @@
+package kvite
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// createExpiresIndex indexes the keys that have an expiry time, so that sweeping them does not scan the table.
+// Tables created WithoutRowID are left without secondary indexes, and are scanned instead.
+func (db *DB) createExpiresIndex(tx *sql.Tx) error {
+	if clustered, err := isWithoutRowID(tx, db.table); err != nil || clustered {
+		return err
+	}
+	query := fmt.Sprintf("create INDEX IF NOT EXISTS '%s_kvite_expires_index' ON '%s' (expires) WHERE expires IS NOT NULL", db.table, db.table)
+	_, err := tx.Exec(query)
+	return err
+}
+
+// expiresFor returns the expiry time of a key written now to a bucket, as stored in the expires column.
+// Keys of buckets without a DefaultTTL do not expire.
+func (tx *Tx) expiresFor(bucket string) interface{} {
+	ttl := tx.BucketConfig(bucket).DefaultTTL
+	if ttl <= 0 {
+		return nil
+	}
+	return time.Now().Add(ttl).UnixNano()
+}
+
+// slideExpiry pushes back the expiry of a key that was read from a bucket with SlidingTTL, given the expiry it was
+// read with. Keys that do not expire, or have expired already, are left alone, and so are keys whose expiry was
+// pushed back less than a quarter of DefaultTTL ago, so that most reads of a busy key do not write.
+func (b *Bucket) slideExpiry(key string, expires int64) error {
+	cfg := b.tx.BucketConfig(b.name)
+	if !cfg.SlidingTTL || cfg.DefaultTTL <= 0 || b.tx.readOnly || b.tx.db.bucketAccess(b.name) != BucketReadWrite {
+		return nil
+	}
+	now := time.Now()
+	if expires == 0 || expires > now.Add(cfg.DefaultTTL-cfg.DefaultTTL/4).UnixNano() {
+		return nil
+	}
+	query := fmt.Sprintf("UPDATE '%s' SET expires = ? WHERE key = ? AND bucket = ? AND expires > ?", b.tx.db.table)
+	if _, err := b.tx.exec(query, now.Add(cfg.DefaultTTL).UnixNano(), key, b.name, now.UnixNano()); err != nil {
+		return err
+	}
+	b.tx.invalidateCache(cacheKey{bucket: b.name, key: key})
+	return nil
+}
+
+// Touch sets a key to expire ttl from now, or never if ttl is zero or negative, without rewriting its value. The
+// key keeps its version and no change is recorded in the change feed. The expiry applies until the key is written
+// again, when the bucket's DefaultTTL takes over. Touching a key that does not exist, or has expired, does nothing.
+func (b *Bucket) Touch(key string, ttl time.Duration) error {
+	if b.tx.readOnly {
+		return ErrTxReadOnly
+	}
+	if err := b.checkAccess("touch", true); err != nil {
+		return err
+	}
+	if err := b.tx.db.allowWrite(b.name); err != nil {
+		return err
+	}
+	now := time.Now()
+	var expires interface{}
+	if ttl > 0 {
+		expires = now.Add(ttl).UnixNano()
+	}
+	query := fmt.Sprintf("UPDATE '%s' SET expires = ? WHERE key = ? AND bucket = ? AND (expires IS NULL OR expires > ?)", b.tx.db.table)
+	if _, err := b.tx.exec(query, expires, key, b.name, now.UnixNano()); err != nil {
+		return err
+	}
+	b.tx.invalidateCache(cacheKey{bucket: b.name, key: key})
+	return nil
+}
+
+// expired reports whether a key with the given expires column has expired by now.
+func expired(expires sql.NullInt64, now time.Time) bool {
+	return expires.Valid && expires.Int64 <= now.UnixNano()
+}
+
+// dropExpired deletes keys that a read found to have expired. Reads never wait for the write lock to do so: the
+// keys are only deleted if the transaction already holds it, and are otherwise left to ExpireKeys.
+func (b *Bucket) dropExpired(keys ...string) error {
+	if len(keys) == 0 || !b.tx.wrote || b.tx.db.bucketAccess(b.name) != BucketReadWrite {
+		return nil
+	}
+	now := time.Now().UnixNano()
+	for _, key := range keys {
+		if _, err := b.deleteRows("expire", "bucket = ? AND key = ? AND expires <= ?", b.name, key, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetWithTTL retrieves the value of a key together with the time left until it expires, which is zero for keys
+// that do not expire. A key whose expiry time has passed is reported as missing, with a nil value.
+func (b *Bucket) GetWithTTL(key string) ([]byte, time.Duration, error) {
+	value, err := b.Get(key)
+	if err != nil || value == nil {
+		return nil, 0, err
+	}
+	var expires sql.NullInt64
+	query := fmt.Sprintf("SELECT expires FROM '%s' WHERE key = ? AND bucket = ?", b.tx.db.table)
+	if err := b.tx.queryRow(query, key, b.name).Scan(&expires); err != nil {
+		return nil, 0, sqliteError(err)
+	}
+	if !expires.Valid {
+		return value, 0, nil
+	}
+	ttl := time.Until(time.Unix(0, expires.Int64))
+	if ttl <= 0 {
+		return nil, 0, nil
+	}
+	return value, ttl, nil
+}
+
+// ExpireKeys deletes the keys whose expiry time has passed, as set by their bucket's DefaultTTL or by Touch, and
+// returns how many were deleted. Expired keys are recorded as ChangeExpire in the change feed and for watchers,
+// however they are deleted. Keys of buckets the handle may not write to are left alone.
+// Get, GetWithTTL, Has, ForEach and Query treat expired keys as missing whether or not they have been deleted, and
+// delete the ones they come across in transactions that have written already. Other expired keys take up space
+// until ExpireKeys removes them, so applications using expiry should still call it periodically.
+func (db *DB) ExpireKeys() (int64, error) {
+	if err := db.life.check(); err != nil {
+		return 0, err
+	}
+	var total int64
+	err := db.Transaction(func(tx *Tx) error {
+		total = 0
+		if err := tx.lockForWrite(); err != nil {
+			return err
+		}
+		now := time.Now().UnixNano()
+		buckets, err := tx.expiredBuckets(now)
+		if err != nil {
+			return err
+		}
+
+		for _, name := range buckets {
+			n, err := tx.newBucket(name).deleteRows("expire", "bucket = ? AND expires <= ?", name, now)
+			var aerr *BucketAccessError
+			if errors.As(err, &aerr) {
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			total += n
+		}
+		return nil
+	})
+	return total, err
+}
+
+// expiredBuckets returns the buckets that have keys which expired by now.
+func (tx *Tx) expiredBuckets(now int64) ([]string, error) {
+	rows, err := tx.query(fmt.Sprintf("SELECT DISTINCT bucket FROM '%s' WHERE expires <= ?", tx.db.table), now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var buckets []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		buckets = append(buckets, name)
+	}
+	return buckets, rows.Err()
+}
@@ -0,0 +1,154 @@
+package kvite
+
+import "time"
+
+func (s *KViteTestSuite) TestTouch() {
+	expires := func(key string) *int64 {
+		var expires *int64
+		s.NoError(s.DB.db.QueryRow("SELECT expires FROM 'testing' WHERE key = ?", key).Scan(&expires))
+		return expires
+	}
+
+	before := time.Now()
+	s.NoError(s.DB.Transaction(func(tx *Tx) error {
+		b, _ := tx.CreateBucket("test")
+		s.NoError(b.Put("short", []byte("1")))
+		s.NoError(b.Put("long", []byte("2")))
+		s.NoError(b.Touch("short", time.Minute))
+		s.NoError(b.Touch("long", time.Hour))
+		return b.Touch("missing", time.Millisecond)
+	}))
+	after := time.Now()
+	short := expires("short")
+	s.Require().NotNil(short)
+	s.True(*short >= before.Add(time.Minute).UnixNano() && *short <= after.Add(time.Minute).UnixNano())
+	_, version, err := s.getVersion("test", "short")
+	s.NoError(err)
+	s.Equal(int64(1), version, "touching does not write the value")
+
+	// Expired keys cannot be touched back to life
+	past := before.Add(-time.Second).UnixNano()
+	_, err = s.DB.db.Exec("UPDATE 'testing' SET expires = ? WHERE key = 'short'", past)
+	s.Require().NoError(err)
+	s.NoError(s.DB.Transaction(func(tx *Tx) error {
+		b, _ := tx.Bucket("test")
+		return b.Touch("short", time.Hour)
+	}))
+	s.Equal(past, *expires("short"))
+	n, err := s.DB.ExpireKeys()
+	s.NoError(err)
+	s.Equal(int64(1), n)
+
+	// A negative TTL makes a key never expire
+	s.NoError(s.DB.Transaction(func(tx *Tx) error {
+		b, _ := tx.Bucket("test")
+		return b.Touch("long", -1)
+	}))
+	s.Nil(expires("long"))
+}
+
+func (s *KViteTestSuite) getVersion(bucket, key string) ([]byte, int64, error) {
+	var (
+		value   []byte
+		version int64
+	)
+	err := s.DB.Transaction(func(tx *Tx) error {
+		b, err := tx.Bucket(bucket)
+		if err != nil {
+			return err
+		}
+		value, version, err = b.GetVersion(key)
+		return err
+	})
+	return value, version, err
+}
+
+func (s *KViteTestSuite) TestGetWithTTL() {
+	s.NoError(s.DB.Transaction(func(tx *Tx) error {
+		s.NoError(tx.SetBucketConfig("cache", BucketConfig{DefaultTTL: time.Hour}))
+		b, _ := tx.Bucket("cache")
+		s.NoError(b.Put("a", []byte("1")))
+		s.NoError(b.Put("gone", []byte("2")))
+		s.NoError(b.Touch("gone", time.Nanosecond))
+		b, _ = tx.CreateBucket("forever")
+		return b.Put("a", []byte("1"))
+	}))
+
+	s.NoError(s.DB.Transaction(func(tx *Tx) error {
+		b, _ := tx.Bucket("cache")
+		value, ttl, err := b.GetWithTTL("a")
+		s.NoError(err)
+		s.Equal([]byte("1"), value)
+		s.True(ttl > 59*time.Minute && ttl <= time.Hour, ttl)
+
+		value, ttl, err = b.GetWithTTL("gone")
+		s.NoError(err)
+		s.Nil(value)
+		s.Zero(ttl)
+
+		b, _ = tx.Bucket("forever")
+		value, ttl, err = b.GetWithTTL("a")
+		s.NoError(err)
+		s.Equal([]byte("1"), value)
+		s.Zero(ttl)
+		return nil
+	}))
+}
+
+func (s *KViteTestSuite) TestLazyExpiration() {
+	db := s.openDB("lazy.db", WithReadCache("*", 10))
+	defer func() { _ = db.Close() }()
+	s.NoError(db.Transaction(func(tx *Tx) error {
+		b, _ := tx.CreateBucket("test")
+		s.NoError(b.Put("a", []byte("1")))
+		s.NoError(b.Put("b", []byte("2")))
+		s.NoError(b.Put("c", []byte("3")))
+		s.NoError(b.Touch("b", 20*time.Millisecond))
+		return b.Touch("c", 20*time.Millisecond)
+	}))
+	s.NoError(db.Transaction(func(tx *Tx) error {
+		b, _ := tx.Bucket("test")
+		value, err := b.Get("b")
+		s.Equal([]byte("2"), value, "cached until it expires")
+		return err
+	}))
+	time.Sleep(25 * time.Millisecond)
+
+	// Expired keys read as missing without being deleted by read-only transactions
+	s.NoError(db.Transaction(func(tx *Tx) error {
+		b, _ := tx.Bucket("test")
+		value, err := b.Get("b")
+		s.NoError(err)
+		s.Nil(value)
+		ok, err := b.Has("c")
+		s.NoError(err)
+		s.False(ok)
+		keys := []string{}
+		s.NoError(b.ForEach(func(k string, v []byte) error {
+			keys = append(keys, k)
+			return nil
+		}))
+		s.Equal([]string{"a"}, keys)
+		n, err := b.Query().Count()
+		s.NoError(err)
+		s.Equal(1, n)
+		return nil
+	}))
+	var rows int
+	s.NoError(db.db.QueryRow("SELECT count(*) FROM 'testing'").Scan(&rows))
+	s.Equal(3, rows)
+
+	// Transactions that have written delete the expired keys they read
+	s.NoError(db.Transaction(func(tx *Tx) error {
+		b, _ := tx.Bucket("test")
+		s.NoError(b.Put("d", []byte("4")))
+		value, err := b.Get("b")
+		s.Nil(value)
+		return err
+	}))
+	s.NoError(db.db.QueryRow("SELECT count(*) FROM 'testing'").Scan(&rows))
+	s.Equal(3, rows)
+	n, err := db.ExpireKeys()
+	s.NoError(err)
+	s.Equal(int64(1), n)
+}
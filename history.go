@@ -0,0 +1,75 @@
+package kvite
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+func (db *DB) historyTable() string {
+	return db.table + "_kvite_history"
+}
+
+// createHistoryTable creates the table that keeps the replaced values of buckets with versioning enabled.
+func (db *DB) createHistoryTable(tx *sql.Tx) error {
+	query := fmt.Sprintf("create TABLE IF NOT EXISTS '%s' (bucket text not null, key text not null, version integer not null, value blob not null, PRIMARY KEY (bucket, key, version)) WITHOUT ROWID", db.historyTable())
+	_, err := tx.Exec(query)
+	return err
+}
+
+// initHistoryQuery prepares the statement that copies a row into the history table before it is replaced.
+// Deduplicated values are copied out of the values table, so that history does not hold references to it.
+func (db *DB) initHistoryQuery() error {
+	exists, err := tableExists(db.db, db.historyTable())
+	if err != nil {
+		return err
+	}
+	db.history = exists
+	if !exists {
+		return nil
+	}
+
+	t, h := db.table, db.historyTable()
+	if !db.dedup {
+		db.historyQuery = fmt.Sprintf("INSERT OR REPLACE INTO '%s' (bucket, key, version, value) SELECT bucket, key, version, value FROM '%s' WHERE key = ? AND bucket = ?", h, t)
+		return nil
+	}
+	db.historyQuery = fmt.Sprintf(`INSERT OR REPLACE INTO '%s' (bucket, key, version, value) SELECT t.bucket, t.key, t.version, coalesce(v.value, t.value)
+		FROM '%s' t LEFT JOIN '%s' v ON v.hash = t.value_ref WHERE t.key = ? AND t.bucket = ?`, h, t, db.valuesTable())
+	return nil
+}
+
+// saveHistory keeps the current value of a key before it is overwritten or deleted, if its bucket has versioning
+// enabled.
+func (tx *Tx) saveHistory(bucket, key string) error {
+	if !tx.db.history || !tx.BucketConfig(bucket).Versioning {
+		return nil
+	}
+	_, err := tx.exec(tx.db.historyQuery, key, bucket)
+	return err
+}
+
+// GetAt returns the value a key had at a version, as reported by GetVersion. Earlier versions are only kept while
+// the bucket has versioning enabled in its BucketConfig. It returns a nil value if the version is neither the
+// current one nor kept.
+func (b *Bucket) GetAt(key string, version int64) ([]byte, error) {
+	current, err := b.version(key)
+	if err != nil {
+		return nil, err
+	}
+	if current == version {
+		return b.Get(key)
+	}
+	if !b.tx.db.history {
+		return nil, nil
+	}
+
+	var value []byte
+	query := fmt.Sprintf("SELECT value FROM '%s' WHERE bucket = ? AND key = ? AND version = ?", b.tx.db.historyTable())
+	if err := b.tx.queryRow(query, b.name, key, version).Scan(&value); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, sqliteError(err)
+	}
+	return b.scanValue(key, value, sql.NullInt64{})
+}
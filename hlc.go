@@ -0,0 +1,162 @@
+package kvite
+
+import (
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Timestamp is a hybrid logical clock reading. The high 48 bits hold wall clock milliseconds and the low 16 bits a
+// logical counter that orders events within the same millisecond. Timestamps issued by one database always increase,
+// and a database that applies a remote change never issues a timestamp lower than that change's.
+type Timestamp uint64
+
+func newTimestamp(wall time.Time, logical uint16) Timestamp {
+	return Timestamp(uint64(wall.UnixNano()/int64(time.Millisecond))<<16 | uint64(logical))
+}
+
+// Time returns the wall clock component of the timestamp.
+func (t Timestamp) Time() time.Time {
+	return time.Unix(0, int64(t>>16)*int64(time.Millisecond))
+}
+
+// Logical returns the logical counter component of the timestamp.
+func (t Timestamp) Logical() uint16 {
+	return uint16(t)
+}
+
+func (t Timestamp) String() string {
+	return fmt.Sprintf("%s.%d", t.Time().UTC().Format(time.RFC3339Nano), t.Logical())
+}
+
+// Version is the last-writer-wins metadata of a key: when it was written and by which database.
+type Version struct {
+	Timestamp Timestamp
+	// Origin is the NodeID of the database where the write was made.
+	Origin string
+}
+
+// Less reports whether v was written before o. Versions with equal timestamps are ordered by origin, so any two
+// writes from different databases have a deterministic winner.
+func (v Version) Less(o Version) bool {
+	if v.Timestamp != o.Timestamp {
+		return v.Timestamp < o.Timestamp
+	}
+	return v.Origin < o.Origin
+}
+
+// WithTimestamps maintains a hybrid logical clock timestamp and origin for every key written through Put, and
+// carries them through the change feed and Sync. Use Bucket.Version to read them and LastWriterWins to resolve
+// conflicts with them.
+func WithTimestamps() Option {
+	return func(db *DB) error {
+		db.clock = &hlc{}
+		return nil
+	}
+}
+
+// hlc is a hybrid logical clock.
+type hlc struct {
+	mu   sync.Mutex
+	last Timestamp
+}
+
+// now returns a timestamp greater than any previously issued or observed.
+func (c *hlc) now() Timestamp {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ts := newTimestamp(time.Now(), 0)
+	if ts <= c.last {
+		ts = c.last + 1
+	}
+	c.last = ts
+	return ts
+}
+
+// observe advances the clock past a timestamp received from another database.
+func (c *hlc) observe(ts Timestamp) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if ts > c.last {
+		c.last = ts
+	}
+}
+
+// initClock starts the clock after the highest timestamp already stored, so that timestamps keep increasing across
+// restarts even if the wall clock has gone backwards.
+func (db *DB) initClock() error {
+	tables := []string{db.table}
+	if db.changeFeed {
+		tables = append(tables, db.changesTable())
+	}
+	for _, table := range tables {
+		var max sql.NullInt64
+		if err := db.db.QueryRow(fmt.Sprintf("SELECT max(hlc) FROM '%s'", table)).Scan(&max); err != nil {
+			return err
+		}
+		if max.Valid {
+			db.clock.observe(Timestamp(max.Int64))
+		}
+	}
+	return nil
+}
+
+// stamp fills in the origin and, if timestamps are enabled, the timestamp of a local change. Changes that already
+// carry a timestamp come from another database and advance the clock instead.
+func (tx *Tx) stamp(c *Change) {
+	if c.Origin == "" {
+		c.Origin = tx.db.nodeID
+	}
+	if tx.db.clock == nil {
+		return
+	}
+	if c.Timestamp == 0 {
+		c.Timestamp = tx.db.clock.now()
+	} else {
+		tx.db.clock.observe(c.Timestamp)
+	}
+}
+
+// issueTimestamp gives a local change a timestamp from the clock, if timestamps are enabled and it has none yet,
+// and reports whether it did.
+func (db *DB) issueTimestamp(c *Change) bool {
+	if db.clock == nil || c.Timestamp != 0 {
+		return false
+	}
+	c.Timestamp = db.clock.now()
+	return true
+}
+
+// versionColumns returns the values to store in the hlc and origin columns for a change.
+func (db *DB) versionColumns(c *Change) (interface{}, interface{}) {
+	if db.clock == nil || c.Timestamp == 0 {
+		return nil, nil
+	}
+	if c.Origin == "" {
+		return int64(c.Timestamp), db.nodeID
+	}
+	return int64(c.Timestamp), c.Origin
+}
+
+// Version returns the last-writer-wins metadata of a key. It returns the zero Version if the key does not exist or
+// was written without WithTimestamps.
+func (b *Bucket) Version(key string) (Version, error) {
+	var (
+		ts     sql.NullInt64
+		origin sql.NullString
+	)
+	if err := b.checkAccess("version", false); err != nil {
+		return Version{}, err
+	}
+	err := b.tx.queryRow(b.tx.db.versionQuery, key, b.name).Scan(&ts, &origin)
+	if err == sql.ErrNoRows {
+		return Version{}, nil
+	}
+	if err != nil || !ts.Valid {
+		return Version{}, err
+	}
+	return Version{Timestamp: Timestamp(ts.Int64), Origin: origin.String}, nil
+}
@@ -0,0 +1,75 @@
+package kvite
+
+func (s *KViteTestSuite) TestVersion() {
+	db := s.openDB("hlc.db", WithTimestamps())
+
+	var first, second Version
+	s.Require().NoError(db.Transaction(func(tx *Tx) error {
+		b, _ := tx.CreateBucket("test")
+		_ = b.Put("foo", []byte("bar"))
+		var err error
+		first, err = b.Version("foo")
+		s.NoError(err)
+		_ = b.Put("foo", []byte("baz"))
+		second, err = b.Version("foo")
+		s.NoError(err)
+
+		missing, err := b.Version("missing")
+		s.NoError(err)
+		s.Equal(Version{}, missing)
+		return err
+	}))
+	s.NotZero(first.Timestamp)
+	s.Equal(db.NodeID(), first.Origin)
+	s.True(first.Less(second))
+
+	// The clock resumes after the highest stored timestamp
+	s.Require().NoError(db.Close())
+	db = s.openDB("hlc.db", WithTimestamps())
+	defer func() { _ = db.Close() }()
+	s.True(second.Timestamp < db.clock.now())
+
+	// Without WithTimestamps no metadata is kept
+	s.Require().NoError(s.DB.Transaction(func(tx *Tx) error {
+		b, _ := tx.CreateBucket("test")
+		_ = b.Put("foo", []byte("bar"))
+		v, err := b.Version("foo")
+		s.Equal(Version{}, v)
+		return err
+	}))
+}
+
+func (s *KViteTestSuite) TestSyncLastWriterWins() {
+	a := s.openDB("lww-a.db", WithChangeFeed(), WithTimestamps())
+	defer func() { _ = a.Close() }()
+	b := s.openDB("lww-b.db", WithChangeFeed(), WithTimestamps())
+	defer func() { _ = b.Close() }()
+
+	put := func(db *DB, value string) {
+		s.Require().NoError(db.Transaction(func(tx *Tx) error {
+			bucket, _ := tx.CreateBucket("test")
+			return bucket.Put("key", []byte(value))
+		}))
+	}
+	put(b, "older")
+	// a's write comes after b's in HLC order, however little wall clock time has passed
+	a.clock.observe(b.clock.now())
+	put(a, "newer")
+
+	s.NoError(Sync(b, a, LastWriterWins))
+	for _, db := range []*DB{a, b} {
+		s.NoError(db.Transaction(func(tx *Tx) error {
+			bucket, _ := tx.CreateBucketIfNotExists("test")
+			value, _ := bucket.Get("key")
+			s.Equal("newer", string(value))
+			v, err := bucket.Version("key")
+			s.Equal(a.NodeID(), v.Origin)
+			return err
+		}))
+	}
+
+	// Applying the remote change moved b's clock past it
+	changes, err := a.Changes(0)
+	s.NoError(err)
+	s.True(changes[len(changes)-1].Timestamp < b.clock.now())
+}
@@ -0,0 +1,42 @@
+package kvite
+
+import "errors"
+
+// Op describes a bucket operation passed to a Hook.
+type Op struct {
+	// Name is the operation: "get", "put", "delete" or "foreach".
+	Name   string
+	Bucket string
+	// Key is the key being read, written or deleted. It is empty for "foreach".
+	Key string
+	// Value is the value being written by "put", and nil for other operations. Hooks must not modify it.
+	Value []byte
+}
+
+// Hook intercepts bucket operations. It is called with the operation and a function that performs it, and must
+// call next to let the operation run, returning its error or one of its own. A hook that returns without calling
+// next skips the operation, so hooks can also validate what is written.
+type Hook func(op Op, next func() error) error
+
+// WithHook adds a hook around Get, Put, Delete and ForEach on every bucket, for logging, metrics, tracing or
+// validation. Hooks run in the order they were added, the first one outermost. Operations made by kvite itself
+// through these methods, such as the writes of Update or Rewrite, go through the hooks too.
+func WithHook(h Hook) Option {
+	return func(db *DB) error {
+		if h == nil {
+			return errors.New("hook must not be nil")
+		}
+		db.hooks = append(db.hooks, h)
+		return nil
+	}
+}
+
+// intercept runs fn through the hooks of the database.
+func (b *Bucket) intercept(op Op, fn func() error) error {
+	hooks := b.tx.db.hooks
+	for i := len(hooks) - 1; i >= 0; i-- {
+		h, next := hooks[i], fn
+		fn = func() error { return h(op, next) }
+	}
+	return fn()
+}
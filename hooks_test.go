@@ -0,0 +1,60 @@
+package kvite
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+)
+
+func (s *KViteTestSuite) TestHooks() {
+	var calls []string
+	record := func(name string) Hook {
+		return func(op Op, next func() error) error {
+			calls = append(calls, fmt.Sprintf("%s %s %s/%s %q", name, op.Name, op.Bucket, op.Key, op.Value))
+			err := next()
+			calls = append(calls, fmt.Sprintf("%s done %v", name, err))
+			return err
+		}
+	}
+	errInvalid := errors.New("invalid")
+	validate := func(op Op, next func() error) error {
+		if op.Name == "put" && len(op.Value) == 0 {
+			return errInvalid
+		}
+		return next()
+	}
+
+	db := s.openDB("hooks.db", WithHook(record("outer")), WithHook(record("inner")), WithHook(validate))
+	defer func() { _ = db.Close() }()
+
+	s.NoError(db.Transaction(func(tx *Tx) error {
+		b := tx.newBucket("test")
+		if err := b.Put("foo", []byte("bar")); err != nil {
+			return err
+		}
+		if _, err := b.Get("foo"); err != nil {
+			return err
+		}
+		if err := b.ForEach(func(k string, v []byte) error { return nil }); err != nil {
+			return err
+		}
+		return b.Delete("foo")
+	}))
+	s.Equal([]string{
+		`outer put test/foo "bar"`, `inner put test/foo "bar"`, "inner done <nil>", "outer done <nil>",
+		`outer get test/foo ""`, `inner get test/foo ""`, "inner done <nil>", "outer done <nil>",
+		`outer foreach test/ ""`, `inner foreach test/ ""`, "inner done <nil>", "outer done <nil>",
+		`outer delete test/foo ""`, `inner delete test/foo ""`, "inner done <nil>", "outer done <nil>",
+	}, calls)
+
+	calls = nil
+	err := db.Transaction(func(tx *Tx) error {
+		return tx.newBucket("test").Put("empty", nil)
+	})
+	s.Equal(errInvalid, err)
+	s.Equal([]string{`outer put test/empty ""`, `inner put test/empty ""`, "inner done invalid", "outer done invalid"}, calls)
+	s.Equal(0, s.countKeys(db, "test"))
+
+	_, err = Open(filepath.Join(s.TempDir, "nil-hook.db"), "testing", WithHook(nil))
+	s.Error(err)
+}
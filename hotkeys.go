@@ -0,0 +1,117 @@
+package kvite
+
+import (
+	"errors"
+	"sort"
+	"sync"
+)
+
+// WithHotKeyTracking counts reads and writes per key in memory so that HotKeys can report the busiest keys.
+// Each bucket tracks at most capacity keys. When a bucket is full, a newly seen key replaces the least accessed one
+// and inherits its count, so keys that are truly hot stay tracked while counts for the rest are overestimates.
+// Reads are counted by Get and writes by Put and Delete, whether or not their transaction commits.
+func WithHotKeyTracking(capacity int) Option {
+	return func(db *DB) error {
+		if capacity <= 0 {
+			return errors.New("hot key capacity must be positive")
+		}
+		db.hot = &hotKeys{capacity: capacity, buckets: make(map[string]map[string]*KeyStat)}
+		return nil
+	}
+}
+
+// KeyStat is the access count of one key, as reported by HotKeys.
+type KeyStat struct {
+	Bucket string
+	Key    string
+	Reads  int64
+	Writes int64
+}
+
+// hotKeys holds the access counts of a database. It is shared by handles returned from Restrict.
+type hotKeys struct {
+	capacity int
+
+	mu      sync.Mutex
+	buckets map[string]map[string]*KeyStat
+}
+
+func (h *hotKeys) record(bucket, key string, write bool) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	keys := h.buckets[bucket]
+	if keys == nil {
+		keys = make(map[string]*KeyStat)
+		h.buckets[bucket] = keys
+	}
+
+	stat := keys[key]
+	if stat == nil {
+		stat = &KeyStat{Bucket: bucket, Key: key}
+		if len(keys) >= h.capacity {
+			var min *KeyStat
+			for _, s := range keys {
+				if min == nil || s.Reads+s.Writes < min.Reads+min.Writes {
+					min = s
+				}
+			}
+			delete(keys, min.Key)
+			stat.Reads, stat.Writes = min.Reads, min.Writes
+		}
+		keys[key] = stat
+	}
+
+	if write {
+		stat.Writes++
+	} else {
+		stat.Reads++
+	}
+}
+
+// HotKeys returns up to n of the most accessed keys in bucket, or in every bucket if bucket is empty, busiest
+// first. It returns nil unless the database was opened with WithHotKeyTracking.
+func (db *DB) HotKeys(bucket string, n int) []KeyStat {
+	h := db.hot
+	if h == nil {
+		return nil
+	}
+	h.mu.Lock()
+	var stats []KeyStat
+	for name, keys := range h.buckets {
+		if (bucket != "" && name != bucket) || db.bucketAccess(name) == BucketRestricted {
+			continue
+		}
+		for _, s := range keys {
+			stats = append(stats, *s)
+		}
+	}
+	h.mu.Unlock()
+
+	sort.Slice(stats, func(i, j int) bool {
+		a, b := stats[i], stats[j]
+		if a.Reads+a.Writes != b.Reads+b.Writes {
+			return a.Reads+a.Writes > b.Reads+b.Writes
+		}
+		if a.Bucket != b.Bucket {
+			return a.Bucket < b.Bucket
+		}
+		return a.Key < b.Key
+	})
+	if len(stats) > n {
+		stats = stats[:n]
+	}
+	return stats
+}
+
+// ResetHotKeys discards the access counts collected so far.
+func (db *DB) ResetHotKeys() {
+	if h := db.hot; h != nil {
+		h.mu.Lock()
+		h.buckets = make(map[string]map[string]*KeyStat)
+		h.mu.Unlock()
+	}
+}
@@ -0,0 +1,49 @@
+package kvite
+
+import "path/filepath"
+
+func (s *KViteTestSuite) TestHotKeys() {
+	s.Nil(s.DB.HotKeys("", 10))
+
+	db := s.openDB("hot.db", WithHotKeyTracking(2))
+	defer func() { _ = db.Close() }()
+
+	s.NoError(db.Transaction(func(tx *Tx) error {
+		b, _ := tx.CreateBucketIfNotExists("a")
+		for i := 0; i < 3; i++ {
+			if err := b.Put("hot", []byte("x")); err != nil {
+				return err
+			}
+			if _, err := b.Get("hot"); err != nil {
+				return err
+			}
+		}
+		if err := b.Put("warm", []byte("x")); err != nil {
+			return err
+		}
+		if err := b.Put("warm", []byte("x")); err != nil {
+			return err
+		}
+		// The bucket is full, so cold replaces warm and inherits its count.
+		if err := b.Delete("cold"); err != nil {
+			return err
+		}
+		other, _ := tx.CreateBucketIfNotExists("b")
+		_, err := other.Get("k")
+		return err
+	}))
+
+	s.Equal([]KeyStat{
+		{Bucket: "a", Key: "hot", Reads: 3, Writes: 3},
+		{Bucket: "a", Key: "cold", Writes: 3},
+		{Bucket: "b", Key: "k", Reads: 1},
+	}, db.HotKeys("", 10))
+	s.Equal([]KeyStat{{Bucket: "b", Key: "k", Reads: 1}}, db.HotKeys("b", 10))
+	s.Len(db.HotKeys("", 1), 1)
+
+	db.ResetHotKeys()
+	s.Empty(db.HotKeys("", 10))
+
+	_, err := Open(filepath.Join(s.TempDir, "bad.db"), "testing", WithHotKeyTracking(0))
+	s.Error(err)
+}
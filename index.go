@@ -0,0 +1,93 @@
+package kvite
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// IndexLayout selects the indexes kvite builds on its table. It has no effect on WithoutRowID tables, which are
+// clustered on (bucket, key) and carry no separate index.
+type IndexLayout int
+
+// Index layouts.
+const (
+	// IndexKeyFirst builds a unique index on (key, bucket). It is the default, and suits workloads dominated by
+	// point lookups.
+	IndexKeyFirst IndexLayout = iota + 1
+	// IndexBucketFirst builds the unique index on (bucket, key), so that ForEach and Buckets read one contiguous
+	// range of the index instead of scanning the table.
+	IndexBucketFirst
+	// IndexCovering adds a second index on (bucket, key, value, checksum, expires) to IndexBucketFirst, so that
+	// ForEach is answered from the index alone without visiting the table, at the cost of storing every value twice.
+	IndexCovering
+)
+
+// WithIndexLayout selects the index layout. If the table exists with a different layout, its indexes are rebuilt
+// when it is opened. Without this option an existing table keeps its layout and a new one uses IndexKeyFirst.
+func WithIndexLayout(layout IndexLayout) Option {
+	return func(db *DB) error {
+		if layout < IndexKeyFirst || layout > IndexCovering {
+			return fmt.Errorf("invalid index layout %d", int(layout))
+		}
+		db.indexLayout = layout
+		return nil
+	}
+}
+
+// coveringColumns are the columns of the IndexCovering index: those read by ForEach.
+const coveringColumns = "bucket, key, value, checksum, expires"
+
+func (db *DB) createIndexes(tx *sql.Tx) error {
+	columns := "key, bucket"
+	if db.indexLayout != IndexKeyFirst {
+		columns = "bucket, key"
+	}
+	query := fmt.Sprintf("create UNIQUE INDEX IF NOT EXISTS '%s_kvite_key_index' ON '%s' (%s)", db.table, db.table, columns)
+	if _, err := tx.Exec(query); err != nil {
+		return err
+	}
+
+	if db.indexLayout == IndexCovering {
+		query := fmt.Sprintf("create INDEX IF NOT EXISTS '%s_kvite_covering_index' ON '%s' (%s)", db.table, db.table, coveringColumns)
+		_, err := tx.Exec(query)
+		return err
+	}
+	return nil
+}
+
+// coveringIndexOutdated reports whether the table has a covering index that lacks some of coveringColumns, having
+// been built by an earlier release.
+func (db *DB) coveringIndexOutdated(tx *sql.Tx) (bool, error) {
+	var def string
+	err := tx.QueryRow("SELECT sql FROM sqlite_master WHERE type = 'index' AND name = ?", db.table+"_kvite_covering_index").Scan(&def)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil && !strings.Contains(def, coveringColumns), err
+}
+
+// storedIndexLayout returns the index layout recorded in the meta table. Tables created before layouts were
+// recorded have the IndexKeyFirst layout.
+func (db *DB) storedIndexLayout(tx *sql.Tx) (IndexLayout, error) {
+	value, err := getMeta(tx, db.metaTable(), "index_layout")
+	if err != nil || value == "" {
+		return IndexKeyFirst, err
+	}
+	n, err := strconv.Atoi(value)
+	return IndexLayout(n), err
+}
+
+// rebuildIndexes replaces the indexes of an existing table with those of the selected layout.
+func (db *DB) rebuildIndexes(tx *sql.Tx) error {
+	if clustered, err := isWithoutRowID(tx, db.table); err != nil || clustered {
+		return err
+	}
+	for _, name := range []string{"key_index", "covering_index"} {
+		if _, err := tx.Exec(fmt.Sprintf("DROP INDEX IF EXISTS '%s_kvite_%s'", db.table, name)); err != nil {
+			return err
+		}
+	}
+	return db.createIndexes(tx)
+}
@@ -0,0 +1,53 @@
+package kvite
+
+func (s *KViteTestSuite) TestIndexLayout() {
+	indexDef := func(db *DB, name string) string {
+		var def string
+		err := db.db.QueryRow("SELECT sql FROM sqlite_master WHERE type = 'index' AND name = ?", "testing_kvite_"+name).Scan(&def)
+		if err != nil {
+			return ""
+		}
+		return def
+	}
+	plan := func(db *DB, query string, args ...interface{}) string {
+		rows, err := db.db.Query("EXPLAIN QUERY PLAN "+query, args...)
+		s.Require().NoError(err)
+		defer rows.Close()
+		var detail string
+		for rows.Next() {
+			var id, parent, notused int
+			s.Require().NoError(rows.Scan(&id, &parent, &notused, &detail))
+		}
+		return detail
+	}
+
+	_, err := Open(s.TempDir+"/bad.db", "testing", WithIndexLayout(IndexLayout(42)))
+	s.Error(err)
+
+	db := s.openDB("layout.db")
+	s.putN(db, "test", 0, 10)
+	s.Contains(indexDef(db, "key_index"), "(key, bucket)")
+	s.NoError(db.Close())
+
+	// Changing the layout rebuilds the indexes
+	db = s.openDB("layout.db", WithIndexLayout(IndexBucketFirst))
+	s.Contains(indexDef(db, "key_index"), "(bucket, key)")
+	s.Contains(plan(db, db.foreachQuery, "test"), "testing_kvite_key_index")
+	s.NoError(db.Close())
+
+	// Without the option the stored layout is kept
+	db = s.openDB("layout.db")
+	s.Contains(indexDef(db, "key_index"), "(bucket, key)")
+	s.NoError(db.Close())
+
+	db = s.openDB("layout.db", WithIndexLayout(IndexCovering))
+	s.Contains(plan(db, db.foreachQuery, "test"), "COVERING INDEX testing_kvite_covering_index")
+	s.Equal(10, s.countKeys(db, "test"))
+	s.NoError(db.Close())
+
+	db = s.openDB("layout.db", WithIndexLayout(IndexKeyFirst))
+	defer func() { _ = db.Close() }()
+	s.Contains(indexDef(db, "key_index"), "(key, bucket)")
+	s.Equal("", indexDef(db, "covering_index"))
+	s.Equal(10, s.countKeys(db, "test"))
+}
@@ -0,0 +1,290 @@
+// Package conformance is a test suite for implementations of kv.Store, which checks that the in-memory
+// implementation behaves like the SQLite one.
+package conformance
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/mistifyio/kvite/kv"
+	"github.com/stretchr/testify/suite"
+)
+
+// Run runs the suite against databases returned by open. Each test gets a new, empty database.
+func Run(t *testing.T, open func() (kv.Store, error)) {
+	suite.Run(t, &conformanceSuite{open: open})
+}
+
+type conformanceSuite struct {
+	suite.Suite
+	open func() (kv.Store, error)
+	db   kv.Store
+}
+
+func (s *conformanceSuite) SetupTest() {
+	db, err := s.open()
+	s.Require().NoError(err)
+	s.db = db
+}
+
+func (s *conformanceSuite) TearDownTest() {
+	s.NoError(s.db.Close())
+}
+
+// get reads a key in a transaction of its own. It returns nil if the bucket does not exist.
+func (s *conformanceSuite) get(bucket, key string) []byte {
+	var value []byte
+	s.NoError(s.db.Transaction(func(tx kv.Transactor) error {
+		b, err := tx.Bucket(bucket)
+		if errors.Is(err, kv.ErrBucketNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		value, err = b.Get(key)
+		return err
+	}))
+	return value
+}
+
+func (s *conformanceSuite) put(tx kv.Transactor, bucket, key, value string) {
+	b, err := tx.CreateBucket(bucket)
+	s.Require().NoError(err)
+	s.Require().NoError(b.Put(key, []byte(value)))
+}
+
+func (s *conformanceSuite) TestBucketOperations() {
+	s.NoError(s.db.Transaction(func(tx kv.Transactor) error {
+		b, err := tx.CreateBucket("test")
+		s.Require().NoError(err)
+
+		value, err := b.Get("missing")
+		s.NoError(err)
+		s.Nil(value)
+		ok, err := b.Has("missing")
+		s.NoError(err)
+		s.False(ok)
+		s.NoError(b.Delete("missing"))
+
+		s.NoError(b.Put("a", []byte("1")))
+		s.NoError(b.Put("b", nil))
+		s.NoError(b.Put("c", []byte("3")))
+		s.NoError(b.Put("c", []byte("33")))
+
+		value, err = b.Get("b")
+		s.NoError(err)
+		s.Equal([]byte{}, value)
+		ok, err = b.Has("b")
+		s.NoError(err)
+		s.True(ok)
+
+		s.NoError(b.Update("a", func(old []byte) ([]byte, error) {
+			return append(old, '!'), nil
+		}))
+		s.NoError(b.Update("new", func(old []byte) ([]byte, error) {
+			s.Nil(old)
+			return nil, nil
+		}))
+		s.NoError(b.Delete("b"))
+
+		seen := map[string]string{}
+		s.NoError(b.ForEach(func(k string, v []byte) error {
+			seen[k] = string(v)
+			return nil
+		}))
+		s.Equal(map[string]string{"a": "1!", "c": "33"}, seen)
+
+		errStop := errors.New("stop")
+		s.Equal(errStop, b.ForEach(func(k string, v []byte) error { return errStop }))
+		return nil
+	}))
+	s.Equal([]byte("1!"), s.get("test", "a"))
+}
+
+func (s *conformanceSuite) TestValuesAreCopied() {
+	value := []byte("value")
+	s.NoError(s.db.Transaction(func(tx kv.Transactor) error {
+		b, err := tx.CreateBucket("test")
+		if err != nil {
+			return err
+		}
+		return b.Put("k", value)
+	}))
+	value[0] = 'X'
+
+	got := s.get("test", "k")
+	s.Equal([]byte("value"), got)
+	got[0] = 'X'
+	s.Equal([]byte("value"), s.get("test", "k"))
+}
+
+func (s *conformanceSuite) TestRollback() {
+	tx, err := s.db.Begin()
+	s.Require().NoError(err)
+	s.put(tx, "test", "k", "v")
+	s.NoError(tx.Rollback())
+	s.Error(tx.Rollback())
+	s.Error(tx.Commit())
+	s.Nil(s.get("test", "k"))
+
+	errFailed := errors.New("failed")
+	s.Equal(errFailed, s.db.Transaction(func(tx kv.Transactor) error {
+		s.put(tx, "test", "k", "v")
+		return errFailed
+	}))
+	s.Nil(s.get("test", "k"))
+}
+
+func (s *conformanceSuite) TestManagedTransaction() {
+	s.NoError(s.db.Transaction(func(tx kv.Transactor) error {
+		s.Error(tx.Commit())
+		s.Error(tx.Rollback())
+		s.put(tx, "test", "k", "v")
+		return nil
+	}))
+	s.Equal([]byte("v"), s.get("test", "k"))
+}
+
+func (s *conformanceSuite) TestCommit() {
+	tx, err := s.db.Begin()
+	s.Require().NoError(err)
+	s.put(tx, "test", "k", "v")
+	s.NoError(tx.Commit())
+	s.Error(tx.Commit())
+	s.Equal([]byte("v"), s.get("test", "k"))
+}
+
+func (s *conformanceSuite) TestIsolation() {
+	tx, err := s.db.Begin()
+	s.Require().NoError(err)
+	s.put(tx, "test", "k", "uncommitted")
+
+	// The write is visible within the transaction but not outside it until it commits.
+	b, _ := tx.Bucket("test")
+	value, err := b.Get("k")
+	s.NoError(err)
+	s.Equal([]byte("uncommitted"), value)
+	s.Nil(s.get("test", "k"))
+
+	s.NoError(tx.Commit())
+	s.Equal([]byte("uncommitted"), s.get("test", "k"))
+}
+
+func (s *conformanceSuite) TestBuckets() {
+	s.NoError(s.db.Transaction(func(tx kv.Transactor) error {
+		for i := 0; i < 3; i++ {
+			s.put(tx, fmt.Sprint("bucket", i), "k", "v")
+		}
+		_, err := tx.CreateBucket("empty")
+		return err
+	}))
+	buckets, err := s.db.Buckets()
+	s.NoError(err)
+	s.ElementsMatch([]string{"bucket0", "bucket1", "bucket2", "empty"}, buckets)
+
+	// Created buckets remain once their last key is deleted.
+	s.NoError(s.db.Transaction(func(tx kv.Transactor) error {
+		b, err := tx.Bucket("bucket1")
+		if err != nil {
+			return err
+		}
+		return b.Delete("k")
+	}))
+	buckets, err = s.db.Buckets()
+	s.NoError(err)
+	s.ElementsMatch([]string{"bucket0", "bucket1", "bucket2", "empty"}, buckets)
+}
+
+func (s *conformanceSuite) TestMissingBucket() {
+	s.NoError(s.db.Transaction(func(tx kv.Transactor) error {
+		b, err := tx.Bucket("missing")
+		s.True(errors.Is(err, kv.ErrBucketNotFound))
+		s.Nil(b)
+
+		_, err = tx.CreateBucket("missing")
+		s.NoError(err)
+		_, err = tx.Bucket("missing")
+		return err
+	}))
+
+	// A bucket created by a transaction that rolled back does not exist.
+	s.Error(s.db.Transaction(func(tx kv.Transactor) error {
+		_, err := tx.CreateBucket("rolled back")
+		s.NoError(err)
+		return errors.New("failed")
+	}))
+	s.NoError(s.db.Transaction(func(tx kv.Transactor) error {
+		_, err := tx.Bucket("rolled back")
+		s.True(errors.Is(err, kv.ErrBucketNotFound))
+		return nil
+	}))
+}
+
+func (s *conformanceSuite) TestFinishedTransaction() {
+	tx, err := s.db.Begin()
+	s.Require().NoError(err)
+	s.NoError(tx.Commit())
+	s.True(errors.Is(tx.Commit(), kv.ErrTxFinished))
+	s.True(errors.Is(tx.Rollback(), kv.ErrTxFinished))
+
+	tx, err = s.db.Begin()
+	s.Require().NoError(err)
+	s.NoError(tx.Rollback())
+	s.True(errors.Is(tx.Rollback(), kv.ErrTxFinished))
+	s.True(errors.Is(tx.Commit(), kv.ErrTxFinished))
+}
+
+func (s *conformanceSuite) TestPanic() {
+	err := s.db.Transaction(func(tx kv.Transactor) error {
+		s.put(tx, "test", "k", "v")
+		panic("boom")
+	})
+	var perr *kv.PanicError
+	s.Require().True(errors.As(err, &perr))
+	s.Equal("boom", perr.Value)
+	s.NotEmpty(perr.Stack)
+	s.Nil(s.get("test", "k"))
+
+	errBoom := errors.New("boom")
+	err = s.db.Transaction(func(tx kv.Transactor) error { panic(errBoom) })
+	s.True(errors.As(err, &perr))
+	s.True(errors.Is(err, errBoom))
+}
+
+func (s *conformanceSuite) TestConflict() {
+	s.NoError(s.db.Transaction(func(tx kv.Transactor) error {
+		s.put(tx, "test", "k", "old")
+		return nil
+	}))
+
+	// The reader sees the data as it was when it first read, so writing after another transaction has committed
+	// would overwrite a change it has not seen.
+	reader, err := s.db.Begin()
+	s.Require().NoError(err)
+	b, err := reader.Bucket("test")
+	s.Require().NoError(err)
+	value, err := b.Get("k")
+	s.NoError(err)
+	s.Equal([]byte("old"), value)
+
+	s.NoError(s.db.Transaction(func(tx kv.Transactor) error {
+		s.put(tx, "test", "k", "new")
+		return nil
+	}))
+	s.True(errors.Is(b.Put("k", []byte("stale")), kv.ErrBusy))
+	s.NoError(reader.Rollback())
+	s.Equal([]byte("new"), s.get("test", "k"))
+}
+
+func (s *conformanceSuite) TestClose() {
+	s.NoError(s.db.Close())
+	_, err := s.db.Begin()
+	s.True(errors.Is(err, kv.ErrDBClosed))
+
+	// TearDownTest closes the database again.
+	db, err := s.open()
+	s.Require().NoError(err)
+	s.db = db
+}
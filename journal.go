@@ -0,0 +1,65 @@
+package kvite
+
+import (
+	"fmt"
+	"strings"
+)
+
+// JournalEntry is a write recorded in the journal of a transaction.
+type JournalEntry struct {
+	// Op is "put" or "delete" for a single key, or the operation of a statement that wrote many keys at once, such
+	// as "truncate" or "expire", with an empty Key.
+	Op     string
+	Bucket string
+	Key    string
+	// Size is the length of the value of a put, before it is encoded.
+	Size int
+	// Keys is the number of keys written, which for a single key is 0 if it found no key to delete.
+	Keys int64
+}
+
+// TxJournal is the ordered list of writes a transaction has made. Writes that failed are not recorded.
+type TxJournal []JournalEntry
+
+// String formats the journal with one line per write, to be attached to logs.
+func (j TxJournal) String() string {
+	var sb strings.Builder
+	for i, e := range j {
+		if i > 0 {
+			sb.WriteByte('\n')
+		}
+		if e.Key != "" {
+			fmt.Fprintf(&sb, "%s %s %q", e.Op, e.Bucket, e.Key)
+		} else {
+			fmt.Fprintf(&sb, "%s %s", e.Op, e.Bucket)
+		}
+		if e.Op == "put" {
+			fmt.Fprintf(&sb, " %d bytes", e.Size)
+		} else {
+			fmt.Fprintf(&sb, " %d keys", e.Keys)
+		}
+	}
+	return sb.String()
+}
+
+// StartJournal has the transaction record the puts and deletes made from now on, with their sizes, so that what
+// a failed transaction had done can be logged from Journal once it has been rolled back. Writes made
+// through ExecRaw or Unwrap are not recorded.
+func (tx *Tx) StartJournal() {
+	if tx.journal == nil {
+		tx.journal = TxJournal{}
+	}
+}
+
+// Journal returns the writes the transaction has recorded since StartJournal, also once it has finished. It is
+// empty if StartJournal was not called.
+func (tx *Tx) Journal() TxJournal {
+	return append(TxJournal(nil), tx.journal...)
+}
+
+// journalWrite records a write that has been made, if the journal is on.
+func (tx *Tx) journalWrite(op, bucket, key string, size int, keys int64) {
+	if tx.journal != nil {
+		tx.journal = append(tx.journal, JournalEntry{Op: op, Bucket: bucket, Key: key, Size: size, Keys: keys})
+	}
+}
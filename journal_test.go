@@ -0,0 +1,37 @@
+package kvite
+
+import "errors"
+
+func (s *KViteTestSuite) TestTxJournal() {
+	s.NoError(s.DB.Put("journal", "old", []byte("v")))
+
+	var journal TxJournal
+	err := s.DB.Transaction(func(tx *Tx) error {
+		b, _ := tx.CreateBucketIfNotExists("journal")
+		s.NoError(b.Put("ignored", []byte("v")))
+		tx.StartJournal()
+		s.NoError(b.Put("new", []byte("value")))
+		s.NoError(b.Delete("missing"))
+		_, err := b.DeletePrefix("")
+		s.NoError(err)
+		journal = tx.Journal()
+		return errors.New("failed")
+	})
+	s.EqualError(err, "failed")
+
+	s.Equal(TxJournal{
+		{Op: "put", Bucket: "journal", Key: "new", Size: 5, Keys: 1},
+		{Op: "delete", Bucket: "journal", Key: "missing"},
+		{Op: "delete", Bucket: "journal", Keys: 3},
+	}, journal)
+	s.Equal(`put journal "new" 5 bytes
+delete journal "missing" 0 keys
+delete journal 3 keys`, journal.String())
+
+	s.NoError(s.DB.Transaction(func(tx *Tx) error {
+		b, _ := tx.CreateBucketIfNotExists("journal")
+		s.NoError(b.Put("k", []byte("v")))
+		s.Empty(tx.Journal())
+		return nil
+	}))
+}
@@ -0,0 +1,289 @@
+package kvite
+
+import (
+	"database/sql"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// keyIndexRefreshLimit is the number of keys of a bucket a transaction can write before committing it drops the
+// bucket's key index, to be loaded again, instead of refreshing the keys one by one.
+const keyIndexRefreshLimit = 1000
+
+// WithKeyIndex keeps the keys of each bucket matching pattern in memory, sorted, so that Has and List can answer
+// without querying SQLite; values are still read from SQLite. pattern uses the syntax of path.Match. A bucket's
+// keys are loaded the first time they are needed, and kept up to date as transactions commit. Adding a key to a
+// bucket takes time proportional to the number of keys in it.
+//
+// The index answers a transaction only while it matches the transaction's view of the database: not once the
+// transaction has written anything, nor while another transaction is writing to an indexed bucket or has
+// committed since the transaction began. Writes made by other processes sharing the database file are not seen,
+// so the index must only be used when this process is the only writer.
+func WithKeyIndex(pattern string) Option {
+	return func(db *DB) error {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return err
+		}
+		if db.keys == nil {
+			db.keys = &keyIndex{buckets: make(map[string]*indexedKeys)}
+		}
+		db.keys.patterns = append(db.keys.patterns, pattern)
+		return nil
+	}
+}
+
+// keyIndex holds the key indexes of a database. It is shared by handles returned from Restrict.
+// Every write and commit advances gen, and writers counts the transactions that have written to indexed buckets
+// and not finished. A transaction records gen when it begins and uses the index only while gen is unchanged and
+// there are no writers, so the index never holds keys the transaction cannot see, or misses keys it can.
+type keyIndex struct {
+	patterns []string
+
+	mu      sync.Mutex
+	gen     uint64
+	writers int
+	buckets map[string]*indexedKeys
+}
+
+// indexedKeys are the keys of a bucket, with the expiry times of those that expire in Unix nanoseconds.
+type indexedKeys struct {
+	keys    []string
+	expires map[string]int64
+}
+
+// keyChanges records the keys a transaction wrote, to refresh the index with when it commits. A nil set of keys
+// stands for the whole bucket.
+type keyChanges struct {
+	all     bool
+	buckets map[string]map[string]bool
+}
+
+func (ix *keyIndex) generation() uint64 {
+	if ix == nil {
+		return 0
+	}
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	return ix.gen
+}
+
+func (ix *keyIndex) indexed(bucket string) bool {
+	for _, pattern := range ix.patterns {
+		if ok, _ := path.Match(pattern, bucket); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// markKeys records a write covered by k, which the index must be refreshed with when tx commits.
+func (tx *Tx) markKeys(k cacheKey) {
+	ix := tx.db.keys
+	if ix == nil || !k.all && !ix.indexed(k.bucket) {
+		return
+	}
+	c := tx.keyChanges
+	if c == nil {
+		ix.mu.Lock()
+		ix.gen++
+		ix.writers++
+		ix.mu.Unlock()
+		c = &keyChanges{buckets: make(map[string]map[string]bool)}
+		tx.keyChanges = c
+	}
+
+	switch keys, ok := c.buckets[k.bucket]; {
+	case k.all:
+		c.all = true
+	case k.key == "" || ok && keys == nil || len(keys) >= keyIndexRefreshLimit:
+		c.buckets[k.bucket] = nil
+	case !ok:
+		c.buckets[k.bucket] = map[string]bool{k.key: true}
+	default:
+		keys[k.key] = true
+	}
+}
+
+// finishKeys refreshes the index with the keys tx wrote once it has finished.
+func (tx *Tx) finishKeys(committed bool) {
+	c := tx.keyChanges
+	if c == nil {
+		return
+	}
+	tx.keyChanges = nil
+	ix := tx.db.keys
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	ix.gen++
+	ix.writers--
+	if !committed {
+		return
+	}
+	if c.all {
+		ix.buckets = make(map[string]*indexedKeys)
+		return
+	}
+
+	// Keys are read back while the lock is held, so that a transaction committing later refreshes them after this
+	// one and the index ends up with the latest state.
+	query := fmt.Sprintf("SELECT expires FROM '%s' WHERE key = ? AND bucket = ?", tx.db.table)
+	for bucket, keys := range c.buckets {
+		idx := ix.buckets[bucket]
+		if idx == nil {
+			continue
+		}
+		if keys == nil {
+			delete(ix.buckets, bucket)
+			continue
+		}
+		for key := range keys {
+			var expires sql.NullInt64
+			err := tx.db.db.QueryRow(query, key, bucket).Scan(&expires)
+			if err == sql.ErrNoRows {
+				idx.remove(key)
+				continue
+			}
+			if err != nil {
+				delete(ix.buckets, bucket)
+				break
+			}
+			idx.add(key, expires.Int64)
+		}
+	}
+}
+
+func (idx *indexedKeys) add(key string, expires int64) {
+	i := sort.SearchStrings(idx.keys, key)
+	if i == len(idx.keys) || idx.keys[i] != key {
+		idx.keys = append(idx.keys, "")
+		copy(idx.keys[i+1:], idx.keys[i:])
+		idx.keys[i] = key
+	}
+	if expires != 0 {
+		idx.expires[key] = expires
+	} else {
+		delete(idx.expires, key)
+	}
+}
+
+func (idx *indexedKeys) remove(key string) {
+	i := sort.SearchStrings(idx.keys, key)
+	if i < len(idx.keys) && idx.keys[i] == key {
+		idx.keys = append(idx.keys[:i], idx.keys[i+1:]...)
+	}
+	delete(idx.expires, key)
+}
+
+func (idx *indexedKeys) live(key string, now int64) bool {
+	expires, ok := idx.expires[key]
+	return !ok || expires > now
+}
+
+// keyIndex calls fn with the index of the bucket if it can answer for the transaction, loading it if needed, and
+// reports whether it did.
+func (b *Bucket) keyIndex(fn func(idx *indexedKeys)) (bool, error) {
+	ix := b.tx.db.keys
+	if ix == nil || b.tx.wrote || !ix.indexed(b.name) {
+		return false, nil
+	}
+	ix.mu.Lock()
+	idx := ix.buckets[b.name]
+	usable := ix.writers == 0 && ix.gen == b.tx.keyGen
+	if usable && idx != nil {
+		fn(idx)
+	}
+	ix.mu.Unlock()
+	if !usable {
+		return false, nil
+	}
+	if idx != nil {
+		return true, nil
+	}
+
+	idx, err := b.loadKeyIndex()
+	if err != nil {
+		return false, err
+	}
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	if ix.writers != 0 || ix.gen != b.tx.keyGen {
+		return false, nil
+	}
+	if ix.buckets[b.name] == nil {
+		ix.buckets[b.name] = idx
+	}
+	fn(ix.buckets[b.name])
+	return true, nil
+}
+
+// loadKeyIndex reads the keys of the bucket as the transaction sees them.
+func (b *Bucket) loadKeyIndex() (*indexedKeys, error) {
+	rows, err := b.tx.query(fmt.Sprintf("SELECT key, expires FROM '%s' WHERE bucket = ? ORDER BY key", b.tx.db.table), b.name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	idx := &indexedKeys{expires: make(map[string]int64)}
+	for rows.Next() {
+		var (
+			key     string
+			expires sql.NullInt64
+		)
+		if err := rows.Scan(&key, &expires); err != nil {
+			return nil, err
+		}
+		idx.keys = append(idx.keys, key)
+		if expires.Valid {
+			idx.expires[key] = expires.Int64
+		}
+	}
+	return idx, rows.Err()
+}
+
+// hasIndexed answers Has from the key index.
+func (b *Bucket) hasIndexed(key string) (found, ok bool, err error) {
+	now := time.Now().UnixNano()
+	ok, err = b.keyIndex(func(idx *indexedKeys) {
+		i := sort.SearchStrings(idx.keys, key)
+		found = i < len(idx.keys) && idx.keys[i] == key && idx.live(key, now)
+	})
+	return found, ok, err
+}
+
+// listIndexed answers List from the key index.
+func (b *Bucket) listIndexed(prefix, delimiter string) (keys, prefixes []string, ok bool, err error) {
+	now := time.Now().UnixNano()
+	ok, err = b.keyIndex(func(idx *indexedKeys) {
+		for i := sort.SearchStrings(idx.keys, prefix); i < len(idx.keys); i++ {
+			key := idx.keys[i]
+			if !strings.HasPrefix(key, prefix) {
+				return
+			}
+			if !idx.live(key, now) {
+				continue
+			}
+			j := -1
+			if delimiter != "" {
+				j = strings.Index(key[len(prefix):], delimiter)
+			}
+			if j < 0 {
+				keys = append(keys, key)
+				continue
+			}
+
+			sub := key[:len(prefix)+j+len(delimiter)]
+			prefixes = append(prefixes, sub)
+			end, more := prefixEnd(sub)
+			if !more {
+				return
+			}
+			i = sort.SearchStrings(idx.keys, end) - 1
+		}
+	})
+	return keys, prefixes, ok, err
+}
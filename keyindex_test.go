@@ -0,0 +1,87 @@
+package kvite
+
+import "time"
+
+func (s *KViteTestSuite) TestKeyIndex() {
+	db := s.openDB("keyindex.db", WithKeyIndex("idx*"))
+	defer func() { _ = db.Close() }()
+
+	s.NoError(db.Transaction(func(tx *Tx) error {
+		b, _ := tx.CreateBucket("idx")
+		for _, key := range []string{"a", "b/1", "b/2", "c"} {
+			s.NoError(b.Put(key, []byte(key)))
+		}
+		return nil
+	}))
+
+	// statements runs fn in a transaction and returns the number of statements it ran
+	statements := func(fn func(b *Bucket)) int {
+		var n int
+		s.NoError(db.Transaction(func(tx *Tx) error {
+			start := tx.Stats().Statements
+			fn(tx.newBucket("idx"))
+			n = tx.Stats().Statements - start
+			return nil
+		}))
+		return n
+	}
+	has := func(b *Bucket, key string, want bool) {
+		ok, err := b.Has(key)
+		s.NoError(err)
+		s.Equal(want, ok, key)
+	}
+
+	s.Equal(1, statements(func(b *Bucket) { has(b, "a", true) }), "the index is loaded on first use")
+	s.Equal(0, statements(func(b *Bucket) {
+		has(b, "a", true)
+		has(b, "b", false)
+		keys, prefixes, err := b.List("", "/")
+		s.NoError(err)
+		s.Equal([]string{"a", "c"}, keys)
+		s.Equal([]string{"b/"}, prefixes)
+	}))
+
+	// Commits refresh the index, and transactions that write read from SQLite
+	s.NoError(db.Transaction(func(tx *Tx) error {
+		b := tx.newBucket("idx")
+		s.NoError(b.Delete("a"))
+		s.NoError(b.Put("d", nil))
+		has(b, "d", true)
+		return b.Touch("c", time.Millisecond)
+	}))
+	time.Sleep(5 * time.Millisecond)
+	s.Equal(0, statements(func(b *Bucket) {
+		has(b, "a", false)
+		has(b, "c", false)
+		has(b, "d", true)
+		keys, _, err := b.List("", "")
+		s.NoError(err)
+		s.Equal([]string{"b/1", "b/2", "d"}, keys)
+	}))
+
+	// Bulk deletes drop the index of the bucket, which is loaded again
+	s.NoError(db.Transaction(func(tx *Tx) error {
+		_, err := tx.newBucket("idx").DeletePrefix("b/")
+		return err
+	}))
+	s.Equal(1, statements(func(b *Bucket) {
+		keys, prefixes, err := b.List("", "/")
+		s.NoError(err)
+		s.Equal([]string{"d"}, keys)
+		s.Empty(prefixes)
+	}))
+
+	// A rolled back write leaves the index as it was
+	s.Error(db.Transaction(func(tx *Tx) error {
+		s.NoError(tx.newBucket("idx").Put("e", nil))
+		return ErrTxFinished
+	}))
+	s.Equal(0, statements(func(b *Bucket) { has(b, "e", false) }))
+
+	// Buckets that are not indexed are read from SQLite
+	s.Equal(1, statements(func(b *Bucket) {
+		ok, err := b.tx.newBucket("other").Has("a")
+		s.NoError(err)
+		s.False(ok)
+	}))
+}
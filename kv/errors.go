@@ -0,0 +1,41 @@
+package kv
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Errors returned by every implementation, so that code written against the interfaces can tell them apart with
+// errors.Is.
+var (
+	// ErrBucketNotFound is returned by Transactor.Bucket when the bucket does not exist.
+	ErrBucketNotFound = errors.New("bucket not found")
+	// ErrTxFinished is returned by Commit and Rollback on a transaction that has already been committed or rolled
+	// back.
+	ErrTxFinished = errors.New("transaction has already been committed or rolled back")
+	// ErrDBClosed is returned when using a store that has been closed.
+	ErrDBClosed = errors.New("database is closed")
+	// ErrBusy is matched by the errors of writes that conflict with another transaction, such as a write made after
+	// another transaction has committed since the writing one began.
+	ErrBusy = errors.New("database is busy")
+)
+
+// PanicError is returned by Store.Transaction when its function panics.
+type PanicError struct {
+	// Value is the value passed to panic and Stack the stack trace of the panicking goroutine.
+	Value interface{}
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	if err, ok := e.Value.(error); ok {
+		return err.Error()
+	}
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// Unwrap returns the value passed to panic if it is an error.
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
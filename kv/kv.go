@@ -0,0 +1,45 @@
+// Package kv defines the interfaces of a transactional key/value store, as implemented by kvite and kvitemem.
+// It has no dependencies, so code that only needs the interfaces does not pull in SQLite or cgo.
+package kv
+
+type (
+	// Store is a key/value store whose data is read and written in transactions.
+	Store interface {
+		// Begin starts a transaction.
+		Begin() (Transactor, error)
+		// Transaction runs fn in a transaction, committing it if fn returns nil and rolling it back otherwise.
+		// fn must not commit or roll back the transaction itself.
+		Transaction(fn func(Transactor) error) error
+		// Buckets returns the names of the buckets that have been created or have keys.
+		Buckets() ([]string, error)
+		// Close releases the store's resources.
+		Close() error
+	}
+
+	// Transactor is a transaction on a Store.
+	Transactor interface {
+		// Bucket gets a bucket by name. It returns ErrBucketNotFound if the bucket has not been created and has no
+		// keys.
+		Bucket(name string) (KVBucket, error)
+		// CreateBucket creates a bucket if it does not exist yet, and returns it.
+		CreateBucket(name string) (KVBucket, error)
+		Commit() error
+		Rollback() error
+	}
+
+	// KVBucket is a collection of key/value pairs, accessed through a transaction.
+	KVBucket interface {
+		// Put sets the value for a key. A nil value is stored as an empty value.
+		Put(key string, value []byte) error
+		// Get returns the value of a key, or nil if the key does not exist.
+		Get(key string) ([]byte, error)
+		// Has reports whether a key exists.
+		Has(key string) (bool, error)
+		// Delete removes a key. Deleting a key that does not exist is not an error.
+		Delete(key string) error
+		// Update replaces the value of a key with the result of fn, deleting the key if fn returns nil.
+		Update(key string, fn func(old []byte) ([]byte, error)) error
+		// ForEach calls fn for each key/value pair, stopping at the first error.
+		ForEach(fn func(k string, v []byte) error) error
+	}
+)
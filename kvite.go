@@ -1,31 +1,124 @@
 // Package kvite is a simple embedded K/V store backed by SQLite
+//
+// Applications that want to depend on an interface rather than on *DB use the Store, Transactor and KVBucket
+// interfaces of package kv. *Bucket implements KVBucket, but *DB and *Tx do not implement Store and Transactor
+// themselves: their Begin, Transaction and Bucket methods return and take the concrete types, and changing that
+// would break existing callers. NewStore adapts a *DB to Store instead.
 package kvite
 
 import (
+	"context"
 	"database/sql"
 	"errors"
-	"fmt"
-
-	_ "github.com/mattn/go-sqlite3" //import sqlite3 for driver
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"time"
 )
 
 type (
 	// DB is a wrapper around the underlying SQLite database.
 	DB struct {
-		db           *sql.DB
-		table        string
-		putQuery     string
-		deleteQuery  string
-		getQuery     string
-		foreachQuery string
-		bucketsQuery string
+		db            *sql.DB
+		filename      string
+		table         string
+		putQuery      string
+		deleteQuery   string
+		getQuery      string
+		foreachQuery  string
+		bucketsQuery  string
+		existsQuery   string
+		checksums     bool
+		wal           bool
+		readOnly      bool
+		changeFeed    bool
+		nodeID        string
+		clock         *hlc
+		versionQuery  string
+		bucketRules   []bucketRule
+		limiter       *writeLimiter
+		metrics       *txMetrics
+		bucketStats   *bucketStats
+		locks         *lockDiagnostics
+		heartbeat     *lockHeartbeat
+		life          *lifecycle
+		hasQuery      string
+		skipSchema    bool
+		createDir     bool
+		dirPerm       os.FileMode
+		uri           URIOptions
+		withoutRowID  bool
+		indexLayout   IndexLayout
+		dedup         bool
+		valueQuery    string
+		mergeRules    []mergeRule
+		hooks         []Hook
+		revisionQuery string
+		hub           *watchHub
+		sizesQuery    string
+		largestQuery  string
+		hot           *hotKeys
+		cache         *readCache
+		behind        *writeBehind
+		batcher       *batcher
+		durability    Durability
+		pragmas       []string
+		ownsDB        bool
+		extensions    []extension
+		functions     []function
+		exclusive     *sql.DB
+		retry         *RetryPolicy
+		repanic       bool
+		writer        *singleWriter
+		async         *asyncWriter
+		registry      bool
+		codecs        map[string]Codec
+		configs       *bucketConfigs
+		history       bool
+		historyQuery  string
+		macKey        []byte
+		spatial       bool
+		keys          *keyIndex
+		bloom         *bloomFilters
 	}
 
 	// Tx wraps most interactions with the datastore.
 	Tx struct {
-		db      *DB
-		tx      *sql.Tx
-		managed bool
+		db        *DB
+		tx        *sql.Tx
+		managed   bool
+		readOnly  bool
+		stats     TxStats
+		started   time.Time
+		finished  time.Time
+		label     string
+		holdsLock bool
+		pending   []Change
+		cacheGen  uint64
+		keyGen    uint64
+		cacheKeys []cacheKey
+		// conn and synchronous are set when the transaction has a connection of its own, whose synchronous
+		// setting is restored when it finishes.
+		conn        *sql.Conn
+		synchronous int
+		// configs holds bucket configuration set by the transaction, which is applied to the database on commit.
+		configs map[string]BucketConfig
+		// shredded is set by Shred, to vacuum the database once the transaction commits.
+		shredded bool
+		// wrote is set once the transaction has run a write statement, and so holds the write lock.
+		wrote bool
+		// keyChanges records the keys the transaction wrote to buckets with a key index.
+		keyChanges *keyChanges
+		// dryRun is set for transactions run by DB.DryRun, which collect their changes in planned.
+		dryRun  bool
+		planned []Change
+		// journal records writes once StartJournal has been called.
+		journal TxJournal
+		// writes is the number of keys the transaction has written, reported by PendingWrites.
+		writes int64
+		// bloomSuspended is set when the transaction writes rows without adding their keys to the bloom filters.
+		bloomSuspended bool
 	}
 
 	//Bucket represents a collection of key/value pairs inside the database.
@@ -37,75 +130,278 @@ type (
 
 // Open opens a KVite datastore. The returned DB is safe for concurrent use by multiple goroutines.
 // It is rarely necessary to close a DB.
-func Open(filename, table string) (*DB, error) {
-	db, err := sql.Open("sqlite3", filename)
+func Open(filename, table string, opts ...Option) (*DB, error) {
+	d, err := newDB(table, opts)
 	if err != nil {
 		return nil, err
 	}
+	d.filename = filename
+	d.ownsDB = true
+	if d.uri.Immutable && !d.readOnly {
+		return nil, errors.New("immutable databases must be opened ReadOnly")
+	}
+	if d.createDir && filename != ":memory:" {
+		if err := os.MkdirAll(filepath.Dir(filename), d.dirPerm); err != nil {
+			return nil, err
+		}
+	}
 
-	if table == "" {
-		table = "kvite"
+	params := url.Values{}
+	if d.readOnly {
+		params.Set("mode", "ro")
 	}
+	if d.durability != DurabilityDefault {
+		params.Set("_sync", d.durability.pragma())
+	}
+	d.uri.set(params)
+	dsn := filename
+	if len(params) > 0 {
+		dsn = fileURI(filename, params)
+	}
+	d.db = sql.OpenDB(newConnector(dsn, d))
+
+	// The driver issues BEGIN EXCLUSIVE for every transaction on a connection opened with _txlock=exclusive, so
+	// exclusive transactions get a pool of their own. Its connections are only opened when needed.
+	params.Set("_txlock", "exclusive")
+	d.exclusive = sql.OpenDB(newConnector(fileURI(filename, params), d))
+	if err := d.init(); err != nil {
+		return nil, sqliteError(err)
+	}
+	return d, nil
+}
 
-	tx, err := db.Begin()
+// OpenWithDB layers a KVite datastore over an existing SQLite handle, so that an application that manages its own
+// connections, pragmas and hooks does not need a second pool for the same file. Options that configure
+// connections, such as WithDurability, WithCacheSize, WithExtension, WithFunction and WithURIOptions, cannot be
+// used; configure db instead. With ReadOnly, transactions are read-only but the connections are not. Closing the DB does not close db.
+func OpenWithDB(db *sql.DB, table string, opts ...Option) (*DB, error) {
+	d, err := newDB(table, opts)
 	if err != nil {
 		return nil, err
 	}
+	if d.durability != DurabilityDefault || len(d.pragmas) > 0 || len(d.extensions) > 0 || len(d.functions) > 0 || d.uri != (URIOptions{}) {
+		return nil, errors.New("connection options cannot be used with OpenWithDB")
+	}
+	d.db = db
 
-	defer func() {
-		_ = tx.Rollback()
-	}()
-
-	query := fmt.Sprintf("create TABLE IF NOT EXISTS '%s' (key text not null, bucket text not null, value blob not null)", table)
-	if _, err := tx.Exec(query); err != nil {
+	// Features such as replication work with the database file, so find out which one db has open.
+	rows, err := db.Query("PRAGMA database_list")
+	if err != nil {
 		return nil, err
 	}
-	query = fmt.Sprintf("create UNIQUE INDEX IF NOT EXISTS '%s_kvite_key_index' ON '%s' (key, bucket)", table, table)
-	if _, err := tx.Exec(query); err != nil {
+	defer rows.Close()
+	for rows.Next() {
+		var (
+			seq        int
+			name, file string
+		)
+		if err := rows.Scan(&seq, &name, &file); err != nil {
+			return nil, err
+		}
+		if name == "main" {
+			d.filename = file
+		}
+	}
+	if err := rows.Err(); err != nil {
 		return nil, err
 	}
 
-	if err := tx.Commit(); err != nil {
-		return nil, err
+	if err := d.init(); err != nil {
+		return nil, sqliteError(err)
+	}
+	return d, nil
+}
+
+func newDB(table string, opts []Option) (*DB, error) {
+	if table == "" {
+		table = "kvite"
+	}
+
+	d := &DB{
+		table:       table,
+		metrics:     &txMetrics{},
+		bucketStats: &bucketStats{m: make(map[string]*bucketCounters)},
+		life:        &lifecycle{},
+		hub:         &watchHub{},
+		batcher:     &batcher{maxSize: DefaultMaxBatchSize, maxDelay: DefaultMaxBatchDelay},
+		configs:     &bucketConfigs{m: make(map[string]BucketConfig)},
+	}
+	d.async = newAsyncWriter(d)
+
+	for _, opt := range opts {
+		if err := opt(d); err != nil {
+			return nil, err
+		}
+	}
+	return d, nil
+}
+
+// init prepares a DB whose handle has been opened.
+func (db *DB) init() error {
+	if db.wal {
+		if _, err := db.db.Exec("PRAGMA journal_mode=WAL"); err != nil {
+			return err
+		}
+	}
+
+	if db.readOnly || db.skipSchema {
+		if err := db.loadNodeID(); err != nil {
+			return err
+		}
+	} else if err := db.createSchema(); err != nil {
+		return err
+	}
+
+	if err := db.initQueries(); err != nil {
+		return err
+	}
+	if err := db.loadBucketConfigs(); err != nil {
+		return err
+	}
+	if err := db.bloom.load(db); err != nil {
+		return err
 	}
 
-	return &DB{
-		db:           db,
-		table:        table,
-		getQuery:     fmt.Sprintf("SELECT value FROM '%s' WHERE key = ? and bucket = ?", table),
-		deleteQuery:  fmt.Sprintf("DELETE FROM '%s' WHERE key = ? AND bucket = ?", table),
-		putQuery:     fmt.Sprintf("INSERT OR REPLACE INTO '%s' (key, value, bucket) VALUES (?, ?, ?)", table),
-		foreachQuery: fmt.Sprintf("SELECT key, value FROM '%s' WHERE bucket = ?", table),
-		bucketsQuery: fmt.Sprintf("SELECT DISTINCT bucket from '%s'", table),
-	}, nil
+	if db.clock != nil {
+		if err := db.initClock(); err != nil {
+			return err
+		}
+	}
+
+	if err := db.heartbeat.open(db.filename); err != nil {
+		return err
+	}
+
+	if db.behind != nil {
+		go db.behind.run(db)
+	}
+	if db.writer != nil {
+		go db.writer.run()
+	}
+	return nil
+}
+
+func (db *DB) createSchema() error {
+	tx, err := db.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	if err := db.initSchema(tx); err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
 // Close closes the database, releasing any open resources.
 // It is rare to Close a DB, as the DB handle is meant to be long-lived and shared between many goroutines.
+// Close does not wait for open transactions; use CloseContext to let them finish first.
+// Writes queued by PutAsync, and with WithWriteBehind the journal, are written first.
 func (db *DB) Close() error {
-	return db.db.Close()
+	db.async.wait()
+	err := db.stopWriteBehind()
+	db.life.close()
+	if cerr := db.closeDB(); err == nil {
+		err = cerr
+	}
+	return err
 }
 
-// Begin starts a transaction.
+// closeDB stops the single writer, if any, and closes the handle if Open created it.
+func (db *DB) closeDB() error {
+	if db.writer != nil {
+		db.writer.stop()
+	}
+	err := db.heartbeat.close()
+	if !db.ownsDB {
+		return err
+	}
+	if cerr := db.db.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := db.exclusive.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func (db *DB) stopWriteBehind() error {
+	if db.behind == nil {
+		return nil
+	}
+	return db.behind.stop(db)
+}
+
+// Begin starts a transaction. With WithSingleWriter, the transaction is read-only.
 func (db *DB) Begin() (*Tx, error) {
-	tx, err := db.db.Begin()
-	if err != nil {
+	tx, err := db.begin(context.Background())
+	if err == nil && db.writer != nil {
+		tx.readOnly = true
+	}
+	return tx, err
+}
+
+func (db *DB) begin(ctx context.Context) (*Tx, error) {
+	return db.beginOn(ctx, db.db, nil)
+}
+
+// beginOn starts a transaction on conn, or on any connection from pool if conn is nil.
+func (db *DB) beginOn(ctx context.Context, pool *sql.DB, conn *sql.Conn) (*Tx, error) {
+	if err := db.life.acquire(); err != nil {
 		return nil, err
 	}
+
+	started := time.Now()
+	gen := db.cache.generation()
+	var (
+		tx  *sql.Tx
+		err error
+	)
+	if conn != nil {
+		tx, err = conn.BeginTx(ctx, nil)
+	} else {
+		tx, err = pool.BeginTx(ctx, nil)
+	}
+	if err != nil {
+		db.life.release()
+		return nil, sqliteError(err)
+	}
 	t := &Tx{
-		db: db,
-		tx: tx,
+		db:       db,
+		tx:       tx,
+		readOnly: db.readOnly,
+		started:  started,
+		cacheGen: gen,
+		keyGen:   db.keys.generation(),
 	}
 	return t, nil
 
 }
 
-// Buckets returns all the buckets
+// Buckets returns all the buckets: those created with CreateBucket, in the order they were created, followed by
+// any others that have keys.
 func (db *DB) Buckets() ([]string, error) {
-	rows, err := db.db.Query(db.bucketsQuery)
+	if err := db.life.check(); err != nil {
+		return nil, err
+	}
+	return db.buckets(db.db)
+}
+
+type querier interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+func (db *DB) buckets(q querier) ([]string, error) {
+	rows, err := q.Query(db.bucketsQuery)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
 	buckets := make([]string, 0, 32)
 	for rows.Next() {
@@ -113,6 +409,9 @@ func (db *DB) Buckets() ([]string, error) {
 		if err := rows.Scan(&name); err != nil {
 			return nil, err
 		}
+		if db.bucketAccess(name) == BucketRestricted {
+			continue
+		}
 		buckets = append(buckets, name)
 	}
 	if err := rows.Err(); err != nil {
@@ -126,17 +425,35 @@ func (db *DB) Buckets() ([]string, error) {
 // If no error is returned from the function then the transaction is committed.
 // If an error is returned then the entire transaction is rolled back.
 // Rollback and Commit cannot be used inside of the function
+// If the database was opened WithRetryPolicy, transactions that fail with a retryable error are run again.
+// If the function panics, the transaction is rolled back and a *PanicError is returned, or, with WithRepanic,
+// the panic carries on once the transaction has been rolled back.
+// With WithSingleWriter, fn runs on the writer goroutine once the transactions queued before it have finished.
 func (db *DB) Transaction(fn func(*Tx) error) error {
-	tx, err := db.Begin()
+	if db.writer != nil {
+		return db.withRetry(func() error { return db.writer.do(db, fn) })
+	}
+	return db.withRetry(func() error { return db.transaction(fn) })
+}
+
+func (db *DB) transaction(fn func(*Tx) error) (err error) {
+	tx, err := db.begin(context.Background())
 	if err != nil {
 		return err
 	}
 
 	// Make sure the transaction rolls back in the event of a panic.
 	defer func() {
-		if tx.db != nil {
-			_ = tx.Rollback()
+		p := recover()
+		if p == nil {
+			return
 		}
+		tx.managed = false
+		_ = tx.Rollback()
+		if db.repanic {
+			panic(p)
+		}
+		err = &PanicError{Value: p, Stack: debug.Stack()}
 	}()
 
 	tx.managed = true
@@ -150,22 +467,69 @@ func (db *DB) Transaction(fn func(*Tx) error) error {
 	return tx.Commit()
 }
 
-// Commit commits the transaction.
+// Commit commits the transaction. It returns ErrTxFinished if the transaction has already been committed or
+// rolled back.
 func (tx *Tx) Commit() error {
 	if tx.managed {
 		return errors.New("managed tx commit not allowed")
 	}
+	if !tx.finished.IsZero() {
+		return ErrTxFinished
+	}
 
+	publish := len(tx.pending) > 0
+	if publish {
+		tx.db.hub.order.Lock()
+	}
 	err := tx.tx.Commit()
-	return err
+	tx.finish(err == nil)
+	if err == nil {
+		for _, k := range tx.cacheKeys {
+			tx.db.cache.invalidate(k)
+		}
+		tx.db.hub.publish(tx.pending)
+	}
+	if publish {
+		tx.db.hub.order.Unlock()
+	}
+	if err == nil {
+		tx.db.configs.set(tx.configs)
+		if tx.shredded {
+			err = tx.db.vacuumShredded()
+		}
+	}
+	tx.pending = nil
+	tx.cacheKeys = nil
+	return sqliteError(err)
 }
 
-// Rollback aborts the transaction.
+// CommitAsync commits the transaction on a background goroutine and returns a channel that receives the result
+// of the commit. The transaction must not be used after CommitAsync is called. Like Commit, it cannot be used
+// inside of a managed transaction.
+func (tx *Tx) CommitAsync() <-chan error {
+	ch := make(chan error, 1)
+	if tx.managed {
+		ch <- errors.New("managed tx commit not allowed")
+		return ch
+	}
+	go func() {
+		ch <- tx.Commit()
+	}()
+	return ch
+}
+
+// Rollback aborts the transaction. Like Commit, it returns ErrTxFinished if the transaction has already been
+// committed or rolled back.
 func (tx *Tx) Rollback() error {
 	if tx.managed {
 		return errors.New("managed tx commit not allowed")
 	}
-	return tx.tx.Rollback()
+	if !tx.finished.IsZero() {
+		return ErrTxFinished
+	}
+	err := tx.tx.Rollback()
+	tx.finish(false)
+	return sqliteError(err)
 }
 
 func (tx *Tx) newBucket(name string) *Bucket {
@@ -175,63 +539,261 @@ func (tx *Tx) newBucket(name string) *Bucket {
 	}
 }
 
-// Bucket gets a bucket by name.  Buckets can be created on the fly and do not "exist" until they have keys.
+// Bucket gets an existing bucket by name. It returns ErrBucketNotFound if the bucket was never created and has no
+// keys; use CreateBucketIfNotExists to create a bucket.
 func (tx *Tx) Bucket(name string) (*Bucket, error) {
+	ok, err := tx.BucketExists(name)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrBucketNotFound
+	}
 	return tx.newBucket(name), nil
 }
 
-// CreateBucket is provided for compatibility. It just calls Bucket.
+// CreateBucket records a bucket in the registry, so that it is listed by Buckets even while it has no keys.
+// Unlike bolt's, it does not fail if the bucket already exists.
 func (tx *Tx) CreateBucket(name string) (*Bucket, error) {
-	return tx.Bucket(name)
-
+	return tx.createBucket(name)
 }
 
-// CreateBucketIfNotExists is provided for compatibility. It just calls Bucket.
+// CreateBucketIfNotExists records a bucket in the registry if it is not there yet. It is the same as CreateBucket.
 func (tx *Tx) CreateBucketIfNotExists(name string) (*Bucket, error) {
-	return tx.Bucket(name)
+	return tx.createBucket(name)
 }
 
 // Put sets the value for a key in the bucket. If the key exists, then its previous value will be overwritten.
+// A nil or empty value is stored as an empty value: the key exists, and Get returns a non-nil, zero-length slice.
+// Metadata stored with PutWithMeta is cleared.
 func (b *Bucket) Put(key string, value []byte) error {
-	_, err := b.tx.tx.Exec(b.tx.db.putQuery, key, value, b.name)
-	return err
+	return b.PutWithMeta(key, value, ValueMeta{})
+}
+
+func (b *Bucket) put(key string, value []byte, meta ValueMeta) error {
+	if b.tx.readOnly {
+		return ErrTxReadOnly
+	}
+	if err := b.checkAccess("put", true); err != nil {
+		return err
+	}
+	if err := b.tx.db.allowWrite(b.name); err != nil {
+		return err
+	}
+	if value == nil {
+		value = []byte{}
+	}
+	return b.tx.put(&Change{Bucket: b.name, Key: key, Type: ChangePut, Value: value}, meta)
 }
 
 // Delete removes a key from the bucket. If the key does not exist then nothing is done and a nil error is returned.
 func (b *Bucket) Delete(key string) error {
-	_, err := b.tx.tx.Exec(b.tx.db.deleteQuery, key, b.name)
-	return err
+	if len(b.tx.db.hooks) == 0 {
+		return b.delete(key)
+	}
+	return b.intercept(Op{Name: "delete", Bucket: b.name, Key: key}, func() error { return b.delete(key) })
+}
+
+func (b *Bucket) delete(key string) error {
+	if b.tx.readOnly {
+		return ErrTxReadOnly
+	}
+	if err := b.checkAccess("delete", true); err != nil {
+		return err
+	}
+	if err := b.tx.db.allowWrite(b.name); err != nil {
+		return err
+	}
+	return b.tx.delete(&Change{Bucket: b.name, Key: key, Type: ChangeDelete})
+}
+
+// Update reads the current value of a key, passes it to fn and stores the value fn returns, all within the
+// surrounding transaction. old is nil if the key does not exist. If fn returns a nil value the key is deleted,
+// and if it returns an error nothing is written and the error is returned.
+func (b *Bucket) Update(key string, fn func(old []byte) ([]byte, error)) error {
+	old, err := b.Get(key)
+	if err != nil {
+		return err
+	}
+	value, err := fn(old)
+	if err != nil {
+		return err
+	}
+	if value == nil {
+		if old == nil {
+			return nil
+		}
+		return b.Delete(key)
+	}
+	return b.Put(key, value)
+}
+
+// put writes a key and records the change in the feed.
+func (tx *Tx) put(c *Change, meta ValueMeta) error {
+	if err := tx.saveHistory(c.Bucket, c.Key); err != nil {
+		return err
+	}
+	// The timestamp of a local change is stored in its row, so it is taken from the clock before the row is
+	// written. A write that fails only leaves the clock past an unused reading.
+	issued := tx.db.issueTimestamp(c)
+	ts, origin := tx.db.versionColumns(c)
+	if err := tx.writeRow(c.Bucket, c.Key, c.Value, ts, origin, meta); err != nil {
+		if issued {
+			c.Timestamp = 0
+		}
+		return err
+	}
+	tx.stamp(c)
+	tx.journalWrite("put", c.Bucket, c.Key, len(c.Value), 1)
+	tx.db.hot.record(c.Bucket, c.Key, true)
+	tx.countWrite(c.Bucket, 1, len(c.Value))
+	tx.db.bloom.add(c.Bucket, c.Key)
+	tx.invalidateCache(cacheKey{bucket: c.Bucket, key: c.Key})
+	return tx.recordChange(c)
+}
+
+// delete removes a key and, if it existed, records the change in the feed.
+func (tx *Tx) delete(c *Change) error {
+	if err := tx.saveHistory(c.Bucket, c.Key); err != nil {
+		return err
+	}
+	res, err := tx.exec(tx.db.deleteQuery, c.Key, c.Bucket)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	tx.journalWrite("delete", c.Bucket, c.Key, 0, n)
+	tx.db.hot.record(c.Bucket, c.Key, true)
+	if n == 0 {
+		return nil
+	}
+	tx.countWrite(c.Bucket, 1, 0)
+	tx.invalidateCache(cacheKey{bucket: c.Bucket, key: c.Key})
+	tx.stamp(c)
+	return tx.recordChange(c)
 }
 
-// Get retrieves the value for a key in the bucket. Returns a nil value if the key does not exist
+// Get retrieves the value for a key in the bucket. Returns a nil value if the key does not exist.
+// A key that exists with an empty value returns a non-nil, zero-length slice, so presence can be tested with
+// value != nil. Use Has to test presence without reading the value.
 func (b *Bucket) Get(key string) ([]byte, error) {
+	if len(b.tx.db.hooks) == 0 {
+		return b.get(key)
+	}
 	var value []byte
+	err := b.intercept(Op{Name: "get", Bucket: b.name, Key: key}, func() (err error) {
+		value, err = b.get(key)
+		return err
+	})
+	return value, err
+}
+
+func (b *Bucket) get(key string) ([]byte, error) {
+	var (
+		value   []byte
+		sum     sql.NullInt64
+		expires sql.NullInt64
+	)
 
-	if err := b.tx.tx.QueryRow(b.tx.db.getQuery, key, b.name).Scan(&value); err != nil {
+	if err := b.checkAccess("get", false); err != nil {
+		return nil, err
+	}
+	b.tx.db.hot.record(b.name, key, false)
+	if !b.tx.db.bloom.mayContain(b.tx, b.name, key) {
+		return nil, nil
+	}
+	if value, expires, ok := b.tx.db.cache.get(b.tx, b.name, key); ok {
+		b.tx.db.bucketStats.read(b.name, len(value))
+		return value, b.slideExpiry(key, expires)
+	}
+	if err := b.tx.queryRow(b.tx.db.getQuery, key, b.name).Scan(&value, &sum, &expires); err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
 		}
+		return nil, sqliteError(err)
+	}
+	if expired(expires, time.Now()) {
+		return nil, b.dropExpired(key)
+	}
+
+	value, err := b.scanValue(key, value, sum)
+	if err != nil {
 		return nil, err
 	}
+	b.tx.db.cache.add(b.tx, b.name, key, value, expires.Int64)
+	b.tx.db.bucketStats.read(b.name, len(value))
+	return value, b.slideExpiry(key, expires.Int64)
+}
 
-	return value, nil
+// Has reports whether a key exists in the bucket, including keys with an empty value.
+func (b *Bucket) Has(key string) (bool, error) {
+	if err := b.checkAccess("get", false); err != nil {
+		return false, err
+	}
+	if !b.tx.db.bloom.mayContain(b.tx, b.name, key) {
+		return false, nil
+	}
+	if found, ok, err := b.hasIndexed(key); ok || err != nil {
+		return found, err
+	}
+	var expires sql.NullInt64
+	err := b.tx.queryRow(b.tx.db.hasQuery, key, b.name).Scan(&expires)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil && !expired(expires, time.Now()), sqliteError(err)
 }
 
-//ForEach executes a function for each key/value pair in a bucket. If the provided function returns an error then the iteration is stopped and the error is returned to the caller.
+// ForEach executes a function for each key/value pair in a bucket. If the provided function returns an error then the iteration is stopped and the error is returned to the caller.
 func (b *Bucket) ForEach(fn func(k string, v []byte) error) error {
-	rows, err := b.tx.tx.Query(b.tx.db.foreachQuery, b.name)
+	if len(b.tx.db.hooks) == 0 {
+		return b.forEach(fn)
+	}
+	return b.intercept(Op{Name: "foreach", Bucket: b.name}, func() error { return b.forEach(fn) })
+}
+
+func (b *Bucket) forEach(fn func(k string, v []byte) error) error {
+	if err := b.checkAccess("foreach", false); err != nil {
+		return err
+	}
+	rows, err := b.tx.query(b.tx.db.foreachQuery, b.name)
 	if err != nil {
 		return err
 	}
+	defer rows.Close()
+
+	var (
+		now  = time.Now()
+		gone []string
+	)
 	for rows.Next() {
 		var key string
 		var value []byte
-		if err := rows.Scan(&key, &value); err != nil {
+		var sum, expires sql.NullInt64
+		if err := rows.Scan(&key, &value, &sum, &expires); err != nil {
 			return err
 		}
+		if expired(expires, now) {
+			gone = append(gone, key)
+			continue
+		}
+		value, err := b.scanValue(key, value, sum)
+		if err != nil {
+			return err
+		}
+		b.tx.db.bucketStats.read(b.name, len(value))
 		if err := fn(key, value); err != nil {
 			return err
 		}
 	}
-	return rows.Err()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if err := rows.Close(); err != nil {
+		return err
+	}
+	return b.dropExpired(gone...)
 }
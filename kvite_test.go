@@ -1,6 +1,7 @@
 package kvite
 
 import (
+	"database/sql"
 	"errors"
 	"io/ioutil"
 	"os"
@@ -92,14 +93,30 @@ func (s *KViteTestSuite) TestTxRollback() {
 	tx, _ := s.DB.Begin()
 	s.NoError(tx.Rollback())
 	// Can't rollback a finished tx
-	s.Error(tx.Rollback())
+	s.Equal(ErrTxFinished, tx.Rollback())
+	s.Equal(ErrTxFinished, tx.Commit())
 }
 
 func (s *KViteTestSuite) TestTxCommit() {
 	tx, _ := s.DB.Begin()
 	s.NoError(tx.Commit())
 	// Can't commit a finished tx
-	s.Error(tx.Commit())
+	s.Equal(ErrTxFinished, tx.Commit())
+	s.Equal(ErrTxFinished, tx.Rollback())
+}
+
+func (s *KViteTestSuite) TestTxCommitAsync() {
+	tx, _ := s.DB.Begin()
+	b, _ := tx.CreateBucketIfNotExists("async")
+	s.NoError(b.Put("k", []byte("v")))
+	s.NoError(<-tx.CommitAsync())
+	s.testStoredValue("async", "k", []byte("v"))
+	s.Error(<-tx.CommitAsync())
+
+	s.NoError(s.DB.Transaction(func(tx *Tx) error {
+		s.Error(<-tx.CommitAsync())
+		return nil
+	}))
 }
 
 func (s *KViteTestSuite) TestTxCreateBucket() {
@@ -273,3 +290,125 @@ func (s *KViteTestSuite) TestBucketPutUnique() {
 	})
 	s.Equal(1, i)
 }
+
+func (s *KViteTestSuite) openDB(name string, opts ...Option) *DB {
+	db, err := Open(filepath.Join(s.TempDir, name), "testing", opts...)
+	s.Require().NoError(err)
+	return db
+}
+
+func (s *KViteTestSuite) TestEmptyValue() {
+	s.NoError(s.DB.Transaction(func(tx *Tx) error {
+		b, _ := tx.CreateBucket("test")
+		s.NoError(b.Put("empty", []byte{}))
+		s.NoError(b.Put("nil", nil))
+
+		for _, key := range []string{"empty", "nil"} {
+			value, err := b.Get(key)
+			s.NoError(err)
+			s.NotNil(value, key)
+			s.Len(value, 0)
+			ok, err := b.Has(key)
+			s.NoError(err)
+			s.True(ok)
+		}
+
+		value, err := b.Get("missing")
+		s.NoError(err)
+		s.Nil(value)
+		ok, err := b.Has("missing")
+		s.NoError(err)
+		s.False(ok)
+
+		return b.ForEach(func(k string, v []byte) error {
+			s.NotNil(v, k)
+			return nil
+		})
+	}))
+}
+
+func (s *KViteTestSuite) TestBucketUpdate() {
+	incr := func(old []byte) ([]byte, error) {
+		return append(old, 'x'), nil
+	}
+
+	s.NoError(s.DB.Transaction(func(tx *Tx) error {
+		b, _ := tx.CreateBucket("test")
+		s.NoError(b.Update("counter", incr))
+		s.NoError(b.Update("counter", incr))
+		value, _ := b.Get("counter")
+		s.Equal([]byte("xx"), value)
+
+		s.Equal(errors.New("an error"), b.Update("counter", func([]byte) ([]byte, error) {
+			return []byte("ignored"), errors.New("an error")
+		}))
+
+		s.NoError(b.Update("counter", func([]byte) ([]byte, error) { return nil, nil }))
+		ok, err := b.Has("counter")
+		s.False(ok)
+		return err
+	}))
+}
+
+func (s *KViteTestSuite) TestOpenWithDB() {
+	path := filepath.Join(s.TempDir, "shared.db")
+	sqlDB, err := sql.Open("sqlite3", path)
+	s.Require().NoError(err)
+	defer func() { _ = sqlDB.Close() }()
+
+	db, err := OpenWithDB(sqlDB, "testing", WithChecksums())
+	s.Require().NoError(err)
+	s.Equal(path, db.filename)
+
+	s.NoError(db.Put("shared", "k", []byte("v")))
+	s.NoError(db.Close())
+
+	// The handle stays open for the application.
+	var n int
+	s.NoError(sqlDB.QueryRow("SELECT count(*) FROM testing").Scan(&n))
+	s.Equal(1, n)
+
+	_, err = OpenWithDB(sqlDB, "testing", WithCacheSize(100))
+	s.Error(err)
+}
+
+func (s *KViteTestSuite) TestDBTransactionPanic() {
+	errBoom := errors.New("boom")
+	err := s.DB.Transaction(func(tx *Tx) error {
+		b, _ := tx.CreateBucket("panic")
+		_ = b.Put("k", []byte("v"))
+		panic(errBoom)
+	})
+	var perr *PanicError
+	s.Require().True(errors.As(err, &perr))
+	s.Equal(errBoom, perr.Value)
+	s.NotEmpty(perr.Stack)
+	s.True(errors.Is(err, errBoom))
+	s.Equal(int64(1), s.DB.TxMetrics().Rollbacks)
+	s.testStoredValue("panic", "k", []byte(nil))
+
+	db := s.openDB("repanic.db", WithRepanic())
+	defer func() { _ = db.Close() }()
+	s.PanicsWithValue(errBoom, func() {
+		_ = db.Transaction(func(tx *Tx) error {
+			panic(errBoom)
+		})
+	})
+	s.Equal(int64(1), db.TxMetrics().Rollbacks)
+}
+
+func (s *KViteTestSuite) TestCreateDir() {
+	filename := filepath.Join(s.TempDir, "nested", "dirs", "kvite.db")
+	_, err := Open(filename, "testing")
+	s.Error(err)
+
+	db, err := Open(filename, "testing", WithCreateDir(0750))
+	s.Require().NoError(err)
+	defer func() { _ = db.Close() }()
+	s.NoError(db.Put("test", "foo", []byte("bar")))
+
+	info, err := os.Stat(filepath.Dir(filename))
+	s.Require().NoError(err)
+	s.True(info.IsDir())
+	s.Zero(info.Mode().Perm() &^ 0750)
+}
@@ -0,0 +1,440 @@
+// Package kviteetcd is a minimal etcd clientv3-like API backed by a kvite bucket, so that components written
+// against etcd can run in single-node embedded mode. It supports Get, Put and Delete, optionally over a prefix,
+// Txn with compare/then/else, and Watch.
+//
+// Revisions are per key: KeyValue.Version is the kvite version of the key, and there is no store-wide revision.
+package kviteetcd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/mistifyio/kvite"
+)
+
+// Client reads and writes the keys of a single kvite bucket.
+type Client struct {
+	db     *kvite.DB
+	bucket string
+}
+
+// New returns a client for a bucket of db. The bucket is created on the first write.
+func New(db *kvite.DB, bucket string) *Client {
+	return &Client{db: db, bucket: bucket}
+}
+
+type (
+	// KeyValue is a key and its value. Version is the version of the key, as returned by kvite's
+	// Bucket.GetVersion.
+	KeyValue struct {
+		Key     []byte
+		Value   []byte
+		Version int64
+	}
+
+	// GetResponse is the result of Get. Count is the number of keys matched, which can be more than len(Kvs)
+	// when the result is limited.
+	GetResponse struct {
+		Kvs   []*KeyValue
+		Count int64
+	}
+
+	// PutResponse is the result of Put. PrevKv is set WithPrevKV if the key existed.
+	PutResponse struct {
+		PrevKv *KeyValue
+	}
+
+	// DeleteResponse is the result of Delete. PrevKvs is set WithPrevKV.
+	DeleteResponse struct {
+		Deleted int64
+		PrevKvs []*KeyValue
+	}
+)
+
+// OpOption configures an operation.
+type OpOption func(*Op)
+
+// WithPrefix makes an operation apply to every key beginning with its key.
+func WithPrefix() OpOption {
+	return func(op *Op) { op.prefix = true }
+}
+
+// WithLimit limits the number of keys returned by Get.
+func WithLimit(n int64) OpOption {
+	return func(op *Op) { op.limit = n }
+}
+
+// WithKeysOnly makes Get return keys without their values.
+func WithKeysOnly() OpOption {
+	return func(op *Op) { op.keysOnly = true }
+}
+
+// WithPrevKV makes Put and Delete return the values they replaced.
+func WithPrevKV() OpOption {
+	return func(op *Op) { op.prevKV = true }
+}
+
+type opType int
+
+const (
+	opGet opType = iota
+	opPut
+	opDelete
+)
+
+// Op is an operation to run in a Txn.
+type Op struct {
+	typ      opType
+	key      string
+	value    []byte
+	prefix   bool
+	limit    int64
+	keysOnly bool
+	prevKV   bool
+}
+
+func newOp(typ opType, key string, value []byte, opts []OpOption) Op {
+	op := Op{typ: typ, key: key, value: value}
+	for _, opt := range opts {
+		opt(&op)
+	}
+	return op
+}
+
+// OpGet returns a Get operation for a Txn.
+func OpGet(key string, opts ...OpOption) Op {
+	return newOp(opGet, key, nil, opts)
+}
+
+// OpPut returns a Put operation for a Txn.
+func OpPut(key, val string, opts ...OpOption) Op {
+	return newOp(opPut, key, []byte(val), opts)
+}
+
+// OpDelete returns a Delete operation for a Txn.
+func OpDelete(key string, opts ...OpOption) Op {
+	return newOp(opDelete, key, nil, opts)
+}
+
+// OpResponse is the result of an operation run by a Txn. Only the accessor matching the operation returns
+// a response.
+type OpResponse struct {
+	get *GetResponse
+	put *PutResponse
+	del *DeleteResponse
+}
+
+// Get returns the result of a Get operation.
+func (r OpResponse) Get() *GetResponse { return r.get }
+
+// Put returns the result of a Put operation.
+func (r OpResponse) Put() *PutResponse { return r.put }
+
+// Del returns the result of a Delete operation.
+func (r OpResponse) Del() *DeleteResponse { return r.del }
+
+// Get retrieves a key, or with WithPrefix the keys beginning with it in key order.
+func (c *Client) Get(ctx context.Context, key string, opts ...OpOption) (*GetResponse, error) {
+	resp, err := c.do(ctx, OpGet(key, opts...))
+	return resp.get, err
+}
+
+// Put sets the value of a key.
+func (c *Client) Put(ctx context.Context, key, val string, opts ...OpOption) (*PutResponse, error) {
+	resp, err := c.do(ctx, OpPut(key, val, opts...))
+	return resp.put, err
+}
+
+// Delete deletes a key, or with WithPrefix the keys beginning with it.
+func (c *Client) Delete(ctx context.Context, key string, opts ...OpOption) (*DeleteResponse, error) {
+	resp, err := c.do(ctx, OpDelete(key, opts...))
+	return resp.del, err
+}
+
+// do runs a single operation in a transaction of its own.
+func (c *Client) do(ctx context.Context, op Op) (OpResponse, error) {
+	if err := ctx.Err(); err != nil {
+		return OpResponse{}, err
+	}
+	var resp OpResponse
+	err := c.db.Transaction(func(tx *kvite.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(c.bucket)
+		if err != nil {
+			return err
+		}
+		resp, err = run(b, op)
+		return err
+	})
+	return resp, err
+}
+
+// run runs an operation on a bucket.
+func run(b *kvite.Bucket, op Op) (OpResponse, error) {
+	switch op.typ {
+	case opGet:
+		kvs, count, err := get(b, op)
+		return OpResponse{get: &GetResponse{Kvs: kvs, Count: count}}, err
+	case opPut:
+		resp := &PutResponse{}
+		if op.prevKV {
+			kvs, _, err := get(b, Op{key: op.key})
+			if err != nil {
+				return OpResponse{}, err
+			}
+			if len(kvs) > 0 {
+				resp.PrevKv = kvs[0]
+			}
+		}
+		return OpResponse{put: resp}, b.Put(op.key, op.value)
+	case opDelete:
+		kvs, _, err := get(b, Op{key: op.key, prefix: op.prefix})
+		if err != nil {
+			return OpResponse{}, err
+		}
+		resp := &DeleteResponse{Deleted: int64(len(kvs))}
+		if op.prevKV {
+			resp.PrevKvs = kvs
+		}
+		for _, kv := range kvs {
+			if err := b.Delete(string(kv.Key)); err != nil {
+				return OpResponse{}, err
+			}
+		}
+		return OpResponse{del: resp}, nil
+	}
+	return OpResponse{}, fmt.Errorf("unknown operation %d", op.typ)
+}
+
+// get reads the keys an operation applies to.
+func get(b *kvite.Bucket, op Op) ([]*KeyValue, int64, error) {
+	if !op.prefix {
+		value, version, err := b.GetVersion(op.key)
+		if err != nil || value == nil {
+			return nil, 0, err
+		}
+		if op.keysOnly {
+			value = nil
+		}
+		return []*KeyValue{{Key: []byte(op.key), Value: value, Version: version}}, 1, nil
+	}
+
+	var (
+		kvs   []*KeyValue
+		count int64
+	)
+	err := b.Query().Prefix(op.key).ForEach(func(k string, v []byte) error {
+		count++
+		if op.limit > 0 && int64(len(kvs)) >= op.limit {
+			return nil
+		}
+		_, version, err := b.GetVersion(k)
+		if err != nil {
+			return err
+		}
+		if op.keysOnly {
+			v = nil
+		}
+		kvs = append(kvs, &KeyValue{Key: []byte(k), Value: v, Version: version})
+		return nil
+	})
+	return kvs, count, err
+}
+
+// CompareTarget is what a Cmp compares.
+type CompareTarget int
+
+const (
+	// CompareValue compares the value of a key.
+	CompareValue CompareTarget = iota
+	// CompareVersion compares the version of a key, which is 0 if the key does not exist.
+	CompareVersion
+)
+
+// Cmp is a condition of a Txn, built with Compare.
+type Cmp struct {
+	Target CompareTarget
+	Key    string
+	Result string
+	value  interface{}
+}
+
+// Value is the value of a key, for Compare.
+func Value(key string) Cmp {
+	return Cmp{Target: CompareValue, Key: key}
+}
+
+// Version is the version of a key, for Compare.
+func Version(key string) Cmp {
+	return Cmp{Target: CompareVersion, Key: key}
+}
+
+// Compare builds a condition comparing cmp's target with v using result, which is one of "=", "!=", "<" and ">".
+// Values are compared with strings and versions with integers.
+func Compare(cmp Cmp, result string, v interface{}) Cmp {
+	cmp.Result = result
+	cmp.value = v
+	return cmp
+}
+
+// eval evaluates the condition against a bucket. A missing key's value compares as an empty string, but only
+// matches "!=".
+func (cmp Cmp) eval(b *kvite.Bucket) (bool, error) {
+	switch cmp.Result {
+	case "=", "!=", "<", ">":
+	default:
+		return false, fmt.Errorf("unknown comparison %q", cmp.Result)
+	}
+	value, version, err := b.GetVersion(cmp.Key)
+	if err != nil {
+		return false, err
+	}
+
+	var c int
+	switch cmp.Target {
+	case CompareValue:
+		want, ok := cmp.value.(string)
+		if !ok {
+			return false, errors.New("value comparisons need a string")
+		}
+		if value == nil {
+			return cmp.Result == "!=", nil
+		}
+		c = compareStrings(string(value), want)
+	case CompareVersion:
+		want, ok := toInt64(cmp.value)
+		if !ok {
+			return false, errors.New("version comparisons need an integer")
+		}
+		c = compareInts(version, want)
+	default:
+		return false, fmt.Errorf("unknown compare target %d", cmp.Target)
+	}
+
+	switch cmp.Result {
+	case "=":
+		return c == 0, nil
+	case "!=":
+		return c != 0, nil
+	case "<":
+		return c < 0, nil
+	}
+	return c > 0, nil
+}
+
+func compareStrings(a, b string) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func compareInts(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func toInt64(v interface{}) (int64, bool) {
+	switch n := v.(type) {
+	case int:
+		return int64(n), true
+	case int64:
+		return n, true
+	case int32:
+		return int64(n), true
+	}
+	return 0, false
+}
+
+// Txn runs operations depending on conditions, atomically. It is built with If, Then and Else and run with
+// Commit.
+type Txn struct {
+	c       *Client
+	ctx     context.Context
+	cmps    []Cmp
+	thenOps []Op
+	elseOps []Op
+}
+
+// TxnResponse is the result of a Txn. Succeeded reports whether every condition held, in which case Responses
+// are those of the Then operations, and otherwise of the Else operations.
+type TxnResponse struct {
+	Succeeded bool
+	Responses []OpResponse
+}
+
+// Txn starts building a transaction.
+func (c *Client) Txn(ctx context.Context) *Txn {
+	return &Txn{c: c, ctx: ctx}
+}
+
+// If adds conditions to the transaction.
+func (t *Txn) If(cmps ...Cmp) *Txn {
+	t.cmps = append(t.cmps, cmps...)
+	return t
+}
+
+// Then adds the operations run if every condition holds.
+func (t *Txn) Then(ops ...Op) *Txn {
+	t.thenOps = append(t.thenOps, ops...)
+	return t
+}
+
+// Else adds the operations run if a condition does not hold.
+func (t *Txn) Else(ops ...Op) *Txn {
+	t.elseOps = append(t.elseOps, ops...)
+	return t
+}
+
+// Commit evaluates the conditions and runs the chosen operations in a single kvite transaction. The conditions are
+// read before anything is written, so a Txn can fail with kvite.ErrBusy when other connections write at the same
+// time; open the database WithRetryPolicy to retry it.
+func (t *Txn) Commit() (*TxnResponse, error) {
+	if err := t.ctx.Err(); err != nil {
+		return nil, err
+	}
+	var resp *TxnResponse
+	err := t.c.db.Transaction(func(tx *kvite.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(t.c.bucket)
+		if err != nil {
+			return err
+		}
+
+		resp = &TxnResponse{Succeeded: true}
+		for _, cmp := range t.cmps {
+			ok, err := cmp.eval(b)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				resp.Succeeded = false
+				break
+			}
+		}
+
+		ops := t.thenOps
+		if !resp.Succeeded {
+			ops = t.elseOps
+		}
+		for _, op := range ops {
+			r, err := run(b, op)
+			if err != nil {
+				return err
+			}
+			resp.Responses = append(resp.Responses, r)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
@@ -0,0 +1,110 @@
+package kviteetcd
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/mistifyio/kvite/kvitetest"
+	"github.com/stretchr/testify/suite"
+)
+
+type KViteEtcdTestSuite struct {
+	suite.Suite
+	Client *Client
+}
+
+func (s *KViteEtcdTestSuite) SetupTest() {
+	s.Client = New(kvitetest.NewTestDB(s.T()), "etcd")
+}
+
+func TestKViteEtcdTestSuite(t *testing.T) {
+	suite.Run(t, new(KViteEtcdTestSuite))
+}
+
+func (s *KViteEtcdTestSuite) TestGetPutDelete() {
+	ctx := context.Background()
+	for _, key := range []string{"/a/1", "/a/2", "/a/3", "/b/1"} {
+		_, err := s.Client.Put(ctx, key, "v"+key)
+		s.Require().NoError(err)
+	}
+
+	resp, err := s.Client.Get(ctx, "/a/2")
+	s.NoError(err)
+	s.Equal([]*KeyValue{{Key: []byte("/a/2"), Value: []byte("v/a/2"), Version: 1}}, resp.Kvs)
+
+	resp, err = s.Client.Get(ctx, "/a/", WithPrefix(), WithLimit(2), WithKeysOnly())
+	s.NoError(err)
+	s.Equal(int64(3), resp.Count)
+	s.Len(resp.Kvs, 2)
+	s.Equal("/a/1", string(resp.Kvs[0].Key))
+	s.Nil(resp.Kvs[0].Value)
+
+	put, err := s.Client.Put(ctx, "/a/1", "new", WithPrevKV())
+	s.NoError(err)
+	s.Equal("v/a/1", string(put.PrevKv.Value))
+
+	del, err := s.Client.Delete(ctx, "/a/", WithPrefix(), WithPrevKV())
+	s.NoError(err)
+	s.Equal(int64(3), del.Deleted)
+	s.Len(del.PrevKvs, 3)
+
+	resp, err = s.Client.Get(ctx, "/", WithPrefix())
+	s.NoError(err)
+	s.Equal(int64(1), resp.Count)
+}
+
+func (s *KViteEtcdTestSuite) TestTxn() {
+	ctx := context.Background()
+
+	// Create the key only if it does not exist
+	create := func() *TxnResponse {
+		resp, err := s.Client.Txn(ctx).
+			If(Compare(Version("leader"), "=", 0)).
+			Then(OpPut("leader", "me")).
+			Else(OpGet("leader")).
+			Commit()
+		s.Require().NoError(err)
+		return resp
+	}
+	s.True(create().Succeeded)
+	resp := create()
+	s.False(resp.Succeeded)
+	s.Equal("me", string(resp.Responses[0].Get().Kvs[0].Value))
+
+	resp, err := s.Client.Txn(ctx).If(Compare(Value("leader"), "=", "me")).Then(OpDelete("leader")).Commit()
+	s.NoError(err)
+	s.True(resp.Succeeded)
+	s.Equal(int64(1), resp.Responses[0].Del().Deleted)
+
+	_, err = s.Client.Txn(ctx).If(Compare(Value("leader"), "~", "me")).Commit()
+	s.Error(err)
+}
+
+func (s *KViteEtcdTestSuite) TestWatch() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ch := s.Client.Watch(ctx, "/a/", WithPrefix())
+
+	_, err := s.Client.Put(ctx, "/b/1", "ignored")
+	s.NoError(err)
+	_, err = s.Client.Put(ctx, "/a/1", "v")
+	s.NoError(err)
+	_, err = s.Client.Delete(ctx, "/a/1")
+	s.NoError(err)
+
+	for _, want := range []EventType{EventTypePut, EventTypeDelete} {
+		select {
+		case resp := <-ch:
+			s.Require().Len(resp.Events, 1)
+			s.Equal(want, resp.Events[0].Type)
+			s.Equal("/a/1", string(resp.Events[0].Kv.Key))
+		case <-time.After(time.Second):
+			s.FailNow("no event")
+		}
+	}
+
+	cancel()
+	for range ch {
+	}
+}
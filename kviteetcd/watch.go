@@ -0,0 +1,74 @@
+package kviteetcd
+
+import (
+	"context"
+
+	"github.com/mistifyio/kvite"
+)
+
+// EventType is the kind of change an Event reports.
+type EventType int
+
+const (
+	// EventTypePut is a key being written.
+	EventTypePut EventType = iota
+	// EventTypeDelete is a key being deleted.
+	EventTypeDelete
+)
+
+// Event is a change of a key. Kv.Version is not set, as the change is delivered after its transaction commits.
+type Event struct {
+	Type EventType
+	Kv   *KeyValue
+}
+
+// WatchResponse carries the events of a committed transaction.
+type WatchResponse struct {
+	Events []*Event
+	// Canceled is set on the last response of a watch that stopped because it fell too far behind.
+	Canceled bool
+	err      error
+}
+
+// Err returns the reason a canceled watch stopped.
+func (r WatchResponse) Err() error {
+	return r.err
+}
+
+// WatchChan delivers the responses of a watch.
+type WatchChan <-chan WatchResponse
+
+// Watch delivers the changes of a key, or with WithPrefix of the keys beginning with it, committed through the
+// client's database. The channel is closed when ctx is done. Like kvite.DB.Watch, it does not see changes made by
+// other processes.
+func (c *Client) Watch(ctx context.Context, key string, opts ...OpOption) WatchChan {
+	op := newOp(opGet, key, nil, opts)
+	w := c.db.Watch(ctx, kvite.WatchFilter{Bucket: c.bucket, Prefix: key})
+	ch := make(chan WatchResponse)
+
+	go func() {
+		defer close(ch)
+		for change := range w.C {
+			if !op.prefix && change.Key != key {
+				continue
+			}
+			ev := &Event{Type: EventTypePut, Kv: &KeyValue{Key: []byte(change.Key), Value: change.Value}}
+			if change.Type == kvite.ChangeDelete || change.Type == kvite.ChangeExpire {
+				ev.Type = EventTypeDelete
+			}
+			select {
+			case ch <- WatchResponse{Events: []*Event{ev}}:
+			case <-ctx.Done():
+				w.Close()
+				return
+			}
+		}
+		if err := w.Err(); err != nil {
+			select {
+			case ch <- WatchResponse{Canceled: true, err: err}:
+			case <-ctx.Done():
+			}
+		}
+	}()
+	return ch
+}
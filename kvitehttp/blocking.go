@@ -0,0 +1,99 @@
+package kvitehttp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mistifyio/kvite"
+)
+
+// Blocking queries wait for DefaultWait unless ?wait asks for another duration, which is capped at MaxWait, as in
+// Consul.
+const (
+	DefaultWait = 5 * time.Minute
+	MaxWait     = 10 * time.Minute
+)
+
+// pollInterval is how often a blocking query reads the change feed. Changes committed through the handler's
+// database wake it straight away; polling catches those made by other processes.
+var pollInterval = time.Second
+
+// block implements Consul's blocking queries over the change feed. With ?index=N it waits until a change to the
+// key, or with prefix to a key beginning with it, is recorded after N, or until the wait is over. It returns the
+// index to report in X-Consul-Index, which is the sequence number of the last change in the feed.
+// It returns kvite.ErrNoChangeFeed if the database was not opened WithChangeFeed.
+func (h *Handler) block(r *http.Request, key string, prefix bool) (int64, error) {
+	last, err := h.db.LastChangeSeq()
+	if err != nil {
+		return 0, err
+	}
+	q := r.URL.Query()
+	if q.Get("index") == "" {
+		return last, nil
+	}
+	index, err := strconv.ParseInt(q.Get("index"), 10, 64)
+	if err != nil || index < 0 {
+		return 0, errors.New("invalid index")
+	}
+	// Index 0 does not block, and an index from the future belongs to a feed that was reset, so the client must
+	// start over.
+	if index == 0 || index > last {
+		return last, nil
+	}
+	wait, err := waitDuration(q.Get("wait"))
+	if err != nil {
+		return 0, err
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), wait)
+	defer cancel()
+	watcher := h.db.Watch(ctx, kvite.WatchFilter{Bucket: h.bucket, Prefix: key})
+	defer watcher.Close()
+	woken := watcher.C
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	seen := index
+	for {
+		changes, err := h.db.Changes(seen)
+		if err != nil {
+			return 0, err
+		}
+		for _, c := range changes {
+			seen = c.Seq
+			if c.Bucket == h.bucket && (c.Key == key || prefix && strings.HasPrefix(c.Key, key)) {
+				return h.db.LastChangeSeq()
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return h.db.LastChangeSeq()
+		case _, ok := <-woken:
+			if !ok {
+				// The watcher fell behind, so rely on polling
+				woken = nil
+			}
+		case <-ticker.C:
+		}
+	}
+}
+
+// waitDuration parses the ?wait parameter of a blocking query, such as "30s" or "5m".
+func waitDuration(s string) (time.Duration, error) {
+	if s == "" {
+		return DefaultWait, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, errors.New("invalid wait")
+	}
+	if d > MaxWait {
+		d = MaxWait
+	}
+	return d, nil
+}
@@ -0,0 +1,57 @@
+package kvitehttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"github.com/mistifyio/kvite"
+	"github.com/mistifyio/kvite/kvitetest"
+)
+
+func (s *KViteHTTPTestSuite) TestBlockingQuery() {
+	db := kvitetest.NewTestDB(s.T(), kvite.WithChangeFeed())
+	s.Server = httptest.NewServer(NewHandler(db, "consul"))
+	s.T().Cleanup(s.Server.Close)
+
+	s.do("PUT", "/v1/kv/watched", "1")
+	resp, err := http.Get(s.Server.URL + "/v1/kv/watched")
+	s.Require().NoError(err)
+	resp.Body.Close()
+	index := resp.Header.Get("X-Consul-Index")
+	s.Equal("1", index)
+
+	// Nothing changes, so the query waits it out
+	start := time.Now()
+	resp, err = http.Get(s.Server.URL + "/v1/kv/watched?wait=50ms&index=" + index)
+	s.Require().NoError(err)
+	resp.Body.Close()
+	s.GreaterOrEqual(time.Since(start), 50*time.Millisecond)
+	s.Equal(index, resp.Header.Get("X-Consul-Index"))
+
+	// Changes to other keys do not wake it, and a change to the key does
+	done := make(chan *http.Response)
+	go func() {
+		resp, err := http.Get(s.Server.URL + "/v1/kv/watched?wait=10s&index=" + index)
+		s.NoError(err)
+		done <- resp
+	}()
+	time.Sleep(20 * time.Millisecond)
+	s.do("PUT", "/v1/kv/other", "x")
+	select {
+	case <-done:
+		s.FailNow("woken by another key")
+	case <-time.After(50 * time.Millisecond):
+	}
+	s.do("PUT", "/v1/kv/watched", "2")
+
+	select {
+	case resp := <-done:
+		resp.Body.Close()
+		s.Equal(http.StatusOK, resp.StatusCode)
+		s.Equal("3", resp.Header.Get("X-Consul-Index"))
+	case <-time.After(5 * time.Second):
+		s.FailNow("blocking query was not woken")
+	}
+
+}
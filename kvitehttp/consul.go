@@ -0,0 +1,228 @@
+package kvitehttp
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"github.com/mistifyio/kvite"
+)
+
+// kvPath is the path of Consul's key/value endpoint.
+const kvPath = "/v1/kv/"
+
+// KVPair is a key as returned by Consul's key/value endpoint. Flags, LockIndex and Session are always zero.
+// CreateIndex and ModifyIndex are the kvite version of the key, so CAS with ?cas=ModifyIndex works as in Consul.
+type KVPair struct {
+	Key         string
+	CreateIndex uint64
+	ModifyIndex uint64
+	LockIndex   uint64
+	Flags       uint64
+	Value       []byte
+	Session     string `json:",omitempty"`
+}
+
+// errCASFailed rolls back a write whose check-and-set index did not match.
+var errCASFailed = errors.New("check-and-set index does not match")
+
+func (h *Handler) serveKV(w http.ResponseWriter, r *http.Request, key string) {
+	switch r.Method {
+	case http.MethodGet:
+		h.getKV(w, r, key)
+	case http.MethodPut:
+		h.putKV(w, r, key)
+	case http.MethodDelete:
+		h.deleteKV(w, r, key)
+	default:
+		w.Header().Set("Allow", "GET, PUT, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// getKV reads a key, the keys beginning with it with ?recurse, or only their names with ?keys. ?raw returns the
+// value of a single key as the response body, with the content type it was written with. With ?index it is a blocking query, see block.
+func (h *Handler) getKV(w http.ResponseWriter, r *http.Request, key string) {
+	q := r.URL.Query()
+	index, err := h.block(r, key, q.Has("recurse") || q.Has("keys"))
+	noFeed := err == kvite.ErrNoChangeFeed
+	if err != nil && !noFeed {
+		writeError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	var (
+		pairs []*KVPair
+		keys  []string
+		meta  kvite.ValueMeta
+	)
+	err = h.read(func(b *kvite.Bucket) (err error) {
+		switch {
+		case q.Has("keys"):
+			var prefixes []string
+			keys, prefixes, err = b.List(key, q.Get("separator"))
+			keys = append(keys, prefixes...)
+			sort.Strings(keys)
+			return err
+		case q.Has("recurse"):
+			pairs, err = readPairs(b, key)
+			return err
+		}
+		pair, err := readPair(b, key)
+		if pair != nil {
+			pairs = []*KVPair{pair}
+		}
+		if err == nil && pair != nil && q.Has("raw") {
+			_, meta, err = b.GetWithMeta(key)
+		}
+		return err
+	})
+	if err != nil {
+		writeError(w, err, http.StatusInternalServerError)
+		return
+	}
+
+	// Without a change feed, the index is the highest version of the keys read
+	if noFeed {
+		for _, p := range pairs {
+			if int64(p.ModifyIndex) > index {
+				index = int64(p.ModifyIndex)
+			}
+		}
+	}
+	if index < 1 {
+		index = 1
+	}
+	w.Header().Set("X-Consul-Index", strconv.FormatInt(index, 10))
+
+	switch {
+	case q.Has("keys"):
+		if len(keys) == 0 {
+			http.NotFound(w, r)
+			return
+		}
+		writeJSON(w, keys)
+	case len(pairs) == 0:
+		http.NotFound(w, r)
+	case q.Has("raw"):
+		if meta.ContentType != "" {
+			w.Header().Set("Content-Type", meta.ContentType)
+		}
+		_, _ = w.Write(pairs[0].Value)
+	default:
+		writeJSON(w, pairs)
+	}
+}
+
+func readPair(b *kvite.Bucket, key string) (*KVPair, error) {
+	value, version, err := b.GetVersion(key)
+	if err != nil || value == nil {
+		return nil, err
+	}
+	return newPair(key, value, version), nil
+}
+
+func readPairs(b *kvite.Bucket, prefix string) ([]*KVPair, error) {
+	var pairs []*KVPair
+	err := b.Query().Prefix(prefix).ForEach(func(k string, v []byte) error {
+		_, version, err := b.GetVersion(k)
+		pairs = append(pairs, newPair(k, v, version))
+		return err
+	})
+	return pairs, err
+}
+
+func newPair(key string, value []byte, version int64) *KVPair {
+	if len(value) == 0 {
+		value = nil
+	}
+	return &KVPair{Key: key, CreateIndex: uint64(version), ModifyIndex: uint64(version), Value: value}
+}
+
+// putKV writes the request body to a key. With ?cas=N the key is only written if its ModifyIndex is N, where 0
+// means the key must not exist. Without ?cas, the request's Content-Type is stored with the value. The response
+// body is true or false.
+func (h *Handler) putKV(w http.ResponseWriter, r *http.Request, key string) {
+	cas, ok, err := casIndex(r)
+	if err != nil {
+		writeError(w, err, http.StatusBadRequest)
+		return
+	}
+	value, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	err = h.db.Transaction(func(tx *kvite.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(h.bucket)
+		if err != nil {
+			return err
+		}
+		if ok {
+			return b.PutVersion(key, value, cas)
+		}
+		return b.PutWithMeta(key, value, kvite.ValueMeta{ContentType: r.Header.Get("Content-Type")})
+	})
+	h.writeResult(w, err)
+}
+
+// deleteKV deletes a key, or with ?recurse the keys beginning with it. With ?cas=N the key is only deleted if its
+// ModifyIndex is N. The response body is true or false.
+func (h *Handler) deleteKV(w http.ResponseWriter, r *http.Request, key string) {
+	cas, ok, err := casIndex(r)
+	if err != nil {
+		writeError(w, err, http.StatusBadRequest)
+		return
+	}
+
+	err = h.db.Transaction(func(tx *kvite.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(h.bucket)
+		if err != nil {
+			return err
+		}
+		if r.URL.Query().Has("recurse") {
+			_, err := b.DeletePrefix(key)
+			return err
+		}
+		if ok {
+			_, version, err := b.GetVersion(key)
+			if err != nil {
+				return err
+			}
+			if version != cas {
+				return errCASFailed
+			}
+		}
+		return b.Delete(key)
+	})
+	h.writeResult(w, err)
+}
+
+// writeResult reports the outcome of a write as Consul does: true if it was made, false if its check-and-set
+// failed.
+func (h *Handler) writeResult(w http.ResponseWriter, err error) {
+	switch {
+	case err == nil:
+		writeJSON(w, true)
+	case errors.Is(err, kvite.ErrVersionMismatch), errors.Is(err, errCASFailed):
+		writeJSON(w, false)
+	default:
+		writeError(w, err, http.StatusInternalServerError)
+	}
+}
+
+// casIndex parses the ?cas parameter of a write.
+func casIndex(r *http.Request) (int64, bool, error) {
+	s := r.URL.Query().Get("cas")
+	if s == "" {
+		return 0, false, nil
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 {
+		return 0, false, errors.New("invalid cas index")
+	}
+	return n, true, nil
+}
@@ -0,0 +1,106 @@
+package kvitehttp
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mistifyio/kvite/kvitetest"
+	"github.com/stretchr/testify/suite"
+)
+
+type KViteHTTPTestSuite struct {
+	suite.Suite
+	Server *httptest.Server
+}
+
+func (s *KViteHTTPTestSuite) SetupTest() {
+	s.Server = httptest.NewServer(NewHandler(kvitetest.NewTestDB(s.T()), "consul"))
+	s.T().Cleanup(s.Server.Close)
+}
+
+func TestKViteHTTPTestSuite(t *testing.T) {
+	suite.Run(t, new(KViteHTTPTestSuite))
+}
+
+// do sends a request and returns the status and body of the response.
+func (s *KViteHTTPTestSuite) do(method, path, body string) (int, string) {
+	req, err := http.NewRequest(method, s.Server.URL+path, strings.NewReader(body))
+	s.Require().NoError(err)
+	resp, err := http.DefaultClient.Do(req)
+	s.Require().NoError(err)
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	s.Require().NoError(err)
+	return resp.StatusCode, strings.TrimSpace(string(b))
+}
+
+func (s *KViteHTTPTestSuite) pairs(path string) []KVPair {
+	code, body := s.do("GET", path, "")
+	s.Require().Equal(http.StatusOK, code, body)
+	var pairs []KVPair
+	s.Require().NoError(json.Unmarshal([]byte(body), &pairs))
+	return pairs
+}
+
+func (s *KViteHTTPTestSuite) TestConsulKV() {
+	code, _ := s.do("GET", "/v1/kv/app/name", "")
+	s.Equal(http.StatusNotFound, code)
+
+	for key, value := range map[string]string{"app/name": "kvite", "app/db/host": "localhost", "other": "x"} {
+		code, body := s.do("PUT", "/v1/kv/"+key, value)
+		s.Equal(http.StatusOK, code)
+		s.Equal("true", body)
+	}
+
+	pairs := s.pairs("/v1/kv/app/name")
+	s.Require().Len(pairs, 1)
+	s.Equal("kvite", string(pairs[0].Value))
+	s.Equal(uint64(1), pairs[0].ModifyIndex)
+
+	_, body := s.do("GET", "/v1/kv/app/name?raw", "")
+	s.Equal("kvite", body)
+
+	pairs = s.pairs("/v1/kv/app/?recurse")
+	s.Len(pairs, 2)
+
+	_, body = s.do("GET", "/v1/kv/app/?keys&separator=/", "")
+	s.Equal(`["app/db/","app/name"]`, body)
+
+	// Check-and-set
+	_, body = s.do("PUT", "/v1/kv/app/name?cas=0", "again")
+	s.Equal("false", body)
+	_, body = s.do("PUT", "/v1/kv/app/name?cas=1", "v2")
+	s.Equal("true", body)
+	_, body = s.do("DELETE", "/v1/kv/app/name?cas=1", "")
+	s.Equal("false", body)
+	_, body = s.do("DELETE", "/v1/kv/app/name?cas=2", "")
+	s.Equal("true", body)
+
+	_, body = s.do("DELETE", "/v1/kv/app/?recurse", "")
+	s.Equal("true", body)
+	code, _ = s.do("GET", "/v1/kv/app/?recurse", "")
+	s.Equal(http.StatusNotFound, code)
+	s.Len(s.pairs("/v1/kv/?recurse"), 1)
+}
+
+func (s *KViteHTTPTestSuite) TestConsulContentType() {
+	req, err := http.NewRequest("PUT", s.Server.URL+"/v1/kv/config.json", strings.NewReader(`{"a":1}`))
+	s.Require().NoError(err)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	s.Require().NoError(err)
+	resp.Body.Close()
+	s.Equal(http.StatusOK, resp.StatusCode)
+
+	resp, err = http.Get(s.Server.URL + "/v1/kv/config.json?raw")
+	s.Require().NoError(err)
+	defer resp.Body.Close()
+	s.Equal("application/json", resp.Header.Get("Content-Type"))
+	body, err := io.ReadAll(resp.Body)
+	s.NoError(err)
+	s.Equal(`{"a":1}`, string(body))
+}
@@ -0,0 +1,70 @@
+package kvitehttp
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/mistifyio/kvite"
+)
+
+// streamPath is the path of the endpoints streaming committed changes.
+const streamPath = "/v1/stream/"
+
+// Event is a committed change of a key, as streamed to clients. Value is base64 encoded in JSON, and is omitted
+// for deletes.
+type Event struct {
+	Bucket string `json:"bucket"`
+	Key    string `json:"key"`
+	// Op is "put", "delete" or "expire".
+	Op    string `json:"op"`
+	Value []byte `json:"value,omitempty"`
+}
+
+// eventStream delivers the committed changes of the handler's bucket that match the filter of a request.
+// Each ?prefix parameter adds a key prefix to match; without any, every key matches. Like kvite.DB.Watch, it
+// only sees changes committed through the handler's database.
+type eventStream struct {
+	C <-chan Event
+
+	w *kvite.Watcher
+}
+
+func (h *Handler) events(ctx context.Context, r *http.Request) *eventStream {
+	prefixes := r.URL.Query()["prefix"]
+	w := h.db.Watch(ctx, kvite.WatchFilter{Bucket: h.bucket})
+	c := make(chan Event)
+
+	go func() {
+		defer close(c)
+		for change := range w.C {
+			if !matchPrefixes(change.Key, prefixes) {
+				continue
+			}
+			select {
+			case c <- Event{Bucket: change.Bucket, Key: change.Key, Op: change.Type.String(), Value: change.Value}:
+			case <-ctx.Done():
+				w.Close()
+				return
+			}
+		}
+	}()
+	return &eventStream{C: c, w: w}
+}
+
+// Err returns the reason the stream ended early, such as the client falling too far behind.
+func (s *eventStream) Err() error {
+	return s.w.Err()
+}
+
+func matchPrefixes(key string, prefixes []string) bool {
+	if len(prefixes) == 0 {
+		return true
+	}
+	for _, p := range prefixes {
+		if strings.HasPrefix(key, p) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,69 @@
+// Package kvitehttp serves a kvite bucket over HTTP. The Handler implements the key/value API of Consul, so that
+// tools written for Consul, such as consul-template and envconsul, can point at an embedded store in development.
+//
+// Blocking queries (?index=N&wait=30s) need a database opened with kvite.WithChangeFeed, whose sequence numbers
+// are reported in X-Consul-Index. Without a change feed, queries return straight away.
+//
+// Committed changes are also streamed as JSON events, for UIs and sidecars that want live updates.
+package kvitehttp
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/mistifyio/kvite"
+)
+
+// Handler serves the keys of a kvite bucket under /v1/kv/, and streams their changes from /v1/stream/ws as
+// WebSocket messages and from /v1/stream/sse as server-sent events.
+type Handler struct {
+	db     *kvite.DB
+	bucket string
+}
+
+// NewHandler returns a handler serving a bucket of db.
+func NewHandler(db *kvite.DB, bucket string) *Handler {
+	return &Handler{db: db, bucket: bucket}
+}
+
+// ServeHTTP routes a request to the endpoint its path names.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case strings.HasPrefix(r.URL.Path, kvPath):
+		h.serveKV(w, r, strings.TrimPrefix(r.URL.Path, kvPath))
+	case r.URL.Path == streamPath+"ws":
+		h.serveWebSocket(w, r)
+	case r.URL.Path == streamPath+"sse":
+		h.serveSSE(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// read runs fn with the bucket in a read transaction. fn is not called if the bucket does not exist.
+func (h *Handler) read(fn func(b *kvite.Bucket) error) error {
+	tx, err := h.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	b, err := tx.Bucket(h.bucket)
+	if err == kvite.ErrBucketNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return fn(b)
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, err error, code int) {
+	http.Error(w, err.Error(), code)
+}
@@ -0,0 +1,55 @@
+package kvitehttp
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// sseKeepAlive is how often an idle server-sent events stream sends a comment, so that proxies do not time it out.
+var sseKeepAlive = 15 * time.Second
+
+// serveSSE streams the same events as serveWebSocket, with the same filters, as server-sent events. Each event is
+// a message whose data is the JSON event. If the client falls too far behind, an "error" event is sent and the
+// stream ends.
+func (h *Handler) serveSSE(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported by the server", http.StatusInternalServerError)
+		return
+	}
+	stream := h.events(r.Context(), r)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	ticker := time.NewTicker(sseKeepAlive)
+	defer ticker.Stop()
+	for {
+		select {
+		case ev, ok := <-stream.C:
+			if !ok {
+				if err := stream.Err(); err != nil {
+					fmt.Fprintf(w, "event: error\ndata: %s\n\n", err)
+					flusher.Flush()
+				}
+				return
+			}
+			msg, err := json.Marshal(ev)
+			if err != nil {
+				return
+			}
+			if _, err := fmt.Fprintf(w, "data: %s\n\n", msg); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
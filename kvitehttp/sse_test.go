@@ -0,0 +1,36 @@
+package kvitehttp
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/mistifyio/kvite"
+	"github.com/mistifyio/kvite/kvitetest"
+)
+
+func (s *KViteHTTPTestSuite) TestSSE() {
+	db := kvitetest.NewTestDB(s.T())
+	s.Server.Config.Handler = NewHandler(db, "consul")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "GET", s.Server.URL+"/v1/stream/sse?prefix=app/", nil)
+	s.Require().NoError(err)
+	resp, err := http.DefaultClient.Do(req)
+	s.Require().NoError(err)
+	defer resp.Body.Close()
+	s.Equal("text/event-stream", resp.Header.Get("Content-Type"))
+
+	s.NoError(db.Transaction(func(tx *kvite.Tx) error {
+		b, _ := tx.CreateBucket("consul")
+		s.NoError(b.Put("other", []byte("x")))
+		return b.Put("app/name", []byte("kvite"))
+	}))
+
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	s.Require().NoError(err)
+	s.Equal(`data: {"bucket":"consul","key":"app/name","op":"put","value":"a3ZpdGU="}`, strings.TrimSpace(line))
+}
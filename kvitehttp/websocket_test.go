@@ -0,0 +1,81 @@
+package kvitehttp
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/mistifyio/kvite"
+	"github.com/mistifyio/kvite/kvitetest"
+)
+
+func (s *KViteHTTPTestSuite) TestWebSocket() {
+	db := kvitetest.NewTestDB(s.T())
+	h := NewHandler(db, "consul")
+	s.Server.Config.Handler = h
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(s.Server.URL, "http://"))
+	s.Require().NoError(err)
+	defer conn.Close()
+	_, err = conn.Write([]byte("GET /v1/stream/ws?prefix=app/ HTTP/1.1\r\nHost: kvite\r\nUpgrade: websocket\r\n" +
+		"Connection: Upgrade\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\nSec-WebSocket-Version: 13\r\n\r\n"))
+	s.Require().NoError(err)
+
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, nil)
+	s.Require().NoError(err)
+	s.Equal(http.StatusSwitchingProtocols, resp.StatusCode)
+	s.Equal("s3pPLMBiTxaQ9kYGzzhZRbK+xOo=", resp.Header.Get("Sec-WebSocket-Accept"))
+
+	// The stream is subscribed before the handshake completes, so these are not missed
+	s.NoError(db.Transaction(func(tx *kvite.Tx) error {
+		b, _ := tx.CreateBucket("consul")
+		s.NoError(b.Put("other", []byte("x")))
+		s.NoError(b.Put("app/name", []byte("kvite")))
+		return b.Delete("app/name")
+	}))
+
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for _, want := range []Event{
+		{Bucket: "consul", Key: "app/name", Op: "put", Value: []byte("kvite")},
+		{Bucket: "consul", Key: "app/name", Op: "delete"},
+	} {
+		op, payload := s.readTextFrame(r)
+		s.Equal(byte(opText), op)
+		var ev Event
+		s.NoError(json.Unmarshal(payload, &ev))
+		s.Equal(want, ev)
+	}
+
+	// Pings are answered, and a close is echoed
+	_, err = conn.Write([]byte{0x80 | opPing, 0x80 | 2, 0, 0, 0, 0, 'h', 'i'})
+	s.NoError(err)
+	op, payload := s.readTextFrame(r)
+	s.Equal(byte(opPong), op)
+	s.Equal("hi", string(payload))
+	_, err = conn.Write([]byte{0x80 | opClose, 0x80, 0, 0, 0, 0})
+	s.NoError(err)
+	op, _ = s.readTextFrame(r)
+	s.Equal(byte(opClose), op)
+}
+
+// readTextFrame reads an unmasked frame sent by the server, including data frames, which readFrame skips.
+func (s *KViteHTTPTestSuite) readTextFrame(r *bufio.Reader) (byte, []byte) {
+	hdr := make([]byte, 2)
+	_, err := io.ReadFull(r, hdr)
+	s.Require().NoError(err)
+	s.Require().Less(int(hdr[1]), 126, "test messages are short")
+	payload := make([]byte, hdr[1])
+	_, err = io.ReadFull(r, payload)
+	s.Require().NoError(err)
+	return hdr[0] & 0x0f, payload
+}
+
+func (s *KViteHTTPTestSuite) TestWebSocketHandshake() {
+	code, _ := s.do("GET", "/v1/stream/ws", "")
+	s.Equal(http.StatusBadRequest, code)
+}
@@ -0,0 +1,14 @@
+package kvitemem
+
+import (
+	"testing"
+
+	"github.com/mistifyio/kvite/internal/conformance"
+	"github.com/mistifyio/kvite/kv"
+)
+
+func TestConformance(t *testing.T) {
+	conformance.Run(t, func() (kv.Store, error) {
+		return NewStore(Open()), nil
+	})
+}
@@ -0,0 +1,393 @@
+// Package kvitemem is an in-memory implementation of the core kvite API, for unit tests that should not depend on
+// cgo or SQLite. DB, Tx and Bucket have the same methods and transactional behaviour as their kvite counterparts:
+//
+//   - A transaction reads the data as it was when the transaction began, plus its own writes.
+//   - One transaction at a time may write. A transaction that writes while another one is writing waits for it
+//     to finish.
+//   - A transaction that writes after another transaction has committed since it began fails with ErrConflict,
+//     as SQLite does in WAL mode.
+//
+// The errors are those of package kv, which kvite returns as well, so that errors.Is and errors.As behave the same
+// with both. Nothing is persisted.
+package kvitemem
+
+import (
+	"errors"
+	"runtime/debug"
+	"sort"
+	"sync"
+
+	"github.com/mistifyio/kvite/kv"
+)
+
+var (
+	// ErrTxFinished is returned when using a transaction that has been committed or rolled back, like kvite's.
+	ErrTxFinished = kv.ErrTxFinished
+	// ErrDBClosed is returned when beginning a transaction on a database that has been closed.
+	ErrDBClosed = kv.ErrDBClosed
+	// ErrConflict is returned when a transaction writes after another transaction has committed since it began.
+	// It is kv.ErrBusy, which kvite's errors for such writes match with errors.Is.
+	ErrConflict = kv.ErrBusy
+	// ErrBucketNotFound is returned by Bucket when the bucket does not exist.
+	ErrBucketNotFound = kv.ErrBucketNotFound
+)
+
+// PanicError is returned by DB.Transaction when its function panics.
+type PanicError = kv.PanicError
+
+type (
+	// DB is an in-memory key/value store.
+	DB struct {
+		// writer is held by the transaction that is writing, if any.
+		writer chan struct{}
+
+		mu   sync.Mutex
+		data map[string]map[string][]byte
+		// buckets holds the names of the buckets that have been created, which exist even without keys.
+		buckets map[string]bool
+		version uint64
+		closed  bool
+	}
+
+	// Tx is a transaction on a DB.
+	Tx struct {
+		db      *DB
+		data    map[string]map[string][]byte
+		buckets map[string]bool
+		version uint64
+		// created holds the buckets created by the transaction.
+		created map[string]bool
+		// writes holds the values written by the transaction, with nil for deleted keys.
+		writes  map[string]map[string][]byte
+		writing bool
+		managed bool
+		done    bool
+	}
+
+	// Bucket represents a collection of key/value pairs inside the database.
+	Bucket struct {
+		name string
+		tx   *Tx
+	}
+)
+
+// Open returns a new, empty database.
+func Open() *DB {
+	return &DB{
+		writer:  make(chan struct{}, 1),
+		data:    make(map[string]map[string][]byte),
+		buckets: make(map[string]bool),
+	}
+}
+
+// Close closes the database. Transactions begun afterwards fail with ErrDBClosed.
+func (db *DB) Close() error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.closed = true
+	return nil
+}
+
+// Begin starts a transaction.
+func (db *DB) Begin() (*Tx, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	if db.closed {
+		return nil, ErrDBClosed
+	}
+	return &Tx{
+		db:      db,
+		data:    db.data,
+		buckets: db.buckets,
+		version: db.version,
+		created: make(map[string]bool),
+		writes:  make(map[string]map[string][]byte),
+	}, nil
+}
+
+// Transaction executes a function within the context of a managed transaction.
+// If no error is returned from the function then the transaction is committed.
+// If an error is returned then the entire transaction is rolled back.
+// Rollback and Commit cannot be used inside of the function.
+// If the function panics, the transaction is rolled back and a *PanicError is returned.
+func (db *DB) Transaction(fn func(*Tx) error) (err error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		p := recover()
+		if p == nil {
+			return
+		}
+		tx.managed = false
+		_ = tx.Rollback()
+		err = &PanicError{Value: p, Stack: debug.Stack()}
+	}()
+
+	tx.managed = true
+	err = fn(tx)
+	tx.managed = false
+	if err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// Buckets returns all the buckets that have been created or have keys, in order.
+func (db *DB) Buckets() ([]string, error) {
+	db.mu.Lock()
+	data, buckets := db.data, db.buckets
+	db.mu.Unlock()
+	return bucketNames(data, buckets), nil
+}
+
+func bucketNames(data map[string]map[string][]byte, buckets map[string]bool) []string {
+	names := make([]string, 0, len(data)+len(buckets))
+	for name := range buckets {
+		names = append(names, name)
+	}
+	for name := range data {
+		if !buckets[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Commit commits the transaction.
+func (tx *Tx) Commit() error {
+	if tx.managed {
+		return errors.New("managed tx commit not allowed")
+	}
+	if tx.done {
+		return ErrTxFinished
+	}
+	tx.done = true
+	if !tx.writing {
+		return nil
+	}
+	defer tx.releaseWriter()
+
+	db := tx.db
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	// Copy the buckets that changed, leaving the data seen by other transactions untouched.
+	data := make(map[string]map[string][]byte, len(db.data))
+	for name, keys := range db.data {
+		data[name] = keys
+	}
+	for name, writes := range tx.writes {
+		keys := make(map[string][]byte, len(data[name])+len(writes))
+		for k, v := range data[name] {
+			keys[k] = v
+		}
+		for k, v := range writes {
+			if v == nil {
+				delete(keys, k)
+			} else {
+				keys[k] = v
+			}
+		}
+		if len(keys) == 0 {
+			delete(data, name)
+		} else {
+			data[name] = keys
+		}
+	}
+	db.data = data
+	if len(tx.created) > 0 {
+		buckets := make(map[string]bool, len(db.buckets)+len(tx.created))
+		for name := range db.buckets {
+			buckets[name] = true
+		}
+		for name := range tx.created {
+			buckets[name] = true
+		}
+		db.buckets = buckets
+	}
+	db.version++
+	return nil
+}
+
+// Rollback aborts the transaction.
+func (tx *Tx) Rollback() error {
+	if tx.managed {
+		return errors.New("managed tx commit not allowed")
+	}
+	if tx.done {
+		return ErrTxFinished
+	}
+	tx.done = true
+	if tx.writing {
+		tx.releaseWriter()
+	}
+	return nil
+}
+
+// beginWrite makes tx the writing transaction, waiting for any other writer to finish first.
+func (tx *Tx) beginWrite() error {
+	if tx.done {
+		return ErrTxFinished
+	}
+	if tx.writing {
+		return nil
+	}
+	tx.db.writer <- struct{}{}
+
+	tx.db.mu.Lock()
+	stale := tx.db.version != tx.version
+	tx.db.mu.Unlock()
+	if stale {
+		<-tx.db.writer
+		return ErrConflict
+	}
+	tx.writing = true
+	return nil
+}
+
+func (tx *Tx) releaseWriter() {
+	tx.writing = false
+	<-tx.db.writer
+}
+
+// Bucket gets a bucket by name. It returns ErrBucketNotFound if the bucket has not been created and has no keys.
+func (tx *Tx) Bucket(name string) (*Bucket, error) {
+	if tx.done {
+		return nil, ErrTxFinished
+	}
+	b := &Bucket{name: name, tx: tx}
+	if !tx.created[name] && !tx.buckets[name] && len(b.keys()) == 0 {
+		return nil, ErrBucketNotFound
+	}
+	return b, nil
+}
+
+// CreateBucket records a bucket, so that it is listed by Buckets even while it has no keys. Like kvite's, it does
+// not fail if the bucket already exists.
+func (tx *Tx) CreateBucket(name string) (*Bucket, error) {
+	if err := tx.beginWrite(); err != nil {
+		return nil, err
+	}
+	tx.created[name] = true
+	return &Bucket{name: name, tx: tx}, nil
+}
+
+// CreateBucketIfNotExists records a bucket if it is not there yet. It is the same as CreateBucket.
+func (tx *Tx) CreateBucketIfNotExists(name string) (*Bucket, error) {
+	return tx.CreateBucket(name)
+}
+
+// Put sets the value for a key in the bucket. If the key exists, then its previous value will be overwritten.
+// A nil or empty value is stored as an empty value.
+func (b *Bucket) Put(key string, value []byte) error {
+	if err := b.tx.beginWrite(); err != nil {
+		return err
+	}
+	b.write(key, append([]byte{}, value...))
+	return nil
+}
+
+// Delete removes a key from the bucket. If the key does not exist then nothing is done and a nil error is returned.
+func (b *Bucket) Delete(key string) error {
+	if err := b.tx.beginWrite(); err != nil {
+		return err
+	}
+	b.write(key, nil)
+	return nil
+}
+
+func (b *Bucket) write(key string, value []byte) {
+	writes := b.tx.writes[b.name]
+	if writes == nil {
+		writes = make(map[string][]byte)
+		b.tx.writes[b.name] = writes
+	}
+	writes[key] = value
+}
+
+// Update reads the current value of a key, passes it to fn and stores the value fn returns, all within the
+// surrounding transaction. old is nil if the key does not exist. If fn returns a nil value the key is deleted,
+// and if it returns an error nothing is written and the error is returned.
+func (b *Bucket) Update(key string, fn func(old []byte) ([]byte, error)) error {
+	old, err := b.Get(key)
+	if err != nil {
+		return err
+	}
+	value, err := fn(old)
+	if err != nil {
+		return err
+	}
+	if value == nil {
+		if old == nil {
+			return nil
+		}
+		return b.Delete(key)
+	}
+	return b.Put(key, value)
+}
+
+// Get retrieves the value for a key in the bucket. Returns a nil value if the key does not exist.
+func (b *Bucket) Get(key string) ([]byte, error) {
+	if b.tx.done {
+		return nil, ErrTxFinished
+	}
+	value, ok := b.lookup(key)
+	if !ok {
+		return nil, nil
+	}
+	return append([]byte{}, value...), nil
+}
+
+// Has reports whether a key exists in the bucket, including keys with an empty value.
+func (b *Bucket) Has(key string) (bool, error) {
+	if b.tx.done {
+		return false, ErrTxFinished
+	}
+	_, ok := b.lookup(key)
+	return ok, nil
+}
+
+func (b *Bucket) lookup(key string) ([]byte, bool) {
+	if value, ok := b.tx.writes[b.name][key]; ok {
+		return value, value != nil
+	}
+	value, ok := b.tx.data[b.name][key]
+	return value, ok
+}
+
+// keys returns the keys of the bucket in order.
+func (b *Bucket) keys() []string {
+	keys := make([]string, 0, len(b.tx.data[b.name]))
+	for k := range b.tx.data[b.name] {
+		if _, ok := b.tx.writes[b.name][k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	for k, v := range b.tx.writes[b.name] {
+		if v != nil {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ForEach executes a function for each key/value pair in a bucket, in key order. If the provided function returns
+// an error then the iteration is stopped and the error is returned to the caller.
+func (b *Bucket) ForEach(fn func(k string, v []byte) error) error {
+	if b.tx.done {
+		return ErrTxFinished
+	}
+	for _, k := range b.keys() {
+		value, _ := b.lookup(k)
+		if err := fn(k, append([]byte{}, value...)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
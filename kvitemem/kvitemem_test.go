@@ -0,0 +1,93 @@
+package kvitemem
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type KViteMemTestSuite struct {
+	suite.Suite
+	DB *DB
+}
+
+func (s *KViteMemTestSuite) SetupTest() {
+	s.DB = Open()
+}
+
+func TestKViteMemTestSuite(t *testing.T) {
+	suite.Run(t, new(KViteMemTestSuite))
+}
+
+func (s *KViteMemTestSuite) TestSnapshotRead() {
+	s.NoError(s.DB.Transaction(func(tx *Tx) error {
+		b, _ := tx.CreateBucket("test")
+		return b.Put("k", []byte("old"))
+	}))
+
+	reader, err := s.DB.Begin()
+	s.Require().NoError(err)
+	s.NoError(s.DB.Transaction(func(tx *Tx) error {
+		b, _ := tx.Bucket("test")
+		return b.Put("k", []byte("new"))
+	}))
+
+	b, _ := reader.Bucket("test")
+	value, err := b.Get("k")
+	s.NoError(err)
+	s.Equal([]byte("old"), value)
+	s.Equal(ErrConflict, b.Put("k", []byte("stale")))
+	s.NoError(reader.Rollback())
+}
+
+func (s *KViteMemTestSuite) TestWritersWait() {
+	first, err := s.DB.Begin()
+	s.Require().NoError(err)
+	b, _ := first.CreateBucket("test")
+	s.NoError(b.Put("k", []byte("first")))
+
+	done := make(chan error)
+	go func() {
+		second, err := s.DB.Begin()
+		if err == nil {
+			var b *Bucket
+			if b, err = second.CreateBucket("test"); err == nil {
+				if err = b.Put("other", []byte("second")); err == nil {
+					err = second.Commit()
+				}
+			}
+		}
+		done <- err
+	}()
+
+	select {
+	case <-done:
+		s.Fail("second writer did not wait")
+	case <-time.After(20 * time.Millisecond):
+	}
+	s.NoError(first.Commit())
+	// The second transaction began before the first committed, so its write conflicts.
+	s.Equal(ErrConflict, <-done)
+}
+
+func (s *KViteMemTestSuite) TestBucketRegistry() {
+	s.NoError(s.DB.Transaction(func(tx *Tx) error {
+		_, err := tx.Bucket("test")
+		s.Equal(ErrBucketNotFound, err)
+		_, err = tx.CreateBucket("test")
+		s.NoError(err)
+		_, err = tx.Bucket("test")
+		return err
+	}))
+
+	// A created bucket exists before it has keys, and another transaction sees it once committed.
+	tx, err := s.DB.Begin()
+	s.Require().NoError(err)
+	_, err = tx.Bucket("test")
+	s.NoError(err)
+	s.NoError(tx.Rollback())
+	buckets, err := s.DB.Buckets()
+	s.NoError(err)
+	s.Equal([]string{"test"}, buckets)
+}
@@ -0,0 +1,42 @@
+package kvitemem
+
+import "github.com/mistifyio/kvite/kv"
+
+var _ kv.KVBucket = (*Bucket)(nil)
+
+// NewStore returns db as a kv.Store, so that it can stand in for a kvite database adapted with kvite.NewStore.
+func NewStore(db *DB) kv.Store {
+	return store{db}
+}
+
+type store struct{ *DB }
+
+func (s store) Begin() (kv.Transactor, error) {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return transactor{tx}, nil
+}
+
+func (s store) Transaction(fn func(kv.Transactor) error) error {
+	return s.DB.Transaction(func(tx *Tx) error { return fn(transactor{tx}) })
+}
+
+type transactor struct{ *Tx }
+
+func (t transactor) Bucket(name string) (kv.KVBucket, error) {
+	b, err := t.Tx.Bucket(name)
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (t transactor) CreateBucket(name string) (kv.KVBucket, error) {
+	b, err := t.Tx.CreateBucket(name)
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
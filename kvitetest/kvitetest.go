@@ -0,0 +1,95 @@
+// Package kvitetest provides helpers for tests of code that uses kvite.
+package kvitetest
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mistifyio/kvite"
+)
+
+// NewTestDB opens a database in a new temporary directory. The database is closed and the directory removed when
+// the test finishes. Any failure stops the test.
+func NewTestDB(t testing.TB, opts ...kvite.Option) *kvite.DB {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "kvitetest-")
+	if err != nil {
+		t.Fatalf("kvitetest: creating temp dir: %v", err)
+	}
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+
+	db, err := kvite.Open(filepath.Join(dir, "kvite.db"), "kvite", opts...)
+	if err != nil {
+		t.Fatalf("kvitetest: opening database: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+	return db
+}
+
+// Fixtures maps bucket names to the keys and values to store in them.
+type Fixtures map[string]map[string]string
+
+// Load writes fixtures to db in a single transaction. Any failure stops the test.
+func Load(t testing.TB, db *kvite.DB, fixtures Fixtures) {
+	t.Helper()
+
+	err := db.Transaction(func(tx *kvite.Tx) error {
+		for bucket, keys := range fixtures {
+			b, err := tx.CreateBucketIfNotExists(bucket)
+			if err != nil {
+				return err
+			}
+			for key, value := range keys {
+				if err := b.Put(key, []byte(value)); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("kvitetest: loading fixtures: %v", err)
+	}
+}
+
+// LoadFile writes the fixtures in a JSON file to db. The file holds an object of buckets, each an object of string
+// keys and values, as in Fixtures. Any failure stops the test.
+func LoadFile(t testing.TB, db *kvite.DB, path string) {
+	t.Helper()
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("kvitetest: reading fixtures: %v", err)
+	}
+	var fixtures Fixtures
+	if err := json.Unmarshal(data, &fixtures); err != nil {
+		t.Fatalf("kvitetest: parsing fixtures %s: %v", path, err)
+	}
+	Load(t, db, fixtures)
+}
+
+// Get returns the value of a key, or nil if it does not exist. Any failure stops the test.
+func Get(t testing.TB, db *kvite.DB, bucket, key string) []byte {
+	t.Helper()
+
+	var value []byte
+	err := db.Transaction(func(tx *kvite.Tx) error {
+		b, err := tx.Bucket(bucket)
+		if err == kvite.ErrBucketNotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		value, err = b.Get(key)
+		return err
+	})
+	if err != nil {
+		t.Fatalf("kvitetest: getting %s/%s: %v", bucket, key, err)
+	}
+	return value
+}
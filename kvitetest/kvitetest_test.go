@@ -0,0 +1,50 @@
+package kvitetest
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/mistifyio/kvite"
+	"github.com/stretchr/testify/suite"
+)
+
+type KViteTestTestSuite struct {
+	suite.Suite
+}
+
+func TestKViteTestTestSuite(t *testing.T) {
+	suite.Run(t, new(KViteTestTestSuite))
+}
+
+func (s *KViteTestTestSuite) TestNewTestDB() {
+	var db *kvite.DB
+	s.Run("open", func() {
+		db = NewTestDB(s.T(), kvite.WithChecksums())
+		s.NoError(db.Ping(context.Background()))
+	})
+	// The database was closed when the subtest finished.
+	_, err := db.Begin()
+	s.Equal(kvite.ErrDBClosed, err)
+}
+
+func (s *KViteTestTestSuite) TestLoad() {
+	db := NewTestDB(s.T())
+	Load(s.T(), db, Fixtures{
+		"a": {"k1": "v1", "k2": "v2"},
+		"b": {"k": ""},
+	})
+	s.Equal([]byte("v1"), Get(s.T(), db, "a", "k1"))
+	s.Equal([]byte{}, Get(s.T(), db, "b", "k"))
+	s.Nil(Get(s.T(), db, "b", "missing"))
+}
+
+func (s *KViteTestTestSuite) TestLoadFile() {
+	path := filepath.Join(s.T().TempDir(), "fixtures.json")
+	s.Require().NoError(ioutil.WriteFile(path, []byte(`{"users": {"alice": "admin"}}`), 0644))
+
+	db := NewTestDB(s.T())
+	LoadFile(s.T(), db, path)
+	s.Equal([]byte("admin"), Get(s.T(), db, "users", "alice"))
+}
@@ -0,0 +1,150 @@
+package kvite
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+)
+
+// leaseBucket holds the records of locks taken with AcquireLock.
+const leaseBucket = "_kvite_locks"
+
+// leaseRecord is the stored form of a lock.
+type leaseRecord struct {
+	Owner   string `json:"owner"`
+	Expires int64  `json:"expires"`
+}
+
+// Lock is an exclusive, expiring lock on a name, shared by every process using the database file.
+// A holder that stops renewing the lock loses it once its TTL elapses.
+type Lock struct {
+	db      *DB
+	name    string
+	owner   string
+	expires time.Time
+}
+
+// AcquireLock takes the lock called name for ttl. It does not wait: if another holder has the lock and it has not
+// expired, AcquireLock returns ErrLockHeld.
+func (db *DB) AcquireLock(name string, ttl time.Duration) (*Lock, error) {
+	owner, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+	return db.acquireLock(name, owner, ttl)
+}
+
+// acquireLock takes a lock on behalf of owner. Holders that share an owner share the lock.
+func (db *DB) acquireLock(name, owner string, ttl time.Duration) (*Lock, error) {
+	l := &Lock{db: db, name: name, owner: owner}
+	if err := l.claim(ttl, false); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+func newToken() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Name returns the name of the lock.
+func (l *Lock) Name() string {
+	return l.name
+}
+
+// Expires returns the time at which the lock lapses unless it is renewed.
+func (l *Lock) Expires() time.Time {
+	return l.expires
+}
+
+// Renew extends the lock to ttl from now. It returns ErrLockLost if the lock was released or has been taken by
+// another holder. A lock that expired but was not taken by anyone else is renewed.
+func (l *Lock) Renew(ttl time.Duration) error {
+	return l.claim(ttl, true)
+}
+
+// Release gives up the lock. Releasing a lock that has been taken by another holder does nothing.
+func (l *Lock) Release() error {
+	return l.db.Transaction(func(tx *Tx) error {
+		if err := tx.lockForWrite(); err != nil {
+			return err
+		}
+		b := tx.newBucket(leaseBucket)
+		rec, err := getLease(b, l.name)
+		if err != nil || rec == nil || rec.Owner != l.owner {
+			return err
+		}
+		return b.Delete(l.name)
+	})
+}
+
+// claim writes the lock record if the lock is free, expired, or already ours. When renewing, the record must still
+// be ours.
+func (l *Lock) claim(ttl time.Duration, renew bool) error {
+	expires := time.Now().Add(ttl)
+	err := l.db.Transaction(func(tx *Tx) error {
+		if err := tx.lockForWrite(); err != nil {
+			return err
+		}
+		b := tx.newBucket(leaseBucket)
+		rec, err := getLease(b, l.name)
+		if err != nil {
+			return err
+		}
+		if rec != nil && rec.Owner != l.owner {
+			if renew {
+				return ErrLockLost
+			}
+			if time.Now().UnixNano() < rec.Expires {
+				return ErrLockHeld
+			}
+		}
+		if rec == nil && renew {
+			return ErrLockLost
+		}
+		return putLease(b, l.name, leaseRecord{Owner: l.owner, Expires: expires.UnixNano()})
+	})
+	if err == nil {
+		l.expires = expires
+	}
+	return err
+}
+
+// lockOwner returns the owner of an unexpired lock, or "" if the lock is free.
+func (db *DB) lockOwner(name string) (string, error) {
+	var owner string
+	err := db.Transaction(func(tx *Tx) error {
+		b := tx.newBucket(leaseBucket)
+		rec, err := getLease(b, name)
+		if err == nil && rec != nil && time.Now().UnixNano() < rec.Expires {
+			owner = rec.Owner
+		}
+		return err
+	})
+	return owner, err
+}
+
+func getLease(b *Bucket, name string) (*leaseRecord, error) {
+	value, err := b.Get(name)
+	if err != nil || value == nil {
+		return nil, err
+	}
+	var rec leaseRecord
+	if err := json.Unmarshal(value, &rec); err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
+
+func putLease(b *Bucket, name string, rec leaseRecord) error {
+	value, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	return b.Put(name, value)
+}
@@ -0,0 +1,44 @@
+package kvite
+
+import "time"
+
+func (s *KViteTestSuite) TestAcquireLock() {
+	l, err := s.DB.AcquireLock("compaction", time.Minute)
+	s.NoError(err)
+	s.Equal("compaction", l.Name())
+	s.True(l.Expires().After(time.Now()))
+
+	_, err = s.DB.AcquireLock("compaction", time.Minute)
+	s.Equal(ErrLockHeld, err)
+
+	other, err := s.DB.AcquireLock("other", time.Minute)
+	s.NoError(err)
+	s.NoError(other.Release())
+
+	expires := l.Expires()
+	s.NoError(l.Renew(2 * time.Minute))
+	s.True(l.Expires().After(expires))
+
+	s.NoError(l.Release())
+	s.Equal(ErrLockLost, l.Renew(time.Minute))
+
+	l, err = s.DB.AcquireLock("compaction", time.Minute)
+	s.NoError(err)
+	s.NoError(l.Release())
+}
+
+func (s *KViteTestSuite) TestAcquireLockExpired() {
+	l, err := s.DB.AcquireLock("compaction", time.Millisecond)
+	s.NoError(err)
+	time.Sleep(5 * time.Millisecond)
+
+	next, err := s.DB.AcquireLock("compaction", time.Minute)
+	s.NoError(err)
+
+	s.Equal(ErrLockLost, l.Renew(time.Minute))
+	// Releasing a lock that was taken over leaves the new holder in place.
+	s.NoError(l.Release())
+	_, err = s.DB.AcquireLock("compaction", time.Minute)
+	s.Equal(ErrLockHeld, err)
+	s.NoError(next.Release())
+}
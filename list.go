@@ -0,0 +1,56 @@
+package kvite
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// List returns the keys in the bucket that begin with prefix, grouped by delimiter like the directories of etcd
+// or S3. Keys with no delimiter after the prefix are returned in keys, and the others are collapsed to their
+// sub-prefix up to and including the first delimiter, returned once in prefixes. Both are in key order.
+// Each sub-prefix costs a single index seek, so listing a directory does not read the keys beneath its
+// subdirectories. An empty delimiter lists every key beginning with prefix. Expired keys are left out.
+func (b *Bucket) List(prefix, delimiter string) (keys, prefixes []string, err error) {
+	if err := b.checkAccess("list", false); err != nil {
+		return nil, nil, err
+	}
+	if keys, prefixes, ok, err := b.listIndexed(prefix, delimiter); ok || err != nil {
+		return keys, prefixes, err
+	}
+	query := fmt.Sprintf("SELECT key FROM '%s' WHERE bucket = ? AND key >= ? AND (expires IS NULL OR expires > ?) ORDER BY key LIMIT 1", b.tx.db.table)
+	now := time.Now().UnixNano()
+
+	cursor := prefix
+	for {
+		var key string
+		if err := b.tx.queryRow(query, b.name, cursor, now).Scan(&key); err != nil {
+			if err == sql.ErrNoRows {
+				return keys, prefixes, nil
+			}
+			return nil, nil, sqliteError(err)
+		}
+		if !strings.HasPrefix(key, prefix) {
+			return keys, prefixes, nil
+		}
+
+		i := -1
+		if delimiter != "" {
+			i = strings.Index(key[len(prefix):], delimiter)
+		}
+		if i < 0 {
+			keys = append(keys, key)
+			cursor = key + "\x00"
+			continue
+		}
+
+		sub := key[:len(prefix)+i+len(delimiter)]
+		prefixes = append(prefixes, sub)
+		end, ok := prefixEnd(sub)
+		if !ok {
+			return keys, prefixes, nil
+		}
+		cursor = end
+	}
+}
@@ -0,0 +1,35 @@
+package kvite
+
+func (s *KViteTestSuite) TestList() {
+	s.NoError(s.DB.Transaction(func(tx *Tx) error {
+		b, _ := tx.CreateBucket("test")
+		for _, key := range []string{"/a", "/b/c", "/b/d/e", "/b/f", "/g/h", "/i", "other"} {
+			s.NoError(b.Put(key, []byte("v")))
+		}
+		return nil
+	}))
+
+	s.NoError(s.DB.Transaction(func(tx *Tx) error {
+		b, _ := tx.Bucket("test")
+		keys, prefixes, err := b.List("/", "/")
+		s.NoError(err)
+		s.Equal([]string{"/a", "/i"}, keys)
+		s.Equal([]string{"/b/", "/g/"}, prefixes)
+
+		keys, prefixes, err = b.List("/b/", "/")
+		s.NoError(err)
+		s.Equal([]string{"/b/c", "/b/f"}, keys)
+		s.Equal([]string{"/b/d/"}, prefixes)
+
+		keys, prefixes, err = b.List("/b", "")
+		s.NoError(err)
+		s.Equal([]string{"/b/c", "/b/d/e", "/b/f"}, keys)
+		s.Empty(prefixes)
+
+		keys, prefixes, err = b.List("/missing/", "/")
+		s.NoError(err)
+		s.Empty(keys)
+		s.Empty(prefixes)
+		return nil
+	}))
+}
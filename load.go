@@ -0,0 +1,507 @@
+package kvite
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Load reads a dump written by Dump and writes its rows to the database, replacing keys that already exist. Only the
+// statements Dump writes are accepted: CREATE TABLE and CREATE INDEX statements for the kvite tables, which are
+// checked and skipped since the schema of the open database is used, INSERT statements for them whose values are
+// literals, and the PRAGMA foreign_keys, BEGIN and COMMIT statements around them. Anything else makes Load fail
+// before the database is touched.
+// The rows are written in a single transaction, so a dump is loaded entirely or not at all. Like ExecRaw, loading
+// bypasses the change feed and watchers, and clears the read cache. Rows of the meta table are skipped, and bucket
+// configurations in the dump's registry apply once Load returns. Load returns ErrRawRestricted on a handle with
+// bucket access rules.
+func (db *DB) Load(r io.Reader) error {
+	if len(db.bucketRules) > 0 {
+		return ErrRawRestricted
+	}
+	if err := db.life.check(); err != nil {
+		return err
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	inserts, err := db.parseDump(string(data))
+	if err != nil {
+		return err
+	}
+
+	return db.Transaction(func(tx *Tx) error {
+		if tx.readOnly {
+			return ErrTxReadOnly
+		}
+		tx.invalidateCache(cacheKey{all: true})
+		for _, ins := range inserts {
+			if err := tx.loadRows(ins); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// dumpInsert is an INSERT statement of a dump.
+type dumpInsert struct {
+	table string
+	cols  []string
+	rows  [][]interface{}
+}
+
+// parseDump checks the statements of a dump and returns its INSERT statements.
+func (db *DB) parseDump(dump string) ([]dumpInsert, error) {
+	statements, err := splitStatements(dump)
+	if err != nil {
+		return nil, err
+	}
+	tables := map[string]bool{db.table: true, db.metaTable(): true, db.bucketsTable(): true}
+
+	var inserts []dumpInsert
+	for i, toks := range statements {
+		p := &dumpParser{toks: toks}
+		var err error
+		switch p.keyword() {
+		case "PRAGMA":
+			if p.keyword() != "FOREIGN_KEYS" {
+				err = errors.New("only PRAGMA foreign_keys is allowed")
+			}
+		case "BEGIN", "COMMIT", "END":
+		case "CREATE":
+			err = p.create(tables)
+		case "INSERT":
+			var ins dumpInsert
+			if ins, err = p.insert(tables); err == nil && ins.table != db.metaTable() {
+				inserts = append(inserts, ins)
+			}
+		default:
+			err = errors.New("statement is not allowed")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("dump statement %d: %v", i+1, err)
+		}
+	}
+	return inserts, nil
+}
+
+// loadRows writes the rows of an INSERT statement.
+func (tx *Tx) loadRows(ins dumpInsert) error {
+	cols, err := tx.columnNames(ins.table)
+	if err != nil {
+		return err
+	}
+	if ins.cols == nil {
+		ins.cols = cols
+	}
+	known := make(map[string]bool, len(cols))
+	for _, c := range cols {
+		known[c] = true
+	}
+	pos := make(map[string]int, len(ins.cols))
+	for i, c := range ins.cols {
+		if !known[c] {
+			return fmt.Errorf("table %s has no column %s", ins.table, c)
+		}
+		pos[c] = i
+	}
+
+	kvRows := ins.table == tx.db.table
+	if _, ok := pos["bucket"]; kvRows && !ok {
+		return errors.New("dump rows have no bucket column")
+	}
+	if _, ok := pos["key"]; kvRows && !ok {
+		return errors.New("dump rows have no key column")
+	}
+	if _, ok := pos["name"]; ins.table == tx.db.bucketsTable() && !ok {
+		return errors.New("dump buckets have no name column")
+	}
+	params := strings.TrimSuffix(strings.Repeat("?, ", len(ins.cols)), ", ")
+	query := fmt.Sprintf("INSERT OR REPLACE INTO '%s' (%s) VALUES (%s)", ins.table, strings.Join(ins.cols, ", "), params)
+	for _, row := range ins.rows {
+		if len(row) != len(ins.cols) {
+			return fmt.Errorf("dump row for table %s has %d values for %d columns", ins.table, len(row), len(ins.cols))
+		}
+		if kvRows {
+			// The delete done by INSERT OR REPLACE does not fire the triggers counting deduplicated values
+			if i, ok := pos["value_ref"]; ok && row[i] != nil {
+				return errors.New("dump rows must hold their values inline")
+			}
+			if tx.db.dedup {
+				if _, err := tx.exec(tx.db.deleteQuery, row[pos["key"]], row[pos["bucket"]]); err != nil {
+					return err
+				}
+			}
+		}
+		if _, err := tx.exec(query, row...); err != nil {
+			return err
+		}
+		if ins.table != tx.db.bucketsTable() {
+			continue
+		}
+		if i, ok := pos["config"]; ok {
+			if err := tx.loadBucketConfig(row[pos["name"]], row[i]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// loadBucketConfig applies the configuration of a bucket loaded from a dump when the transaction commits.
+func (tx *Tx) loadBucketConfig(name, config interface{}) error {
+	bucket, ok := name.(string)
+	if !ok {
+		return errors.New("dump has a bucket without a name")
+	}
+	var cfg BucketConfig
+	if config != nil {
+		var data []byte
+		switch c := config.(type) {
+		case string:
+			data = []byte(c)
+		case []byte:
+			data = c
+		default:
+			return fmt.Errorf("bucket %q has an invalid configuration", bucket)
+		}
+		var err error
+		if cfg, err = tx.db.parseBucketConfig(bucket, data); err != nil {
+			return err
+		}
+	}
+	tx.setConfig(bucket, cfg)
+	return nil
+}
+
+// dumpParser reads the tokens of a statement.
+type dumpParser struct {
+	toks []sqlToken
+	pos  int
+}
+
+func (p *dumpParser) next() sqlToken {
+	if p.pos >= len(p.toks) {
+		return sqlToken{}
+	}
+	t := p.toks[p.pos]
+	p.pos++
+	return t
+}
+
+func (p *dumpParser) peek() sqlToken {
+	if p.pos >= len(p.toks) {
+		return sqlToken{}
+	}
+	return p.toks[p.pos]
+}
+
+// keyword returns the next token in upper case if it is a bare word, and "" otherwise.
+func (p *dumpParser) keyword() string {
+	t := p.next()
+	if t.kind != tokWord {
+		return ""
+	}
+	return strings.ToUpper(t.text)
+}
+
+// expect reads a keyword or punctuation, and fails if the next token is something else.
+func (p *dumpParser) expect(want string) error {
+	t := p.next()
+	if (t.kind == tokWord && strings.EqualFold(t.text, want)) || (t.kind == tokPunct && t.text == want) {
+		return nil
+	}
+	return fmt.Errorf("expected %s", want)
+}
+
+// name reads a table or column name, quoted or not.
+func (p *dumpParser) name() (string, error) {
+	t := p.next()
+	if t.kind != tokWord && t.kind != tokIdent && t.kind != tokString {
+		return "", errors.New("expected a name")
+	}
+	return t.text, nil
+}
+
+// create checks a CREATE TABLE or CREATE INDEX statement for one of the kvite tables.
+func (p *dumpParser) create(tables map[string]bool) error {
+	typ := p.keyword()
+	if typ == "UNIQUE" {
+		typ = p.keyword()
+	}
+	if typ != "TABLE" && typ != "INDEX" {
+		return errors.New("only tables and indexes can be created")
+	}
+	if strings.EqualFold(p.peek().text, "IF") {
+		p.pos++
+		if err := p.expect("NOT"); err != nil {
+			return err
+		}
+		if err := p.expect("EXISTS"); err != nil {
+			return err
+		}
+	}
+	name, err := p.name()
+	if err != nil {
+		return err
+	}
+	if typ == "INDEX" {
+		if err := p.expect("ON"); err != nil {
+			return err
+		}
+		if name, err = p.name(); err != nil {
+			return err
+		}
+	}
+	if !tables[name] {
+		return fmt.Errorf("table %s is not a kvite table", name)
+	}
+	return nil
+}
+
+// insert parses an INSERT statement for one of the kvite tables whose values are literals.
+func (p *dumpParser) insert(tables map[string]bool) (dumpInsert, error) {
+	var ins dumpInsert
+	if strings.EqualFold(p.peek().text, "OR") {
+		p.pos++
+		if err := p.expect("REPLACE"); err != nil {
+			return ins, err
+		}
+	}
+	if err := p.expect("INTO"); err != nil {
+		return ins, err
+	}
+	var err error
+	if ins.table, err = p.name(); err != nil {
+		return ins, err
+	}
+	if !tables[ins.table] {
+		return ins, fmt.Errorf("table %s is not a kvite table", ins.table)
+	}
+
+	if p.peek().text == "(" {
+		p.pos++
+		for {
+			col, err := p.name()
+			if err != nil {
+				return ins, err
+			}
+			ins.cols = append(ins.cols, col)
+			if t := p.next(); t.text == ")" {
+				break
+			} else if t.text != "," {
+				return ins, errors.New("expected , or )")
+			}
+		}
+	}
+
+	if err := p.expect("VALUES"); err != nil {
+		return ins, err
+	}
+	for {
+		row, err := p.values()
+		if err != nil {
+			return ins, err
+		}
+		ins.rows = append(ins.rows, row)
+		if p.peek().text != "," {
+			break
+		}
+		p.pos++
+	}
+	if p.pos != len(p.toks) {
+		return ins, errors.New("unexpected text after VALUES")
+	}
+	return ins, nil
+}
+
+// values parses a parenthesized list of literals.
+func (p *dumpParser) values() ([]interface{}, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	var row []interface{}
+	for {
+		v, err := p.literal()
+		if err != nil {
+			return nil, err
+		}
+		row = append(row, v)
+		if t := p.next(); t.text == ")" {
+			return row, nil
+		} else if t.text != "," {
+			return nil, errors.New("expected , or )")
+		}
+	}
+}
+
+// literal parses a string, blob, number or NULL.
+func (p *dumpParser) literal() (interface{}, error) {
+	t := p.next()
+	sign := ""
+	if t.kind == tokPunct && (t.text == "-" || t.text == "+") {
+		sign, t = t.text, p.next()
+		if t.kind != tokNumber {
+			return nil, errors.New("expected a number")
+		}
+	}
+	switch t.kind {
+	case tokString:
+		return t.text, nil
+	case tokBlob:
+		return hex.DecodeString(t.text)
+	case tokNumber:
+		if n, err := strconv.ParseInt(sign+t.text, 10, 64); err == nil {
+			return n, nil
+		}
+		return strconv.ParseFloat(sign+t.text, 64)
+	case tokWord:
+		if strings.EqualFold(t.text, "NULL") {
+			return nil, nil
+		}
+	}
+	return nil, errors.New("values must be literals")
+}
+
+type sqlTokenKind int
+
+const (
+	tokNone sqlTokenKind = iota
+	tokWord
+	tokIdent
+	tokString
+	tokBlob
+	tokNumber
+	tokPunct
+)
+
+// sqlToken is a token of an SQL statement. The text of quoted tokens is unquoted.
+type sqlToken struct {
+	kind sqlTokenKind
+	text string
+}
+
+// splitStatements splits SQL text into the tokens of each of its statements. Comments are dropped, as are empty
+// statements.
+func splitStatements(text string) ([][]sqlToken, error) {
+	var (
+		statements [][]sqlToken
+		current    []sqlToken
+	)
+	for i := 0; i < len(text); {
+		c := text[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case strings.HasPrefix(text[i:], "--"):
+			end := strings.IndexByte(text[i:], '\n')
+			if end < 0 {
+				end = len(text) - i
+			}
+			i += end
+		case strings.HasPrefix(text[i:], "/*"):
+			end := strings.Index(text[i+2:], "*/")
+			if end < 0 {
+				return nil, errors.New("unterminated comment in dump")
+			}
+			i += end + 4
+		case c == ';':
+			if len(current) > 0 {
+				statements = append(statements, current)
+			}
+			current = nil
+			i++
+		case (c == 'x' || c == 'X') && i+1 < len(text) && text[i+1] == '\'':
+			s, n, err := unquote(text[i+1:], '\'')
+			if err != nil {
+				return nil, err
+			}
+			current = append(current, sqlToken{kind: tokBlob, text: s})
+			i += n + 1
+		case c == '\'' || c == '"' || c == '`' || c == '[':
+			kind, closing := tokIdent, c
+			if c == '\'' {
+				kind = tokString
+			} else if c == '[' {
+				closing = ']'
+			}
+			s, n, err := unquote(text[i:], closing)
+			if err != nil {
+				return nil, err
+			}
+			current = append(current, sqlToken{kind: kind, text: s})
+			i += n
+		case isDigit(c) || (c == '.' && i+1 < len(text) && isDigit(text[i+1])):
+			n := numberLength(text[i:])
+			current = append(current, sqlToken{kind: tokNumber, text: text[i : i+n]})
+			i += n
+		case isWordByte(c):
+			n := 1
+			for i+n < len(text) && (isWordByte(text[i+n]) || isDigit(text[i+n])) {
+				n++
+			}
+			current = append(current, sqlToken{kind: tokWord, text: text[i : i+n]})
+			i += n
+		default:
+			current = append(current, sqlToken{kind: tokPunct, text: text[i : i+1]})
+			i++
+		}
+	}
+	if len(current) > 0 {
+		statements = append(statements, current)
+	}
+	return statements, nil
+}
+
+// unquote reads a quoted token at the start of text, in which the closing quote is escaped by doubling it. It
+// returns the unquoted text and the length of the token.
+func unquote(text string, closing byte) (string, int, error) {
+	var b strings.Builder
+	for i := 1; i < len(text); i++ {
+		if text[i] != closing {
+			b.WriteByte(text[i])
+			continue
+		}
+		if closing != ']' && i+1 < len(text) && text[i+1] == closing {
+			b.WriteByte(closing)
+			i++
+			continue
+		}
+		return b.String(), i + 1, nil
+	}
+	return "", 0, errors.New("unterminated quote in dump")
+}
+
+// numberLength returns the length of the number at the start of text.
+func numberLength(text string) int {
+	n := 0
+	for n < len(text) && (isDigit(text[n]) || text[n] == '.') {
+		n++
+	}
+	if n < len(text) && (text[n] == 'e' || text[n] == 'E') {
+		m := n + 1
+		if m < len(text) && (text[m] == '+' || text[m] == '-') {
+			m++
+		}
+		if m < len(text) && isDigit(text[m]) {
+			for m < len(text) && isDigit(text[m]) {
+				m++
+			}
+			n = m
+		}
+	}
+	return n
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || c >= 0x80 || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
@@ -0,0 +1,172 @@
+package kvite
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	sqlite3 "github.com/mattn/go-sqlite3"
+)
+
+// maxLockContentions is the number of recent contention events kept by a database.
+const maxLockContentions = 100
+
+// LockHolder describes the transaction holding the SQLite write lock.
+type LockHolder struct {
+	// Label is the label of the holding transaction, as set with Tx.SetLabel.
+	Label string
+	// Since is when the transaction made its first write and so took the lock.
+	Since time.Time
+}
+
+// LockContention records a write that waited for the write lock longer than the diagnostics threshold,
+// or gave up with SQLITE_BUSY.
+type LockContention struct {
+	// Label is the label of the waiting transaction.
+	Label string
+	// Time is when the wait began and Waited is how long it lasted.
+	Time   time.Time
+	Waited time.Duration
+	// Busy is true if the write failed because the lock could not be obtained.
+	Busy bool
+	// Holder is the transaction in this process that held the lock when the wait began. It is nil if the lock was
+	// held by another process or connection kvite does not manage.
+	Holder *LockHolder
+	// Process is the process holding the lock when the wait ended, with WithLockHeartbeat. It is nil if none was
+	// recorded.
+	Process *ProcessLockHolder
+}
+
+// WithLockDiagnostics tracks which transaction holds the write lock and records every write that waits on it
+// for longer than threshold. Use DB.LockHolder and DB.LockContentions to inspect them, and Tx.SetLabel to name
+// transactions.
+func WithLockDiagnostics(threshold time.Duration) Option {
+	return func(db *DB) error {
+		db.locks = &lockDiagnostics{threshold: threshold}
+		return nil
+	}
+}
+
+// lockDiagnostics holds the lock tracking state of a database. It is shared by handles returned from Restrict.
+type lockDiagnostics struct {
+	threshold time.Duration
+
+	mu          sync.Mutex
+	holderTx    *Tx
+	holder      LockHolder
+	contentions []LockContention
+}
+
+// SetLabel names the transaction in lock diagnostics, such as the operation or component it belongs to.
+func (tx *Tx) SetLabel(label string) {
+	tx.label = label
+}
+
+// LockHolder returns the transaction in this process currently holding the write lock, if any.
+// It always returns false unless the database was opened WithLockDiagnostics.
+func (db *DB) LockHolder() (LockHolder, bool) {
+	l := db.locks
+	if l == nil {
+		return LockHolder{}, false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.holder, l.holderTx != nil
+}
+
+// LockContentions returns the most recent writes that waited on the write lock, oldest first.
+func (db *DB) LockContentions() []LockContention {
+	l := db.locks
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]LockContention(nil), l.contentions...)
+}
+
+// trackWrite runs a write statement, taking note of the transaction as the lock holder once it succeeds.
+func (tx *Tx) trackWrite(fn func() error) error {
+	if tx.holdsLock || tx.db.locks == nil && tx.db.heartbeat == nil {
+		return fn()
+	}
+	err := tx.trackContention(fn)
+	if err == nil {
+		tx.holdsLock = true
+		tx.db.heartbeat.acquire(tx)
+	}
+	return err
+}
+
+// trackContention runs a write statement, recording contention if it waited for the lock.
+func (tx *Tx) trackContention(fn func() error) error {
+	l := tx.db.locks
+	if l == nil {
+		return fn()
+	}
+
+	l.mu.Lock()
+	var holder *LockHolder
+	if l.holderTx != nil {
+		h := l.holder
+		holder = &h
+	}
+	l.mu.Unlock()
+
+	start := time.Now()
+	err := fn()
+	waited := time.Since(start)
+
+	busy := isBusy(err)
+	var process *ProcessLockHolder
+	if (busy || waited > l.threshold) && tx.db.heartbeat != nil {
+		if p, ok, _ := tx.db.heartbeat.holder(); ok {
+			process = &p
+		}
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if busy || waited > l.threshold {
+		l.contentions = append(l.contentions, LockContention{
+			Label:   tx.label,
+			Time:    start,
+			Waited:  waited,
+			Busy:    busy,
+			Holder:  holder,
+			Process: process,
+		})
+		if n := len(l.contentions); n > maxLockContentions {
+			l.contentions = append(l.contentions[:0], l.contentions[n-maxLockContentions:]...)
+		}
+	}
+	if err == nil {
+		l.holderTx = tx
+		l.holder = LockHolder{Label: tx.label, Since: start}
+	}
+	return err
+}
+
+// releaseLock clears the transaction as the lock holder when it finishes.
+func (tx *Tx) releaseLock() {
+	if !tx.holdsLock {
+		return
+	}
+	tx.holdsLock = false
+	tx.db.heartbeat.release(tx)
+	l := tx.db.locks
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.holderTx == tx {
+		l.holderTx = nil
+		l.holder = LockHolder{}
+	}
+}
+
+func isBusy(err error) bool {
+	var serr sqlite3.Error
+	return errors.As(err, &serr) && (serr.Code == sqlite3.ErrBusy || serr.Code == sqlite3.ErrLocked)
+}
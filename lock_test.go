@@ -0,0 +1,86 @@
+package kvite
+
+import (
+	"os"
+	"time"
+)
+
+func (s *KViteTestSuite) TestLockDiagnostics() {
+	db := s.openDB("lock.db", WithLockDiagnostics(10*time.Millisecond))
+	defer func() { _ = db.Close() }()
+
+	_, held := db.LockHolder()
+	s.False(held)
+
+	tx, err := db.Begin()
+	s.Require().NoError(err)
+	tx.SetLabel("importer")
+	b, _ := tx.CreateBucketIfNotExists("test")
+	s.Require().NoError(b.Put("foo", []byte("bar")))
+
+	holder, held := db.LockHolder()
+	s.True(held)
+	s.Equal("importer", holder.Label)
+
+	done := make(chan error)
+	go func() {
+		done <- db.Transaction(func(tx *Tx) error {
+			tx.SetLabel("api")
+			b, _ := tx.CreateBucketIfNotExists("test")
+			return b.Put("baz", []byte("stuff"))
+		})
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	s.NoError(tx.Commit())
+	s.NoError(<-done)
+
+	_, held = db.LockHolder()
+	s.False(held)
+
+	contentions := db.LockContentions()
+	s.Require().Len(contentions, 1)
+	c := contentions[0]
+	s.Equal("api", c.Label)
+	s.False(c.Busy)
+	s.True(c.Waited >= 10*time.Millisecond)
+	s.Require().NotNil(c.Holder)
+	s.Equal("importer", c.Holder.Label)
+	s.Equal(holder.Since, c.Holder.Since)
+}
+
+func (s *KViteTestSuite) TestLockHeartbeat() {
+	agent := s.openDB("heartbeat.db", WithLockHeartbeat(10*time.Millisecond))
+	defer func() { _ = agent.Close() }()
+	other := s.openDB("heartbeat.db", WithLockHeartbeat(10*time.Millisecond))
+	defer func() { _ = other.Close() }()
+
+	_, held, err := other.ProcessLockHolder()
+	s.NoError(err)
+	s.False(held)
+
+	tx, err := agent.Begin()
+	s.Require().NoError(err)
+	tx.SetLabel("agent")
+	b, _ := tx.CreateBucketIfNotExists("test")
+	s.Require().NoError(b.Put("foo", []byte("bar")))
+	time.Sleep(30 * time.Millisecond)
+
+	holder, held, err := other.ProcessLockHolder()
+	s.NoError(err)
+	s.True(held)
+	s.Equal(os.Getpid(), holder.PID)
+	s.Equal(agent.NodeID(), holder.NodeID)
+	s.Equal("agent", holder.Label)
+	s.True(holder.Heartbeat.After(holder.Since))
+
+	s.NoError(tx.Commit())
+	_, held, err = other.ProcessLockHolder()
+	s.NoError(err)
+	s.False(held)
+
+	_, _, err = s.DB.ProcessLockHolder()
+	s.Equal(ErrNoLockHeartbeat, err)
+	_, err = Open(":memory:", "", WithLockHeartbeat(time.Second))
+	s.Error(err)
+}
@@ -0,0 +1,169 @@
+package kvite
+
+import (
+	"database/sql"
+	"errors"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+)
+
+// ProcessLockHolder describes the process holding the SQLite write lock of a database file, as recorded by
+// WithLockHeartbeat.
+type ProcessLockHolder struct {
+	PID  int
+	Host string
+	// NodeID is the node ID of the database handle holding the lock.
+	NodeID string
+	// Label is the label of the holding transaction, as set with Tx.SetLabel.
+	Label string
+	// Since is when the transaction took the lock, and Heartbeat when the process last recorded that it held it.
+	Since     time.Time
+	Heartbeat time.Time
+}
+
+// WithLockHeartbeat records the transaction holding the write lock in a lock metadata table, and refreshes the
+// record every interval while it holds the lock, so that DB.ProcessLockHolder can tell who holds the lock of the
+// database file and for how long, even when it is another process. It helps to find out why writes fail with
+// "database is locked", such as when a second instance of an agent runs against the same file. With
+// WithLockDiagnostics, contention records include the holder as well.
+//
+// The table is kept in a file of its own, next to the database file with "-kvite-lock" appended to its name,
+// because the holder cannot commit rows to the database file while it holds the lock. Every process sharing the
+// database file must use this option for its transactions to be recorded. Recording costs two writes to that
+// file per write transaction, and is best effort: failures are ignored. It cannot be used with in-memory
+// databases.
+func WithLockHeartbeat(interval time.Duration) Option {
+	return func(db *DB) error {
+		if interval <= 0 {
+			return errors.New("lock heartbeat interval must be positive")
+		}
+		host, _ := os.Hostname()
+		db.heartbeat = &lockHeartbeat{interval: interval, pid: os.Getpid(), host: host}
+		return nil
+	}
+}
+
+// lockHeartbeat records the transactions of this process that hold the write lock. It is shared by handles
+// returned from Restrict.
+type lockHeartbeat struct {
+	interval time.Duration
+	pid      int
+	host     string
+	db       *sql.DB
+
+	mu sync.Mutex
+	tx *Tx
+	// since identifies the record of tx among those of other handles of this process on the same file.
+	since int64
+	stop  chan struct{}
+	done  chan struct{}
+}
+
+// open opens the file holding the lock metadata table of a database file, creating the table if needed.
+func (h *lockHeartbeat) open(filename string) error {
+	if h == nil {
+		return nil
+	}
+	if filename == "" || filename == ":memory:" {
+		return errors.New("lock heartbeat needs a database file")
+	}
+	params := url.Values{"_busy_timeout": {"1000"}, "_sync": {"OFF"}}
+	db, err := sql.Open("sqlite3", fileURI(filename+"-kvite-lock", params))
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(`create TABLE IF NOT EXISTS kvite_lock (id integer primary key check (id = 1), pid integer not null,
+		host text not null, node text not null, label text not null, since integer not null, heartbeat integer not null,
+		interval integer not null)`)
+	if err != nil {
+		_ = db.Close()
+		return err
+	}
+	h.db = db
+	return nil
+}
+
+func (h *lockHeartbeat) close() error {
+	if h == nil || h.db == nil {
+		return nil
+	}
+	return h.db.Close()
+}
+
+// acquire records tx as the holder of the lock, and refreshes the record until release is called.
+func (h *lockHeartbeat) acquire(tx *Tx) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	now := time.Now().UnixNano()
+	_, _ = h.db.Exec("INSERT OR REPLACE INTO kvite_lock (id, pid, host, node, label, since, heartbeat, interval) VALUES (1, ?, ?, ?, ?, ?, ?, ?)",
+		h.pid, h.host, tx.db.nodeID, tx.label, now, now, int64(h.interval))
+	h.tx, h.since = tx, now
+	h.stop, h.done = make(chan struct{}), make(chan struct{})
+	go h.beat(now, h.stop, h.done)
+}
+
+func (h *lockHeartbeat) beat(since int64, stop, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(h.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			_, _ = h.db.Exec("UPDATE kvite_lock SET heartbeat = ? WHERE pid = ? AND host = ? AND since = ?", time.Now().UnixNano(), h.pid, h.host, since)
+		}
+	}
+}
+
+// release removes the record of tx holding the lock.
+func (h *lockHeartbeat) release(tx *Tx) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.tx != tx {
+		return
+	}
+	close(h.stop)
+	<-h.done
+	h.tx = nil
+	_, _ = h.db.Exec("DELETE FROM kvite_lock WHERE pid = ? AND host = ? AND since = ?", h.pid, h.host, h.since)
+}
+
+// holder reads the recorded holder of the lock. A record whose heartbeat is older than three intervals is left
+// by a process that exited while holding the lock, and is ignored.
+func (h *lockHeartbeat) holder() (ProcessLockHolder, bool, error) {
+	var (
+		p                          ProcessLockHolder
+		since, heartbeat, interval int64
+	)
+	err := h.db.QueryRow("SELECT pid, host, node, label, since, heartbeat, interval FROM kvite_lock WHERE id = 1").
+		Scan(&p.PID, &p.Host, &p.NodeID, &p.Label, &since, &heartbeat, &interval)
+	if err == sql.ErrNoRows {
+		return ProcessLockHolder{}, false, nil
+	}
+	if err != nil {
+		return ProcessLockHolder{}, false, err
+	}
+	p.Since, p.Heartbeat = time.Unix(0, since), time.Unix(0, heartbeat)
+	if time.Since(p.Heartbeat) > 3*time.Duration(interval) {
+		return ProcessLockHolder{}, false, nil
+	}
+	return p, true, nil
+}
+
+// ProcessLockHolder returns the process holding the write lock of the database file, which may be this one, if
+// any. It returns ErrNoLockHeartbeat unless the database was opened WithLockHeartbeat.
+func (db *DB) ProcessLockHolder() (ProcessLockHolder, bool, error) {
+	if db.heartbeat == nil {
+		return ProcessLockHolder{}, false, ErrNoLockHeartbeat
+	}
+	return db.heartbeat.holder()
+}
@@ -0,0 +1,217 @@
+package kvite
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Manager opens and caches databases by name, for services that keep one database per tenant or per VM. Databases
+// are opened with the same table and options the first time they are asked for, and closed once they have been
+// idle for a while. It is safe for concurrent use by multiple goroutines.
+type Manager struct {
+	dir   string
+	table string
+	opts  []Option
+	idle  time.Duration
+
+	mu sync.Mutex
+	// dbs holds the databases by file, so that names of the same file share one.
+	dbs    map[string]*managedDB
+	closed bool
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+// managedDB is a database held by a Manager. ready is closed once it has been opened, successfully or not.
+type managedDB struct {
+	ready chan struct{}
+	db    *DB
+	err   error
+
+	// lastUsed is when the database was last asked for or seen running transactions, counted by txs.
+	lastUsed time.Time
+	txs      int64
+}
+
+// NewManager returns a Manager opening the databases it is asked for in dir, using table and opts for each of them.
+// A database is closed once neither Get nor any transaction has used it for idle, which handles taken from Get
+// before then find out with ErrDBClosed; asking for it again opens it again. An idle of 0 keeps databases open
+// until Evict or Close.
+func NewManager(dir, table string, idle time.Duration, opts ...Option) *Manager {
+	m := &Manager{
+		dir:   dir,
+		table: table,
+		opts:  opts,
+		idle:  idle,
+		dbs:   make(map[string]*managedDB),
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	if idle > 0 {
+		go m.run()
+	} else {
+		close(m.done)
+	}
+	return m
+}
+
+// path returns the file of a database name, which is relative to the manager's directory unless it is absolute.
+func (m *Manager) path(name string) (string, error) {
+	if filepath.IsAbs(name) {
+		return filepath.Clean(name), nil
+	}
+	clean := filepath.Clean(name)
+	if name == "" || clean == "." || clean == ".." || strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("invalid database name %q", name)
+	}
+	return filepath.Join(m.dir, clean), nil
+}
+
+// Get returns the database of a name, opening it if it is not open yet. name is a file name within the manager's
+// directory, or an absolute path; names of the same file, such as "a.db" and "./a.db", get the same database. opts
+// are applied after the manager's options when the database is opened, and ignored when it is already open. If
+// opening fails, the error is returned and the next Get tries again.
+func (m *Manager) Get(name string, opts ...Option) (*DB, error) {
+	filename, err := m.path(name)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return nil, ErrDBClosed
+	}
+	e, ok := m.dbs[filename]
+	if !ok {
+		e = &managedDB{ready: make(chan struct{})}
+		m.dbs[filename] = e
+	}
+	m.mu.Unlock()
+
+	if !ok {
+		e.db, e.err = Open(filename, m.table, append(append([]Option{}, m.opts...), opts...)...)
+		m.mu.Lock()
+		if e.err != nil && m.dbs[filename] == e {
+			delete(m.dbs, filename)
+		}
+		m.mu.Unlock()
+		close(e.ready)
+	}
+
+	<-e.ready
+	if e.err != nil {
+		return nil, e.err
+	}
+	m.mu.Lock()
+	e.lastUsed = time.Now()
+	m.mu.Unlock()
+	return e.db, nil
+}
+
+// Evict closes the database of a name, if it is open, so that the next Get opens it again. Names are resolved as
+// by Get.
+func (m *Manager) Evict(name string) error {
+	filename, err := m.path(name)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	e, ok := m.dbs[filename]
+	delete(m.dbs, filename)
+	m.mu.Unlock()
+	if !ok {
+		return nil
+	}
+	return e.close()
+}
+
+// Close closes every open database and stops the manager. Get fails with ErrDBClosed afterwards. It returns the
+// first error from closing a database.
+func (m *Manager) Close() error {
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return nil
+	}
+	m.closed = true
+	dbs := m.dbs
+	m.dbs = make(map[string]*managedDB)
+	close(m.stop)
+	m.mu.Unlock()
+	<-m.done
+
+	var err error
+	for _, e := range dbs {
+		if cerr := e.close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// close waits for the database to be opened and closes it.
+func (e *managedDB) close() error {
+	<-e.ready
+	if e.db == nil {
+		return nil
+	}
+	return e.db.Close()
+}
+
+// run closes idle databases until the manager is closed.
+func (m *Manager) run() {
+	defer close(m.done)
+	interval := m.idle / 2
+	if interval <= 0 {
+		interval = m.idle
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-m.stop:
+			return
+		case <-ticker.C:
+			for _, e := range m.evictIdle(time.Now()) {
+				_ = e.close()
+			}
+		}
+	}
+}
+
+// evictIdle removes the databases that have been idle for the manager's idle time and returns them, to be closed.
+func (m *Manager) evictIdle(now time.Time) []*managedDB {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var idle []*managedDB
+	for filename, e := range m.dbs {
+		select {
+		case <-e.ready:
+		default:
+			continue
+		}
+		if e.db == nil {
+			continue
+		}
+		metrics := e.db.metrics
+		txs := atomic.LoadInt64(&metrics.commits) + atomic.LoadInt64(&metrics.rollbacks)
+		if txs != e.txs || !e.db.life.idle() {
+			e.txs = txs
+			e.lastUsed = now
+			continue
+		}
+		if now.Sub(e.lastUsed) >= m.idle {
+			delete(m.dbs, filename)
+			idle = append(idle, e)
+		}
+	}
+	return idle
+}
@@ -0,0 +1,76 @@
+package kvite
+
+import (
+	"path/filepath"
+	"time"
+)
+
+func (s *KViteTestSuite) TestManager() {
+	m := NewManager(s.TempDir, "tenants", 0)
+	defer func() { _ = m.Close() }()
+
+	a, err := m.Get("a.db")
+	s.Require().NoError(err)
+	s.NoError(a.Put("bucket", "k", []byte("a")))
+	for _, name := range []string{"a.db", "./a.db", "sub/../a.db", filepath.Join(s.TempDir, "a.db")} {
+		same, err := m.Get(name)
+		s.NoError(err, name)
+		s.True(a == same, name)
+	}
+
+	b, err := m.Get(filepath.Join(s.TempDir, "b.db"))
+	s.Require().NoError(err)
+	s.False(a == b)
+	value, err := b.Get("bucket", "k")
+	s.NoError(err)
+	s.Nil(value)
+
+	for _, name := range []string{"", ".", "..", "../escape.db"} {
+		_, err := m.Get(name)
+		s.Error(err, name)
+	}
+
+	s.Error(m.Evict("../escape.db"))
+	s.NoError(m.Evict("./a.db"))
+	s.Equal(ErrDBClosed, a.Put("bucket", "k", []byte("a")))
+	a, err = m.Get("a.db")
+	s.Require().NoError(err)
+	value, err = a.Get("bucket", "k")
+	s.NoError(err)
+	s.Equal([]byte("a"), value)
+
+	s.NoError(m.Close())
+	s.Equal(ErrDBClosed, a.Put("bucket", "k", []byte("a")))
+	_, err = m.Get("a.db")
+	s.Equal(ErrDBClosed, err)
+}
+
+func (s *KViteTestSuite) TestManagerIdle() {
+	m := NewManager(s.TempDir, "testing", 20*time.Millisecond, ReadOnly())
+	defer func() { _ = m.Close() }()
+	s.Require().NoError(s.DB.Put("bucket", "k", []byte("v")))
+
+	db, err := m.Get(filepath.Base(s.DB.filename))
+	s.Require().NoError(err)
+	s.Error(db.Put("bucket", "k", []byte("v")))
+
+	// A database in use is kept open.
+	for i := 0; i < 5; i++ {
+		value, err := db.Get("bucket", "k")
+		s.NoError(err)
+		s.Equal([]byte("v"), value)
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	s.Eventually(func() bool {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		return len(m.dbs) == 0
+	}, time.Second, 10*time.Millisecond)
+	_, err = db.Get("bucket", "k")
+	s.Equal(ErrDBClosed, err)
+	db, err = m.Get(filepath.Base(s.DB.filename))
+	s.Require().NoError(err)
+	_, err = db.Get("bucket", "k")
+	s.NoError(err)
+}
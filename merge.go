@@ -0,0 +1,46 @@
+package kvite
+
+import "path"
+
+// MergeFunc combines merge operands with the stored value of a key. existing is nil if the key does not exist.
+// The returned value replaces the stored one; returning nil deletes the key.
+type MergeFunc func(key string, existing []byte, operands [][]byte) ([]byte, error)
+
+type mergeRule struct {
+	pattern string
+	fn      MergeFunc
+}
+
+// WithMergeOperator registers fn as the merge operator of the buckets matching pattern, which uses the syntax of
+// path.Match. When several patterns match a bucket, the one added last applies.
+func WithMergeOperator(pattern string, fn MergeFunc) Option {
+	return func(db *DB) error {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return err
+		}
+		db.mergeRules = append(db.mergeRules, mergeRule{pattern, fn})
+		return nil
+	}
+}
+
+func (db *DB) mergeOperator(bucket string) MergeFunc {
+	for i := len(db.mergeRules) - 1; i >= 0; i-- {
+		if ok, _ := path.Match(db.mergeRules[i].pattern, bucket); ok {
+			return db.mergeRules[i].fn
+		}
+	}
+	return nil
+}
+
+// Merge combines operand with the stored value of a key using the bucket's merge operator, such as adding to a
+// counter or appending to a list. Operands are applied eagerly: the merged value is written straight away, within
+// the surrounding transaction. It returns ErrNoMergeOperator if the bucket has no merge operator.
+func (b *Bucket) Merge(key string, operand []byte) error {
+	fn := b.tx.db.mergeOperator(b.name)
+	if fn == nil {
+		return ErrNoMergeOperator
+	}
+	return b.Update(key, func(old []byte) ([]byte, error) {
+		return fn(key, old, [][]byte{operand})
+	})
+}
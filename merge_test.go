@@ -0,0 +1,49 @@
+package kvite
+
+import (
+	"bytes"
+	"strconv"
+)
+
+func (s *KViteTestSuite) TestBucketMerge() {
+	add := func(key string, existing []byte, operands [][]byte) ([]byte, error) {
+		n, _ := strconv.Atoi(string(existing))
+		for _, op := range operands {
+			d, err := strconv.Atoi(string(op))
+			if err != nil {
+				return nil, err
+			}
+			n += d
+		}
+		return []byte(strconv.Itoa(n)), nil
+	}
+	appendList := func(key string, existing []byte, operands [][]byte) ([]byte, error) {
+		list := [][]byte{}
+		if existing != nil {
+			list = append(list, existing)
+		}
+		return bytes.Join(append(list, operands...), []byte(",")), nil
+	}
+
+	db := s.openDB("merge.db", WithMergeOperator("counters", add), WithMergeOperator("lists/*", appendList))
+	defer func() { _ = db.Close() }()
+
+	s.NoError(db.Transaction(func(tx *Tx) error {
+		counters, _ := tx.CreateBucketIfNotExists("counters")
+		s.NoError(counters.Merge("hits", []byte("1")))
+		s.NoError(counters.Merge("hits", []byte("41")))
+		s.Error(counters.Merge("hits", []byte("x")))
+		value, _ := counters.Get("hits")
+		s.Equal("42", string(value))
+
+		list, _ := tx.CreateBucketIfNotExists("lists/recent")
+		s.NoError(list.Merge("ids", []byte("a")))
+		s.NoError(list.Merge("ids", []byte("b")))
+		value, _ = list.Get("ids")
+		s.Equal("a,b", string(value))
+
+		other, _ := tx.CreateBucketIfNotExists("other")
+		s.Equal(ErrNoMergeOperator, other.Merge("x", []byte("1")))
+		return nil
+	}))
+}
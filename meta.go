@@ -0,0 +1,50 @@
+package kvite
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// ValueMeta is metadata stored alongside a value by PutWithMeta.
+type ValueMeta struct {
+	// ContentType is the media type of the value, such as "application/json", for frontends that serve values.
+	ContentType string
+}
+
+// contentType returns the content_type column of the metadata.
+func (m ValueMeta) contentType() interface{} {
+	if m.ContentType == "" {
+		return nil
+	}
+	return m.ContentType
+}
+
+// PutWithMeta sets the value for a key like Put, and stores meta with it. The metadata is replaced whenever the key
+// is written, so a later Put clears it. It is not recorded in the change feed, nor kept by versioning.
+func (b *Bucket) PutWithMeta(key string, value []byte, meta ValueMeta) error {
+	if len(b.tx.db.hooks) == 0 {
+		return b.put(key, value, meta)
+	}
+	return b.intercept(Op{Name: "put", Bucket: b.name, Key: key, Value: value}, func() error { return b.put(key, value, meta) })
+}
+
+// GetWithMeta retrieves the value for a key like Get, along with the metadata stored with it. It returns a nil
+// value and zero metadata if the key does not exist.
+func (b *Bucket) GetWithMeta(key string) ([]byte, ValueMeta, error) {
+	var meta ValueMeta
+	value, err := b.Get(key)
+	if err != nil || value == nil {
+		return value, meta, err
+	}
+
+	var contentType sql.NullString
+	query := fmt.Sprintf("SELECT content_type FROM '%s' WHERE key = ? AND bucket = ?", b.tx.db.table)
+	if err := b.tx.queryRow(query, key, b.name).Scan(&contentType); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, meta, nil
+		}
+		return nil, meta, sqliteError(err)
+	}
+	meta.ContentType = contentType.String
+	return value, meta, nil
+}
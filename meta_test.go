@@ -0,0 +1,51 @@
+package kvite
+
+func (s *KViteTestSuite) TestValueMeta() {
+	s.NoError(s.DB.Transaction(func(tx *Tx) error {
+		b, _ := tx.CreateBucket("test")
+		s.NoError(b.PutWithMeta("doc", []byte(`{"a":1}`), ValueMeta{ContentType: "application/json"}))
+		s.NoError(b.Put("raw", []byte("bytes")))
+		return nil
+	}))
+
+	s.NoError(s.DB.Transaction(func(tx *Tx) error {
+		b, _ := tx.Bucket("test")
+		value, meta, err := b.GetWithMeta("doc")
+		s.NoError(err)
+		s.Equal([]byte(`{"a":1}`), value)
+		s.Equal(ValueMeta{ContentType: "application/json"}, meta)
+
+		value, meta, err = b.GetWithMeta("raw")
+		s.NoError(err)
+		s.Equal([]byte("bytes"), value)
+		s.Equal(ValueMeta{}, meta)
+
+		value, meta, err = b.GetWithMeta("missing")
+		s.NoError(err)
+		s.Nil(value)
+		s.Equal(ValueMeta{}, meta)
+
+		// Writing the key again replaces its metadata
+		s.NoError(b.Put("doc", []byte("text")))
+		_, meta, err = b.GetWithMeta("doc")
+		s.NoError(err)
+		s.Equal(ValueMeta{}, meta)
+		return nil
+	}))
+
+	// Rewrite keeps the metadata of the keys it rewrites
+	s.NoError(s.DB.Transaction(func(tx *Tx) error {
+		b, _ := tx.Bucket("test")
+		return b.PutWithMeta("doc", []byte("text"), ValueMeta{ContentType: "text/plain"})
+	}))
+	_, err := s.DB.Rewrite(func(bucket, key string, v []byte) ([]byte, error) { return append(v, '!'), nil })
+	s.NoError(err)
+	s.NoError(s.DB.Transaction(func(tx *Tx) error {
+		b, _ := tx.Bucket("test")
+		value, meta, err := b.GetWithMeta("doc")
+		s.NoError(err)
+		s.Equal([]byte("text!"), value)
+		s.Equal(ValueMeta{ContentType: "text/plain"}, meta)
+		return nil
+	}))
+}
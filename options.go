@@ -0,0 +1,72 @@
+package kvite
+
+import "os"
+
+// Option configures a DB when it is opened.
+type Option func(*DB) error
+
+// WithChecksums makes Put store a CRC32 checksum alongside each value.
+// Checksums are verified by Get and ForEach whenever a row has one, and a mismatch is reported as a *ChecksumError.
+func WithChecksums() Option {
+	return func(db *DB) error {
+		db.checksums = true
+		return nil
+	}
+}
+
+// WithWAL switches the database file to write-ahead logging, which lets readers, including snapshots,
+// proceed while a writer is active. The journal mode is persistent, so it only needs to be set once per file.
+func WithWAL() Option {
+	return func(db *DB) error {
+		db.wal = true
+		return nil
+	}
+}
+
+// ReadOnly opens the database file read-only. The tables are expected to exist already,
+// and every transaction is read-only: writes fail with ErrTxReadOnly.
+func ReadOnly() Option {
+	return func(db *DB) error {
+		db.readOnly = true
+		return nil
+	}
+}
+
+// WithCreateDir makes Open create the directory of the database file, and any missing parents, with permissions
+// perm before the umask, instead of failing with "unable to open database file" when it does not exist yet.
+func WithCreateDir(perm os.FileMode) Option {
+	return func(db *DB) error {
+		db.createDir = true
+		db.dirPerm = perm
+		return nil
+	}
+}
+
+// SkipSchema skips the schema transaction that Open normally runs to create or upgrade the tables. It saves a write
+// transaction per Open for processes that open many databases whose schema is known to be current. Nothing is
+// checked: opening a database whose tables are missing or out of date this way makes later operations fail.
+func SkipSchema() Option {
+	return func(db *DB) error {
+		db.skipSchema = true
+		return nil
+	}
+}
+
+// WithoutRowID creates the kvite table WITHOUT ROWID, clustered on a (bucket, key) primary key. This removes the
+// separate unique index, which makes the file smaller and point lookups cheaper, especially for small values.
+// It only affects newly created tables; an existing table keeps its layout.
+func WithoutRowID() Option {
+	return func(db *DB) error {
+		db.withoutRowID = true
+		return nil
+	}
+}
+
+// WithRepanic makes DB.Transaction panic again after rolling back a transaction whose function panicked, instead of
+// returning a *PanicError.
+func WithRepanic() Option {
+	return func(db *DB) error {
+		db.repanic = true
+		return nil
+	}
+}
@@ -0,0 +1,85 @@
+package kvite
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// healthBucket is the bucket Ping writes to. The write is always rolled back.
+const healthBucket = "_kvite_health"
+
+// Ping checks that the database is usable: the connection is alive, the kvite table exists with the expected
+// columns, and a value can be written and read back. The test write is rolled back, so Ping leaves no trace.
+// Databases opened ReadOnly are only checked for reads.
+func (db *DB) Ping(ctx context.Context) error {
+	if err := db.life.check(); err != nil {
+		return err
+	}
+	if err := db.db.PingContext(ctx); err != nil {
+		return err
+	}
+
+	var n int
+	query := fmt.Sprintf("SELECT count(*) FROM pragma_table_info('%s') WHERE name IN ('key', 'bucket', 'value')", db.table)
+	if err := db.db.QueryRowContext(ctx, query).Scan(&n); err != nil {
+		return err
+	}
+	if n != 3 {
+		return fmt.Errorf("table %s is missing or is not a kvite table", db.table)
+	}
+
+	// The test write of a single-writer database waits its turn on the writer goroutine.
+	if db.writer != nil && !db.readOnly {
+		err := db.writer.do(db, func(tx *Tx) error {
+			if err := db.pingWrite(tx); err != nil {
+				return err
+			}
+			return errPingRollback
+		})
+		if err == errPingRollback {
+			return nil
+		}
+		return err
+	}
+
+	tx, err := db.begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if db.readOnly {
+		var one int
+		err := tx.queryRow(fmt.Sprintf("SELECT 1 FROM '%s' LIMIT 1", db.table)).Scan(&one)
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		return err
+	}
+	return db.pingWrite(tx)
+}
+
+// pingWrite writes a test value in tx and reads it back.
+func (db *DB) pingWrite(tx *Tx) error {
+	value := []byte(strconv.FormatInt(time.Now().UnixNano(), 10))
+	if err := tx.writeRow(healthBucket, "ping", value, nil, nil, ValueMeta{}); err != nil {
+		return err
+	}
+
+	var (
+		got     []byte
+		sum     sql.NullInt64
+		expires sql.NullInt64
+	)
+	if err := tx.queryRow(db.getQuery, "ping", healthBucket).Scan(&got, &sum, &expires); err != nil {
+		return err
+	}
+	if !bytes.Equal(got, value) {
+		return fmt.Errorf("health check read back %q, wrote %q", got, value)
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package kvite
+
+import (
+	"context"
+	"path/filepath"
+)
+
+func (s *KViteTestSuite) TestPing() {
+	ctx := context.Background()
+	s.NoError(s.DB.Ping(ctx))
+
+	// The health check write is not kept
+	buckets, err := s.DB.Buckets()
+	s.NoError(err)
+	s.NotContains(buckets, healthBucket)
+
+	ro, err := Open(filepath.Join(s.TempDir, "kvite.db"), "testing", ReadOnly())
+	s.Require().NoError(err)
+	s.NoError(ro.Ping(ctx))
+	s.NoError(ro.Close())
+
+	other, err := Open(filepath.Join(s.TempDir, "kvite.db"), "other", ReadOnly())
+	s.Require().NoError(err)
+	s.Error(other.Ping(ctx))
+	s.NoError(other.Close())
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	s.Error(s.DB.Ping(canceled))
+
+	db := s.openDB("ping.db")
+	s.NoError(db.Close())
+	s.Error(db.Ping(ctx))
+}
@@ -0,0 +1,115 @@
+package kvite
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// pipelineVersion is the first byte of values stored by a pipeline, so that the header format can change.
+const pipelineVersion byte = 1
+
+// pipelineStages returns the stage names of a BucketConfig.Pipeline.
+func pipelineStages(pipeline string) []string {
+	if pipeline == "" {
+		return nil
+	}
+	return strings.Split(pipeline, ",")
+}
+
+// validatePipeline checks that every stage of a pipeline is a compression algorithm or a registered codec.
+func (db *DB) validatePipeline(cfg BucketConfig) error {
+	if cfg.Pipeline == "" {
+		return nil
+	}
+	if cfg.Codec != "" || cfg.Compress || cfg.Compression != "" {
+		return errors.New("a bucket with a pipeline cannot also have a codec or compression")
+	}
+	for _, name := range pipelineStages(cfg.Pipeline) {
+		if name == "" || len(name) > 255 {
+			return fmt.Errorf("invalid pipeline stage %q", name)
+		}
+		if _, err := db.pipelineStage(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pipelineStage returns the codec of a stage. Compression algorithms are stages of their own, without the header
+// compressValue adds.
+func (db *DB) pipelineStage(name string) (Codec, error) {
+	if c, ok := compressors[name]; ok && c.newWriter != nil {
+		return compressStage(c), nil
+	}
+	if c, ok := db.codecs[name]; ok {
+		return c, nil
+	}
+	return nil, fmt.Errorf("pipeline stage %q is neither a compression algorithm nor a registered codec", name)
+}
+
+type compressStage compressor
+
+func (c compressStage) Encode(value []byte) ([]byte, error) { return compressor(c).compress(value) }
+
+func (c compressStage) Decode(stored []byte) ([]byte, error) { return compressor(c).decompress(stored) }
+
+// encodePipeline passes a value through the stages of a pipeline in order and prefixes the result with a header
+// listing the stages that were applied: the format version, the number of stages, and the length and name of each.
+// Compression stages are skipped for values shorter than threshold and for values they do not make smaller.
+func (db *DB) encodePipeline(pipeline string, threshold int, value []byte) ([]byte, error) {
+	var applied []string
+	for _, name := range pipelineStages(pipeline) {
+		stage, err := db.pipelineStage(name)
+		if err != nil {
+			return nil, err
+		}
+		_, compress := stage.(compressStage)
+		if compress && len(value) < threshold {
+			continue
+		}
+		out, err := stage.Encode(value)
+		if err != nil {
+			return nil, err
+		}
+		if compress && len(out) >= len(value) {
+			continue
+		}
+		value = out
+		applied = append(applied, name)
+	}
+
+	header := []byte{pipelineVersion, byte(len(applied))}
+	for _, name := range applied {
+		header = append(append(header, byte(len(name))), name...)
+	}
+	return append(header, value...), nil
+}
+
+// decodePipeline reverses encodePipeline, using the stages recorded in the value's header rather than the
+// bucket's current pipeline.
+func (db *DB) decodePipeline(stored []byte) ([]byte, error) {
+	if len(stored) < 2 || stored[0] != pipelineVersion {
+		return nil, errors.New("value has no pipeline header")
+	}
+	n, rest := int(stored[1]), stored[2:]
+	names := make([]string, n)
+	for i := range names {
+		if len(rest) == 0 || len(rest) < 1+int(rest[0]) {
+			return nil, errors.New("value has a truncated pipeline header")
+		}
+		names[i], rest = string(rest[1:1+int(rest[0])]), rest[1+int(rest[0]):]
+	}
+
+	value := rest
+	for i := n - 1; i >= 0; i-- {
+		stage, err := db.pipelineStage(names[i])
+		if err != nil {
+			return nil, err
+		}
+		if value, err = stage.Decode(value); err != nil {
+			return nil, err
+		}
+	}
+	return value, nil
+}
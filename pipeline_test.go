@@ -0,0 +1,57 @@
+package kvite
+
+import "bytes"
+
+func (s *KViteTestSuite) TestBucketPipeline() {
+	cipher, err := NewCipherCodec(bytes.Repeat([]byte{1}, 32))
+	s.Require().NoError(err)
+	sign, err := NewSigningCodec(bytes.Repeat([]byte{2}, 16))
+	s.Require().NoError(err)
+	db := s.openDB("pipeline.db", WithCodec("cipher", cipher), WithCodec("sign", sign), WithCodec("reverse", reverseCodec{}))
+	defer func() { _ = db.Close() }()
+
+	long := bytes.Repeat([]byte("abc"), 100)
+	put := func(cfg BucketConfig, key string, value []byte) {
+		s.NoError(db.Transaction(func(tx *Tx) error {
+			s.NoError(tx.SetBucketConfig("test", cfg))
+			b, _ := tx.Bucket("test")
+			return b.Put(key, value)
+		}))
+	}
+	header := func(stages ...string) []byte {
+		h := []byte{pipelineVersion, byte(len(stages))}
+		for _, name := range stages {
+			h = append(append(h, byte(len(name))), name...)
+		}
+		return h
+	}
+
+	put(BucketConfig{Pipeline: "gzip,cipher,sign", CompressThreshold: 100}, "long", long)
+	put(BucketConfig{Pipeline: "gzip,cipher,sign", CompressThreshold: 100}, "short", []byte("abcabc"))
+	put(BucketConfig{Pipeline: "reverse"}, "reversed", []byte("value"))
+
+	s.True(bytes.HasPrefix(s.storedValue(db, "test", "long"), header("gzip", "cipher", "sign")))
+	s.True(bytes.HasPrefix(s.storedValue(db, "test", "short"), header("cipher", "sign")), "values below the threshold are not compressed")
+	s.Equal(append(header("reverse"), "eulav"...), s.storedValue(db, "test", "reversed"))
+
+	// Every value is read with the stages it was written with
+	s.NoError(db.Transaction(func(tx *Tx) error {
+		b, _ := tx.Bucket("test")
+		for key, want := range map[string][]byte{"long": long, "short": []byte("abcabc"), "reversed": []byte("value")} {
+			value, err := b.Get(key)
+			s.NoError(err)
+			s.Equal(want, value, key)
+		}
+
+		s.Error(tx.SetBucketConfig("test", BucketConfig{Pipeline: "gzip,missing"}))
+		s.Error(tx.SetBucketConfig("test", BucketConfig{Pipeline: "gzip,,sign"}))
+		s.Error(tx.SetBucketConfig("test", BucketConfig{Pipeline: "sign", Codec: "cipher"}))
+		return nil
+	}))
+
+	signed, err := sign.Encode([]byte("value"))
+	s.Require().NoError(err)
+	signed[0] ^= 1
+	_, err = sign.Decode(signed)
+	s.Equal(ErrSignature, err)
+}
@@ -0,0 +1,101 @@
+package kvite
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// PriorityQueue is a durable priority queue stored in a bucket. Pop returns the item with the highest priority,
+// and items of equal priority in the order they were pushed. Keys combine the inverted priority with a sequence
+// number, so the bucket's keys sort in queue order. Every operation runs in its own transaction.
+type PriorityQueue struct {
+	q Queue
+}
+
+// PriorityQueue returns the priority queue stored in bucket. The bucket should not be used for anything else.
+func (db *DB) PriorityQueue(bucket string) *PriorityQueue {
+	return &PriorityQueue{q: Queue{db: db, bucket: bucket}}
+}
+
+// priorityKey orders higher priorities first, then lower sequence numbers.
+func priorityKey(priority int64, seq uint64) string {
+	return fmt.Sprintf("%016x/%020d", ^(uint64(priority) ^ 1<<63), seq)
+}
+
+func parsePriorityKey(key string) (int64, error) {
+	i := strings.IndexByte(key, '/')
+	if i < 0 {
+		return 0, fmt.Errorf("key %q is not a priority queue item", key)
+	}
+	n, err := strconv.ParseUint(key[:i], 16, 64)
+	if err != nil {
+		return 0, fmt.Errorf("key %q is not a priority queue item", key)
+	}
+	return int64(^n ^ 1<<63), nil
+}
+
+// Push adds a value to the queue with the given priority.
+func (pq *PriorityQueue) Push(priority int64, value []byte) error {
+	db := pq.q.db
+	return db.Transaction(func(tx *Tx) error {
+		if err := tx.lockForWrite(); err != nil {
+			return err
+		}
+
+		name := "pqueue_seq:" + pq.q.bucket
+		last, err := getMeta(tx.tx, db.metaTable(), name)
+		if err != nil {
+			return err
+		}
+		var seq uint64
+		if last != "" {
+			if seq, err = strconv.ParseUint(last, 10, 64); err != nil {
+				return err
+			}
+			seq++
+		}
+		if err := setMeta(tx.tx, db.metaTable(), name, strconv.FormatUint(seq, 10)); err != nil {
+			return err
+		}
+
+		b := tx.newBucket(pq.q.bucket)
+		return b.Put(priorityKey(priority, seq), value)
+	})
+}
+
+// Pop removes the highest priority item from the queue and returns it with its priority. It returns a nil value if
+// the queue is empty. As with Queue.Pop, concurrent consumers never receive the same item.
+func (pq *PriorityQueue) Pop() ([]byte, int64, error) {
+	var (
+		value    []byte
+		priority int64
+	)
+	err := pq.q.db.Transaction(func(tx *Tx) error {
+		if err := tx.lockForWrite(); err != nil {
+			return err
+		}
+		b, key, err := pq.q.head(tx)
+		if err != nil || key == "" {
+			return err
+		}
+		if priority, err = parsePriorityKey(key); err != nil {
+			return err
+		}
+		if value, err = b.Get(key); err != nil {
+			return err
+		}
+		return b.Delete(key)
+	})
+	return value, priority, err
+}
+
+// Peek returns the highest priority item without removing it. It returns nil if the queue is empty.
+func (pq *PriorityQueue) Peek() ([]byte, error) {
+	return pq.q.Peek()
+}
+
+// Len returns the number of items in the queue.
+func (pq *PriorityQueue) Len() (int, error) {
+	return pq.q.Len()
+}
@@ -0,0 +1,43 @@
+package kvite
+
+import "math"
+
+func (s *KViteTestSuite) TestPriorityQueue() {
+	pq := s.DB.PriorityQueue("retries")
+
+	s.NoError(pq.Push(1, []byte("low")))
+	s.NoError(pq.Push(10, []byte("high")))
+	s.NoError(pq.Push(-5, []byte("negative")))
+	s.NoError(pq.Push(10, []byte("high, later")))
+	s.NoError(pq.Push(math.MaxInt64, []byte("max")))
+	s.NoError(pq.Push(math.MinInt64, []byte("min")))
+
+	n, err := pq.Len()
+	s.NoError(err)
+	s.Equal(6, n)
+
+	value, err := pq.Peek()
+	s.NoError(err)
+	s.Equal("max", string(value))
+
+	for _, want := range []struct {
+		value    string
+		priority int64
+	}{
+		{"max", math.MaxInt64},
+		{"high", 10},
+		{"high, later", 10},
+		{"low", 1},
+		{"negative", -5},
+		{"min", math.MinInt64},
+	} {
+		value, priority, err := pq.Pop()
+		s.NoError(err)
+		s.Equal(want.value, string(value))
+		s.Equal(want.priority, priority)
+	}
+
+	value, _, err = pq.Pop()
+	s.NoError(err)
+	s.Nil(value)
+}
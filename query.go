@@ -0,0 +1,173 @@
+package kvite
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Query selects keys of a bucket by their values, which are expected to be JSON documents. Conditions are compiled
+// to SQL over the value column and combined with AND. Values that are not valid JSON never match a WhereJSON
+// condition, and neither do the values of buckets whose BucketConfig encodes or compresses them. A Query is built with Bucket.Query and runs in the bucket's transaction.
+type Query struct {
+	b      *Bucket
+	conds  []string
+	args   []interface{}
+	limit  int
+	prefix string
+	start  string
+	end    string
+}
+
+// Query starts a query over the bucket.
+func (b *Bucket) Query() *Query {
+	return &Query{b: b}
+}
+
+// WhereJSON matches values whose JSON at path, such as "$.state", equals value. value may be a string, number,
+// bool or nil; nil matches a JSON null or a missing path.
+func (q *Query) WhereJSON(path string, value interface{}) *Query {
+	expr := fmt.Sprintf("CASE WHEN json_valid(%s) THEN json_extract(%s, ?) END", q.valueText(), q.valueText())
+	if value == nil {
+		q.conds = append(q.conds, fmt.Sprintf("json_valid(%s) AND %s IS NULL", q.valueText(), expr))
+		q.args = append(q.args, path)
+		return q
+	}
+	q.conds = append(q.conds, expr+" = ?")
+	q.args = append(q.args, path, value)
+	return q
+}
+
+// Prefix restricts the query to keys beginning with prefix.
+func (q *Query) Prefix(prefix string) *Query {
+	q.prefix = prefix
+	return q
+}
+
+// Range restricts the query to keys from start, inclusive, to end, exclusive. An empty end leaves the range open.
+func (q *Query) Range(start, end string) *Query {
+	q.start, q.end = start, end
+	return q
+}
+
+// Limit returns at most n keys. Keys are taken in order.
+func (q *Query) Limit(n int) *Query {
+	q.limit = n
+	return q
+}
+
+// valueText is the SQL expression for a row's value as text. SQLite reads a BLOB as binary JSON, so values,
+// which are stored as BLOBs, are cast to text for the JSON functions.
+func (q *Query) valueText() string {
+	if q.b.tx.db.dedup {
+		return "CAST(coalesce(v.value, t.value) AS TEXT)"
+	}
+	return "CAST(t.value AS TEXT)"
+}
+
+// build returns the SQL selecting cols for the matching rows, with its arguments.
+func (q *Query) build(cols string) (string, []interface{}) {
+	db := q.b.tx.db
+	from := fmt.Sprintf("'%s' t", db.table)
+	if db.dedup {
+		from += fmt.Sprintf(" LEFT JOIN '%s' v ON v.hash = t.value_ref", db.valuesTable())
+	}
+
+	where := []string{"t.bucket = ?", "(t.expires IS NULL OR t.expires > ?)"}
+	args := []interface{}{q.b.name, time.Now().UnixNano()}
+	if q.prefix != "" {
+		if end, ok := prefixEnd(q.prefix); ok {
+			where = append(where, "t.key >= ? AND t.key < ?")
+			args = append(args, q.prefix, end)
+		} else {
+			where = append(where, "t.key >= ?")
+			args = append(args, q.prefix)
+		}
+	}
+	if q.start != "" {
+		where = append(where, "t.key >= ?")
+		args = append(args, q.start)
+	}
+	if q.end != "" {
+		where = append(where, "t.key < ?")
+		args = append(args, q.end)
+	}
+	where = append(where, q.conds...)
+	args = append(args, q.args...)
+
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE %s ORDER BY t.key", cols, from, strings.Join(where, " AND "))
+	if q.limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, q.limit)
+	}
+	return query, args
+}
+
+// Keys returns the matching keys in order.
+func (q *Query) Keys() ([]string, error) {
+	var keys []string
+	err := q.run("t.key", func(rows *sql.Rows) error {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return err
+		}
+		keys = append(keys, key)
+		return nil
+	})
+	return keys, err
+}
+
+// ForEach calls fn for each matching key and its value, in key order. If fn returns an error then the iteration is
+// stopped and the error is returned to the caller.
+func (q *Query) ForEach(fn func(k string, v []byte) error) error {
+	cols := "t.key, t.value, t.checksum"
+	if q.b.tx.db.dedup {
+		cols = "t.key, coalesce(v.value, t.value), t.checksum"
+	}
+	return q.run(cols, func(rows *sql.Rows) error {
+		var (
+			key   string
+			value []byte
+			sum   sql.NullInt64
+		)
+		if err := rows.Scan(&key, &value, &sum); err != nil {
+			return err
+		}
+		value, err := q.b.scanValue(key, value, sum)
+		if err != nil {
+			return err
+		}
+		q.b.tx.db.bucketStats.read(q.b.name, len(value))
+		return fn(key, value)
+	})
+}
+
+// Count returns the number of matching keys, up to the limit.
+func (q *Query) Count() (int, error) {
+	n := 0
+	err := q.run("t.key", func(*sql.Rows) error {
+		n++
+		return nil
+	})
+	return n, err
+}
+
+func (q *Query) run(cols string, fn func(*sql.Rows) error) error {
+	if err := q.b.checkAccess("query", false); err != nil {
+		return err
+	}
+	query, args := q.build(cols)
+	rows, err := q.b.tx.query(query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := fn(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
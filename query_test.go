@@ -0,0 +1,68 @@
+package kvite
+
+import "fmt"
+
+func (s *KViteTestSuite) TestQuery() {
+	dedup := s.openDB("dedup.db", WithDeduplication())
+	defer func() { _ = dedup.Close() }()
+
+	for _, db := range []*DB{s.DB, dedup} {
+		s.NoError(db.Transaction(func(tx *Tx) error {
+			b, _ := tx.CreateBucketIfNotExists("jobs")
+			for i := 0; i < 6; i++ {
+				state := "running"
+				if i%2 == 1 {
+					state = "done"
+				}
+				value := fmt.Sprintf(`{"state": %q, "n": %d, "ok": %t}`, state, i, i < 3)
+				if err := b.Put(fmt.Sprint("job-", i), []byte(value)); err != nil {
+					return err
+				}
+			}
+			if err := b.Put("job-nulls", []byte(`{"state": null}`)); err != nil {
+				return err
+			}
+			if err := b.Put("job-text", []byte("not json")); err != nil {
+				return err
+			}
+			other, _ := tx.CreateBucketIfNotExists("other")
+			return other.Put("job-9", []byte(`{"state": "running"}`))
+		}))
+
+		s.NoError(db.Transaction(func(tx *Tx) error {
+			b, _ := tx.CreateBucketIfNotExists("jobs")
+
+			keys, err := b.Query().WhereJSON("$.state", "running").Keys()
+			s.NoError(err)
+			s.Equal([]string{"job-0", "job-2", "job-4"}, keys)
+
+			keys, err = b.Query().WhereJSON("$.state", "running").Limit(2).Keys()
+			s.NoError(err)
+			s.Equal([]string{"job-0", "job-2"}, keys)
+
+			keys, err = b.Query().WhereJSON("$.state", "done").WhereJSON("$.ok", true).Keys()
+			s.NoError(err)
+			s.Equal([]string{"job-1"}, keys)
+
+			keys, err = b.Query().WhereJSON("$.n", 4).Keys()
+			s.NoError(err)
+			s.Equal([]string{"job-4"}, keys)
+
+			keys, err = b.Query().WhereJSON("$.state", nil).Keys()
+			s.NoError(err)
+			s.Equal([]string{"job-nulls"}, keys)
+
+			n, err := b.Query().Prefix("job-").Count()
+			s.NoError(err)
+			s.Equal(8, n)
+
+			values := map[string]string{}
+			s.NoError(b.Query().WhereJSON("$.n", 5).ForEach(func(k string, v []byte) error {
+				values[k] = string(v)
+				return nil
+			}))
+			s.Equal(map[string]string{"job-5": `{"state": "done", "n": 5, "ok": false}`}, values)
+			return nil
+		}))
+	}
+}
@@ -0,0 +1,121 @@
+package kvite
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Queue is a durable FIFO queue stored in a bucket. Items are kept under zero-padded sequence numbers, so the
+// bucket's keys sort in queue order. Every operation runs in its own transaction. Items that have expired, through
+// the bucket's DefaultTTL, are not part of the queue.
+type Queue struct {
+	db     *DB
+	bucket string
+}
+
+// Queue returns the queue stored in bucket. The bucket should not be used for anything else.
+func (db *DB) Queue(bucket string) *Queue {
+	return &Queue{db: db, bucket: bucket}
+}
+
+// queueKey formats a sequence number so that keys sort numerically.
+func queueKey(seq uint64) string {
+	return fmt.Sprintf("%020d", seq)
+}
+
+// Push appends a value to the tail of the queue.
+func (q *Queue) Push(value []byte) error {
+	return q.db.Transaction(func(tx *Tx) error {
+		if err := tx.lockForWrite(); err != nil {
+			return err
+		}
+
+		var last sql.NullString
+		query := fmt.Sprintf("SELECT max(key) FROM '%s' WHERE bucket = ?", q.db.table)
+		if err := tx.queryRow(query, q.bucket).Scan(&last); err != nil {
+			return err
+		}
+		var seq uint64
+		if last.Valid {
+			n, err := strconv.ParseUint(last.String, 10, 64)
+			if err != nil {
+				return fmt.Errorf("bucket %s holds key %q, which is not a queue item", q.bucket, last.String)
+			}
+			seq = n + 1
+		}
+
+		b := tx.newBucket(q.bucket)
+		return b.Put(queueKey(seq), value)
+	})
+}
+
+// Pop removes the item at the head of the queue and returns it. It returns nil if the queue is empty.
+// Claiming and deleting the item happen in one transaction, so concurrent consumers, including other processes,
+// never receive the same item.
+func (q *Queue) Pop() ([]byte, error) {
+	var value []byte
+	err := q.db.Transaction(func(tx *Tx) error {
+		if err := tx.lockForWrite(); err != nil {
+			return err
+		}
+		b, key, err := q.head(tx)
+		if err != nil || key == "" {
+			return err
+		}
+		if value, err = b.Get(key); err != nil {
+			return err
+		}
+		return b.Delete(key)
+	})
+	return value, err
+}
+
+// Peek returns the item at the head of the queue without removing it. It returns nil if the queue is empty.
+func (q *Queue) Peek() ([]byte, error) {
+	tx, err := q.begin()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+	b, key, err := q.head(tx)
+	if err != nil || key == "" {
+		return nil, err
+	}
+	return b.Get(key)
+}
+
+// Len returns the number of items in the queue.
+func (q *Queue) Len() (int, error) {
+	tx, err := q.begin()
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback() }()
+	var n int
+	query := fmt.Sprintf("SELECT count(*) FROM '%s' WHERE bucket = ? AND (expires IS NULL OR expires > ?)", q.db.table)
+	err = tx.queryRow(query, q.bucket, time.Now().UnixNano()).Scan(&n)
+	return n, err
+}
+
+// begin starts the read-only transaction Peek and Len run in, which does not wait for WithSingleWriter's writer.
+func (q *Queue) begin() (*Tx, error) {
+	tx, err := q.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	tx.readOnly = true
+	return tx, nil
+}
+
+// head returns the key of the item at the head of the queue, or "" if the queue is empty.
+func (q *Queue) head(tx *Tx) (*Bucket, string, error) {
+	b := tx.newBucket(q.bucket)
+	var key sql.NullString
+	query := fmt.Sprintf("SELECT min(key) FROM '%s' WHERE bucket = ? AND (expires IS NULL OR expires > ?)", q.db.table)
+	if err := tx.queryRow(query, q.bucket, time.Now().UnixNano()).Scan(&key); err != nil {
+		return nil, "", err
+	}
+	return b, key.String, nil
+}
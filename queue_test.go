@@ -0,0 +1,91 @@
+package kvite
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+func (s *KViteTestSuite) TestQueue() {
+	q := s.DB.Queue("jobs")
+
+	value, err := q.Pop()
+	s.NoError(err)
+	s.Nil(value)
+
+	for i := 0; i < 3; i++ {
+		s.NoError(q.Push([]byte(fmt.Sprint(i))))
+	}
+	n, err := q.Len()
+	s.NoError(err)
+	s.Equal(3, n)
+
+	value, err = q.Peek()
+	s.NoError(err)
+	s.Equal([]byte("0"), value)
+
+	for i := 0; i < 3; i++ {
+		value, err = q.Pop()
+		s.NoError(err)
+		s.Equal([]byte(fmt.Sprint(i)), value)
+	}
+	n, _ = q.Len()
+	s.Equal(0, n)
+}
+
+func (s *KViteTestSuite) TestQueueExpiredHead() {
+	q := s.DB.Queue("jobs")
+	setTTL := func(ttl time.Duration) {
+		s.NoError(s.DB.Transaction(func(tx *Tx) error {
+			return tx.SetBucketConfig("jobs", BucketConfig{DefaultTTL: ttl})
+		}))
+	}
+	setTTL(20 * time.Millisecond)
+	s.NoError(q.Push([]byte("stale")))
+	setTTL(0)
+	s.NoError(q.Push([]byte("fresh")))
+	time.Sleep(30 * time.Millisecond)
+
+	n, err := q.Len()
+	s.NoError(err)
+	s.Equal(1, n)
+	value, err := q.Peek()
+	s.NoError(err)
+	s.Equal([]byte("fresh"), value)
+	value, err = q.Pop()
+	s.NoError(err)
+	s.Equal([]byte("fresh"), value)
+}
+
+func (s *KViteTestSuite) TestQueueConcurrentPop() {
+	db := s.openDB("queue.db")
+	defer func() { _ = db.Close() }()
+	q := db.Queue("jobs")
+	for i := 0; i < 100; i++ {
+		s.Require().NoError(q.Push([]byte(fmt.Sprint(i))))
+	}
+
+	var (
+		mu   sync.Mutex
+		seen = make(map[string]bool)
+		wg   sync.WaitGroup
+	)
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				value, err := q.Pop()
+				if !s.NoError(err) || value == nil {
+					return
+				}
+				mu.Lock()
+				s.False(seen[string(value)], "popped twice")
+				seen[string(value)] = true
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	s.Len(seen, 100)
+}
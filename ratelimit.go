@@ -0,0 +1,135 @@
+package kvite
+
+import (
+	"path"
+	"sync"
+	"time"
+)
+
+// WithWriteLimit limits Put and Delete across the whole database to rate operations per second, with bursts of up
+// to burst operations. Writes over the limit fail immediately with ErrRateLimited rather than waiting, since a
+// waiting write would hold the SQLite writer lock and block everyone else.
+func WithWriteLimit(rate float64, burst int) Option {
+	return func(db *DB) error {
+		db.writeLimits().global = newTokenBucket(rate, burst)
+		return nil
+	}
+}
+
+// WithBucketWriteLimit limits Put and Delete in each bucket matching pattern, which uses the syntax of path.Match.
+// Every matching bucket gets its own allowance of rate operations per second and bursts of up to burst. When several
+// patterns match a bucket, the one added last applies. Bucket limits apply in addition to WithWriteLimit.
+func WithBucketWriteLimit(pattern string, rate float64, burst int) Option {
+	return func(db *DB) error {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return err
+		}
+		l := db.writeLimits()
+		l.rules = append(l.rules, limitRule{pattern: pattern, rate: rate, burst: burst})
+		return nil
+	}
+}
+
+type limitRule struct {
+	pattern string
+	rate    float64
+	burst   int
+}
+
+// writeLimiter holds the token buckets of a database. It is shared by handles returned from Restrict.
+type writeLimiter struct {
+	global *tokenBucket
+	rules  []limitRule
+
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+func (db *DB) writeLimits() *writeLimiter {
+	if db.limiter == nil {
+		db.limiter = &writeLimiter{buckets: make(map[string]*tokenBucket)}
+	}
+	return db.limiter
+}
+
+// allowWrite takes a token for a write to a bucket, returning ErrRateLimited if none is available. A write refused
+// by one limit takes no token from the other.
+func (db *DB) allowWrite(bucket string) error {
+	l := db.limiter
+	if l == nil {
+		return nil
+	}
+
+	now := time.Now()
+	tb := l.bucketLimit(bucket)
+	if tb != nil && !tb.allow(now) {
+		return ErrRateLimited
+	}
+	if l.global != nil && !l.global.allow(now) {
+		if tb != nil {
+			tb.refund()
+		}
+		return ErrRateLimited
+	}
+	return nil
+}
+
+func (l *writeLimiter) bucketLimit(bucket string) *tokenBucket {
+	for i := len(l.rules) - 1; i >= 0; i-- {
+		r := l.rules[i]
+		if ok, _ := path.Match(r.pattern, bucket); !ok {
+			continue
+		}
+
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		tb, ok := l.buckets[bucket]
+		if !ok {
+			tb = newTokenBucket(r.rate, r.burst)
+			l.buckets[bucket] = tb
+		}
+		return tb
+	}
+	return nil
+}
+
+// tokenBucket is a token bucket rate limiter.
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	return &tokenBucket{rate: rate, burst: float64(burst), tokens: float64(burst)}
+}
+
+func (tb *tokenBucket) allow(now time.Time) bool {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+
+	if !tb.last.IsZero() {
+		tb.tokens += now.Sub(tb.last).Seconds() * tb.rate
+		if tb.tokens > tb.burst {
+			tb.tokens = tb.burst
+		}
+	}
+	tb.last = now
+
+	if tb.tokens < 1 {
+		return false
+	}
+	tb.tokens--
+	return true
+}
+
+// refund returns a token taken by allow for a write that was refused after all.
+func (tb *tokenBucket) refund() {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+	if tb.tokens++; tb.tokens > tb.burst {
+		tb.tokens = tb.burst
+	}
+}
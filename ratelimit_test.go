@@ -0,0 +1,59 @@
+package kvite
+
+import "time"
+
+func (s *KViteTestSuite) TestWriteLimit() {
+	db := s.openDB("limit.db", WithWriteLimit(10, 5), WithBucketWriteLimit("noisy", 0.001, 2))
+	defer func() { _ = db.Close() }()
+
+	put := func(bucket string) error {
+		return db.Transaction(func(tx *Tx) error {
+			b, _ := tx.CreateBucketIfNotExists(bucket)
+			return b.Put("foo", []byte("bar"))
+		})
+	}
+
+	s.NoError(put("noisy"))
+	s.NoError(put("noisy"))
+	s.Equal(ErrRateLimited, put("noisy"))
+	s.NoError(put("quiet"))
+
+	// Neither limit refills noticeably in 10ms
+	time.Sleep(10 * time.Millisecond)
+	s.Equal(ErrRateLimited, put("noisy"))
+	s.NoError(put("quiet"))
+
+	for i := 0; i < 10; i++ {
+		_ = put("quiet")
+	}
+	s.Equal(ErrRateLimited, put("quiet"))
+}
+
+func (s *KViteTestSuite) TestWriteLimitGlobalFirst() {
+	db := s.openDB("limit-global.db", WithWriteLimit(0.001, 1), WithBucketWriteLimit("metered", 0.001, 2))
+	defer func() { _ = db.Close() }()
+
+	s.NoError(db.allowWrite("other"))
+	// Writes refused by the database limit do not use up the bucket's allowance.
+	for i := 0; i < 3; i++ {
+		s.Equal(ErrRateLimited, db.allowWrite("metered"))
+	}
+	tb := db.limiter.bucketLimit("metered")
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+	s.Equal(2.0, tb.tokens)
+}
+
+func (s *KViteTestSuite) TestTokenBucket() {
+	tb := newTokenBucket(10, 2)
+	now := time.Now()
+	s.True(tb.allow(now))
+	s.True(tb.allow(now))
+	s.False(tb.allow(now))
+	s.True(tb.allow(now.Add(100 * time.Millisecond)))
+	s.False(tb.allow(now.Add(100 * time.Millisecond)))
+	// Tokens never exceed the burst
+	s.True(tb.allow(now.Add(time.Hour)))
+	s.True(tb.allow(now.Add(time.Hour)))
+	s.False(tb.allow(now.Add(time.Hour)))
+}
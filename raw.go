@@ -0,0 +1,52 @@
+package kvite
+
+import (
+	"database/sql"
+	"strings"
+)
+
+// RawTable is replaced with the quoted name of the kvite table in queries given to QueryRaw and ExecRaw.
+const RawTable = "{table}"
+
+// QueryRaw runs a query in the transaction, with RawTable standing for the kvite table, as in
+// "SELECT bucket, count(*) FROM {table} GROUP BY bucket". Arguments are passed as query parameters. It is meant for
+// one-off analysis: the table layout may change between releases, and values may be stored elsewhere when
+// WithDeduplication is used.
+func (tx *Tx) QueryRaw(query string, args ...interface{}) (*sql.Rows, error) {
+	if len(tx.db.bucketRules) > 0 {
+		return nil, ErrRawRestricted
+	}
+	return tx.query(tx.db.rawQuery(query), args...)
+}
+
+// ExecRaw runs a statement in the transaction, as for QueryRaw. Changes made this way bypass checksums, versions,
+// the change feed and watchers, and clear the read cache.
+func (tx *Tx) ExecRaw(query string, args ...interface{}) (sql.Result, error) {
+	if tx.readOnly {
+		return nil, ErrTxReadOnly
+	}
+	if len(tx.db.bucketRules) > 0 {
+		return nil, ErrRawRestricted
+	}
+	res, err := tx.exec(tx.db.rawQuery(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	tx.invalidateCache(cacheKey{all: true})
+	if n, err := res.RowsAffected(); err == nil {
+		tx.writes += n
+	}
+	return res, nil
+}
+
+func (db *DB) rawQuery(query string) string {
+	return strings.Replace(query, RawTable, `"`+strings.Replace(db.table, `"`, `""`, -1)+`"`, -1)
+}
+
+// Unwrap returns the SQL transaction underlying tx, so that an application can update its own tables atomically
+// with kvite's. This is unsafe: statements run through it bypass kvite's bookkeeping, including statistics, lock
+// diagnostics, the read cache and the change feed, and the transaction must still be committed or rolled back
+// through tx.
+func (tx *Tx) Unwrap() *sql.Tx {
+	return tx.tx
+}
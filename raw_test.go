@@ -0,0 +1,85 @@
+package kvite
+
+func (s *KViteTestSuite) TestRawSQL() {
+	db := s.openDB("raw.db", WithReadCache("*", 10))
+	defer func() { _ = db.Close() }()
+
+	for _, k := range []string{"a", "b", "c"} {
+		s.NoError(db.Put("raw", k, []byte(k)))
+	}
+	s.NoError(db.Put("other", "x", []byte("x")))
+
+	s.NoError(db.Transaction(func(tx *Tx) error {
+		rows, err := tx.QueryRaw("SELECT bucket, count(*) FROM {table} WHERE bucket = ? GROUP BY bucket", "raw")
+		s.Require().NoError(err)
+		defer rows.Close()
+		s.True(rows.Next())
+		var (
+			bucket string
+			n      int
+		)
+		s.NoError(rows.Scan(&bucket, &n))
+		s.Equal("raw", bucket)
+		s.Equal(3, n)
+		return rows.Err()
+	}))
+
+	// Cache a value, then change it behind the cache's back.
+	s.NoError(db.Transaction(func(tx *Tx) error {
+		b, _ := tx.CreateBucketIfNotExists("raw")
+		_, err := b.Get("a")
+		return err
+	}))
+	s.NoError(db.Transaction(func(tx *Tx) error {
+		res, err := tx.ExecRaw("UPDATE {table} SET value = ? WHERE bucket = ?", []byte("z"), "raw")
+		if err != nil {
+			return err
+		}
+		n, err := res.RowsAffected()
+		s.Equal(int64(3), n)
+		return err
+	}))
+	s.NoError(db.Transaction(func(tx *Tx) error {
+		b, _ := tx.CreateBucketIfNotExists("raw")
+		value, err := b.Get("a")
+		s.Equal([]byte("z"), value)
+		return err
+	}))
+
+	restricted, err := db.Restrict("other", BucketRestricted)
+	s.NoError(err)
+	s.NoError(restricted.Transaction(func(tx *Tx) error {
+		_, err := tx.QueryRaw("SELECT * FROM {table}")
+		s.Equal(ErrRawRestricted, err)
+		_, err = tx.ExecRaw("DELETE FROM {table}")
+		s.Equal(ErrRawRestricted, err)
+		return nil
+	}))
+}
+
+func (s *KViteTestSuite) TestTxUnwrap() {
+	s.NoError(s.DB.Transaction(func(tx *Tx) error {
+		if _, err := tx.Unwrap().Exec("CREATE TABLE app_events (name text)"); err != nil {
+			return err
+		}
+		if _, err := tx.Unwrap().Exec("INSERT INTO app_events VALUES ('created')"); err != nil {
+			return err
+		}
+		b, _ := tx.CreateBucketIfNotExists("app")
+		return b.Put("k", []byte("v"))
+	}))
+
+	// A rollback undoes both kvite's and the application's writes.
+	tx, err := s.DB.Begin()
+	s.Require().NoError(err)
+	_, err = tx.Unwrap().Exec("INSERT INTO app_events VALUES ('rolled back')")
+	s.NoError(err)
+	b, _ := tx.CreateBucketIfNotExists("app")
+	s.NoError(b.Put("k", []byte("rolled back")))
+	s.NoError(tx.Rollback())
+
+	var n int
+	s.NoError(s.DB.db.QueryRow("SELECT count(*) FROM app_events").Scan(&n))
+	s.Equal(1, n)
+	s.testStoredValue("app", "k", []byte("v"))
+}
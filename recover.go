@@ -0,0 +1,255 @@
+package kvite
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// RecoverResult describes the outcome of Recover.
+type RecoverResult struct {
+	// Tables lists the kvite tables that were found in the source database.
+	Tables []string
+	// Recovered is the number of key/value pairs copied into the destination.
+	Recovered int
+	// Skipped is the number of rows that were located but could not be read or failed their checksum.
+	Skipped int
+}
+
+type salvagedRow struct {
+	key, bucket string
+	value       []byte
+	sum         sql.NullInt64
+	hlc         sql.NullInt64
+	origin      sql.NullString
+	expires     sql.NullInt64
+	contentType sql.NullString
+}
+
+// Recover copies whatever key/value pairs can still be read from a damaged kvite database at srcPath into a fresh
+// kvite database at dstPath. Every kvite table in the source is salvaged into a table of the same name.
+// Rows are located with forward and backward table scans and through the key index, then read one at a time so that
+// a damaged page only costs the rows stored on it. The source file is opened read-only and is never modified.
+func Recover(srcPath, dstPath string) (*RecoverResult, error) {
+	src, err := sql.Open("sqlite3", fileURI(srcPath, url.Values{"mode": {"ro"}}))
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = src.Close() }()
+
+	tables, err := kviteTables(src)
+	if err != nil {
+		return nil, err
+	}
+	if len(tables) == 0 {
+		return nil, fmt.Errorf("no kvite tables found in %s", srcPath)
+	}
+
+	result := &RecoverResult{Tables: tables}
+	for _, table := range tables {
+		if err := recoverTable(src, dstPath, table, result); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+func recoverTable(src *sql.DB, dstPath, table string, result *RecoverResult) error {
+	dst, err := Open(dstPath, table)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = dst.Close() }()
+
+	clustered, err := isWithoutRowID(src, table)
+	if err != nil {
+		return err
+	}
+
+	var (
+		locators [][]interface{}
+		where    string
+	)
+	if clustered {
+		locators = salvageKeys(src, table)
+		where = "bucket = ? AND key = ?"
+	} else {
+		locators = salvageRowids(src, table)
+		where = "rowid = ?"
+	}
+
+	tx, err := dst.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// Deduplicated values live in the values table
+	value := "value"
+	if dedup, err := tableExists(src, table+"_kvite_values"); err != nil {
+		return err
+	} else if dedup {
+		value = fmt.Sprintf("coalesce((SELECT v.value FROM '%s_kvite_values' v WHERE v.hash = '%s'.value_ref), value)", table, table)
+	}
+
+	query := fmt.Sprintf("SELECT key, bucket, %s, %s, %s, %s, %s, %s FROM '%s' WHERE %s", value,
+		optionalColumn(src, table, "checksum"), optionalColumn(src, table, "hlc"), optionalColumn(src, table, "origin"),
+		optionalColumn(src, table, "expires"), optionalColumn(src, table, "content_type"), table, where)
+	for _, args := range locators {
+		var row salvagedRow
+		if err := src.QueryRow(query, args...).Scan(&row.key, &row.bucket, &row.value, &row.sum, &row.hlc, &row.origin, &row.expires, &row.contentType); err != nil {
+			result.Skipped++
+			continue
+		}
+		if !checksumValid(row.value, row.sum) {
+			result.Skipped++
+			continue
+		}
+		if _, err := tx.Exec(dst.putQuery, row.key, row.value, row.bucket, row.sum, row.hlc, row.origin, nil, row.expires, row.contentType, row.key, row.bucket); err != nil {
+			return err
+		}
+		result.Recovered++
+	}
+
+	// Bucket configuration says how the values were encoded, so carry over whatever of it can be read
+	if exists, _ := tableExists(src, dst.bucketsTable()); exists {
+		if rows, err := src.Query(fmt.Sprintf("SELECT name, %s FROM '%s'", optionalColumn(src, dst.bucketsTable(), "config"), dst.bucketsTable())); err == nil {
+			for rows.Next() {
+				var (
+					name   string
+					config sql.NullString
+				)
+				if rows.Scan(&name, &config) != nil {
+					continue
+				}
+				query := fmt.Sprintf("INSERT OR REPLACE INTO '%s' (name, config) VALUES (?, ?)", dst.bucketsTable())
+				if _, err := tx.Exec(query, name, config); err != nil {
+					_ = rows.Close()
+					return err
+				}
+			}
+			_ = rows.Close()
+		}
+	}
+
+	return tx.Commit()
+}
+
+// salvageRowids collects the rowids that can still be reached in a table. Each strategy stops at the first error,
+// so they are combined to reach rows on both sides of a damaged page.
+func salvageRowids(src *sql.DB, table string) [][]interface{} {
+	seen := make(map[int64]bool)
+	var rowids [][]interface{}
+
+	collect := func(query string) {
+		rows, err := src.Query(query)
+		if err != nil {
+			return
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var rowid int64
+			if err := rows.Scan(&rowid); err != nil {
+				return
+			}
+			if !seen[rowid] {
+				seen[rowid] = true
+				rowids = append(rowids, []interface{}{rowid})
+			}
+		}
+	}
+
+	collect(fmt.Sprintf("SELECT rowid FROM '%s' ORDER BY rowid ASC", table))
+	collect(fmt.Sprintf("SELECT rowid FROM '%s' ORDER BY rowid DESC", table))
+	collect(fmt.Sprintf("SELECT rowid FROM '%s' INDEXED BY '%s_kvite_key_index'", table, table))
+
+	return rowids
+}
+
+// salvageKeys collects the (bucket, key) pairs that can still be reached in a WITHOUT ROWID table, scanning its
+// primary key in both directions.
+func salvageKeys(src *sql.DB, table string) [][]interface{} {
+	seen := make(map[[2]string]bool)
+	var keys [][]interface{}
+
+	collect := func(query string) {
+		rows, err := src.Query(query)
+		if err != nil {
+			return
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var k [2]string
+			if err := rows.Scan(&k[0], &k[1]); err != nil {
+				return
+			}
+			if !seen[k] {
+				seen[k] = true
+				keys = append(keys, []interface{}{k[0], k[1]})
+			}
+		}
+	}
+
+	collect(fmt.Sprintf("SELECT bucket, key FROM '%s' ORDER BY bucket ASC, key ASC", table))
+	collect(fmt.Sprintf("SELECT bucket, key FROM '%s' ORDER BY bucket DESC, key DESC", table))
+
+	return keys
+}
+
+// optionalColumn returns the expression to select a column that older tables may not have.
+func optionalColumn(src *sql.DB, table, name string) string {
+	var n int
+	query := fmt.Sprintf("SELECT count(*) FROM pragma_table_info('%s') WHERE name = ?", table)
+	if err := src.QueryRow(query, name).Scan(&n); err != nil || n == 0 {
+		return "NULL"
+	}
+	return name
+}
+
+// kviteTables returns the names of the tables in a database that have the kvite layout.
+// kvite's own auxiliary tables, including upgrade backups, are excluded.
+func kviteTables(db *sql.DB) ([]string, error) {
+	rows, err := db.Query("SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%' ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			_ = rows.Close()
+			return nil, err
+		}
+		if !strings.Contains(name, "_kvite_") {
+			names = append(names, name)
+		}
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+
+	var tables []string
+	for _, name := range names {
+		var n int
+		query := fmt.Sprintf("SELECT count(*) FROM pragma_table_info('%s') WHERE name IN ('key', 'bucket', 'value')", name)
+		if err := db.QueryRow(query).Scan(&n); err != nil {
+			return nil, err
+		}
+		if n == 3 {
+			tables = append(tables, name)
+		}
+	}
+	return tables, nil
+}
+
+// fileURI builds an SQLite URI filename for a path with the given query parameters.
+func fileURI(path string, params url.Values) string {
+	r := strings.NewReplacer("%", "%25", "?", "%3f", "#", "%23")
+	uri := "file:" + r.Replace(path)
+	if len(params) > 0 {
+		uri += "?" + params.Encode()
+	}
+	return uri
+}
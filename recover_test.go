@@ -0,0 +1,65 @@
+package kvite
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+func (s *KViteTestSuite) TestRecover() {
+	srcPath := filepath.Join(s.TempDir, "damaged.db")
+	dstPath := filepath.Join(s.TempDir, "recovered.db")
+
+	src, err := Open(srcPath, "testing", WithChecksums())
+	s.Require().NoError(err)
+
+	const total = 2000
+	value := bytes.Repeat([]byte("x"), 200)
+	err = src.Transaction(func(tx *Tx) error {
+		b, _ := tx.CreateBucket("test")
+		for i := 0; i < total; i++ {
+			if err := b.Put(fmt.Sprintf("key-%05d", i), value); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	s.Require().NoError(err)
+	s.Require().NoError(src.Close())
+
+	// Scribble over a page in the middle of the file
+	f, err := os.OpenFile(srcPath, os.O_RDWR, 0)
+	s.Require().NoError(err)
+	info, err := f.Stat()
+	s.Require().NoError(err)
+	_, err = f.WriteAt(bytes.Repeat([]byte{0xaa}, 4096), (info.Size()/4096/2)*4096)
+	s.Require().NoError(err)
+	s.Require().NoError(f.Close())
+
+	result, err := Recover(srcPath, dstPath)
+	s.Require().NoError(err)
+	s.Equal([]string{"testing"}, result.Tables)
+	s.True(result.Recovered > 0)
+	s.True(result.Recovered <= total)
+
+	dst := s.openDB("recovered.db")
+	defer func() { _ = dst.Close() }()
+
+	n := 0
+	err = dst.Transaction(func(tx *Tx) error {
+		b, _ := tx.CreateBucketIfNotExists("test")
+		return b.ForEach(func(k string, v []byte) error {
+			n++
+			s.Equal(value, v)
+			return nil
+		})
+	})
+	s.NoError(err)
+	s.Equal(result.Recovered, n)
+}
+
+func (s *KViteTestSuite) TestRecoverNoTables() {
+	_, err := Recover(filepath.Join(s.TempDir, "missing.db"), filepath.Join(s.TempDir, "recovered.db"))
+	s.Error(err)
+}
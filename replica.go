@@ -0,0 +1,281 @@
+package kvite
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"sync"
+	"time"
+)
+
+// Replica is a read-only follower that serves reads from a database shipped by a Replicator.
+// It keeps a local copy of the newest generation up to date by applying new WAL segments as they appear.
+type Replica struct {
+	// OnError is called with errors from syncs started by Start. It may be nil.
+	OnError func(error)
+
+	client ReplicaClient
+	path   string
+	table  string
+	opts   []Option
+
+	// syncMu serializes syncs; mu guards the open database against being swapped out while in use.
+	syncMu     sync.Mutex
+	mu         sync.RWMutex
+	db         *DB
+	generation string
+	pos        *walPosition
+	caughtUp   time.Time
+	// syncErr is the error of the last sync, which leaves db nil if it failed while swapping the local copy.
+	syncErr error
+	closed  bool
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// OpenReplica restores the newest replicated generation from client to path and opens it read-only.
+// Call Sync or Start to keep it up to date. The local file at path is owned by the replica and is replaced as needed.
+func OpenReplica(ctx context.Context, client ReplicaClient, path, table string, opts ...Option) (*Replica, error) {
+	r := &Replica{
+		client: client,
+		path:   path,
+		table:  table,
+		opts:   append(opts, ReadOnly()),
+	}
+	if err := r.Sync(ctx); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// View runs fn in a read-only transaction against the replica. Syncs wait for fn to return. It returns a
+// *ReplicaNotReadyError if a failed sync has left the replica without a local copy, until a sync succeeds, and
+// ErrDBClosed once the replica is closed.
+func (r *Replica) View(fn func(*Tx) error) error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.closed {
+		return ErrDBClosed
+	}
+	if r.db == nil {
+		return &ReplicaNotReadyError{Err: r.syncErr}
+	}
+	return r.db.Transaction(fn)
+}
+
+// ReplicationLag returns how far the replica is behind the primary: the time since the primary recorded the newest
+// position that the replica has fully applied. It returns a negative duration if the replica has not yet caught up
+// with any recorded position.
+func (r *Replica) ReplicationLag() time.Duration {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.caughtUp.IsZero() {
+		return -1
+	}
+	return time.Since(r.caughtUp)
+}
+
+// Start syncs in the background every interval until Close is called.
+func (r *Replica) Start(interval time.Duration) {
+	r.syncMu.Lock()
+	defer r.syncMu.Unlock()
+	if r.stop != nil {
+		return
+	}
+	r.stop = make(chan struct{})
+	r.done = make(chan struct{})
+
+	go func(stop, done chan struct{}) {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if err := r.Sync(context.Background()); err != nil && r.OnError != nil {
+					r.OnError(err)
+				}
+			}
+		}
+	}(r.stop, r.done)
+}
+
+// Close stops background syncing and closes the local database.
+func (r *Replica) Close() error {
+	r.syncMu.Lock()
+	stop, done := r.stop, r.done
+	r.stop, r.done = nil, nil
+	r.syncMu.Unlock()
+
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.closed = true
+	if r.db == nil {
+		return nil
+	}
+	err := r.db.Close()
+	r.db = nil
+	return err
+}
+
+// Sync brings the local copy up to date with the replicated data. If the primary has started a new generation the
+// local copy is rebuilt from scratch, otherwise only new WAL segments are downloaded and applied.
+func (r *Replica) Sync(ctx context.Context) error {
+	r.syncMu.Lock()
+	defer r.syncMu.Unlock()
+
+	position, err := readPosition(ctx, r.client)
+	if err != nil && err != ErrObjectNotFound {
+		return err
+	}
+
+	generation := ""
+	if position != nil {
+		generation = position.Generation
+	} else if generation, err = latestGeneration(ctx, r.client); err != nil {
+		return err
+	}
+
+	if generation != r.generation {
+		err = r.rebuild(ctx, generation)
+	} else {
+		err = r.catchUp(ctx)
+	}
+	r.mu.Lock()
+	r.syncErr = err
+	r.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if position != nil && position.Generation == r.generation &&
+		(r.pos.index > position.Index || r.pos.index == position.Index && r.pos.offset >= position.Offset) {
+		r.mu.Lock()
+		r.caughtUp = position.Time
+		r.mu.Unlock()
+	}
+	return nil
+}
+
+// rebuild restores a generation next to the local copy and swaps it in.
+func (r *Replica) rebuild(ctx context.Context, generation string) error {
+	tmp := r.path + ".kvite-restore"
+	defer func() { _ = os.Remove(tmp) }()
+
+	pos, err := restoreGeneration(ctx, r.client, generation, tmp)
+	if err != nil {
+		return err
+	}
+	if err := setRollbackJournal(tmp); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.closeDB(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, r.path); err != nil {
+		return err
+	}
+	r.generation = generation
+	r.pos = pos
+	r.caughtUp = time.Time{}
+	return r.openDB()
+}
+
+// catchUp downloads the segments written since the last sync and applies them to the local copy.
+func (r *Replica) catchUp(ctx context.Context) error {
+	segments, err := walSegments(ctx, r.client, r.generation)
+	if err != nil {
+		return err
+	}
+
+	var pending []walSegment
+	data := make(map[string][]byte)
+	for _, seg := range segments {
+		if seg.index < r.pos.index || seg.index == r.pos.index && seg.offset < r.pos.offset {
+			continue
+		}
+		var buf bytes.Buffer
+		if err := copyObject(ctx, r.client, seg.name, &buf); err != nil {
+			return err
+		}
+		data[seg.name] = buf.Bytes()
+		pending = append(pending, seg)
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.closeDB(); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(r.path, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	pos, err := applySegments(f, pending, r.pos, func(seg walSegment) ([]byte, error) {
+		return data[seg.name], nil
+	})
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	r.pos = pos
+	if err := setRollbackJournal(r.path); err != nil {
+		return err
+	}
+	return r.openDB()
+}
+
+func (r *Replica) openDB() error {
+	db, err := Open(r.path, r.table, r.opts...)
+	if err != nil {
+		return err
+	}
+	r.db = db
+	return nil
+}
+
+func (r *Replica) closeDB() error {
+	if r.db == nil {
+		return nil
+	}
+	err := r.db.Close()
+	r.db = nil
+	return err
+}
+
+// setRollbackJournal marks a database file as using a rollback journal rather than a WAL, so that it can be opened
+// read-only without creating WAL side files. The file format version bytes live at offsets 18 and 19 of the header.
+func setRollbackJournal(path string) error {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteAt([]byte{1, 1}, 18); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,116 @@
+package kvite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ErrObjectNotFound is returned by a ReplicaClient when the requested object does not exist.
+var ErrObjectNotFound = errors.New("replica object not found")
+
+// ReplicaClient stores replication objects in a remote target. Object names are slash separated.
+type ReplicaClient interface {
+	// Put stores an object, replacing any existing object with the same name.
+	Put(ctx context.Context, name string, r io.Reader) error
+	// Get opens an object for reading. It returns ErrObjectNotFound if the object does not exist.
+	Get(ctx context.Context, name string) (io.ReadCloser, error)
+	// List returns the names of all objects that begin with prefix, in lexical order.
+	List(ctx context.Context, prefix string) ([]string, error)
+}
+
+// NewReplicaClient returns a ReplicaClient for a target URL. Supported forms are a filesystem path or file:// URL,
+// and s3://bucket/prefix for S3-compatible object stores. An s3 URL may set the endpoint and region query
+// parameters; credentials are read from the standard AWS environment variables.
+func NewReplicaClient(target string) (ReplicaClient, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, err
+	}
+
+	switch u.Scheme {
+	case "", "file":
+		return &FileReplicaClient{Dir: u.Path}, nil
+	case "s3":
+		return newS3ReplicaClient(u)
+	default:
+		return nil, fmt.Errorf("unsupported replica scheme %q", u.Scheme)
+	}
+}
+
+// FileReplicaClient stores replication objects as files beneath a directory.
+type FileReplicaClient struct {
+	Dir string
+}
+
+func (c *FileReplicaClient) path(name string) string {
+	return filepath.Join(c.Dir, filepath.FromSlash(name))
+}
+
+// Put stores an object. The file is written under a temporary name and renamed into place.
+func (c *FileReplicaClient) Put(ctx context.Context, name string, r io.Reader) error {
+	path := c.path(name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(path), ".tmp-")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(f.Name()) }()
+
+	if _, err := io.Copy(f, r); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// Get opens an object for reading.
+func (c *FileReplicaClient) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	f, err := os.Open(c.path(name))
+	if os.IsNotExist(err) {
+		return nil, ErrObjectNotFound
+	}
+	return f, err
+}
+
+// List returns the names of all objects that begin with prefix.
+func (c *FileReplicaClient) List(ctx context.Context, prefix string) ([]string, error) {
+	var names []string
+	err := filepath.Walk(c.Dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if info.IsDir() || strings.HasPrefix(info.Name(), ".tmp-") {
+			return nil
+		}
+		rel, err := filepath.Rel(c.Dir, path)
+		if err != nil {
+			return err
+		}
+		if name := filepath.ToSlash(rel); strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
+		return nil
+	})
+	sort.Strings(names)
+	return names, err
+}
@@ -0,0 +1,87 @@
+package kvite
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+func (s *KViteTestSuite) TestReplica() {
+	ctx := context.Background()
+	client := &FileReplicaClient{Dir: filepath.Join(s.TempDir, "replica")}
+
+	primary := s.openDB("primary.db", WithWAL())
+	defer func() { _ = primary.Close() }()
+
+	r, err := NewReplicator(primary, client)
+	s.Require().NoError(err)
+	defer func() { _ = r.Close() }()
+
+	s.putN(primary, "test", 0, 50)
+	s.Require().NoError(r.Sync(ctx))
+
+	replica, err := OpenReplica(ctx, client, filepath.Join(s.TempDir, "follower.db"), "testing")
+	s.Require().NoError(err)
+	defer func() { _ = replica.Close() }()
+
+	count := func() int {
+		n := 0
+		err := replica.View(func(tx *Tx) error {
+			b, _ := tx.CreateBucketIfNotExists("test")
+			return b.ForEach(func(k string, v []byte) error {
+				n++
+				return nil
+			})
+		})
+		s.Require().NoError(err)
+		return n
+	}
+
+	s.Equal(50, count())
+	lag := replica.ReplicationLag()
+	s.True(lag >= 0 && lag < time.Minute)
+
+	// New segments are applied incrementally
+	s.putN(primary, "test", 50, 120)
+	s.Require().NoError(r.Sync(ctx))
+	s.Require().NoError(replica.Sync(ctx))
+	s.Equal(120, count())
+
+	// A new generation is picked up
+	r2, err := NewReplicator(primary, client)
+	s.Require().NoError(err)
+	s.Require().NoError(r.Close())
+	s.putN(primary, "test", 120, 130)
+	s.Require().NoError(r2.Sync(ctx))
+	defer func() { _ = r2.Close() }()
+	s.Require().NoError(replica.Sync(ctx))
+	s.Equal(130, count())
+
+	// The replica is read-only
+	err = replica.View(func(tx *Tx) error {
+		b, _ := tx.CreateBucketIfNotExists("test")
+		return b.Put("foo", []byte("bar"))
+	})
+	s.Equal(ErrTxReadOnly, err)
+
+	// A rebuild that fails once the local copy is closed leaves the replica unreadable until a sync succeeds
+	r3, err := NewReplicator(primary, client)
+	s.Require().NoError(err)
+	s.Require().NoError(r2.Close())
+	s.Require().NoError(r3.Sync(ctx))
+	defer func() { _ = r3.Close() }()
+	follower := filepath.Join(s.TempDir, "follower.db")
+	s.Require().NoError(os.Remove(follower))
+	s.Require().NoError(os.MkdirAll(filepath.Join(follower, "blocked"), 0700))
+	syncErr := replica.Sync(ctx)
+	s.Require().Error(syncErr)
+	err = replica.View(func(tx *Tx) error { return nil })
+	s.Equal(&ReplicaNotReadyError{Err: syncErr}, err)
+	s.Require().NoError(os.RemoveAll(follower))
+	s.Require().NoError(replica.Sync(ctx))
+	s.Equal(130, count())
+
+	s.NoError(replica.Close())
+	s.Equal(ErrDBClosed, replica.View(func(tx *Tx) error { return nil }))
+}
@@ -0,0 +1,548 @@
+package kvite
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultCheckpointFrames is the WAL size, in frames, at which a Replicator checkpoints the database.
+const DefaultCheckpointFrames = 1000
+
+var errWALDiscontinuity = errors.New("WAL was restarted outside of the replicator")
+
+// Replicator continuously ships the write-ahead log of a database to a ReplicaClient.
+//
+// Replication is organised in generations. A generation starts with a snapshot of the database file, followed by
+// every committed WAL frame written since, grouped by WAL index (the WAL restarts with a new index after each
+// checkpoint). Whenever the replicator cannot prove that it has seen every frame, for example because another
+// process checkpointed and restarted the WAL, it starts a new generation. Restore rebuilds the database from the
+// newest generation.
+//
+// While running, the replicator holds a read transaction so that the WAL cannot be restarted behind its back, and it
+// performs checkpoints itself once the WAL reaches CheckpointFrames frames.
+type Replicator struct {
+	// CheckpointFrames is the WAL size, in frames, that triggers a checkpoint.
+	CheckpointFrames int
+	// OnError is called with errors from syncs started by Start. It may be nil.
+	OnError func(error)
+
+	db     *DB
+	client ReplicaClient
+
+	mu         sync.Mutex
+	read       *sql.Conn
+	generation string
+	index      int
+	offset     int64
+	wal        *walReader
+	prevHeader []byte
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewReplicator creates a replicator for a database. The database must be a file in WAL mode (see WithWAL).
+func NewReplicator(db *DB, client ReplicaClient) (*Replicator, error) {
+	if db.filename == "" {
+		return nil, errors.New("replication requires a file-backed database")
+	}
+	if err := db.life.check(); err != nil {
+		return nil, err
+	}
+
+	var mode string
+	if err := db.db.QueryRow("PRAGMA journal_mode").Scan(&mode); err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(mode, "wal") {
+		return nil, ErrNotWAL
+	}
+
+	return &Replicator{
+		CheckpointFrames: DefaultCheckpointFrames,
+		db:               db,
+		client:           client,
+	}, nil
+}
+
+// Generation returns the identifier of the current generation, or an empty string before the first sync.
+func (r *Replicator) Generation() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.generation
+}
+
+// Start syncs in the background every interval until Close is called.
+func (r *Replicator) Start(interval time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.stop != nil {
+		return
+	}
+	r.stop = make(chan struct{})
+	r.done = make(chan struct{})
+
+	go func(stop, done chan struct{}) {
+		defer close(done)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if err := r.Sync(context.Background()); err != nil && r.OnError != nil {
+					r.OnError(err)
+				}
+			}
+		}
+	}(r.stop, r.done)
+}
+
+// Close stops background syncing and releases the replicator's hold on the WAL.
+func (r *Replicator) Close() error {
+	r.mu.Lock()
+	stop, done := r.stop, r.done
+	r.stop, r.done = nil, nil
+	r.mu.Unlock()
+
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.releaseReadLock()
+}
+
+// Sync ships all WAL frames committed since the last sync, starting a new generation when needed,
+// and checkpoints the database if the WAL has grown past CheckpointFrames.
+// Each successful sync also records the replicated position and time, which followers use to measure their lag.
+func (r *Replicator) Sync(ctx context.Context) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if err := r.sync(ctx); err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(replicaPosition{
+		Generation: r.generation,
+		Index:      r.index,
+		Offset:     r.offset,
+		Time:       time.Now().UTC(),
+	})
+	if err != nil {
+		return err
+	}
+	return r.client.Put(ctx, positionObject, bytes.NewReader(b))
+}
+
+func (r *Replicator) sync(ctx context.Context) error {
+	if r.generation == "" {
+		return r.startGeneration(ctx)
+	}
+
+	if err := r.ship(ctx); err != nil {
+		if err == errWALDiscontinuity {
+			return r.startGeneration(ctx)
+		}
+		return err
+	}
+
+	if r.wal != nil && r.frames() >= int64(r.CheckpointFrames) {
+		return r.checkpoint(ctx)
+	}
+	return nil
+}
+
+func (r *Replicator) walPath() string {
+	return r.db.filename + "-wal"
+}
+
+// frames returns the number of frames shipped from the current WAL index.
+func (r *Replicator) frames() int64 {
+	if r.wal == nil || r.offset < walHeaderSize {
+		return 0
+	}
+	return (r.offset - walHeaderSize) / int64(r.wal.hdr.frameSize())
+}
+
+// startGeneration snapshots the database and ships the whole current WAL while holding the write lock.
+func (r *Replicator) startGeneration(ctx context.Context) error {
+	if err := r.releaseReadLock(); err != nil {
+		return err
+	}
+
+	conn, err := r.db.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = conn.Close() }()
+
+	if _, err := conn.ExecContext(ctx, "BEGIN IMMEDIATE"); err != nil {
+		return err
+	}
+	defer func() { _, _ = conn.ExecContext(context.Background(), "ROLLBACK") }()
+
+	if err := r.acquireReadLock(ctx); err != nil {
+		return err
+	}
+
+	generation, err := newGeneration()
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(r.db.filename)
+	if err != nil {
+		return err
+	}
+	err = r.client.Put(ctx, snapshotObject(generation), f)
+	_ = f.Close()
+	if err != nil {
+		return err
+	}
+
+	r.generation = generation
+	r.index = 0
+	r.offset = 0
+	r.wal = nil
+	r.prevHeader = nil
+
+	return r.ship(ctx)
+}
+
+// ship uploads the committed frames appended to the WAL since the last ship as a new segment.
+func (r *Replicator) ship(ctx context.Context) error {
+	var segment []byte
+	var body []byte
+
+	if r.wal == nil {
+		data, err := readWALFile(r.walPath(), 0)
+		if err != nil || len(data) < walHeaderSize {
+			return err
+		}
+		if r.prevHeader != nil && bytes.Equal(data[:walHeaderSize], r.prevHeader) {
+			// The WAL has not been restarted since the last checkpoint yet.
+			return nil
+		}
+		hdr, err := parseWALHeader(data)
+		if err != nil {
+			return errWALDiscontinuity
+		}
+		r.wal = newWALReader(hdr)
+		segment, body = data[:walHeaderSize], data[walHeaderSize:]
+	} else {
+		hdr, err := readWALHeader(r.walPath())
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(hdr, r.wal.hdr.raw) {
+			return errWALDiscontinuity
+		}
+		if body, err = readWALFile(r.walPath(), r.offset); err != nil {
+			return err
+		}
+	}
+
+	reader := *r.wal
+	n, err := reader.readCommitted(body, nil)
+	if err != nil {
+		return err
+	}
+	segment = append(segment, body[:n]...)
+	if len(segment) == 0 {
+		return nil
+	}
+
+	if err := r.client.Put(ctx, walObject(r.generation, r.index, r.offset), bytes.NewReader(segment)); err != nil {
+		if r.offset == 0 {
+			r.wal = nil
+		}
+		return err
+	}
+
+	*r.wal = reader
+	r.offset += int64(len(segment))
+	return nil
+}
+
+// checkpoint copies the WAL into the database file so that the next writer restarts the WAL at a new index.
+// If frames were committed after the last ship, they would be lost when the WAL restarts, so a new generation is
+// started instead.
+func (r *Replicator) checkpoint(ctx context.Context) error {
+	if err := r.releaseReadLock(); err != nil {
+		return err
+	}
+
+	var busy, log, checkpointed int64
+	err := r.db.db.QueryRowContext(ctx, "PRAGMA wal_checkpoint(RESTART)").Scan(&busy, &log, &checkpointed)
+	if aerr := r.acquireReadLock(ctx); err == nil {
+		err = aerr
+	}
+	if err != nil {
+		return err
+	}
+
+	if busy != 0 {
+		// Readers prevented a full checkpoint, so the WAL will not restart. Try again next time.
+		return nil
+	}
+	if log > r.frames() {
+		return r.startGeneration(ctx)
+	}
+
+	r.prevHeader = r.wal.hdr.raw
+	r.wal = nil
+	r.index++
+	r.offset = 0
+	return nil
+}
+
+// acquireReadLock starts a read transaction on a dedicated connection, which stops the WAL from being restarted.
+func (r *Replicator) acquireReadLock(ctx context.Context) error {
+	conn, err := r.db.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	if _, err := conn.ExecContext(ctx, "BEGIN"); err != nil {
+		_ = conn.Close()
+		return err
+	}
+	var n int
+	if err := conn.QueryRowContext(ctx, "SELECT count(*) FROM sqlite_master").Scan(&n); err != nil {
+		_, _ = conn.ExecContext(ctx, "ROLLBACK")
+		_ = conn.Close()
+		return err
+	}
+	r.read = conn
+	return nil
+}
+
+func (r *Replicator) releaseReadLock() error {
+	if r.read == nil {
+		return nil
+	}
+	conn := r.read
+	r.read = nil
+	_, err := conn.ExecContext(context.Background(), "ROLLBACK")
+	if cerr := conn.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func newGeneration() (string, error) {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%016x%s", time.Now().UnixNano(), hex.EncodeToString(b)), nil
+}
+
+// positionObject holds the JSON encoded replicaPosition of the last sync.
+const positionObject = "position"
+
+type replicaPosition struct {
+	Generation string    `json:"generation"`
+	Index      int       `json:"index"`
+	Offset     int64     `json:"offset"`
+	Time       time.Time `json:"time"`
+}
+
+func readPosition(ctx context.Context, client ReplicaClient) (*replicaPosition, error) {
+	var buf bytes.Buffer
+	if err := copyObject(ctx, client, positionObject, &buf); err != nil {
+		return nil, err
+	}
+	var pos replicaPosition
+	if err := json.Unmarshal(buf.Bytes(), &pos); err != nil {
+		return nil, err
+	}
+	return &pos, nil
+}
+
+func snapshotObject(generation string) string {
+	return "generations/" + generation + "/snapshot"
+}
+
+func walObject(generation string, index int, offset int64) string {
+	return fmt.Sprintf("generations/%s/wal/%08x/%016x", generation, index, offset)
+}
+
+// latestGeneration returns the newest generation stored by client.
+func latestGeneration(ctx context.Context, client ReplicaClient) (string, error) {
+	names, err := client.List(ctx, "generations/")
+	if err != nil {
+		return "", err
+	}
+
+	latest := ""
+	for _, name := range names {
+		parts := strings.Split(name, "/")
+		if len(parts) == 3 && parts[2] == "snapshot" && parts[1] > latest {
+			latest = parts[1]
+		}
+	}
+	if latest == "" {
+		return "", errors.New("no replicated generations found")
+	}
+	return latest, nil
+}
+
+type walSegment struct {
+	name   string
+	index  int
+	offset int64
+}
+
+func walSegments(ctx context.Context, client ReplicaClient, generation string) ([]walSegment, error) {
+	prefix := "generations/" + generation + "/wal/"
+	names, err := client.List(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	var segments []walSegment
+	for _, name := range names {
+		var seg walSegment
+		if _, err := fmt.Sscanf(strings.TrimPrefix(name, prefix), "%08x/%016x", &seg.index, &seg.offset); err != nil {
+			continue
+		}
+		seg.name = name
+		segments = append(segments, seg)
+	}
+	sort.Slice(segments, func(i, j int) bool {
+		if segments[i].index != segments[j].index {
+			return segments[i].index < segments[j].index
+		}
+		return segments[i].offset < segments[j].offset
+	})
+	return segments, nil
+}
+
+// Restore rebuilds a database at dstPath from the newest generation stored by client.
+// The database is assembled in a temporary file next to dstPath and renamed into place once complete.
+func Restore(ctx context.Context, client ReplicaClient, dstPath string) error {
+	generation, err := latestGeneration(ctx, client)
+	if err != nil {
+		return err
+	}
+	_, err = restoreGeneration(ctx, client, generation, dstPath)
+	return err
+}
+
+func restoreGeneration(ctx context.Context, client ReplicaClient, generation, dstPath string) (*walPosition, error) {
+	f, err := os.CreateTemp(filepath.Dir(dstPath), ".kvite-restore-")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+	}()
+
+	if err := copyObject(ctx, client, snapshotObject(generation), f); err != nil {
+		return nil, err
+	}
+
+	segments, err := walSegments(ctx, client, generation)
+	if err != nil {
+		return nil, err
+	}
+	pos, err := applySegments(f, segments, nil, func(seg walSegment) ([]byte, error) {
+		var buf bytes.Buffer
+		err := copyObject(ctx, client, seg.name, &buf)
+		return buf.Bytes(), err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := f.Sync(); err != nil {
+		return nil, err
+	}
+	if err := f.Close(); err != nil {
+		return nil, err
+	}
+	return pos, os.Rename(f.Name(), dstPath)
+}
+
+// walPosition tracks how far a sequence of segments has been applied.
+type walPosition struct {
+	index  int
+	offset int64
+	reader *walReader
+}
+
+// applySegments applies segments to a database file, continuing from pos if it is not nil.
+// Segment contents are obtained from fetch. It returns the position after the last applied segment.
+func applySegments(f *os.File, segments []walSegment, pos *walPosition, fetch func(walSegment) ([]byte, error)) (*walPosition, error) {
+	if pos == nil {
+		pos = &walPosition{index: -1}
+	}
+
+	for _, seg := range segments {
+		if seg.index < pos.index || seg.index == pos.index && seg.offset < pos.offset {
+			continue
+		}
+		if seg.index != pos.index {
+			pos = &walPosition{index: seg.index}
+		}
+		if seg.offset != pos.offset {
+			return pos, fmt.Errorf("missing WAL data before %s", seg.name)
+		}
+
+		data, err := fetch(seg)
+		if err != nil {
+			return pos, err
+		}
+		size := int64(len(data))
+
+		if seg.offset == 0 {
+			hdr, err := parseWALHeader(data)
+			if err != nil {
+				return pos, fmt.Errorf("%s: %v", seg.name, err)
+			}
+			pos.reader = newWALReader(hdr)
+			data = data[walHeaderSize:]
+		}
+
+		n, err := applyWAL(f, pos.reader, data)
+		if err != nil {
+			return pos, err
+		}
+		if n != len(data) {
+			return pos, fmt.Errorf("%s: segment is corrupt", seg.name)
+		}
+		pos.offset += size
+	}
+	return pos, nil
+}
+
+func copyObject(ctx context.Context, client ReplicaClient, name string, w io.Writer) error {
+	rc, err := client.Get(ctx, name)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, rc)
+	if cerr := rc.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
@@ -0,0 +1,144 @@
+package kvite
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+func (s *KViteTestSuite) putN(db *DB, bucket string, from, to int) {
+	err := db.Transaction(func(tx *Tx) error {
+		b, _ := tx.CreateBucket(bucket)
+		for i := from; i < to; i++ {
+			if err := b.Put(fmt.Sprintf("key-%05d", i), []byte(fmt.Sprintf("value-%d", i))); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	s.Require().NoError(err)
+}
+
+func (s *KViteTestSuite) countKeys(db *DB, bucket string) int {
+	n := 0
+	err := db.Transaction(func(tx *Tx) error {
+		b, _ := tx.CreateBucketIfNotExists(bucket)
+		return b.ForEach(func(k string, v []byte) error {
+			n++
+			return nil
+		})
+	})
+	s.Require().NoError(err)
+	return n
+}
+
+func (s *KViteTestSuite) testReplication(client ReplicaClient) {
+	ctx := context.Background()
+
+	db := s.openDB("primary.db", WithWAL())
+	defer func() { _ = db.Close() }()
+
+	r, err := NewReplicator(db, client)
+	s.Require().NoError(err)
+	defer func() { _ = r.Close() }()
+
+	s.putN(db, "test", 0, 100)
+	s.Require().NoError(r.Sync(ctx))
+	generation := r.Generation()
+	s.NotEmpty(generation)
+
+	s.putN(db, "test", 100, 200)
+	s.Require().NoError(r.Sync(ctx))
+
+	// Force a checkpoint so the WAL restarts at a new index
+	r.CheckpointFrames = 1
+	s.Require().NoError(r.Sync(ctx))
+	r.CheckpointFrames = DefaultCheckpointFrames
+
+	s.putN(db, "test", 200, 300)
+	s.Require().NoError(r.Sync(ctx))
+	s.Equal(generation, r.Generation())
+
+	names, err := client.List(ctx, "generations/"+generation+"/wal/00000001/")
+	s.NoError(err)
+	s.NotEmpty(names)
+
+	restored := filepath.Join(s.TempDir, "restored.db")
+	s.Require().NoError(Restore(ctx, client, restored))
+
+	replica := s.openDB("restored.db")
+	defer func() { _ = replica.Close() }()
+	s.Equal(300, s.countKeys(replica, "test"))
+}
+
+func (s *KViteTestSuite) TestReplicationFile() {
+	client, err := NewReplicaClient(filepath.Join(s.TempDir, "replica"))
+	s.Require().NoError(err)
+	s.testReplication(client)
+}
+
+func (s *KViteTestSuite) TestReplicationS3() {
+	var (
+		mu      sync.Mutex
+		objects = make(map[string][]byte)
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=key/") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case r.Method == "PUT":
+			body, _ := io.ReadAll(r.Body)
+			objects[r.URL.Path] = body
+		case r.URL.Query().Get("list-type") == "2":
+			prefix := "/bucket/" + r.URL.Query().Get("prefix")
+			var keys []string
+			for name := range objects {
+				if strings.HasPrefix(name, prefix) {
+					keys = append(keys, strings.TrimPrefix(name, "/bucket/"))
+				}
+			}
+			sort.Strings(keys)
+			fmt.Fprint(w, "<ListBucketResult>")
+			for _, key := range keys {
+				fmt.Fprintf(w, "<Contents><Key>%s</Key></Contents>", key)
+			}
+			fmt.Fprint(w, "<IsTruncated>false</IsTruncated></ListBucketResult>")
+		default:
+			body, ok := objects[r.URL.Path]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			_, _ = w.Write(body)
+		}
+	}))
+	defer server.Close()
+
+	client := &S3ReplicaClient{
+		Endpoint:        server.URL,
+		Bucket:          "bucket",
+		Prefix:          "replicas/primary",
+		AccessKeyID:     "key",
+		SecretAccessKey: "secret",
+	}
+	s.testReplication(client)
+
+	_, err := client.Get(context.Background(), "missing")
+	s.Equal(ErrObjectNotFound, err)
+}
+
+func (s *KViteTestSuite) TestNewReplicatorRequiresWAL() {
+	_, err := NewReplicator(s.DB, &FileReplicaClient{Dir: s.TempDir})
+	s.Equal(ErrNotWAL, err)
+}
@@ -0,0 +1,69 @@
+package kvite
+
+import (
+	"errors"
+	"math/rand"
+	"sync/atomic"
+	"time"
+)
+
+// RetryPolicy controls how DB.Transaction and DB.Batch retry transactions that fail with a transient error.
+type RetryPolicy struct {
+	// MaxAttempts is the number of times a transaction is tried before its error is returned.
+	MaxAttempts int
+	// BaseDelay is the wait before the first retry. It doubles after each further attempt, up to MaxDelay if
+	// MaxDelay is set.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// Jitter is the fraction of each delay, between 0 and 1, that is randomized so that competing writers do not
+	// retry in lockstep.
+	Jitter float64
+	// Retryable reports whether a transaction that failed with err should be tried again. If it is nil,
+	// transactions are retried when the database is busy or locked.
+	Retryable func(err error) bool
+}
+
+// WithRetryPolicy makes DB.Transaction and DB.Batch retry failed transactions according to p. A retried function
+// is called again from the start, so it must not have side effects outside the transaction. Each retry is counted
+// in TxMetrics.Retries.
+func WithRetryPolicy(p RetryPolicy) Option {
+	return func(db *DB) error {
+		if p.MaxAttempts < 1 || p.BaseDelay < 0 || p.MaxDelay < 0 || p.Jitter < 0 || p.Jitter > 1 {
+			return errors.New("invalid retry policy")
+		}
+		if p.Retryable == nil {
+			p.Retryable = isBusy
+		}
+		db.retry = &p
+		return nil
+	}
+}
+
+// withRetry calls fn until it succeeds, fails with an error the retry policy does not retry, or runs out of
+// attempts.
+func (db *DB) withRetry(fn func() error) error {
+	p := db.retry
+	if p == nil {
+		return fn()
+	}
+
+	delay := p.BaseDelay
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= p.MaxAttempts || !p.Retryable(err) {
+			return err
+		}
+		atomic.AddInt64(&db.metrics.retries, 1)
+		time.Sleep(p.backoff(delay))
+
+		delay *= 2
+		if p.MaxDelay > 0 && delay > p.MaxDelay {
+			delay = p.MaxDelay
+		}
+	}
+}
+
+// backoff applies jitter to delay.
+func (p *RetryPolicy) backoff(delay time.Duration) time.Duration {
+	return delay - time.Duration(p.Jitter*rand.Float64()*float64(delay))
+}
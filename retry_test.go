@@ -0,0 +1,56 @@
+package kvite
+
+import (
+	"errors"
+	"time"
+)
+
+func (s *KViteTestSuite) TestRetryPolicy() {
+	errTransient := errors.New("transient")
+	db := s.openDB("retry.db", WithRetryPolicy(RetryPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   time.Millisecond,
+		Jitter:      0.5,
+		Retryable:   func(err error) bool { return err == errTransient },
+	}))
+	defer func() { _ = db.Close() }()
+
+	attempts := 0
+	s.NoError(db.Transaction(func(tx *Tx) error {
+		attempts++
+		b, _ := tx.CreateBucketIfNotExists("retry")
+		if err := b.Put("k", []byte{byte(attempts)}); err != nil {
+			return err
+		}
+		if attempts < 3 {
+			return errTransient
+		}
+		return nil
+	}))
+	s.Equal(3, attempts)
+	s.Equal(int64(2), db.TxMetrics().Retries)
+
+	s.NoError(db.Transaction(func(tx *Tx) error {
+		b, _ := tx.CreateBucketIfNotExists("retry")
+		value, err := b.Get("k")
+		s.Equal([]byte{3}, value)
+		return err
+	}))
+
+	attempts = 0
+	s.Equal(errTransient, db.Transaction(func(*Tx) error {
+		attempts++
+		return errTransient
+	}))
+	s.Equal(3, attempts)
+
+	attempts = 0
+	s.Error(db.Transaction(func(*Tx) error {
+		attempts++
+		return errors.New("permanent")
+	}))
+	s.Equal(1, attempts)
+
+	_, err := Open(":memory:", "", WithRetryPolicy(RetryPolicy{}))
+	s.Error(err)
+}
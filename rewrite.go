@@ -0,0 +1,116 @@
+package kvite
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// rewriteBatchSize is the number of keys Rewrite reads and writes per transaction.
+const rewriteBatchSize = 500
+
+// Rewrite passes every key of the buckets the handle may write to through fn and writes the value fn returns with
+// Put, so that the value is encoded with the bucket's current BucketConfig. It is meant for migrating data after
+// changing a bucket's codec or compression, or rotating a cipher: set the new configuration first, then have fn
+// turn the bytes stored by the old configuration into the plain value. fn receives values as they are stored,
+// not decoded. If fn returns a nil value, the key is left as it is, so that keys that were already rewritten can
+// be skipped; if it returns an error, Rewrite stops and returns it.
+// Keys are rewritten in batches, each in a transaction of its own, so Rewrite does not hold the write lock for
+// long, but a failure leaves the keys of earlier batches rewritten. fn may be called again for a key if its batch
+// is retried. Rewritten keys keep their expiry time and metadata, get a new version, and are recorded in the
+// change feed. Rewrite returns the number of keys written.
+func (db *DB) Rewrite(fn func(bucket, key string, v []byte) ([]byte, error)) (int64, error) {
+	if err := db.life.check(); err != nil {
+		return 0, err
+	}
+	names, err := db.buckets(db.db)
+	if err != nil {
+		return 0, err
+	}
+
+	value, from := "t.value", fmt.Sprintf("'%s' t", db.table)
+	if db.dedup {
+		value = "coalesce(v.value, t.value)"
+		from += fmt.Sprintf(" LEFT JOIN '%s' v ON v.hash = t.value_ref", db.valuesTable())
+	}
+	query := fmt.Sprintf("SELECT t.key, %s, t.checksum, t.expires, t.content_type FROM %s WHERE t.bucket = ?1 AND (?2 IS NULL OR t.key > ?2) ORDER BY t.key LIMIT %d", value, from, rewriteBatchSize)
+	restore := fmt.Sprintf("UPDATE '%s' SET expires = ? WHERE key = ? AND bucket = ?", db.table)
+
+	var total int64
+	for _, name := range names {
+		if db.bucketAccess(name) != BucketReadWrite {
+			continue
+		}
+		var after interface{}
+		for {
+			var (
+				n, read int
+				last    string
+			)
+			err := db.Transaction(func(tx *Tx) error {
+				n, read = 0, 0
+				if err := tx.lockForWrite(); err != nil {
+					return err
+				}
+				rows, err := tx.query(query, name, after)
+				if err != nil {
+					return err
+				}
+				type row struct {
+					key         string
+					value       []byte
+					expires     sql.NullInt64
+					contentType sql.NullString
+				}
+				var batch []row
+				b := tx.newBucket(name)
+				for rows.Next() {
+					var (
+						r   row
+						sum sql.NullInt64
+					)
+					if err := rows.Scan(&r.key, &r.value, &sum, &r.expires, &r.contentType); err != nil {
+						_ = rows.Close()
+						return err
+					}
+					if err := b.verify(r.key, r.value, sum); err != nil {
+						_ = rows.Close()
+						return err
+					}
+					batch = append(batch, r)
+				}
+				if err := rows.Close(); err != nil {
+					return err
+				}
+
+				read = len(batch)
+				for _, r := range batch {
+					last = r.key
+					value, err := fn(name, r.key, r.value)
+					if err != nil {
+						return err
+					}
+					if value == nil {
+						continue
+					}
+					if err := b.PutWithMeta(r.key, value, ValueMeta{ContentType: r.contentType.String}); err != nil {
+						return err
+					}
+					if _, err := tx.exec(restore, r.expires, r.key, name); err != nil {
+						return err
+					}
+					n++
+				}
+				return nil
+			})
+			if err != nil {
+				return total, err
+			}
+			total += int64(n)
+			if read < rewriteBatchSize {
+				break
+			}
+			after = last
+		}
+	}
+	return total, nil
+}
@@ -0,0 +1,53 @@
+package kvite
+
+import (
+	"fmt"
+)
+
+func (s *KViteTestSuite) TestRewrite() {
+	db := s.openDB("rewrite.db", WithCodec("reverse", reverseCodec{}))
+	defer func() { _ = db.Close() }()
+
+	s.NoError(db.Transaction(func(tx *Tx) error {
+		b, _ := tx.CreateBucket("docs")
+		for i := 0; i < rewriteBatchSize+10; i++ {
+			s.NoError(b.Put(fmt.Sprintf("key%04d", i), []byte(fmt.Sprintf("value%d", i))))
+		}
+		b, _ = tx.CreateBucket("skip")
+		return b.Put("a", []byte("1"))
+	}))
+	_, err := db.db.Exec("UPDATE 'testing' SET expires = 1e18 WHERE key = 'key0001'")
+	s.Require().NoError(err)
+
+	// Values stored before the codec was configured are rewritten with it
+	s.NoError(db.Transaction(func(tx *Tx) error {
+		return tx.SetBucketConfig("docs", BucketConfig{Codec: "reverse"})
+	}))
+	n, err := db.Rewrite(func(bucket, key string, v []byte) ([]byte, error) {
+		if bucket == "skip" {
+			return nil, nil
+		}
+		return v, nil
+	})
+	s.NoError(err)
+	s.Equal(int64(rewriteBatchSize+10), n)
+
+	s.Equal([]byte("5eulav"), s.storedValue(db, "docs", "key0005"))
+	s.Equal([]byte("1"), s.storedValue(db, "skip", "a"))
+	var expires int64
+	s.NoError(db.db.QueryRow("SELECT expires FROM 'testing' WHERE key = 'key0001'").Scan(&expires))
+	s.Equal(int64(1e18), expires)
+	s.NoError(db.Transaction(func(tx *Tx) error {
+		b, _ := tx.Bucket("docs")
+		value, err := b.Get(fmt.Sprintf("key%04d", rewriteBatchSize+5))
+		s.NoError(err)
+		s.Equal([]byte(fmt.Sprintf("value%d", rewriteBatchSize+5)), value)
+		return nil
+	}))
+
+	// Errors from fn stop the rewrite
+	_, err = db.Rewrite(func(bucket, key string, v []byte) ([]byte, error) {
+		return nil, fmt.Errorf("stop")
+	})
+	s.EqualError(err, "stop")
+}
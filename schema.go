@@ -0,0 +1,330 @@
+package kvite
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// schemaVersion is the version of the table layout created by this package.
+// It is recorded in the meta table so that later releases can tell which upgrades an existing database needs.
+const schemaVersion = 8
+
+type column struct {
+	name string
+	// definition is used when the table is created.
+	definition string
+	// upgrade is used with ALTER TABLE when an existing table lacks the column.
+	// Columns without an upgrade definition are required and cannot be added to a legacy table.
+	upgrade string
+}
+
+// tableColumns are the columns of the kvite table, in creation order.
+var tableColumns = []column{
+	{name: "key", definition: "text not null"},
+	{name: "bucket", definition: "text not null"},
+	{name: "value", definition: "blob not null"},
+	{name: "checksum", definition: "integer", upgrade: "integer"},
+	{name: "hlc", definition: "integer", upgrade: "integer"},
+	{name: "origin", definition: "text", upgrade: "text"},
+	{name: "value_ref", definition: "blob", upgrade: "blob"},
+	{name: "version", definition: "integer not null default 0", upgrade: "integer not null default 0"},
+	{name: "expires", definition: "integer", upgrade: "integer"},
+	{name: "content_type", definition: "text", upgrade: "text"},
+}
+
+func (db *DB) metaTable() string {
+	return db.table + "_kvite_meta"
+}
+
+// initSchema creates the kvite tables if needed and upgrades tables left behind by older releases.
+// Before an existing table is modified it is copied to a backup table in the same database.
+func (db *DB) initSchema(tx *sql.Tx) error {
+	query := fmt.Sprintf("create TABLE IF NOT EXISTS '%s' (name text not null primary key, value text not null)", db.metaTable())
+	if _, err := tx.Exec(query); err != nil {
+		return err
+	}
+
+	version, err := storedSchemaVersion(tx, db.metaTable())
+	if err != nil {
+		return err
+	}
+	if version > schemaVersion {
+		return fmt.Errorf("table %s has schema version %d, which is newer than the supported version %d", db.table, version, schemaVersion)
+	}
+
+	existing, err := tableColumnNames(tx, db.table)
+	if err != nil {
+		return err
+	}
+
+	stored, err := db.storedIndexLayout(tx)
+	if err != nil {
+		return err
+	}
+	if db.indexLayout == 0 {
+		db.indexLayout = stored
+	}
+
+	if len(existing) == 0 {
+		if err := db.createTable(tx); err != nil {
+			return err
+		}
+	} else if err := db.upgradeTable(tx, existing); err != nil {
+		return err
+	} else if outdated, err := db.coveringIndexOutdated(tx); err != nil {
+		return err
+	} else if db.indexLayout != stored || outdated {
+		if err := db.rebuildIndexes(tx); err != nil {
+			return err
+		}
+	}
+
+	if err := setMeta(tx, db.metaTable(), "index_layout", strconv.Itoa(int(db.indexLayout))); err != nil {
+		return err
+	}
+
+	if err := db.createBucketsTable(tx); err != nil {
+		return err
+	}
+
+	if err := db.createExpiresIndex(tx); err != nil {
+		return err
+	}
+
+	if err := db.createHistoryTable(tx); err != nil {
+		return err
+	}
+
+	if db.dedup {
+		if err := db.createValuesTable(tx); err != nil {
+			return err
+		}
+	}
+
+	if db.changeFeed {
+		if err := db.createChangesTable(tx); err != nil {
+			return err
+		}
+	}
+
+	if db.spatial {
+		if err := db.createSpatialTables(tx); err != nil {
+			return err
+		}
+	}
+
+	if err := db.initNodeID(tx); err != nil {
+		return err
+	}
+
+	query = fmt.Sprintf("INSERT OR REPLACE INTO '%s' (name, value) VALUES ('schema_version', ?)", db.metaTable())
+	_, err = tx.Exec(query, strconv.Itoa(schemaVersion))
+	return err
+}
+
+// initNodeID loads the identifier of this database, generating one the first time the database is opened.
+func (db *DB) initNodeID(tx *sql.Tx) error {
+	id, err := getMeta(tx, db.metaTable(), "node_id")
+	if err != nil || id != "" {
+		db.nodeID = id
+		return err
+	}
+
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+	db.nodeID = hex.EncodeToString(b)
+	return setMeta(tx, db.metaTable(), "node_id", db.nodeID)
+}
+
+// loadNodeID reads the identifier of a database whose schema is not initialized by Open. Databases without a meta
+// table have no identifier.
+func (db *DB) loadNodeID() error {
+	if exists, err := tableExists(db.db, db.metaTable()); err != nil || !exists {
+		return err
+	}
+	id, err := getMeta(db.db, db.metaTable(), "node_id")
+	db.nodeID = id
+	return err
+}
+
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+type rowQuerier interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// getMeta reads a value from the meta table. Missing names read as an empty string.
+func getMeta(q rowQuerier, table, name string) (string, error) {
+	var value string
+	err := q.QueryRow(fmt.Sprintf("SELECT value FROM '%s' WHERE name = ?", table), name).Scan(&value)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return value, err
+}
+
+func setMeta(e execer, table, name, value string) error {
+	_, err := e.Exec(fmt.Sprintf("INSERT OR REPLACE INTO '%s' (name, value) VALUES (?, ?)", table), name, value)
+	return err
+}
+
+// addMissingColumns adds any of cols that an existing table lacks.
+func addMissingColumns(tx *sql.Tx, table string, cols []column) error {
+	existing, err := tableColumnNames(tx, table)
+	if err != nil {
+		return err
+	}
+	for _, c := range cols {
+		if existing[c.name] {
+			continue
+		}
+		if _, err := tx.Exec(fmt.Sprintf("ALTER TABLE '%s' ADD COLUMN %s %s", table, c.name, c.upgrade)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (db *DB) createTable(tx *sql.Tx) error {
+	defs := ""
+	for i, c := range tableColumns {
+		if i > 0 {
+			defs += ", "
+		}
+		defs += c.name + " " + c.definition
+	}
+
+	if db.withoutRowID {
+		query := fmt.Sprintf("create TABLE IF NOT EXISTS '%s' (%s, PRIMARY KEY (bucket, key)) WITHOUT ROWID", db.table, defs)
+		_, err := tx.Exec(query)
+		return err
+	}
+
+	query := fmt.Sprintf("create TABLE IF NOT EXISTS '%s' (%s)", db.table, defs)
+	if _, err := tx.Exec(query); err != nil {
+		return err
+	}
+	return db.createIndexes(tx)
+}
+
+// upgradeTable brings a table created by an older release up to date.
+// Missing optional columns are added, duplicate keys that would prevent the unique index
+// from being built are collapsed to the most recently written row, and missing indexes are created.
+func (db *DB) upgradeTable(tx *sql.Tx, existing map[string]bool) error {
+	var missing []column
+	for _, c := range tableColumns {
+		if existing[c.name] {
+			continue
+		}
+		if c.upgrade == "" {
+			return fmt.Errorf("table %s is missing required column %s and cannot be upgraded", db.table, c.name)
+		}
+		missing = append(missing, c)
+	}
+
+	// A WITHOUT ROWID table is clustered on its (bucket, key) primary key and needs no separate index.
+	clustered, err := isWithoutRowID(tx, db.table)
+	if err != nil {
+		return err
+	}
+	hasIndex := clustered
+	if !clustered {
+		if hasIndex, err = indexExists(tx, db.table+"_kvite_key_index"); err != nil {
+			return err
+		}
+	}
+
+	if len(missing) == 0 && hasIndex {
+		return nil
+	}
+
+	if err := db.backupTable(tx); err != nil {
+		return err
+	}
+
+	for _, c := range missing {
+		query := fmt.Sprintf("ALTER TABLE '%s' ADD COLUMN %s %s", db.table, c.name, c.upgrade)
+		if _, err := tx.Exec(query); err != nil {
+			return err
+		}
+	}
+
+	if !hasIndex {
+		query := fmt.Sprintf("DELETE FROM '%s' WHERE rowid NOT IN (SELECT max(rowid) FROM '%s' GROUP BY key, bucket)", db.table, db.table)
+		if _, err := tx.Exec(query); err != nil {
+			return err
+		}
+	}
+
+	if clustered {
+		return nil
+	}
+	return db.createIndexes(tx)
+}
+
+// backupTable copies the kvite table to a timestamped table in the same database.
+func (db *DB) backupTable(tx *sql.Tx) error {
+	name := fmt.Sprintf("%s_kvite_backup_%d", db.table, time.Now().UnixNano())
+	query := fmt.Sprintf("CREATE TABLE '%s' AS SELECT * FROM '%s'", name, db.table)
+	_, err := tx.Exec(query)
+	return err
+}
+
+func storedSchemaVersion(tx *sql.Tx, metaTable string) (int, error) {
+	var value string
+	query := fmt.Sprintf("SELECT value FROM '%s' WHERE name = 'schema_version'", metaTable)
+	if err := tx.QueryRow(query).Scan(&value); err != nil {
+		if err == sql.ErrNoRows {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return strconv.Atoi(value)
+}
+
+// tableColumnNames returns the set of columns of a table. The set is empty if the table does not exist.
+func tableColumnNames(tx *sql.Tx, table string) (map[string]bool, error) {
+	rows, err := tx.Query(fmt.Sprintf("PRAGMA table_info('%s')", table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	names := make(map[string]bool)
+	for rows.Next() {
+		var (
+			cid, notNull, pk int
+			name, typ        string
+			dflt             sql.NullString
+		)
+		if err := rows.Scan(&cid, &name, &typ, &notNull, &dflt, &pk); err != nil {
+			return nil, err
+		}
+		names[name] = true
+	}
+	return names, rows.Err()
+}
+
+// isWithoutRowID reports whether a table was created WITHOUT ROWID.
+func isWithoutRowID(q rowQuerier, table string) (bool, error) {
+	var wr int
+	err := q.QueryRow("SELECT wr FROM pragma_table_list WHERE schema = 'main' AND name = ?", table).Scan(&wr)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return wr == 1, err
+}
+
+func indexExists(tx *sql.Tx, name string) (bool, error) {
+	var n int
+	err := tx.QueryRow("SELECT count(*) FROM sqlite_master WHERE type = 'index' AND name = ?", name).Scan(&n)
+	return n > 0, err
+}
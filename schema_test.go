@@ -0,0 +1,100 @@
+package kvite
+
+import (
+	"database/sql"
+	"path/filepath"
+)
+
+func (s *KViteTestSuite) TestOpenUpgradesLegacyTable() {
+	filename := filepath.Join(s.TempDir, "legacy.db")
+
+	// A table written by a release that did not build the unique index
+	raw, err := sql.Open("sqlite3", filename)
+	s.Require().NoError(err)
+	_, err = raw.Exec("create TABLE 'legacy' (key text not null, bucket text not null, value blob not null)")
+	s.Require().NoError(err)
+	_, err = raw.Exec("INSERT INTO 'legacy' (key, bucket, value) VALUES ('foo', 'test', 'old'), ('foo', 'test', 'new')")
+	s.Require().NoError(err)
+	s.Require().NoError(raw.Close())
+
+	db, err := Open(filename, "legacy")
+	s.Require().NoError(err)
+	defer func() { _ = db.Close() }()
+
+	// Buckets that already have keys are registered by the upgrade
+	var registered int
+	s.NoError(db.db.QueryRow("SELECT count(*) FROM 'legacy_kvite_buckets' WHERE name = 'test'").Scan(&registered))
+	s.Equal(1, registered)
+
+	tx, _ := db.Begin()
+	b, _ := tx.Bucket("test")
+	value, err := b.Get("foo")
+	s.NoError(err)
+	s.Equal([]byte("new"), value)
+	s.NoError(b.Put("foo", []byte("bar")))
+	s.NoError(tx.Commit())
+
+	var backups int
+	err = db.db.QueryRow("SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name LIKE 'legacy_kvite_backup_%'").Scan(&backups)
+	s.NoError(err)
+	s.Equal(1, backups)
+
+	// Opening again does not need another upgrade
+	db2, err := Open(filename, "legacy")
+	s.Require().NoError(err)
+	s.NoError(db2.Close())
+	err = db.db.QueryRow("SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name LIKE 'legacy_kvite_backup_%'").Scan(&backups)
+	s.NoError(err)
+	s.Equal(1, backups)
+}
+
+func (s *KViteTestSuite) TestOpenNewerSchema() {
+	_, err := s.DB.db.Exec("UPDATE 'testing_kvite_meta' SET value = '1000' WHERE name = 'schema_version'")
+	s.Require().NoError(err)
+
+	_, err = Open(filepath.Join(s.TempDir, "kvite.db"), "testing")
+	s.Error(err)
+}
+
+func (s *KViteTestSuite) TestSkipSchema() {
+	db := s.openDB("skip.db", SkipSchema())
+	err := db.Transaction(func(tx *Tx) error {
+		b, _ := tx.CreateBucket("test")
+		return b.Put("foo", []byte("bar"))
+	})
+	s.Error(err, "tables are not created")
+	s.NoError(db.Close())
+
+	db = s.openDB("skip.db")
+	id := db.NodeID()
+	s.NoError(db.Close())
+
+	db = s.openDB("skip.db", SkipSchema())
+	defer func() { _ = db.Close() }()
+	s.Equal(id, db.NodeID())
+	s.NoError(db.Transaction(func(tx *Tx) error {
+		b, _ := tx.CreateBucket("test")
+		return b.Put("foo", []byte("bar"))
+	}))
+}
+
+func (s *KViteTestSuite) TestWithoutRowID() {
+	db := s.openDB("clustered.db", WithoutRowID())
+	s.putN(db, "test", 0, 100)
+	s.putN(db, "test", 0, 100)
+	s.Equal(100, s.countKeys(db, "test"))
+	s.NoError(db.Close())
+
+	// Reopening without the option keeps the layout and needs no upgrade
+	db = s.openDB("clustered.db")
+	var indexes, tables int
+	s.NoError(db.db.QueryRow("SELECT count(*) FROM sqlite_master WHERE type = 'index' AND tbl_name = 'testing'").Scan(&indexes))
+	s.NoError(db.db.QueryRow("SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name LIKE 'testing_kvite_backup_%'").Scan(&tables))
+	s.Equal(0, indexes, "the primary key is the table itself")
+	s.Equal(0, tables)
+	s.NoError(db.Close())
+
+	result, err := Recover(filepath.Join(s.TempDir, "clustered.db"), filepath.Join(s.TempDir, "recovered.db"))
+	s.Require().NoError(err)
+	s.Equal(100, result.Recovered)
+}
@@ -0,0 +1,225 @@
+package kvite
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// sessionBucket holds one record per live session.
+	sessionBucket = "_kvite_sessions"
+	// sessionKeysBucket holds one empty entry per key attached to a session, keyed by sessionKey.
+	sessionKeysBucket = "_kvite_session_keys"
+)
+
+// sessionRecord is the stored form of a session.
+type sessionRecord struct {
+	Expires int64 `json:"expires"`
+}
+
+// Session tracks the liveness of a client of the database. Keys attached to a session are deleted when the
+// session is revoked or expires without being kept alive. Expired sessions are removed by ExpireSessions.
+type Session struct {
+	db  *DB
+	id  string
+	ttl time.Duration
+}
+
+// CreateSession starts a session that expires after ttl unless it is kept alive.
+func (db *DB) CreateSession(ttl time.Duration) (*Session, error) {
+	id, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+	s := &Session{db: db, id: id, ttl: ttl}
+	err = db.Transaction(func(tx *Tx) error {
+		b := tx.newBucket(sessionBucket)
+		return putSession(b, id, ttl)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// ID returns the identifier of the session.
+func (s *Session) ID() string {
+	return s.id
+}
+
+// KeepAlive extends the session to its TTL from now. It returns ErrSessionExpired if the session has expired or
+// been revoked; its keys are then deleted.
+func (s *Session) KeepAlive() error {
+	err := s.db.Transaction(func(tx *Tx) error {
+		if err := tx.lockForWrite(); err != nil {
+			return err
+		}
+		if err := s.check(tx); err != nil {
+			return err
+		}
+		b := tx.newBucket(sessionBucket)
+		return putSession(b, s.id, s.ttl)
+	})
+	if err == ErrSessionExpired {
+		if rerr := s.Revoke(); rerr != nil {
+			return rerr
+		}
+	}
+	return err
+}
+
+// Attach ties a key to the session within tx, so that the key is deleted when the session ends. The key itself is
+// not read or written. Attach returns ErrSessionExpired if the session is no longer live.
+func (s *Session) Attach(tx *Tx, bucket, key string) error {
+	if err := s.check(tx); err != nil {
+		return err
+	}
+	b := tx.newBucket(sessionKeysBucket)
+	return b.Put(sessionKey(s.id, bucket, key), nil)
+}
+
+// Put writes a key and attaches it to the session in one transaction.
+func (s *Session) Put(bucket, key string, value []byte) error {
+	return s.db.Transaction(func(tx *Tx) error {
+		if err := tx.lockForWrite(); err != nil {
+			return err
+		}
+		if err := s.Attach(tx, bucket, key); err != nil {
+			return err
+		}
+		b := tx.newBucket(bucket)
+		return b.Put(key, value)
+	})
+}
+
+// Revoke ends the session immediately and deletes its keys. Revoking an ended session does nothing.
+func (s *Session) Revoke() error {
+	return s.db.Transaction(func(tx *Tx) error {
+		return tx.endSession(s.id)
+	})
+}
+
+// check returns ErrSessionExpired unless the session exists and has not expired.
+func (s *Session) check(tx *Tx) error {
+	b := tx.newBucket(sessionBucket)
+	value, err := b.Get(s.id)
+	if err != nil {
+		return err
+	}
+	if value == nil {
+		return ErrSessionExpired
+	}
+	var rec sessionRecord
+	if err := json.Unmarshal(value, &rec); err != nil {
+		return err
+	}
+	if time.Now().UnixNano() >= rec.Expires {
+		return ErrSessionExpired
+	}
+	return nil
+}
+
+// ExpireSessions ends every session that has expired, deleting the keys attached to it, and returns the number of
+// sessions ended. Applications using sessions should call it periodically.
+func (db *DB) ExpireSessions() (int, error) {
+	n := 0
+	err := db.Transaction(func(tx *Tx) error {
+		if err := tx.lockForWrite(); err != nil {
+			return err
+		}
+
+		var expired []string
+		b := tx.newBucket(sessionBucket)
+		now := time.Now().UnixNano()
+		err := b.ForEach(func(id string, value []byte) error {
+			var rec sessionRecord
+			if err := json.Unmarshal(value, &rec); err != nil {
+				return err
+			}
+			if now >= rec.Expires {
+				expired = append(expired, id)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, id := range expired {
+			if err := tx.endSession(id); err != nil {
+				return err
+			}
+		}
+		n = len(expired)
+		return nil
+	})
+	return n, err
+}
+
+// endSession deletes a session, the keys attached to it and its attachment entries.
+func (tx *Tx) endSession(id string) error {
+	prefix := id + "/"
+	end, _ := prefixEnd(prefix)
+	query := fmt.Sprintf("SELECT key FROM '%s' WHERE bucket = ? AND key >= ? AND key < ?", tx.db.table)
+	rows, err := tx.query(query, sessionKeysBucket, prefix, end)
+	if err != nil {
+		return err
+	}
+	var attached []string
+	for rows.Next() {
+		var k string
+		if err := rows.Scan(&k); err != nil {
+			_ = rows.Close()
+			return err
+		}
+		attached = append(attached, k)
+	}
+	if err := rows.Close(); err != nil {
+		return err
+	}
+
+	for _, k := range attached {
+		bucket, key, err := parseSessionKey(k)
+		if err != nil {
+			return err
+		}
+		b := tx.newBucket(bucket)
+		if err := b.Delete(key); err != nil {
+			return err
+		}
+	}
+
+	keys := tx.newBucket(sessionKeysBucket)
+	if _, err := keys.DeletePrefix(prefix); err != nil {
+		return err
+	}
+	sessions := tx.newBucket(sessionBucket)
+	return sessions.Delete(id)
+}
+
+func putSession(b *Bucket, id string, ttl time.Duration) error {
+	value, err := json.Marshal(sessionRecord{Expires: time.Now().Add(ttl).UnixNano()})
+	if err != nil {
+		return err
+	}
+	return b.Put(id, value)
+}
+
+// sessionKey names the attachment of a key to a session. The bucket name is length-prefixed because bucket
+// names and keys may both contain slashes.
+func sessionKey(id, bucket, key string) string {
+	return fmt.Sprintf("%s/%d/%s/%s", id, len(bucket), bucket, key)
+}
+
+func parseSessionKey(k string) (bucket, key string, err error) {
+	parts := strings.SplitN(k, "/", 3)
+	if len(parts) == 3 {
+		if n, perr := strconv.Atoi(parts[1]); perr == nil && n < len(parts[2]) && parts[2][n] == '/' {
+			return parts[2][:n], parts[2][n+1:], nil
+		}
+	}
+	return "", "", fmt.Errorf("malformed session key %q", k)
+}
@@ -0,0 +1,62 @@
+package kvite
+
+import "time"
+
+func (s *KViteTestSuite) TestSession() {
+	session, err := s.DB.CreateSession(time.Minute)
+	s.NoError(err)
+	s.NotEmpty(session.ID())
+
+	s.NoError(session.Put("agents", "a/1", []byte("alive")))
+	s.NoError(s.DB.Transaction(func(tx *Tx) error {
+		b, _ := tx.CreateBucketIfNotExists("agents/status")
+		if err := b.Put("a", []byte("up")); err != nil {
+			return err
+		}
+		return session.Attach(tx, "agents/status", "a")
+	}))
+	s.NoError(session.Put("agents", "b", []byte("other")))
+	s.NoError(session.KeepAlive())
+
+	n, err := s.DB.ExpireSessions()
+	s.NoError(err)
+	s.Equal(0, n)
+	s.testStoredValue("agents", "a/1", []byte("alive"))
+
+	s.NoError(session.Revoke())
+	s.testStoredValue("agents", "a/1", nil)
+	s.testStoredValue("agents", "b", nil)
+	s.testStoredValue("agents/status", "a", nil)
+	s.Equal(ErrSessionExpired, session.KeepAlive())
+	s.Equal(ErrSessionExpired, session.Put("agents", "c", []byte("late")))
+	s.testStoredValue("agents", "c", nil)
+}
+
+func (s *KViteTestSuite) TestExpireSessions() {
+	short, err := s.DB.CreateSession(50 * time.Millisecond)
+	s.NoError(err)
+	long, err := s.DB.CreateSession(time.Minute)
+	s.NoError(err)
+
+	s.NoError(short.Put("agents", "short", []byte("x")))
+	s.NoError(long.Put("agents", "long", []byte("x")))
+	time.Sleep(60 * time.Millisecond)
+
+	n, err := s.DB.ExpireSessions()
+	s.NoError(err)
+	s.Equal(1, n)
+	s.testStoredValue("agents", "short", nil)
+	s.testStoredValue("agents", "long", []byte("x"))
+	s.Equal(ErrSessionExpired, short.KeepAlive())
+	s.NoError(long.Revoke())
+}
+
+func (s *KViteTestSuite) TestSessionKey() {
+	bucket, key, err := parseSessionKey(sessionKey("id", "a/b", "c/d"))
+	s.NoError(err)
+	s.Equal("a/b", bucket)
+	s.Equal("c/d", key)
+
+	_, _, err = parseSessionKey("id/x/y")
+	s.Error(err)
+}
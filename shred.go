@@ -0,0 +1,70 @@
+package kvite
+
+import "fmt"
+
+// WithSecureDelete sets PRAGMA secure_delete on every connection to the database, so that SQLite overwrites
+// deleted content with zeros instead of leaving it in free pages of the file. It makes deletes write more.
+func WithSecureDelete() Option {
+	return func(db *DB) error {
+		db.pragmas = append(db.pragmas, "PRAGMA secure_delete = ON")
+		return nil
+	}
+}
+
+// Shred deletes a key like Delete, and removes the copies of its value kvite keeps: the stored value is
+// overwritten with zeros before it is deleted, the value's earlier versions are deleted, and the values the change
+// feed recorded for the key are cleared. A deduplicated value is only overwritten if no other key refers to it.
+// Once the transaction commits, the database is vacuumed and, in WAL mode, the WAL is checkpointed and truncated,
+// so that the old pages holding the value are gone from the files; if that fails, Commit returns the error even
+// though the transaction was committed. Vacuuming rewrites the whole database and waits for other transactions to
+// finish, so Shred is meant for the occasional secret. It works best WithSecureDelete, and cannot reach copies
+// made outside the database files, such as backups or blocks the filesystem has already reused.
+func (b *Bucket) Shred(key string) error {
+	if b.tx.readOnly {
+		return ErrTxReadOnly
+	}
+	if err := b.checkAccess("shred", true); err != nil {
+		return err
+	}
+	if err := b.tx.db.allowWrite(b.name); err != nil {
+		return err
+	}
+
+	db := b.tx.db
+	queries := []string{fmt.Sprintf("UPDATE '%s' SET value = zeroblob(length(value)) WHERE key = ? AND bucket = ?", db.table)}
+	if db.dedup {
+		queries = append(queries, fmt.Sprintf("UPDATE '%s' SET value = zeroblob(length(value)) WHERE refs = 1 AND hash = (SELECT value_ref FROM '%s' WHERE key = ? AND bucket = ?)", db.valuesTable(), db.table))
+	}
+	for _, query := range queries {
+		if _, err := b.tx.exec(query, key, b.name); err != nil {
+			return err
+		}
+	}
+	if db.changeFeed {
+		query := fmt.Sprintf("UPDATE '%s' SET value = NULL WHERE key = ? AND bucket = ? AND value IS NOT NULL", db.changesTable())
+		if _, err := b.tx.exec(query, key, b.name); err != nil {
+			return err
+		}
+	}
+
+	if err := b.tx.delete(&Change{Bucket: b.name, Key: key, Type: ChangeDelete}); err != nil {
+		return err
+	}
+	if db.history {
+		query := fmt.Sprintf("DELETE FROM '%s' WHERE key = ? AND bucket = ?", db.historyTable())
+		if _, err := b.tx.exec(query, key, b.name); err != nil {
+			return err
+		}
+	}
+	b.tx.shredded = true
+	return nil
+}
+
+// vacuumShredded rebuilds the database after a transaction that shredded keys has committed.
+func (db *DB) vacuumShredded() error {
+	if _, err := db.db.Exec("VACUUM"); err != nil {
+		return err
+	}
+	_, err := db.db.Exec("PRAGMA wal_checkpoint(TRUNCATE)")
+	return err
+}
@@ -0,0 +1,54 @@
+package kvite
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+)
+
+func (s *KViteTestSuite) TestShred() {
+	secret := bytes.Repeat([]byte("TOPSECRET"), 50)
+	db := s.openDB("shred.db", WithSecureDelete(), WithWAL(), WithChangeFeed())
+	defer func() { _ = db.Close() }()
+	s.NoError(db.Transaction(func(tx *Tx) error {
+		s.NoError(tx.SetBucketConfig("test", BucketConfig{Versioning: true}))
+		b, _ := tx.Bucket("test")
+		s.NoError(b.Put("token", secret[:100]))
+		s.NoError(b.Put("token", secret))
+		return b.Put("other", []byte("kept"))
+	}))
+
+	s.NoError(db.Transaction(func(tx *Tx) error {
+		b, _ := tx.Bucket("test")
+		return b.Shred("token")
+	}))
+
+	s.NoError(db.Transaction(func(tx *Tx) error {
+		b, _ := tx.Bucket("test")
+		value, err := b.Get("token")
+		s.NoError(err)
+		s.Nil(value)
+		value, err = b.GetAt("token", 1)
+		s.NoError(err)
+		s.Nil(value)
+		value, err = b.Get("other")
+		s.NoError(err)
+		s.Equal([]byte("kept"), value)
+		return nil
+	}))
+	changes, err := db.Changes(0)
+	s.NoError(err)
+	s.Len(changes, 4)
+	for _, c := range changes {
+		s.NotContains(string(c.Value), "TOPSECRET")
+	}
+
+	for _, name := range []string{"shred.db", "shred.db-wal"} {
+		data, err := os.ReadFile(filepath.Join(s.TempDir, name))
+		if os.IsNotExist(err) {
+			continue
+		}
+		s.NoError(err)
+		s.False(bytes.Contains(data, []byte("TOPSECRET")), name)
+	}
+}
@@ -0,0 +1,60 @@
+package kvite
+
+// Sizes returns the length in bytes of every value in the bucket, keyed by key. Lengths are computed by SQLite,
+// so the values themselves are not read into memory.
+func (b *Bucket) Sizes() (map[string]int64, error) {
+	if err := b.checkAccess("sizes", false); err != nil {
+		return nil, err
+	}
+	rows, err := b.tx.query(b.tx.db.sizesQuery, b.name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sizes := make(map[string]int64)
+	for rows.Next() {
+		var (
+			key  string
+			size int64
+		)
+		if err := rows.Scan(&key, &size); err != nil {
+			return nil, err
+		}
+		sizes[key] = size
+	}
+	return sizes, rows.Err()
+}
+
+// KeySize is the size of one value, as reported by LargestKeys.
+type KeySize struct {
+	Bucket string
+	Key    string
+	Size   int64
+}
+
+// LargestKeys returns the n largest values across all buckets, largest first. Buckets hidden by
+// WithBucketAccess are skipped.
+func (db *DB) LargestKeys(n int) ([]KeySize, error) {
+	if err := db.life.check(); err != nil {
+		return nil, err
+	}
+	rows, err := db.db.Query(db.largestQuery)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := make([]KeySize, 0, n)
+	for len(keys) < n && rows.Next() {
+		var k KeySize
+		if err := rows.Scan(&k.Bucket, &k.Key, &k.Size); err != nil {
+			return nil, err
+		}
+		if db.bucketAccess(k.Bucket) == BucketRestricted {
+			continue
+		}
+		keys = append(keys, k)
+	}
+	return keys, rows.Err()
+}
@@ -0,0 +1,49 @@
+package kvite
+
+func (s *KViteTestSuite) TestBucketSizes() {
+	dedup := s.openDB("dedup.db", WithDeduplication())
+	defer func() { _ = dedup.Close() }()
+
+	for _, db := range []*DB{s.DB, dedup} {
+		s.NoError(db.Transaction(func(tx *Tx) error {
+			b, _ := tx.CreateBucketIfNotExists("sizes")
+			for key, value := range map[string]string{"a": "", "b": "1234", "c": "1234"} {
+				if err := b.Put(key, []byte(value)); err != nil {
+					return err
+				}
+			}
+			other, _ := tx.CreateBucketIfNotExists("other")
+			return other.Put("d", []byte("ignored"))
+		}))
+
+		s.NoError(db.Transaction(func(tx *Tx) error {
+			b, _ := tx.CreateBucketIfNotExists("sizes")
+			sizes, err := b.Sizes()
+			s.NoError(err)
+			s.Equal(map[string]int64{"a": 0, "b": 4, "c": 4}, sizes)
+			return nil
+		}))
+	}
+}
+
+func (s *KViteTestSuite) TestLargestKeys() {
+	s.NoError(s.DB.Transaction(func(tx *Tx) error {
+		for _, k := range []KeySize{{"a", "small", 1}, {"a", "big", 100}, {"b", "medium", 10}, {"secret", "huge", 1000}} {
+			b, _ := tx.CreateBucketIfNotExists(k.Bucket)
+			if err := b.Put(k.Key, make([]byte, k.Size)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+
+	keys, err := s.DB.LargestKeys(2)
+	s.NoError(err)
+	s.Equal([]KeySize{{"secret", "huge", 1000}, {"a", "big", 100}}, keys)
+
+	restricted, err := s.DB.Restrict("secret", BucketRestricted)
+	s.NoError(err)
+	keys, err = restricted.LargestKeys(10)
+	s.NoError(err)
+	s.Equal([]KeySize{{"a", "big", 100}, {"b", "medium", 10}, {"a", "small", 1}}, keys)
+}
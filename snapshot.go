@@ -0,0 +1,56 @@
+package kvite
+
+import "strings"
+
+// Snapshot is a stable, read-only, point-in-time view of the database.
+// Writes made after the snapshot was taken are not visible through it.
+type Snapshot struct {
+	tx *Tx
+}
+
+// Snapshot pins a read snapshot of the database, which is useful for long-running exports while writes continue.
+// The database must be in WAL mode (see WithWAL), otherwise ErrNotWAL is returned.
+// A snapshot prevents the WAL from being checkpointed past it, so it must be released with Release when done.
+func (db *DB) Snapshot() (*Snapshot, error) {
+	if err := db.life.check(); err != nil {
+		return nil, err
+	}
+	var mode string
+	if err := db.db.QueryRow("PRAGMA journal_mode").Scan(&mode); err != nil {
+		return nil, err
+	}
+	if !strings.EqualFold(mode, "wal") {
+		return nil, ErrNotWAL
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	tx.readOnly = true
+
+	// SQLite starts the read transaction lazily, so read something to pin the snapshot now.
+	var n int
+	if err := tx.tx.QueryRow("SELECT count(*) FROM sqlite_master").Scan(&n); err != nil {
+		_ = tx.Rollback()
+		return nil, err
+	}
+
+	return &Snapshot{tx: tx}, nil
+}
+
+// Bucket gets a read-only view of a bucket as of the snapshot. It returns ErrBucketNotFound if the bucket did not
+// exist when the snapshot was taken.
+func (s *Snapshot) Bucket(name string) (*Bucket, error) {
+	return s.tx.Bucket(name)
+}
+
+// Buckets returns all the buckets as of the snapshot.
+func (s *Snapshot) Buckets() ([]string, error) {
+	return s.tx.db.buckets(s.tx.tx)
+}
+
+// Release releases the snapshot, allowing the WAL to be checkpointed past it.
+func (s *Snapshot) Release() error {
+	return s.tx.Rollback()
+}
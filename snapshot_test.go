@@ -0,0 +1,47 @@
+package kvite
+
+func (s *KViteTestSuite) TestDBSnapshot() {
+	// Snapshots need WAL mode
+	_, err := s.DB.Snapshot()
+	s.Equal(ErrNotWAL, err)
+
+	db := s.openDB("snapshot.db", WithWAL())
+	defer func() { _ = db.Close() }()
+
+	put := func(key, value string) {
+		err := db.Transaction(func(tx *Tx) error {
+			b, _ := tx.CreateBucket("test")
+			return b.Put(key, []byte(value))
+		})
+		s.Require().NoError(err)
+	}
+
+	put("foo", "bar")
+
+	snap, err := db.Snapshot()
+	s.Require().NoError(err)
+
+	// Writes continue while the snapshot is held
+	put("foo", "baz")
+	put("other", "value")
+
+	b, err := snap.Bucket("test")
+	s.NoError(err)
+	value, err := b.Get("foo")
+	s.NoError(err)
+	s.Equal([]byte("bar"), value)
+	value, err = b.Get("other")
+	s.NoError(err)
+	s.Nil(value)
+
+	// Snapshots are read-only
+	s.Equal(ErrTxReadOnly, b.Put("foo", []byte("nope")))
+	s.Equal(ErrTxReadOnly, b.Delete("foo"))
+
+	buckets, err := snap.Buckets()
+	s.NoError(err)
+	s.Equal([]string{"test"}, buckets)
+
+	s.NoError(snap.Release())
+	s.Error(snap.Release())
+}
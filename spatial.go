@@ -0,0 +1,128 @@
+package kvite
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// WithSpatialIndex creates an R*Tree index of boxes, so that keys can be given a bounding box with Bucket.PutBox
+// and found by the boxes they overlap with Bucket.SearchBox. A key's box is removed when the key is deleted, by
+// any means, and kept when it is overwritten.
+func WithSpatialIndex() Option {
+	return func(db *DB) error {
+		db.spatial = true
+		return nil
+	}
+}
+
+func (db *DB) boxesTable() string {
+	return db.table + "_kvite_boxes"
+}
+
+func (db *DB) rtreeTable() string {
+	return db.table + "_kvite_rtree"
+}
+
+// createSpatialTables creates the R*Tree, the table mapping its integer ids to keys, and the triggers that remove
+// the box of a deleted key.
+func (db *DB) createSpatialTables(tx *sql.Tx) error {
+	t, b, r := db.table, db.boxesTable(), db.rtreeTable()
+	queries := []string{
+		fmt.Sprintf("create TABLE IF NOT EXISTS '%s' (id integer primary key, bucket text not null, key text not null, UNIQUE (bucket, key))", b),
+		fmt.Sprintf("create VIRTUAL TABLE IF NOT EXISTS '%s' USING rtree(id, min_x, max_x, min_y, max_y)", r),
+		fmt.Sprintf(`create TRIGGER IF NOT EXISTS '%s_kvite_boxes_delete' AFTER DELETE ON '%s' BEGIN
+			DELETE FROM '%s' WHERE bucket = old.bucket AND key = old.key;
+		END`, t, t, b),
+		fmt.Sprintf(`create TRIGGER IF NOT EXISTS '%s_kvite_rtree_delete' AFTER DELETE ON '%s' BEGIN
+			DELETE FROM '%s' WHERE id = old.id;
+		END`, t, b, r),
+	}
+	for _, query := range queries {
+		if _, err := tx.Exec(query); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PutBox sets the bounding box of a key, replacing any box it had. The key must exist. The R*Tree stores
+// coordinates as 32-bit floats, rounded outwards, so boxes may be found by searches that only touch their edges.
+func (b *Bucket) PutBox(key string, minX, minY, maxX, maxY float64) error {
+	if b.tx.readOnly {
+		return ErrTxReadOnly
+	}
+	if err := b.checkAccess("put", true); err != nil {
+		return err
+	}
+	db := b.tx.db
+	if !db.spatial {
+		return ErrNoSpatialIndex
+	}
+	if minX > maxX || minY > maxY {
+		return errors.New("box minimum must not be greater than its maximum")
+	}
+	ok, err := b.Has(key)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("key %q does not exist in bucket %q", key, b.name)
+	}
+
+	query := fmt.Sprintf("INSERT INTO '%s' (bucket, key) VALUES (?, ?) ON CONFLICT (bucket, key) DO NOTHING", db.boxesTable())
+	if _, err := b.tx.exec(query, b.name, key); err != nil {
+		return err
+	}
+	query = fmt.Sprintf(`INSERT OR REPLACE INTO '%s' (id, min_x, max_x, min_y, max_y)
+		SELECT id, ?, ?, ?, ? FROM '%s' WHERE bucket = ? AND key = ?`, db.rtreeTable(), db.boxesTable())
+	_, err = b.tx.exec(query, minX, maxX, minY, maxY, b.name, key)
+	return err
+}
+
+// DeleteBox removes the bounding box of a key, if it has one. The key itself is kept.
+func (b *Bucket) DeleteBox(key string) error {
+	if b.tx.readOnly {
+		return ErrTxReadOnly
+	}
+	if err := b.checkAccess("delete", true); err != nil {
+		return err
+	}
+	if !b.tx.db.spatial {
+		return ErrNoSpatialIndex
+	}
+	query := fmt.Sprintf("DELETE FROM '%s' WHERE bucket = ? AND key = ?", b.tx.db.boxesTable())
+	_, err := b.tx.exec(query, b.name, key)
+	return err
+}
+
+// SearchBox returns the keys of the bucket whose boxes overlap the given box, including boxes that only touch it,
+// in key order.
+func (b *Bucket) SearchBox(minX, minY, maxX, maxY float64) ([]string, error) {
+	if err := b.checkAccess("get", false); err != nil {
+		return nil, err
+	}
+	db := b.tx.db
+	if !db.spatial {
+		return nil, ErrNoSpatialIndex
+	}
+	query := fmt.Sprintf(`SELECT k.key FROM '%s' r JOIN '%s' k ON k.id = r.id JOIN '%s' t ON t.bucket = k.bucket AND t.key = k.key
+		WHERE r.min_x <= ? AND r.max_x >= ? AND r.min_y <= ? AND r.max_y >= ? AND k.bucket = ?
+		AND (t.expires IS NULL OR t.expires > ?) ORDER BY k.key`, db.rtreeTable(), db.boxesTable(), db.table)
+	rows, err := b.tx.query(query, maxX, minX, maxY, minY, b.name, time.Now().UnixNano())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
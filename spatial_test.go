@@ -0,0 +1,65 @@
+package kvite
+
+func (s *KViteTestSuite) TestSpatialIndex() {
+	s.NoError(s.DB.Transaction(func(tx *Tx) error {
+		b, _ := tx.CreateBucket("places")
+		s.NoError(b.Put("a", nil))
+		s.Equal(ErrNoSpatialIndex, b.PutBox("a", 0, 0, 1, 1))
+		_, err := b.SearchBox(0, 0, 1, 1)
+		s.Equal(ErrNoSpatialIndex, err)
+		return nil
+	}))
+
+	db := s.openDB("spatial.db", WithSpatialIndex())
+	defer func() { _ = db.Close() }()
+	s.NoError(db.Transaction(func(tx *Tx) error {
+		b, _ := tx.CreateBucket("places")
+		for _, key := range []string{"a", "b", "c", "d"} {
+			s.NoError(b.Put(key, []byte(key)))
+		}
+		s.NoError(b.PutBox("a", 0, 0, 1, 1))
+		s.NoError(b.PutBox("b", 5, 5, 6, 6))
+		s.NoError(b.PutBox("c", 0.5, 0.5, 5.5, 5.5))
+		s.NoError(b.PutBox("d", 100, 100, 101, 101))
+		s.Error(b.PutBox("missing", 0, 0, 1, 1))
+		s.Error(b.PutBox("a", 1, 0, 0, 1))
+
+		other, _ := tx.CreateBucket("other")
+		s.NoError(other.Put("a", nil))
+		return other.PutBox("a", 0, 0, 1, 1)
+	}))
+
+	search := func(minX, minY, maxX, maxY float64) []string {
+		var keys []string
+		s.NoError(db.Transaction(func(tx *Tx) (err error) {
+			b, _ := tx.Bucket("places")
+			keys, err = b.SearchBox(minX, minY, maxX, maxY)
+			return err
+		}))
+		return keys
+	}
+	s.Equal([]string{"a", "c"}, search(0.2, 0.2, 0.8, 0.8))
+	s.Equal([]string{"b", "c"}, search(5.2, 5.2, 7, 7))
+	s.Equal([]string{"a", "b", "c"}, search(-10, -10, 10, 10))
+	s.Nil(search(20, 20, 30, 30))
+
+	s.NoError(db.Transaction(func(tx *Tx) error {
+		b, _ := tx.Bucket("places")
+		// Moving a box replaces it, overwriting a key keeps it, and deleting a key removes it
+		s.NoError(b.PutBox("b", 0, 0, 0.5, 0.5))
+		s.NoError(b.Put("a", []byte("new")))
+		s.NoError(b.Delete("c"))
+		return b.DeleteBox("d")
+	}))
+	s.Equal([]string{"a", "b"}, search(-1000, -1000, 1000, 1000))
+
+	s.NoError(db.Transaction(func(tx *Tx) error {
+		b, _ := tx.Bucket("places")
+		_, err := b.DeletePrefix("")
+		return err
+	}))
+	s.Nil(search(-1000, -1000, 1000, 1000))
+	var n int
+	s.NoError(db.db.QueryRow("SELECT count(*) FROM 'testing_kvite_rtree'").Scan(&n))
+	s.Equal(1, n, "only the box of the other bucket is left")
+}
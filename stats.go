@@ -0,0 +1,213 @@
+package kvite
+
+import (
+	"database/sql"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// TxStats describes the work done by a transaction.
+type TxStats struct {
+	// Statements is the number of SQL statements executed, including change feed bookkeeping.
+	Statements int
+	// RowsAffected is the number of rows inserted, replaced or deleted.
+	RowsAffected int64
+	// Duration is the time since the transaction began, or its total duration once it has finished.
+	Duration time.Duration
+}
+
+// Stats returns the statistics of the transaction so far.
+func (tx *Tx) Stats() TxStats {
+	s := tx.stats
+	if tx.finished.IsZero() {
+		s.Duration = time.Since(tx.started)
+	} else {
+		s.Duration = tx.finished.Sub(tx.started)
+	}
+	return s
+}
+
+// PendingWrites returns the number of keys the transaction has put or deleted so far, counting a key each time it
+// is written, and the rows changed by ExecRaw. A batch job can commit and begin a new transaction once it passes
+// a threshold, to bound the memory and WAL a single transaction uses.
+func (tx *Tx) PendingWrites() int64 {
+	return tx.writes
+}
+
+// countWrite records n keys of a bucket written by the transaction, with size bytes of values.
+func (tx *Tx) countWrite(bucket string, n int64, size int) {
+	tx.writes += n
+	tx.db.bucketStats.write(bucket, n, size)
+}
+
+// TxMetrics are cumulative transaction statistics for a database.
+type TxMetrics struct {
+	Commits   int64
+	Rollbacks int64
+	// Retries is the number of times a transaction was restarted because the database was busy.
+	Retries int64
+	// MeanLatency is the mean duration of committed and rolled back transactions, from Begin to Commit or Rollback.
+	MeanLatency time.Duration
+}
+
+// txMetrics holds the counters behind TxMetrics. It is shared by handles returned from Restrict.
+type txMetrics struct {
+	commits   int64
+	rollbacks int64
+	retries   int64
+	latency   int64
+}
+
+// TxMetrics returns cumulative transaction statistics since the database was opened.
+func (db *DB) TxMetrics() TxMetrics {
+	m := TxMetrics{
+		Commits:   atomic.LoadInt64(&db.metrics.commits),
+		Rollbacks: atomic.LoadInt64(&db.metrics.rollbacks),
+		Retries:   atomic.LoadInt64(&db.metrics.retries),
+	}
+	if n := m.Commits + m.Rollbacks; n > 0 {
+		m.MeanLatency = time.Duration(atomic.LoadInt64(&db.metrics.latency) / n)
+	}
+	return m
+}
+
+// finish records the end of a transaction in the database metrics.
+func (tx *Tx) finish(committed bool) {
+	defer tx.db.life.release()
+	tx.releaseLock()
+	if tx.conn != nil {
+		restoreSynchronous(tx.conn, tx.synchronous)
+	}
+	tx.finished = time.Now()
+	atomic.AddInt64(&tx.db.metrics.latency, int64(tx.finished.Sub(tx.started)))
+	if committed {
+		atomic.AddInt64(&tx.db.metrics.commits, 1)
+	} else {
+		atomic.AddInt64(&tx.db.metrics.rollbacks, 1)
+	}
+	tx.resumeBloom(committed)
+	tx.finishKeys(committed)
+}
+
+// exec executes a write statement in the transaction, counting it in the transaction statistics.
+func (tx *Tx) exec(query string, args ...interface{}) (sql.Result, error) {
+	tx.stats.Statements++
+	var res sql.Result
+	err := tx.trackWrite(func() (err error) {
+		res, err = tx.tx.Exec(query, args...)
+		return err
+	})
+	if err != nil {
+		return nil, sqliteError(err)
+	}
+	tx.wrote = true
+	if n, err := res.RowsAffected(); err == nil {
+		tx.stats.RowsAffected += n
+	}
+	return res, nil
+}
+
+func (tx *Tx) query(query string, args ...interface{}) (*sql.Rows, error) {
+	tx.stats.Statements++
+	rows, err := tx.tx.Query(query, args...)
+	return rows, sqliteError(err)
+}
+
+func (tx *Tx) queryRow(query string, args ...interface{}) *sql.Row {
+	tx.stats.Statements++
+	return tx.tx.QueryRow(query, args...)
+}
+
+// lockForWrite takes the write lock before the transaction reads anything. A transaction that reads and then
+// writes would otherwise have to upgrade its lock, which fails straight away with SQLITE_BUSY if another
+// transaction has done the same.
+func (tx *Tx) lockForWrite() error {
+	if tx.readOnly {
+		return ErrTxReadOnly
+	}
+	_, err := tx.exec(fmt.Sprintf("UPDATE '%s' SET value = value WHERE 0", tx.db.metaTable()))
+	return err
+}
+
+// Stats are cumulative statistics for a database.
+type Stats struct {
+	Tx TxMetrics
+	// Buckets holds the operation counts of each bucket that has been used since the database was opened.
+	Buckets map[string]BucketStats
+}
+
+// BucketStats counts the operations on a bucket. Operations are counted when they run, whether or not their
+// transaction commits, and values are counted at their size before encoding.
+type BucketStats struct {
+	// Reads is the number of values returned by Get, ForEach and queries.
+	Reads int64
+	// Writes is the number of keys written or deleted, including keys deleted in bulk or on expiry.
+	Writes int64
+	// BytesRead is the total size of the values read.
+	BytesRead int64
+	// BytesWritten is the total size of the values written.
+	BytesWritten int64
+}
+
+// bucketCounters holds the counters behind BucketStats.
+type bucketCounters struct {
+	reads        int64
+	writes       int64
+	bytesRead    int64
+	bytesWritten int64
+}
+
+// bucketStats holds the counters of each bucket. It is shared by handles returned from Restrict.
+type bucketStats struct {
+	mu sync.RWMutex
+	m  map[string]*bucketCounters
+}
+
+// counters returns the counters of a bucket, creating them on first use.
+func (s *bucketStats) counters(bucket string) *bucketCounters {
+	s.mu.RLock()
+	c := s.m[bucket]
+	s.mu.RUnlock()
+	if c != nil {
+		return c
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if c = s.m[bucket]; c == nil {
+		c = &bucketCounters{}
+		s.m[bucket] = c
+	}
+	return c
+}
+
+func (s *bucketStats) read(bucket string, size int) {
+	c := s.counters(bucket)
+	atomic.AddInt64(&c.reads, 1)
+	atomic.AddInt64(&c.bytesRead, int64(size))
+}
+
+func (s *bucketStats) write(bucket string, n int64, size int) {
+	c := s.counters(bucket)
+	atomic.AddInt64(&c.writes, n)
+	atomic.AddInt64(&c.bytesWritten, int64(size))
+}
+
+// Stats returns cumulative statistics since the database was opened. Handles returned from Restrict share the
+// statistics of the database they were made from.
+func (db *DB) Stats() Stats {
+	s := Stats{Tx: db.TxMetrics(), Buckets: make(map[string]BucketStats)}
+	db.bucketStats.mu.RLock()
+	defer db.bucketStats.mu.RUnlock()
+	for name, c := range db.bucketStats.m {
+		s.Buckets[name] = BucketStats{
+			Reads:        atomic.LoadInt64(&c.reads),
+			Writes:       atomic.LoadInt64(&c.writes),
+			BytesRead:    atomic.LoadInt64(&c.bytesRead),
+			BytesWritten: atomic.LoadInt64(&c.bytesWritten),
+		}
+	}
+	return s
+}
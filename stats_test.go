@@ -0,0 +1,122 @@
+package kvite
+
+import (
+	"errors"
+	"fmt"
+)
+
+func (s *KViteTestSuite) TestTxStats() {
+	db := s.openDB("stats.db")
+	defer func() { _ = db.Close() }()
+
+	var stats TxStats
+	s.NoError(db.Transaction(func(tx *Tx) error {
+		b := tx.newBucket("test")
+		_ = b.Put("foo", []byte("bar"))
+		_ = b.Put("baz", []byte("stuff"))
+		_ = b.Delete("missing")
+		_, _ = b.Get("foo")
+		stats = tx.Stats()
+		return nil
+	}))
+	s.Equal(4, stats.Statements)
+	s.Equal(int64(2), stats.RowsAffected)
+	s.True(stats.Duration > 0)
+
+	_ = db.Transaction(func(tx *Tx) error {
+		return errors.New("an error")
+	})
+
+	tx, err := db.Begin()
+	s.Require().NoError(err)
+	s.NoError(tx.Commit())
+	// Finishing twice is not counted twice
+	s.Error(tx.Rollback())
+	s.Equal(tx.Stats().Duration, tx.Stats().Duration)
+
+	m := db.TxMetrics()
+	s.Equal(int64(2), m.Commits)
+	s.Equal(int64(1), m.Rollbacks)
+	s.Equal(int64(0), m.Retries)
+	s.True(m.MeanLatency > 0)
+}
+
+func (s *KViteTestSuite) TestBucketStats() {
+	db := s.openDB("bucketstats.db")
+	defer func() { _ = db.Close() }()
+
+	s.NoError(db.Transaction(func(tx *Tx) error {
+		b := tx.newBucket("test")
+		_ = b.Put("foo", []byte("bar"))
+		_ = b.Put("baz", []byte("stuff"))
+		_ = b.Delete("missing")
+		_ = b.Delete("baz")
+		_, _ = b.Get("foo")
+		_, _ = b.Get("missing")
+		_ = b.ForEach(func(k string, v []byte) error { return nil })
+
+		other := tx.newBucket("other")
+		_ = other.Put("a", []byte("1"))
+		_ = other.Put("b", []byte("22"))
+		_, err := other.DeletePrefix("")
+		return err
+	}))
+
+	restricted, err := db.Restrict("test", BucketReadOnly)
+	s.Require().NoError(err)
+	s.NoError(restricted.Transaction(func(tx *Tx) error {
+		_, err := tx.newBucket("test").Get("foo")
+		return err
+	}))
+
+	stats := db.Stats()
+	s.Equal(db.TxMetrics(), stats.Tx)
+	s.Equal(map[string]BucketStats{
+		"test":  {Reads: 3, Writes: 3, BytesRead: 9, BytesWritten: 8},
+		"other": {Writes: 4, BytesWritten: 3},
+	}, stats.Buckets)
+	s.Equal(stats.Buckets, restricted.Stats().Buckets)
+}
+
+func (s *KViteTestSuite) TestPendingWrites() {
+	s.NoError(s.DB.Transaction(func(tx *Tx) error {
+		b, _ := tx.CreateBucketIfNotExists("pending")
+		s.Equal(int64(0), tx.PendingWrites())
+		for i := 0; i < 3; i++ {
+			s.NoError(b.Put(fmt.Sprint(i), []byte("v")))
+		}
+		s.NoError(b.Put("0", []byte("again")))
+		s.Equal(int64(4), tx.PendingWrites())
+
+		s.NoError(b.Delete("missing"))
+		s.NoError(b.Delete("0"))
+		s.Equal(int64(5), tx.PendingWrites())
+
+		n, err := b.Truncate()
+		s.Equal(int64(2), n)
+		s.Equal(int64(7), tx.PendingWrites())
+		return err
+	}))
+}
+
+func (s *KViteTestSuite) TestPendingWritesFailedPut() {
+	db := s.openDB("pendingfail.db")
+	defer func() { _ = db.Close() }()
+
+	s.NoError(db.Transaction(func(tx *Tx) error {
+		_, err := tx.ExecRaw(`CREATE TRIGGER refuse BEFORE INSERT ON testing WHEN NEW.key = 'refused'
+			BEGIN SELECT RAISE(ABORT, 'refused'); END`)
+		return err
+	}))
+
+	// A put that fails is neither counted nor journaled.
+	s.NoError(db.Transaction(func(tx *Tx) error {
+		b, _ := tx.CreateBucketIfNotExists("pending")
+		tx.StartJournal()
+		s.NoError(b.Put("ok", []byte("v")))
+		s.Error(b.Put("refused", []byte("v")))
+		s.Equal(int64(1), tx.PendingWrites())
+		s.Len(tx.Journal(), 1)
+		return nil
+	}))
+}
@@ -0,0 +1,54 @@
+package kvite
+
+import "github.com/mistifyio/kvite/kv"
+
+// Store, Transactor and KVBucket are the interfaces of package kv, for applications that want to depend on an
+// interface rather than on *DB, so that kvitemem or another implementation can be swapped in.
+//
+// *Bucket implements KVBucket. *DB and *Tx do not implement Store and Transactor, because Go methods only satisfy an
+// interface with identical signatures, and Begin, Transaction and Bucket keep returning and taking the concrete
+// types for existing callers. NewStore adapts them instead, with one wrapper for the whole database.
+type (
+	Store      = kv.Store
+	Transactor = kv.Transactor
+	KVBucket   = kv.KVBucket
+)
+
+var _ KVBucket = (*Bucket)(nil)
+
+// NewStore returns db as a Store. Transactions begun through the Store are ordinary kvite transactions.
+func NewStore(db *DB) Store {
+	return store{db}
+}
+
+type store struct{ *DB }
+
+func (s store) Begin() (Transactor, error) {
+	tx, err := s.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return transactor{tx}, nil
+}
+
+func (s store) Transaction(fn func(Transactor) error) error {
+	return s.DB.Transaction(func(tx *Tx) error { return fn(transactor{tx}) })
+}
+
+type transactor struct{ *Tx }
+
+func (t transactor) Bucket(name string) (KVBucket, error) {
+	b, err := t.Tx.Bucket(name)
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (t transactor) CreateBucket(name string) (KVBucket, error) {
+	b, err := t.Tx.CreateBucketIfNotExists(name)
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
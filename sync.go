@@ -0,0 +1,211 @@
+package kvite
+
+import (
+	"bytes"
+	"strconv"
+)
+
+// ConflictPolicy resolves a key that was changed in both databases since they were last synced.
+// It is given the latest change from each side and returns the one that should be kept.
+type ConflictPolicy func(a, b Change) Change
+
+// PreferA resolves every conflict in favour of the first database passed to Sync.
+func PreferA(a, b Change) Change {
+	return a
+}
+
+// PreferB resolves every conflict in favour of the second database passed to Sync.
+func PreferB(a, b Change) Change {
+	return b
+}
+
+// LastWriterWins keeps the most recent change. When both changes carry hybrid logical clock timestamps
+// (see WithTimestamps) they are compared, otherwise the wall clock times are. Ties are broken by origin so that the
+// outcome does not depend on the order of the arguments.
+func LastWriterWins(a, b Change) Change {
+	if a.Timestamp != 0 && b.Timestamp != 0 {
+		if (Version{a.Timestamp, a.Origin}).Less(Version{b.Timestamp, b.Origin}) {
+			return b
+		}
+		return a
+	}
+
+	switch {
+	case a.Time.After(b.Time):
+		return a
+	case b.Time.After(a.Time):
+		return b
+	case b.Origin > a.Origin:
+		return b
+	default:
+		return a
+	}
+}
+
+// NodeID returns the identifier generated for this database when it was created. It is recorded as the origin of
+// local changes in the change feed.
+func (db *DB) NodeID() string {
+	return db.nodeID
+}
+
+type changeKey struct {
+	bucket, key string
+}
+
+// Sync exchanges the changes made in a and b since they were last synced, so that both end up with the same
+// contents. Keys changed on only one side are copied to the other; keys changed on both are resolved by policy.
+// Both databases must be opened WithChangeFeed. The position reached in each feed is stored in the other database,
+// so repeated calls only exchange new changes. Sync runs in a write transaction on each database, and b commits
+// before a; if Sync fails in between, the next call sends a the changes it missed and resolves conflicts the same
+// way. With WithSingleWriter, Sync waits for the writers of both databases, so Sync(a, b) and Sync(b, a) must not
+// run at the same time.
+func Sync(a, b *DB, policy ConflictPolicy) error {
+	if !a.changeFeed || !b.changeFeed {
+		return ErrNoChangeFeed
+	}
+	if a.nodeID == b.nodeID {
+		return ErrSameNode
+	}
+
+	return a.Transaction(func(txA *Tx) error {
+		var (
+			toA   []Change
+			fromB *pendingChanges
+			lastB int64
+		)
+		err := b.Transaction(func(txB *Tx) error {
+			fromA, lastA, err := txA.pendingChanges(b.nodeID, txB)
+			if err != nil {
+				return err
+			}
+			if fromB, lastB, err = txB.pendingChanges(a.nodeID, txA); err != nil {
+				return err
+			}
+
+			var toB []Change
+			toA = nil
+			for k, ca := range fromA.latest {
+				cb, ok := fromB.latest[k]
+				if !ok {
+					toB = append(toB, ca)
+					continue
+				}
+				if ca.Type == cb.Type && bytes.Equal(ca.Value, cb.Value) {
+					continue
+				}
+				if w := policy(ca, cb); sameChange(w, cb) && !sameChange(w, ca) {
+					toA = append(toA, cb)
+				} else {
+					toB = append(toB, ca)
+				}
+			}
+			for k, cb := range fromB.latest {
+				if _, ok := fromA.latest[k]; !ok {
+					toA = append(toA, cb)
+				}
+			}
+
+			if err := txB.applyChanges(sortChanges(toB, fromA.order)); err != nil {
+				return err
+			}
+			return txB.setSyncCursor(a.nodeID, lastA)
+		})
+		if err != nil {
+			return err
+		}
+
+		if err := txA.applyChanges(sortChanges(toA, fromB.order)); err != nil {
+			return err
+		}
+		return txA.setSyncCursor(b.nodeID, lastB)
+	})
+}
+
+func sameChange(x, y Change) bool {
+	return x.Seq == y.Seq && x.Origin == y.Origin && x.Type == y.Type && x.Timestamp == y.Timestamp &&
+		x.Time.Equal(y.Time) && bytes.Equal(x.Value, y.Value)
+}
+
+// pendingChanges is the set of changes one side has not yet sent to the other, reduced to the latest per key.
+type pendingChanges struct {
+	latest map[changeKey]Change
+	order  []changeKey
+}
+
+// pendingChanges reads the changes in this transaction's feed that peer has not seen. Changes that originated at
+// peer are left out, since peer already has them, and so are the earlier changes to their keys, which they
+// replaced: a conflict that peer won is not sent back to it, even if peer's cursor was not updated after the sync
+// that resolved it. It also returns the last sequence number read.
+func (tx *Tx) pendingChanges(peer string, peerTx *Tx) (*pendingChanges, int64, error) {
+	since, err := peerTx.syncCursor(tx.db.nodeID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	changes, err := tx.db.changes(tx.tx, since)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	p := &pendingChanges{latest: make(map[changeKey]Change)}
+	last := since
+	for _, c := range changes {
+		last = c.Seq
+		if c.Origin == peer {
+			delete(p.latest, changeKey{c.Bucket, c.Key})
+			continue
+		}
+		k := changeKey{c.Bucket, c.Key}
+		if _, ok := p.latest[k]; !ok {
+			p.order = append(p.order, k)
+		}
+		p.latest[k] = c
+	}
+	return p, last, nil
+}
+
+// sortChanges returns changes in the order their keys were first changed, so they are applied deterministically.
+func sortChanges(changes []Change, order []changeKey) []Change {
+	byKey := make(map[changeKey]Change, len(changes))
+	for _, c := range changes {
+		byKey[changeKey{c.Bucket, c.Key}] = c
+	}
+	sorted := make([]Change, 0, len(changes))
+	for _, k := range order {
+		if c, ok := byKey[k]; ok {
+			sorted = append(sorted, c)
+			delete(byKey, k)
+		}
+	}
+	return sorted
+}
+
+// applyChanges writes changes received from another database, keeping their original origin and time.
+func (tx *Tx) applyChanges(changes []Change) error {
+	for _, c := range changes {
+		c.Seq = 0
+		var err error
+		if c.Type == ChangeDelete || c.Type == ChangeExpire {
+			err = tx.delete(&c)
+		} else {
+			err = tx.put(&c, ValueMeta{})
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// syncCursor returns the last sequence number of peer's change feed that has been applied to this database.
+func (tx *Tx) syncCursor(peer string) (int64, error) {
+	value, err := getMeta(tx.tx, tx.db.metaTable(), "sync:"+peer)
+	if err != nil || value == "" {
+		return 0, err
+	}
+	return strconv.ParseInt(value, 10, 64)
+}
+
+func (tx *Tx) setSyncCursor(peer string, seq int64) error {
+	return setMeta(tx.tx, tx.db.metaTable(), "sync:"+peer, strconv.FormatInt(seq, 10))
+}
@@ -0,0 +1,114 @@
+package kvite
+
+func (s *KViteTestSuite) TestSync() {
+	a := s.openDB("sync-a.db", WithChangeFeed())
+	defer func() { _ = a.Close() }()
+	b := s.openDB("sync-b.db", WithChangeFeed())
+	defer func() { _ = b.Close() }()
+
+	s.NotEqual(a.NodeID(), b.NodeID())
+	s.Equal(ErrNoChangeFeed, Sync(a, s.DB, PreferA))
+	s.Equal(ErrSameNode, Sync(a, a, PreferA))
+
+	put := func(db *DB, key, value string) {
+		s.Require().NoError(db.Transaction(func(tx *Tx) error {
+			bucket, _ := tx.CreateBucket("test")
+			return bucket.Put(key, []byte(value))
+		}))
+	}
+	get := func(db *DB, key string) string {
+		var value []byte
+		s.Require().NoError(db.Transaction(func(tx *Tx) error {
+			bucket, _ := tx.CreateBucketIfNotExists("test")
+			var err error
+			value, err = bucket.Get(key)
+			return err
+		}))
+		return string(value)
+	}
+
+	put(a, "onlyA", "1")
+	put(b, "onlyB", "2")
+	put(a, "both", "fromA")
+	put(b, "both", "fromB")
+	s.Require().NoError(b.Transaction(func(tx *Tx) error {
+		bucket, _ := tx.CreateBucket("test")
+		return bucket.Delete("onlyB")
+	}))
+	put(b, "deleted", "x")
+	s.Require().NoError(b.Transaction(func(tx *Tx) error {
+		bucket, _ := tx.CreateBucket("test")
+		return bucket.Delete("deleted")
+	}))
+
+	s.NoError(Sync(a, b, PreferB))
+	for _, db := range []*DB{a, b} {
+		s.Equal("1", get(db, "onlyA"))
+		s.Equal("", get(db, "onlyB"))
+		s.Equal("fromB", get(db, "both"))
+	}
+
+	// Applied changes keep their origin and are not sent back
+	changes, err := b.Changes(0)
+	s.NoError(err)
+	last := changes[len(changes)-1]
+	s.Equal("onlyA", last.Key)
+	s.Equal(a.NodeID(), last.Origin)
+
+	s.NoError(Sync(a, b, PreferB))
+	after, err := b.Changes(0)
+	s.NoError(err)
+	s.Len(after, len(changes))
+
+	// Later syncs only exchange new changes
+	put(a, "both", "newer")
+	s.NoError(Sync(a, b, LastWriterWins))
+	s.Equal("newer", get(b, "both"))
+}
+
+func (s *KViteTestSuite) TestSyncRecoversFromFailedCommit() {
+	a := s.openDB("sync-fail-a.db", WithChangeFeed())
+	defer func() { _ = a.Close() }()
+	b := s.openDB("sync-fail-b.db", WithChangeFeed())
+	defer func() { _ = b.Close() }()
+
+	for _, db := range []*DB{a, b} {
+		s.Require().NoError(db.Put("test", "both", []byte(db.NodeID())))
+	}
+	s.Require().NoError(b.Put("test", "blocked", []byte("b")))
+
+	// b commits, then a fails to apply b's changes.
+	s.Require().NoError(a.Transaction(func(tx *Tx) error {
+		_, err := tx.ExecRaw(`CREATE TRIGGER block BEFORE INSERT ON testing WHEN NEW.key = 'blocked'
+			BEGIN SELECT RAISE(ABORT, 'blocked'); END`)
+		return err
+	}))
+	s.Error(Sync(a, b, PreferA))
+	s.Require().NoError(a.Transaction(func(tx *Tx) error {
+		_, err := tx.ExecRaw("DROP TRIGGER block")
+		return err
+	}))
+
+	s.NoError(Sync(a, b, PreferA))
+	for _, db := range []*DB{a, b} {
+		value, err := db.Get("test", "both")
+		s.NoError(err)
+		s.Equal(a.NodeID(), string(value))
+		value, err = db.Get("test", "blocked")
+		s.NoError(err)
+		s.Equal("b", string(value))
+	}
+}
+
+func (s *KViteTestSuite) TestSyncSingleWriter() {
+	a := s.openDB("sync-writer-a.db", WithChangeFeed(), WithSingleWriter())
+	defer func() { _ = a.Close() }()
+	b := s.openDB("sync-writer-b.db", WithChangeFeed(), WithSingleWriter())
+	defer func() { _ = b.Close() }()
+
+	s.Require().NoError(a.Put("test", "k", []byte("v")))
+	s.NoError(Sync(a, b, PreferA))
+	value, err := b.Get("test", "k")
+	s.NoError(err)
+	s.Equal([]byte("v"), value)
+}
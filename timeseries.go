@@ -0,0 +1,147 @@
+package kvite
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// TimeSeries stores values by time in a bucket, for metrics and events. Keys encode the time so that the bucket's
+// keys sort in time order, followed by a sequence number that keeps values appended at the same time apart. Every
+// operation runs in its own transaction.
+type TimeSeries struct {
+	db     *DB
+	bucket string
+}
+
+// Point is a value of a TimeSeries and the time it was appended at.
+type Point struct {
+	Time  time.Time
+	Value []byte
+}
+
+// TimeSeries returns the time series stored in bucket. The bucket should not be used for anything else.
+func (db *DB) TimeSeries(bucket string) *TimeSeries {
+	return &TimeSeries{db: db, bucket: bucket}
+}
+
+// timeKey formats a time so that keys sort in time order, including times before 1970.
+func timeKey(t time.Time) string {
+	return fmt.Sprintf("%016x", uint64(t.UnixNano())^1<<63)
+}
+
+func parseTimeKey(key string) (time.Time, error) {
+	if len(key) < 16 {
+		return time.Time{}, fmt.Errorf("key %q is not a time series point", key)
+	}
+	n, err := strconv.ParseUint(key[:16], 16, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("key %q is not a time series point", key)
+	}
+	return time.Unix(0, int64(n^1<<63)), nil
+}
+
+// AppendAt adds a value at time t. Values appended at the same time are all kept, in the order they were appended.
+func (ts *TimeSeries) AppendAt(t time.Time, value []byte) error {
+	return ts.db.Transaction(func(tx *Tx) error {
+		if err := tx.lockForWrite(); err != nil {
+			return err
+		}
+		return ts.append(tx, t, value)
+	})
+}
+
+func (ts *TimeSeries) append(tx *Tx, t time.Time, value []byte) error {
+	// Points at t are the keys between its time key followed by "/" and the same followed by "0", the next byte
+	prefix := timeKey(t)
+	var last sql.NullString
+	query := fmt.Sprintf("SELECT max(key) FROM '%s' WHERE bucket = ? AND key >= ? AND key < ?", ts.db.table)
+	if err := tx.queryRow(query, ts.bucket, prefix+"/", prefix+"0").Scan(&last); err != nil {
+		return err
+	}
+	var seq uint64
+	if last.Valid {
+		n, err := strconv.ParseUint(last.String[len(prefix)+1:], 16, 64)
+		if err != nil {
+			return fmt.Errorf("bucket %s holds key %q, which is not a time series point", ts.bucket, last.String)
+		}
+		seq = n + 1
+	}
+	return tx.newBucket(ts.bucket).Put(fmt.Sprintf("%s/%08x", prefix, seq), value)
+}
+
+// RangeByTime returns the points from from, inclusive, to to, exclusive, in time order.
+func (ts *TimeSeries) RangeByTime(from, to time.Time) ([]Point, error) {
+	var points []Point
+	err := ts.db.Transaction(func(tx *Tx) (err error) {
+		points, err = ts.rangeByTime(tx, from, to)
+		return err
+	})
+	return points, err
+}
+
+func (ts *TimeSeries) rangeByTime(tx *Tx, from, to time.Time) ([]Point, error) {
+	var points []Point
+	err := tx.newBucket(ts.bucket).Query().Range(timeKey(from), timeKey(to)).ForEach(func(k string, v []byte) error {
+		t, err := parseTimeKey(k)
+		if err != nil {
+			return err
+		}
+		points = append(points, Point{Time: t, Value: v})
+		return nil
+	})
+	return points, err
+}
+
+// Prune deletes the points before a time, to enforce a retention period, and returns the number deleted.
+func (ts *TimeSeries) Prune(before time.Time) (int64, error) {
+	var n int64
+	err := ts.db.Transaction(func(tx *Tx) (err error) {
+		n, err = tx.newBucket(ts.bucket).deleteRows("delete", "bucket = ? AND key < ?", ts.bucket, timeKey(before))
+		return err
+	})
+	return n, err
+}
+
+// Downsample replaces the points from from, inclusive, to to, exclusive, with one point per interval: the points
+// of each interval, counted from from, are passed to fn, and the value it returns is appended at the start of the
+// interval in their place. Intervals without points are skipped. If fn returns an error, Downsample stops, leaves
+// the series unchanged and returns the error. The whole range is downsampled in one transaction.
+// Downsampling a range again passes the points written by the earlier run to fn as well.
+func (ts *TimeSeries) Downsample(from, to time.Time, interval time.Duration, fn func(start time.Time, points []Point) ([]byte, error)) error {
+	if interval <= 0 {
+		return errors.New("downsampling interval must be positive")
+	}
+	return ts.db.Transaction(func(tx *Tx) error {
+		if err := tx.lockForWrite(); err != nil {
+			return err
+		}
+		points, err := ts.rangeByTime(tx, from, to)
+		if err != nil || len(points) == 0 {
+			return err
+		}
+		if _, err := tx.newBucket(ts.bucket).deleteRows("delete", "bucket = ? AND key >= ? AND key < ?", ts.bucket, timeKey(from), timeKey(to)); err != nil {
+			return err
+		}
+
+		for len(points) > 0 {
+			start := from.Add(points[0].Time.Sub(from) / interval * interval)
+			end := start.Add(interval)
+			n := 1
+			for n < len(points) && points[n].Time.Before(end) {
+				n++
+			}
+			value, err := fn(start, points[:n])
+			if err != nil {
+				return err
+			}
+			if err := ts.append(tx, start, value); err != nil {
+				return err
+			}
+			points = points[n:]
+		}
+		return nil
+	})
+}
@@ -0,0 +1,57 @@
+package kvite
+
+import (
+	"strconv"
+	"time"
+)
+
+func (s *KViteTestSuite) TestTimeSeries() {
+	ts := s.DB.TimeSeries("metrics")
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(sec int) time.Time { return base.Add(time.Duration(sec) * time.Second) }
+
+	for _, sec := range []int{5, 1, 3, 3, 10, 12} {
+		s.NoError(ts.AppendAt(at(sec), []byte(strconv.Itoa(sec))))
+	}
+	s.NoError(ts.AppendAt(time.Unix(-10, 0), []byte("1969")))
+
+	values := func(points []Point) []string {
+		var v []string
+		for _, p := range points {
+			v = append(v, string(p.Value))
+		}
+		return v
+	}
+	points, err := ts.RangeByTime(at(1), at(10))
+	s.NoError(err)
+	s.Equal([]string{"1", "3", "3", "5"}, values(points))
+	s.True(points[0].Time.Equal(at(1)))
+
+	points, err = ts.RangeByTime(time.Unix(-20, 0), at(0))
+	s.NoError(err)
+	s.Equal([]string{"1969"}, values(points), "times before 1970 sort first")
+
+	// Sum the points of every four seconds
+	s.NoError(ts.Downsample(at(0), at(12), 4*time.Second, func(start time.Time, points []Point) ([]byte, error) {
+		sum := 0
+		for _, p := range points {
+			n, _ := strconv.Atoi(string(p.Value))
+			sum += n
+		}
+		return []byte(strconv.Itoa(sum)), nil
+	}))
+	points, err = ts.RangeByTime(at(0), at(20))
+	s.NoError(err)
+	s.Equal([]string{"7", "5", "10", "12"}, values(points))
+	s.True(points[1].Time.Equal(at(4)))
+	s.True(points[2].Time.Equal(at(8)))
+
+	n, err := ts.Prune(at(8))
+	s.NoError(err)
+	s.Equal(int64(3), n)
+	points, err = ts.RangeByTime(time.Unix(-20, 0), at(20))
+	s.NoError(err)
+	s.Equal([]string{"10", "12"}, values(points))
+
+	s.Error(ts.Downsample(at(0), at(20), 0, nil))
+}
@@ -0,0 +1,131 @@
+package kvite
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// TempStore selects where SQLite keeps temporary tables and indexes, such as those built to sort query results.
+type TempStore int
+
+const (
+	// TempStoreDefault leaves SQLite's compile-time setting, which normally uses files.
+	TempStoreDefault TempStore = iota
+	// TempStoreFile keeps temporary data in files.
+	TempStoreFile
+	// TempStoreMemory keeps temporary data in memory, which speeds up sorting large results at the cost of RAM.
+	TempStoreMemory
+)
+
+// WithTempStore sets PRAGMA temp_store on every connection to the database.
+func WithTempStore(t TempStore) Option {
+	return func(db *DB) error {
+		switch t {
+		case TempStoreDefault:
+			return nil
+		case TempStoreFile:
+			db.pragmas = append(db.pragmas, "PRAGMA temp_store = FILE")
+		case TempStoreMemory:
+			db.pragmas = append(db.pragmas, "PRAGMA temp_store = MEMORY")
+		default:
+			return fmt.Errorf("invalid temp store %d", t)
+		}
+		return nil
+	}
+}
+
+// WithCacheSize sets PRAGMA cache_size on every connection to the database. As in SQLite, a positive size is a
+// number of pages and a negative size is a number of KiB, so WithCacheSize(-64000) gives each connection a
+// page cache of about 64 MB.
+func WithCacheSize(size int) Option {
+	return func(db *DB) error {
+		db.pragmas = append(db.pragmas, fmt.Sprintf("PRAGMA cache_size = %d", size))
+		return nil
+	}
+}
+
+// WithExtension loads a SQLite extension on every connection to the database. entryPoint names the extension's
+// initialization function; if it is empty, SQLite derives it from the file name. Loading fails if the driver was
+// built with the sqlite_omit_load_extension tag.
+func WithExtension(path, entryPoint string) Option {
+	return func(db *DB) error {
+		db.extensions = append(db.extensions, extension{path: path, entryPoint: entryPoint})
+		return nil
+	}
+}
+
+type extension struct {
+	path       string
+	entryPoint string
+}
+
+// WithFunction makes a Go function available as a SQL function named name on every connection to the database.
+// The allowed argument and result types are those of go-sqlite3's RegisterFunc. A pure function's result depends
+// only on its arguments, which lets SQLite optimize calls and is required to use the function in an index
+// expression. An invalid function makes Open fail.
+func WithFunction(name string, impl interface{}, pure bool) Option {
+	return func(db *DB) error {
+		db.functions = append(db.functions, function{name: name, impl: impl, pure: pure})
+		return nil
+	}
+}
+
+type function struct {
+	name string
+	impl interface{}
+	pure bool
+}
+
+// connector opens connections to a database, loading its extensions, registering its functions and running its
+// pragmas on each new connection.
+type connector struct {
+	dsn    string
+	driver *sqlite3.SQLiteDriver
+}
+
+func newConnector(dsn string, db *DB) *connector {
+	d := &sqlite3.SQLiteDriver{}
+	// The driver loads extensions with the default entry point itself, but can't be given an entry point.
+	var named []extension
+	for _, e := range db.extensions {
+		if e.entryPoint == "" {
+			d.Extensions = append(d.Extensions, e.path)
+		} else {
+			named = append(named, e)
+		}
+	}
+
+	functions, pragmas := db.functions, db.pragmas
+	if len(named) > 0 || len(functions) > 0 || len(pragmas) > 0 {
+		d.ConnectHook = func(conn *sqlite3.SQLiteConn) error {
+			for _, e := range named {
+				if err := conn.LoadExtension(e.path, e.entryPoint); err != nil {
+					return fmt.Errorf("loading extension %s: %v", e.path, err)
+				}
+			}
+			for _, f := range functions {
+				if err := conn.RegisterFunc(f.name, f.impl, f.pure); err != nil {
+					return fmt.Errorf("registering function %s: %v", f.name, err)
+				}
+			}
+			for _, p := range pragmas {
+				if _, err := conn.Exec(p, nil); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	}
+	return &connector{dsn: dsn, driver: d}
+}
+
+func (c *connector) Connect(context.Context) (driver.Conn, error) {
+	return c.driver.Open(c.dsn)
+}
+
+func (c *connector) Driver() driver.Driver {
+	return c.driver
+}
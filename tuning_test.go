@@ -0,0 +1,52 @@
+package kvite
+
+import "path/filepath"
+
+func (s *KViteTestSuite) TestTuningPragmas() {
+	db := s.openDB("tuning.db", WithTempStore(TempStoreMemory), WithCacheSize(-4096))
+	defer func() { _ = db.Close() }()
+
+	// Check more than one connection to see that every connection is configured.
+	for i := 0; i < 2; i++ {
+		tx, err := db.Begin()
+		s.Require().NoError(err)
+		defer func() { _ = tx.Rollback() }()
+
+		var tempStore, cacheSize int
+		s.NoError(tx.tx.QueryRow("PRAGMA temp_store").Scan(&tempStore))
+		s.NoError(tx.tx.QueryRow("PRAGMA cache_size").Scan(&cacheSize))
+		s.Equal(2, tempStore)
+		s.Equal(-4096, cacheSize)
+	}
+
+	_, err := Open(":memory:", "", WithTempStore(7))
+	s.Error(err)
+}
+
+func (s *KViteTestSuite) TestWithExtension() {
+	for _, entryPoint := range []string{"", "sqlite3_missing_init"} {
+		db, err := Open(filepath.Join(s.TempDir, "ext.db"), "testing", WithExtension(filepath.Join(s.TempDir, "missing.so"), entryPoint))
+		s.Error(err)
+		s.Nil(db)
+	}
+}
+
+func (s *KViteTestSuite) TestWithFunction() {
+	reverse := func(v []byte) []byte {
+		r := make([]byte, len(v))
+		for i, b := range v {
+			r[len(v)-1-i] = b
+		}
+		return r
+	}
+	db := s.openDB("func.db", WithFunction("kv_reverse", reverse, true))
+	defer func() { _ = db.Close() }()
+
+	s.NoError(db.Put("funcs", "k", []byte("abc")))
+	var got []byte
+	s.NoError(db.db.QueryRow("SELECT kv_reverse(value) FROM testing WHERE key = 'k'").Scan(&got))
+	s.Equal([]byte("cba"), got)
+
+	_, err := Open(filepath.Join(s.TempDir, "badfunc.db"), "testing", WithFunction("bad", 42, true))
+	s.Error(err)
+}
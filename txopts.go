@@ -0,0 +1,93 @@
+package kvite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// TxMode selects when a transaction takes SQLite's locks.
+type TxMode int
+
+const (
+	// TxDeferred takes locks as they are needed: a read lock at the first read and the write lock at the first
+	// write. A transaction that reads and then writes can fail with "database is locked" when another transaction
+	// writes in between.
+	TxDeferred TxMode = iota
+	// TxImmediate takes the write lock when the transaction begins, waiting for other writers, so that a
+	// read-then-write transaction cannot fail half way through. Readers are not blocked.
+	TxImmediate
+	// TxExclusive takes the write lock when the transaction begins and, unless the database is in WAL mode, also
+	// keeps other connections from reading until it finishes. It needs a DB created by Open.
+	TxExclusive
+)
+
+// TxOptions configures a transaction started with BeginTx.
+type TxOptions struct {
+	// Mode selects when the transaction takes its locks.
+	Mode TxMode
+	// Durability overrides the durability of the database for this transaction. DurabilityDefault keeps the
+	// database's setting.
+	Durability Durability
+}
+
+// BeginTx starts a transaction with options. The context is used until the transaction is committed or rolled
+// back; if it is done before then, the transaction is rolled back. With WithSingleWriter, the transaction is
+// read-only and Mode is ignored.
+func (db *DB) BeginTx(ctx context.Context, opts TxOptions) (*Tx, error) {
+	if opts.Durability < DurabilityDefault || opts.Durability > DurabilityFull {
+		return nil, fmt.Errorf("invalid durability %d", opts.Durability)
+	}
+	if err := db.life.check(); err != nil {
+		return nil, err
+	}
+
+	if db.writer != nil {
+		opts.Mode = TxDeferred
+	}
+
+	pool := db.db
+	switch opts.Mode {
+	case TxDeferred, TxImmediate:
+	case TxExclusive:
+		if db.exclusive == nil {
+			return nil, errors.New("exclusive transactions need a database opened with Open")
+		}
+		pool = db.exclusive
+	default:
+		return nil, fmt.Errorf("invalid transaction mode %d", opts.Mode)
+	}
+
+	// PRAGMA synchronous applies to a connection, so a transaction with its own durability gets a connection of
+	// its own and the setting is restored when it finishes.
+	var (
+		conn     *sql.Conn
+		previous int
+		err      error
+	)
+	if opts.Durability != DurabilityDefault {
+		if conn, previous, err = setSynchronous(ctx, pool, opts.Durability); err != nil {
+			return nil, err
+		}
+	}
+
+	tx, err := db.beginOn(ctx, pool, conn)
+	if err != nil {
+		if conn != nil {
+			restoreSynchronous(conn, previous)
+		}
+		return nil, err
+	}
+	tx.conn = conn
+	tx.synchronous = previous
+	tx.readOnly = tx.readOnly || db.writer != nil
+
+	if opts.Mode == TxImmediate {
+		if err := tx.lockForWrite(); err != nil {
+			_ = tx.Rollback()
+			return nil, err
+		}
+	}
+	return tx, nil
+}
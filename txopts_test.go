@@ -0,0 +1,48 @@
+package kvite
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+)
+
+func (s *KViteTestSuite) TestBeginTxMode() {
+	path := filepath.Join(s.TempDir, "txmode.db")
+	db := s.openDB("txmode.db")
+	defer func() { _ = db.Close() }()
+
+	other, err := sql.Open("sqlite3", "file:"+path+"?_busy_timeout=0")
+	s.Require().NoError(err)
+	defer func() { _ = other.Close() }()
+	write := func() error {
+		_, err := other.Exec("INSERT OR REPLACE INTO testing (key, bucket, value) VALUES ('k', 'b', 'v')")
+		return err
+	}
+
+	// A deferred transaction takes no lock until it writes.
+	tx, err := db.BeginTx(context.Background(), TxOptions{})
+	s.Require().NoError(err)
+	s.NoError(write())
+	s.NoError(tx.Rollback())
+
+	for _, mode := range []TxMode{TxImmediate, TxExclusive} {
+		tx, err := db.BeginTx(context.Background(), TxOptions{Mode: mode, Durability: DurabilityFull})
+		s.Require().NoError(err, mode)
+		s.Error(write(), mode)
+		b, _ := tx.CreateBucketIfNotExists("mode")
+		s.NoError(b.Put("k", []byte("v")), mode)
+		s.NoError(tx.Commit(), mode)
+		s.NoError(write(), mode)
+	}
+
+	_, err = db.BeginTx(context.Background(), TxOptions{Mode: 42})
+	s.Error(err)
+
+	shared, err := OpenWithDB(other, "testing")
+	s.Require().NoError(err)
+	_, err = shared.BeginTx(context.Background(), TxOptions{Mode: TxExclusive})
+	s.Error(err)
+	tx, err = shared.BeginTx(context.Background(), TxOptions{Mode: TxImmediate})
+	s.NoError(err)
+	s.NoError(tx.Rollback())
+}
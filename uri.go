@@ -0,0 +1,70 @@
+package kvite
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// CacheMode selects whether the connections to a database share SQLite's page cache.
+type CacheMode int
+
+const (
+	// CacheDefault leaves SQLite's setting, which normally gives each connection a cache of its own.
+	CacheDefault CacheMode = iota
+	// CacheShared shares a cache between the connections of the process to the same file.
+	CacheShared
+	// CachePrivate gives each connection a cache of its own.
+	CachePrivate
+)
+
+// URIOptions are connection parameters that Open renders into the file: URI it opens the database with, so that
+// they need not be written in the driver's syntax. The zero value leaves every parameter unset.
+type URIOptions struct {
+	// Cache selects whether connections share a page cache.
+	Cache CacheMode
+	// Immutable tells SQLite that the file cannot change, so that it reads it without locking. The database must be
+	// opened ReadOnly, and nothing may change the file while it is open, including other processes.
+	Immutable bool
+	// VFS names the SQLite VFS to open the file with, such as "unix-dotfile". Empty uses the default.
+	VFS string
+	// TxLock selects how every transaction begins: TxDeferred, the default, TxImmediate, which takes the write lock
+	// when each transaction begins, even one that only reads, or TxExclusive. Transactions started by BeginTx with
+	// TxExclusive begin exclusively regardless.
+	TxLock TxMode
+}
+
+// WithURIOptions sets connection parameters of the database file. It needs a DB created by Open.
+func WithURIOptions(o URIOptions) Option {
+	return func(db *DB) error {
+		if o.Cache < CacheDefault || o.Cache > CachePrivate {
+			return fmt.Errorf("invalid cache mode %d", o.Cache)
+		}
+		if o.TxLock < TxDeferred || o.TxLock > TxExclusive {
+			return fmt.Errorf("invalid transaction mode %d", o.TxLock)
+		}
+		db.uri = o
+		return nil
+	}
+}
+
+// set adds the parameters to those of a file: URI.
+func (o URIOptions) set(params url.Values) {
+	switch o.Cache {
+	case CacheShared:
+		params.Set("cache", "shared")
+	case CachePrivate:
+		params.Set("cache", "private")
+	}
+	if o.Immutable {
+		params.Set("immutable", "1")
+	}
+	if o.VFS != "" {
+		params.Set("vfs", o.VFS)
+	}
+	switch o.TxLock {
+	case TxImmediate:
+		params.Set("_txlock", "immediate")
+	case TxExclusive:
+		params.Set("_txlock", "exclusive")
+	}
+}
@@ -0,0 +1,32 @@
+package kvite
+
+import (
+	"database/sql"
+	"path/filepath"
+)
+
+func (s *KViteTestSuite) TestURIOptions() {
+	db := s.openDB("uri.db", WithURIOptions(URIOptions{Cache: CachePrivate, VFS: "unix-dotfile", TxLock: TxImmediate}))
+	s.NoError(db.Put("test", "foo", []byte("bar")))
+	s.NoError(db.Close())
+
+	db = s.openDB("uri.db", ReadOnly(), WithURIOptions(URIOptions{Immutable: true}))
+	value, err := db.Get("test", "foo")
+	s.NoError(err)
+	s.Equal([]byte("bar"), value)
+	s.NoError(db.Close())
+
+	filename := filepath.Join(s.TempDir, "uri.db")
+	_, err = Open(filename, "testing", WithURIOptions(URIOptions{Immutable: true}))
+	s.Error(err)
+	_, err = Open(filename, "testing", WithURIOptions(URIOptions{VFS: "missing"}))
+	s.Error(err)
+	_, err = Open(filename, "testing", WithURIOptions(URIOptions{Cache: CacheMode(5)}))
+	s.Error(err)
+
+	raw, err := sql.Open("sqlite3", filename)
+	s.Require().NoError(err)
+	defer func() { _ = raw.Close() }()
+	_, err = OpenWithDB(raw, "testing", WithURIOptions(URIOptions{Cache: CacheShared}))
+	s.Error(err)
+}
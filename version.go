@@ -0,0 +1,41 @@
+package kvite
+
+import "database/sql"
+
+// GetVersion retrieves the value of a key together with its version number. Every Put of a key increments its
+// version, starting from 1; a key that does not exist has version 0 and a nil value.
+func (b *Bucket) GetVersion(key string) ([]byte, int64, error) {
+	value, err := b.Get(key)
+	if err != nil || value == nil {
+		return nil, 0, err
+	}
+	version, err := b.version(key)
+	return value, version, err
+}
+
+// PutVersion sets the value of a key only if its current version is expected, and fails with ErrVersionMismatch
+// otherwise. Pass 0 to create a key that must not exist yet. Together with GetVersion this gives optimistic
+// concurrency control, even between processes sharing the file: SQLite does not let a transaction write after
+// another connection has committed a change since it read.
+func (b *Bucket) PutVersion(key string, value []byte, expected int64) error {
+	version, err := b.version(key)
+	if err != nil {
+		return err
+	}
+	if version != expected {
+		return ErrVersionMismatch
+	}
+	return b.Put(key, value)
+}
+
+func (b *Bucket) version(key string) (int64, error) {
+	if err := b.checkAccess("get", false); err != nil {
+		return 0, err
+	}
+	var version int64
+	err := b.tx.queryRow(b.tx.db.revisionQuery, key, b.name).Scan(&version)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return version, err
+}
@@ -0,0 +1,40 @@
+package kvite
+
+func (s *KViteTestSuite) TestPutVersion() {
+	s.NoError(s.DB.Transaction(func(tx *Tx) error {
+		b, _ := tx.CreateBucket("test")
+
+		value, version, err := b.GetVersion("foo")
+		s.NoError(err)
+		s.Nil(value)
+		s.Equal(int64(0), version)
+
+		s.NoError(b.PutVersion("foo", []byte("bar"), 0))
+		s.Equal(ErrVersionMismatch, b.PutVersion("foo", []byte("bar"), 0))
+
+		s.NoError(b.Put("foo", []byte("baz")))
+		value, version, err = b.GetVersion("foo")
+		s.NoError(err)
+		s.Equal([]byte("baz"), value)
+		s.Equal(int64(2), version)
+
+		s.Equal(ErrVersionMismatch, b.PutVersion("foo", []byte("stale"), 1))
+		s.NoError(b.PutVersion("foo", []byte("fresh"), 2))
+
+		// Deleting a key resets its version
+		s.NoError(b.Delete("foo"))
+		return b.PutVersion("foo", []byte("again"), 0)
+	}))
+
+	// Versions also count with deduplication
+	db := s.openDB("version.db", WithDeduplication())
+	defer func() { _ = db.Close() }()
+	s.NoError(db.Transaction(func(tx *Tx) error {
+		b, _ := tx.CreateBucket("test")
+		_ = b.Put("foo", []byte("bar"))
+		_ = b.Put("foo", []byte("bar"))
+		_, version, err := b.GetVersion("foo")
+		s.Equal(int64(2), version)
+		return err
+	}))
+}
@@ -0,0 +1,71 @@
+package kvite
+
+import (
+	"context"
+	"time"
+)
+
+// waitPollInterval is how often WaitFor looks for the key directly, to notice writes made by other processes,
+// which do not reach watchers.
+const waitPollInterval = time.Second
+
+// WaitFor blocks until the key exists in the bucket and returns its value, or returns ctx's error once ctx is
+// done. It is woken by changes committed through this DB and also checks periodically for writes made by other
+// processes.
+//
+// The key is read in fresh transactions, not in the bucket's own, so the wait is not limited to the transaction's
+// view. The bucket's transaction should not hold the write lock, or the writer being waited for cannot commit.
+func (b *Bucket) WaitFor(ctx context.Context, key string) ([]byte, error) {
+	db := b.tx.db
+	if err := b.checkAccess("get", false); err != nil {
+		return nil, err
+	}
+
+	for {
+		w := db.Watch(ctx, WatchFilter{Bucket: b.name, Prefix: key, Types: []ChangeType{ChangePut}})
+		value, err := db.waitFor(ctx, w, b.name, key)
+		w.Close()
+		if err != ErrWatcherOverflow {
+			return value, err
+		}
+	}
+}
+
+// waitFor waits for the key using a watcher that was started before the first check, so no write is missed.
+func (db *DB) waitFor(ctx context.Context, w *Watcher, bucket, key string) ([]byte, error) {
+	ticker := time.NewTicker(waitPollInterval)
+	defer ticker.Stop()
+
+	for {
+		var value []byte
+		err := db.Transaction(func(tx *Tx) error {
+			b := tx.newBucket(bucket)
+			var err error
+			value, err = b.Get(key)
+			return err
+		})
+		if err != nil || value != nil {
+			return value, err
+		}
+
+	wait:
+		for {
+			select {
+			case c, ok := <-w.C:
+				if !ok {
+					if err := ctx.Err(); err != nil {
+						return nil, err
+					}
+					return nil, w.Err()
+				}
+				if c.Key == key {
+					return c.Value, nil
+				}
+			case <-ticker.C:
+				break wait
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+		}
+	}
+}
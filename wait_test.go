@@ -0,0 +1,47 @@
+package kvite
+
+import (
+	"context"
+	"time"
+)
+
+func (s *KViteTestSuite) TestBucketWaitFor() {
+	// Getting the bucket reads the database, so the waiting transaction holds a read lock, which only lets the
+	// writer commit in WAL mode.
+	db := s.openDB("wait.db", WithWAL())
+	defer func() { _ = db.Close() }()
+	s.NoError(db.Transaction(func(tx *Tx) error {
+		_, err := tx.CreateBucket("test")
+		return err
+	}))
+	tx, err := db.Begin()
+	s.Require().NoError(err)
+	defer func() { _ = tx.Rollback() }()
+	b, err := tx.Bucket("test")
+	s.Require().NoError(err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = b.WaitFor(ctx, "handoff")
+	s.Equal(context.DeadlineExceeded, err)
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		_ = db.Transaction(func(tx *Tx) error {
+			b, _ := tx.CreateBucketIfNotExists("test")
+			_ = b.Put("handoff-other", []byte("no"))
+			return b.Put("handoff", []byte("yes"))
+		})
+	}()
+
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	value, err := b.WaitFor(ctx, "handoff")
+	s.NoError(err)
+	s.Equal([]byte("yes"), value)
+
+	// An existing key is returned straight away
+	value, err = b.WaitFor(ctx, "handoff-other")
+	s.NoError(err)
+	s.Equal([]byte("no"), value)
+}
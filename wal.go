@@ -0,0 +1,181 @@
+package kvite
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+)
+
+const (
+	walHeaderSize      = 32
+	walFrameHeaderSize = 24
+)
+
+var errWALHeader = errors.New("invalid WAL header")
+
+// walHeader is the decoded header of an SQLite write-ahead log.
+type walHeader struct {
+	raw      []byte
+	order    binary.ByteOrder
+	pageSize int
+	salt     [8]byte
+	checksum [2]uint32
+}
+
+func parseWALHeader(b []byte) (*walHeader, error) {
+	if len(b) < walHeaderSize {
+		return nil, errWALHeader
+	}
+
+	h := &walHeader{raw: append([]byte(nil), b[:walHeaderSize]...)}
+	switch binary.BigEndian.Uint32(b[0:4]) {
+	case 0x377f0682:
+		h.order = binary.LittleEndian
+	case 0x377f0683:
+		h.order = binary.BigEndian
+	default:
+		return nil, errWALHeader
+	}
+
+	h.pageSize = int(binary.BigEndian.Uint32(b[8:12]))
+	if h.pageSize == 1 {
+		h.pageSize = 65536
+	}
+	copy(h.salt[:], b[16:24])
+	h.checksum = [2]uint32{binary.BigEndian.Uint32(b[24:28]), binary.BigEndian.Uint32(b[28:32])}
+
+	if walChecksum(h.order, [2]uint32{}, b[:24]) != h.checksum {
+		return nil, errWALHeader
+	}
+	return h, nil
+}
+
+func (h *walHeader) frameSize() int {
+	return walFrameHeaderSize + h.pageSize
+}
+
+// walChecksum continues the WAL checksum s over b, whose length must be a multiple of 8.
+func walChecksum(order binary.ByteOrder, s [2]uint32, b []byte) [2]uint32 {
+	for i := 0; i+8 <= len(b); i += 8 {
+		s[0] += order.Uint32(b[i:]) + s[1]
+		s[1] += order.Uint32(b[i+4:]) + s[0]
+	}
+	return s
+}
+
+// walFrame is a single page image from the WAL.
+type walFrame struct {
+	pgno   uint32
+	commit uint32
+	data   []byte
+}
+
+// walReader reads the committed frames of a WAL, verifying salts and the running checksum.
+type walReader struct {
+	hdr *walHeader
+	sum [2]uint32
+}
+
+func newWALReader(hdr *walHeader) *walReader {
+	return &walReader{hdr: hdr, sum: hdr.checksum}
+}
+
+// readCommitted reads frames from b, which starts at a frame boundary, and returns the number of bytes
+// covering complete transactions. Frames after the last commit frame, or after the first invalid frame, are left
+// unread. The reader's checksum state advances only over the returned bytes.
+func (r *walReader) readCommitted(b []byte, fn func(walFrame) error) (int, error) {
+	var (
+		size    = r.hdr.frameSize()
+		sum     = r.sum
+		n       int
+		pending []walFrame
+	)
+
+	for off := 0; off+size <= len(b); off += size {
+		frame := b[off : off+size]
+		if !bytes.Equal(frame[8:16], r.hdr.salt[:]) {
+			break
+		}
+		sum = walChecksum(r.hdr.order, sum, frame[:8])
+		sum = walChecksum(r.hdr.order, sum, frame[walFrameHeaderSize:])
+		if sum != [2]uint32{binary.BigEndian.Uint32(frame[16:20]), binary.BigEndian.Uint32(frame[20:24])} {
+			break
+		}
+
+		f := walFrame{
+			pgno:   binary.BigEndian.Uint32(frame[0:4]),
+			commit: binary.BigEndian.Uint32(frame[4:8]),
+			data:   frame[walFrameHeaderSize:],
+		}
+		pending = append(pending, f)
+		if f.commit == 0 {
+			continue
+		}
+
+		if fn != nil {
+			for _, p := range pending {
+				if err := fn(p); err != nil {
+					return n, err
+				}
+			}
+		}
+		pending = pending[:0]
+		n = off + size
+		r.sum = sum
+	}
+	return n, nil
+}
+
+// readWALFile reads the WAL at path from offset to the end. A missing WAL reads as empty.
+func readWALFile(path string, offset int64) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return io.ReadAll(f)
+}
+
+// readWALHeader reads the raw header of the WAL at path. A missing or short WAL reads as nil.
+func readWALHeader(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	b := make([]byte, walHeaderSize)
+	if _, err := io.ReadFull(f, b); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return b, nil
+}
+
+// applyWAL writes the committed frames of a WAL segment into a database file.
+func applyWAL(db *os.File, r *walReader, b []byte) (int, error) {
+	pageSize := int64(r.hdr.pageSize)
+	return r.readCommitted(b, func(f walFrame) error {
+		if _, err := db.WriteAt(f.data, int64(f.pgno-1)*pageSize); err != nil {
+			return err
+		}
+		if f.commit > 0 {
+			return db.Truncate(int64(f.commit) * pageSize)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,177 @@
+package kvite
+
+import (
+	"context"
+	"strings"
+	"sync"
+)
+
+// watchBuffer is the number of changes a Watcher can fall behind before it is closed with ErrWatcherOverflow.
+const watchBuffer = 256
+
+// WatchFilter selects the changes delivered to a Watcher. Zero fields match everything.
+type WatchFilter struct {
+	// Bucket restricts changes to a single bucket.
+	Bucket string
+	// Prefix restricts changes to keys beginning with it.
+	Prefix string
+	// Types restricts changes to the listed types.
+	Types []ChangeType
+}
+
+func (f *WatchFilter) match(c *Change) bool {
+	if f.Bucket != "" && c.Bucket != f.Bucket {
+		return false
+	}
+	if !strings.HasPrefix(c.Key, f.Prefix) {
+		return false
+	}
+	if len(f.Types) == 0 {
+		return true
+	}
+	for _, t := range f.Types {
+		if c.Type == t {
+			return true
+		}
+	}
+	return false
+}
+
+// Watcher receives the changes committed through a DB that match its filter.
+type Watcher struct {
+	// C delivers changes in commit order. It is closed when the watcher stops.
+	C <-chan Change
+
+	c      chan Change
+	filter WatchFilter
+	hub    *watchHub
+	err    error
+	once   sync.Once
+	// stopped is closed when the watcher stops, which ends the goroutine waiting for its context.
+	stopped chan struct{}
+}
+
+// Watch starts delivering the changes committed through db, or any handle sharing its connections, that match
+// filter. Changes are delivered after their transaction commits; rolled back changes are never seen. Changes made
+// by other processes are not seen, use the change feed for those. The watcher stops when ctx is done or Close is
+// called. A watcher that falls too far behind is stopped, and Err reports ErrWatcherOverflow.
+func (db *DB) Watch(ctx context.Context, filter WatchFilter) *Watcher {
+	c := make(chan Change, watchBuffer)
+	w := &Watcher{C: c, c: c, filter: filter, hub: db.hub, stopped: make(chan struct{})}
+	db.hub.add(w)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			w.stop(nil)
+		case <-w.stopped:
+		}
+	}()
+	return w
+}
+
+// Close stops the watcher and closes C.
+func (w *Watcher) Close() {
+	w.stop(nil)
+}
+
+// Err returns the reason the watcher stopped early, or nil.
+func (w *Watcher) Err() error {
+	w.hub.mu.Lock()
+	defer w.hub.mu.Unlock()
+	return w.err
+}
+
+func (w *Watcher) stop(err error) {
+	w.once.Do(func() {
+		w.hub.mu.Lock()
+		defer w.hub.mu.Unlock()
+		delete(w.hub.watchers, w)
+		w.err = err
+		close(w.c)
+		close(w.stopped)
+	})
+}
+
+// watchHub holds the watchers of a database. It is shared by handles returned from Restrict.
+type watchHub struct {
+	mu       sync.Mutex
+	watchers map[*Watcher]struct{}
+
+	// order is held by a transaction with changes to publish from before it commits until it has published them,
+	// so that watchers see changes in commit order.
+	order sync.Mutex
+}
+
+func (h *watchHub) add(w *Watcher) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.watchers == nil {
+		h.watchers = make(map[*Watcher]struct{})
+	}
+	h.watchers[w] = struct{}{}
+}
+
+// active reports whether there are any watchers, so that transactions only collect changes when needed.
+func (h *watchHub) active() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.watchers) > 0
+}
+
+// publish delivers committed changes to the matching watchers.
+func (h *watchHub) publish(changes []Change) {
+	if len(changes) == 0 {
+		return
+	}
+
+	var overflowed []*Watcher
+	h.mu.Lock()
+	for w := range h.watchers {
+		if !w.deliver(changes) {
+			overflowed = append(overflowed, w)
+		}
+	}
+	h.mu.Unlock()
+
+	for _, w := range overflowed {
+		w.stop(ErrWatcherOverflow)
+	}
+}
+
+// deliver sends the matching changes to the watcher without blocking. It returns false if the buffer is full.
+func (w *Watcher) deliver(changes []Change) bool {
+	for i := range changes {
+		if !w.filter.match(&changes[i]) {
+			continue
+		}
+		select {
+		case w.c <- changes[i]:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// notify collects a change to publish when the transaction commits, or to report from DryRun.
+func (tx *Tx) notify(c *Change) {
+	if !tx.recordsChanges() {
+		return
+	}
+	n := *c
+	if n.Value != nil {
+		n.Value = append([]byte{}, n.Value...)
+	}
+	if tx.dryRun {
+		n.Seq = 0
+		tx.planned = append(tx.planned, n)
+		return
+	}
+	tx.pending = append(tx.pending, n)
+}
+
+// recordsChanges reports whether notify needs every change the transaction makes.
+func (tx *Tx) recordsChanges() bool {
+	return tx.dryRun || tx.db.hub.active()
+}
@@ -0,0 +1,149 @@
+package kvite
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+)
+
+func (s *KViteTestSuite) TestWatch() {
+	ctx, cancel := context.WithCancel(context.Background())
+	all := s.DB.Watch(ctx, WatchFilter{})
+	users := s.DB.Watch(ctx, WatchFilter{Bucket: "test", Prefix: "user/", Types: []ChangeType{ChangePut}})
+	deletes := s.DB.Watch(ctx, WatchFilter{Types: []ChangeType{ChangeDelete}})
+
+	s.NoError(s.DB.Transaction(func(tx *Tx) error {
+		b, _ := tx.CreateBucket("test")
+		_ = b.Put("user/1", []byte("a"))
+		_ = b.Put("group/1", []byte("b"))
+		_ = b.Delete("user/1")
+		other, _ := tx.CreateBucket("other")
+		_ = other.Put("user/2", []byte("c"))
+		_, err := other.Truncate()
+		return err
+	}))
+
+	// Rolled back changes are not delivered
+	_ = s.DB.Transaction(func(tx *Tx) error {
+		b, _ := tx.CreateBucket("test")
+		_ = b.Put("user/3", []byte("d"))
+		return errors.New("an error")
+	})
+
+	c := <-users.C
+	s.Equal("user/1", c.Key)
+	s.Equal([]byte("a"), c.Value)
+
+	c = <-deletes.C
+	s.Equal("user/1", c.Key)
+	c = <-deletes.C
+	s.Equal("other", c.Bucket)
+	s.Equal("user/2", c.Key)
+
+	s.Len(all.C, 5)
+
+	cancel()
+	_, ok := <-users.C
+	s.False(ok)
+	s.NoError(users.Err())
+
+	// A watcher that falls behind is stopped
+	slow := s.DB.Watch(context.Background(), WatchFilter{})
+	s.putN(s.DB, "test", 0, watchBuffer+1)
+	for range slow.C {
+	}
+	s.Equal(ErrWatcherOverflow, slow.Err())
+}
+
+func (s *KViteTestSuite) TestWatchClose() {
+	db := s.openDB("watch-close.db")
+	defer func() { _ = db.Close() }()
+
+	// Closing watchers whose context is never done unregisters them and ends their goroutines.
+	goroutines := runtime.NumGoroutine()
+	for i := 0; i < 10; i++ {
+		db.Watch(context.Background(), WatchFilter{}).Close()
+	}
+	s.False(db.hub.active())
+	s.Eventually(func() bool { return runtime.NumGoroutine() <= goroutines }, time.Second, 5*time.Millisecond)
+}
+
+func (s *KViteTestSuite) TestWatchCommitOrder() {
+	db := s.openDB("watch-order.db", WithWAL(), WithURIOptions(URIOptions{TxLock: TxImmediate}))
+	defer func() { _ = db.Close() }()
+	w := db.Watch(context.Background(), WatchFilter{Bucket: "counter"})
+	defer w.Close()
+
+	// Concurrent transactions each write the next value of a counter, so the values must be seen in order.
+	const writers, writes = 4, 25
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < writes; j++ {
+				s.NoError(db.Transaction(func(tx *Tx) error {
+					b, err := tx.CreateBucketIfNotExists("counter")
+					if err != nil {
+						return err
+					}
+					value, err := b.Get("n")
+					if err != nil {
+						return err
+					}
+					n, _ := strconv.Atoi(string(value))
+					return b.Put("n", []byte(strconv.Itoa(n+1)))
+				}))
+			}
+		}()
+	}
+	wg.Wait()
+
+	for i := 1; i <= writers*writes; i++ {
+		c := <-w.C
+		s.Equal(strconv.Itoa(i), string(c.Value))
+	}
+}
+
+func (s *KViteTestSuite) TestWatchExpirations() {
+	db := s.openDB("expire-watch.db", WithChangeFeed())
+	defer func() { _ = db.Close() }()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	expirations := db.Watch(ctx, WatchFilter{Types: []ChangeType{ChangeExpire}})
+
+	s.NoError(db.Transaction(func(tx *Tx) error {
+		b, _ := tx.CreateBucket("test")
+		s.NoError(b.Put("swept", []byte("1")))
+		s.NoError(b.Put("read", []byte("2")))
+		s.NoError(b.Touch("swept", time.Nanosecond))
+		return b.Touch("read", time.Nanosecond)
+	}))
+	time.Sleep(time.Millisecond)
+
+	// Keys deleted by a read and by ExpireKeys are both reported
+	s.NoError(db.Transaction(func(tx *Tx) error {
+		b, _ := tx.Bucket("test")
+		s.NoError(b.Put("other", []byte("3")))
+		_, err := b.Get("read")
+		return err
+	}))
+	n, err := db.ExpireKeys()
+	s.NoError(err)
+	s.Equal(int64(1), n)
+
+	c := <-expirations.C
+	s.Equal("read", c.Key)
+	s.Equal(ChangeExpire, c.Type)
+	c = <-expirations.C
+	s.Equal("swept", c.Key)
+
+	changes, err := db.Changes(0)
+	s.NoError(err)
+	s.Require().Len(changes, 5)
+	s.Equal(ChangeExpire, changes[4].Type)
+	s.Equal("expire", changes[4].Type.String())
+}
@@ -0,0 +1,260 @@
+package kvite
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// WithWriteBehind makes DB.Put and DB.Delete return as soon as the write is recorded in an in-memory journal.
+// A background goroutine writes the journal to SQLite every interval, or sooner once maxBatch writes are waiting,
+// in a single transaction. This trades durability for throughput: writes still in the journal are lost if the
+// process exits without calling Close or Flush, and they are not visible to transactions until flushed.
+// Writes made within a transaction are not affected.
+func WithWriteBehind(interval time.Duration, maxBatch int) Option {
+	return func(db *DB) error {
+		if interval <= 0 || maxBatch <= 0 {
+			return errors.New("write-behind interval and batch size must be positive")
+		}
+		db.behind = &writeBehind{
+			interval: interval,
+			maxBatch: maxBatch,
+			kick:     make(chan struct{}, 1),
+			stopped:  make(chan struct{}),
+			done:     make(chan struct{}),
+		}
+		return nil
+	}
+}
+
+// Get retrieves the value of a key in its own read-only transaction, which does not wait for WithSingleWriter's
+// writer and does not push back SlidingTTL expiries. With WithWriteBehind, writes still in the journal are seen as
+// well. It returns nil, without an error, if the key does not exist, including when the bucket does not.
+func (db *DB) Get(bucket, key string) ([]byte, error) {
+	if access := db.bucketAccess(bucket); access == BucketRestricted {
+		return nil, &BucketAccessError{Bucket: bucket, Access: access, Op: "get"}
+	}
+	if db.behind != nil {
+		if c, ok := db.behind.lookup(bucket, key); ok {
+			if c.Type == ChangeDelete {
+				return nil, nil
+			}
+			return append([]byte{}, c.Value...), nil
+		}
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = tx.Rollback() }()
+	tx.readOnly = true
+	return tx.newBucket(bucket).Get(key)
+}
+
+// Put sets the value for a key in its own transaction, or through the journal when the database was opened with
+// WithWriteBehind.
+func (db *DB) Put(bucket, key string, value []byte) error {
+	if value == nil {
+		value = []byte{}
+	}
+	return db.write(Change{Bucket: bucket, Key: key, Type: ChangePut, Value: value})
+}
+
+// Delete removes a key in its own transaction, or through the journal when the database was opened with
+// WithWriteBehind.
+func (db *DB) Delete(bucket, key string) error {
+	return db.write(Change{Bucket: bucket, Key: key, Type: ChangeDelete})
+}
+
+func (db *DB) write(c Change) error {
+	if db.behind == nil {
+		return db.Transaction(func(tx *Tx) error {
+			if c.Type == ChangeDelete {
+				return tx.newBucket(c.Bucket).Delete(c.Key)
+			}
+			b, err := tx.CreateBucketIfNotExists(c.Bucket)
+			if err != nil {
+				return err
+			}
+			return b.Put(c.Key, c.Value)
+		})
+	}
+
+	if err := db.checkWrite(c); err != nil {
+		return err
+	}
+	return db.behind.add(c)
+}
+
+// Flush writes the journal of a database opened with WithWriteBehind to SQLite and waits for the transaction to
+// commit. It does nothing for other databases. Writes that fail, other than because the database is busy, are
+// dropped from the journal so that they do not hold up the writes after them, and reported by Flush, or by Close,
+// with a *DroppedWritesError, including those dropped by earlier flushes in the background.
+func (db *DB) Flush() error {
+	if db.behind == nil {
+		return nil
+	}
+	return db.behind.flush(db, true)
+}
+
+// writeBehind holds the journal of a database opened with WithWriteBehind. It is shared by handles returned from
+// Restrict.
+type writeBehind struct {
+	interval time.Duration
+	maxBatch int
+	kick     chan struct{}
+	stopped  chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+
+	// flushMu keeps flushes in journal order.
+	flushMu sync.Mutex
+
+	mu      sync.Mutex
+	journal []Change
+	closed  bool
+	// dropped holds the writes that failed since the last flush that reported them.
+	dropped *DroppedWritesError
+	// flushing is the part of the journal being written, still looked up until its transaction has finished.
+	flushing []Change
+}
+
+func (w *writeBehind) add(c Change) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return ErrDBClosed
+	}
+	w.journal = append(w.journal, c)
+	if len(w.journal) >= w.maxBatch {
+		select {
+		case w.kick <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// lookup returns the latest write to a key still in the journal, or being flushed from it.
+func (w *writeBehind) lookup(bucket, key string) (Change, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, journal := range [][]Change{w.journal, w.flushing} {
+		for i := len(journal) - 1; i >= 0; i-- {
+			if c := journal[i]; c.Bucket == bucket && c.Key == key {
+				return c, true
+			}
+		}
+	}
+	return Change{}, false
+}
+
+// run flushes the journal until stop is called. Failed flushes leave the journal in place to be retried.
+func (w *writeBehind) run(db *DB) {
+	defer close(w.done)
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.stopped:
+			return
+		case <-ticker.C:
+		case <-w.kick:
+		}
+		_ = w.flush(db, false)
+	}
+}
+
+// flush writes the journal. With report, it also returns the writes dropped by earlier flushes, which background
+// flushes leave for the next call to Flush or Close.
+func (w *writeBehind) flush(db *DB, report bool) error {
+	w.flushMu.Lock()
+	defer w.flushMu.Unlock()
+
+	w.mu.Lock()
+	batch := w.journal
+	if len(batch) == 0 {
+		defer w.mu.Unlock()
+		return w.takeDropped(report)
+	}
+	w.journal, w.flushing = nil, batch
+	w.mu.Unlock()
+
+	// If the batch fails, its writes are retried one at a time, so that a write that can never succeed does not
+	// hold up the others. Writes that fail because the database is busy are kept for the next flush, together with
+	// the writes after them, which must not overtake them; other failures drop the write.
+	var (
+		retry   []Change
+		dropped *DroppedWritesError
+	)
+	err := w.apply(db, batch)
+	if err != nil {
+		err = nil
+		for i, c := range batch {
+			cerr := w.apply(db, []Change{c})
+			if cerr == nil {
+				continue
+			}
+			if isBusy(cerr) {
+				retry, err = batch[i:], cerr
+				break
+			}
+			if dropped == nil {
+				dropped = &DroppedWritesError{}
+			}
+			dropped.Writes = append(dropped.Writes, c)
+			dropped.Errs = append(dropped.Errs, cerr)
+		}
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.journal = append(retry, w.journal...)
+	w.flushing = nil
+	if dropped != nil {
+		if w.dropped == nil {
+			w.dropped = &DroppedWritesError{}
+		}
+		w.dropped.Writes = append(w.dropped.Writes, dropped.Writes...)
+		w.dropped.Errs = append(w.dropped.Errs, dropped.Errs...)
+	}
+	if err != nil {
+		return err
+	}
+	return w.takeDropped(report)
+}
+
+// takeDropped returns the dropped writes and forgets them, if report is set. w.mu must be held.
+func (w *writeBehind) takeDropped(report bool) error {
+	if !report || w.dropped == nil {
+		return nil
+	}
+	dropped := w.dropped
+	w.dropped = nil
+	return dropped
+}
+
+// apply writes changes from the journal in one transaction.
+func (w *writeBehind) apply(db *DB, changes []Change) error {
+	return db.Transaction(func(tx *Tx) error {
+		if err := tx.lockForWrite(); err != nil {
+			return err
+		}
+		return tx.applyChanges(changes)
+	})
+}
+
+// stop refuses further writes, stops the background goroutine and flushes what remains of the journal.
+func (w *writeBehind) stop(db *DB) error {
+	var err error
+	w.stopOnce.Do(func() {
+		w.mu.Lock()
+		w.closed = true
+		w.mu.Unlock()
+		close(w.stopped)
+		<-w.done
+		err = w.flush(db, true)
+	})
+	return err
+}
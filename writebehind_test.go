@@ -0,0 +1,141 @@
+package kvite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+func (s *KViteTestSuite) TestDBPut() {
+	s.NoError(s.DB.Put("direct", "k", []byte("v")))
+	s.testStoredValue("direct", "k", []byte("v"))
+	value, err := s.DB.Get("direct", "k")
+	s.NoError(err)
+	s.Equal([]byte("v"), value)
+
+	s.NoError(s.DB.Delete("direct", "k"))
+	s.testStoredValue("direct", "k", nil)
+	value, err = s.DB.Get("direct", "k")
+	s.NoError(err)
+	s.Nil(value)
+	value, err = s.DB.Get("missing", "k")
+	s.NoError(err)
+	s.Nil(value)
+}
+
+func (s *KViteTestSuite) TestDBGetSingleWriter() {
+	db := s.openDB("get-writer.db", WithSingleWriter())
+	s.NoError(db.Put("direct", "k", []byte("v")))
+
+	// Get does not queue behind a write transaction that is still running
+	started, release := make(chan struct{}), make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- db.Transaction(func(tx *Tx) error {
+			close(started)
+			<-release
+			return nil
+		})
+	}()
+	<-started
+	value, err := db.Get("direct", "k")
+	s.NoError(err)
+	s.Equal([]byte("v"), value)
+	close(release)
+	s.NoError(<-done)
+}
+
+func (s *KViteTestSuite) TestWriteBehind() {
+	db := s.openDB("behind.db", WithWriteBehind(time.Hour, 1000))
+
+	count := func() int { return s.countKeys(db, "buffered") }
+
+	for i := 0; i < 10; i++ {
+		s.NoError(db.Put("buffered", fmt.Sprint(i), []byte("v")))
+	}
+	s.NoError(db.Delete("buffered", "0"))
+	s.Equal(0, count())
+	value, err := db.Get("buffered", "1")
+	s.NoError(err)
+	s.Equal([]byte("v"), value)
+	value, err = db.Get("buffered", "0")
+	s.NoError(err)
+	s.Nil(value)
+
+	s.NoError(db.Flush())
+	s.Equal(9, count())
+
+	s.NoError(db.Put("buffered", "last", []byte("v")))
+	s.NoError(db.CloseContext(context.Background()))
+	s.Equal(ErrDBClosed, db.Put("buffered", "late", []byte("v")))
+
+	db = s.openDB("behind.db")
+	defer func() { _ = db.Close() }()
+	s.Equal(10, count())
+}
+
+func (s *KViteTestSuite) TestWriteBehindBatch() {
+	db := s.openDB("batch.db", WithWriteBehind(time.Hour, 3))
+	defer func() { _ = db.Close() }()
+
+	for i := 0; i < 3; i++ {
+		s.NoError(db.Put("buffered", fmt.Sprint(i), []byte("v")))
+	}
+	s.Eventually(func() bool { return s.countKeys(db, "buffered") == 3 }, time.Second, 5*time.Millisecond)
+}
+
+func (s *KViteTestSuite) TestWriteBehindDropsFailedWrites() {
+	db := s.openDB("dropped.db", WithWriteBehind(time.Hour, 1000))
+	defer func() { _ = db.Close() }()
+	setMax := func(max int) {
+		s.NoError(db.Transaction(func(tx *Tx) error {
+			return tx.SetBucketConfig("limited", BucketConfig{MaxValueSize: max})
+		}))
+	}
+
+	setMax(2)
+	var tooLarge *ValueTooLargeError
+	s.True(errors.As(db.Put("limited", "rejected", []byte("too large")), &tooLarge))
+
+	// A write that only fails once it is flushed is dropped, without holding up the others.
+	setMax(0)
+	s.NoError(db.Put("limited", "before", []byte("v")))
+	s.NoError(db.Put("limited", "large", []byte("too large")))
+	s.NoError(db.Put("limited", "after", []byte("v")))
+	setMax(2)
+
+	err := db.Flush()
+	var dropped *DroppedWritesError
+	s.Require().True(errors.As(err, &dropped))
+	s.Require().Len(dropped.Writes, 1)
+	s.Equal("large", dropped.Writes[0].Key)
+	s.True(errors.As(dropped.Errs[0], &tooLarge))
+	s.Equal(2, s.countKeys(db, "limited"))
+
+	s.NoError(db.Flush())
+}
+
+func (s *KViteTestSuite) TestWriteBehindReportsBackgroundDrops() {
+	db := s.openDB("dropped-background.db", WithWriteBehind(20*time.Millisecond, 1000))
+	defer func() { _ = db.Close() }()
+	s.NoError(db.Transaction(func(tx *Tx) error {
+		return tx.SetBucketConfig("limited", BucketConfig{MaxValueSize: 2})
+	}))
+
+	// The write skips the check made by Put, as if the limit had changed after it was queued.
+	s.NoError(db.behind.add(Change{Bucket: "limited", Key: "large", Type: ChangePut, Value: []byte("too large")}))
+	s.Eventually(func() bool {
+		db.behind.mu.Lock()
+		defer db.behind.mu.Unlock()
+		return len(db.behind.journal) == 0 && db.behind.flushing == nil && db.behind.dropped != nil
+	}, time.Second, 5*time.Millisecond)
+	// Later background flushes leave them for Flush to report.
+	time.Sleep(50 * time.Millisecond)
+
+	var dropped *DroppedWritesError
+	s.Require().True(errors.As(db.Flush(), &dropped))
+	s.Require().Len(dropped.Writes, 1)
+	s.Equal("large", dropped.Writes[0].Key)
+	s.NoError(db.Flush())
+}
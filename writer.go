@@ -0,0 +1,75 @@
+package kvite
+
+import (
+	"errors"
+	"sync"
+)
+
+// WithSingleWriter funnels every write transaction through one goroutine, so that writers in this process never
+// compete for the SQLite write lock and fail with SQLITE_BUSY, and write latency depends only on the queue ahead.
+// Readers stay concurrent. Transactions run by DB.Transaction, and so by Batch, Put and Delete, are the writers:
+// each takes the write lock when it begins. Transactions started with Begin or BeginTx are read-only.
+// A function run by Transaction must not start another write transaction, which would wait for itself forever.
+func WithSingleWriter() Option {
+	return func(db *DB) error {
+		db.writer = &singleWriter{
+			requests: make(chan writeRequest),
+			stopped:  make(chan struct{}),
+		}
+		return nil
+	}
+}
+
+// singleWriter runs the write transactions of a database in turn. It is shared by handles returned from Restrict;
+// each request carries the handle it was made through.
+type singleWriter struct {
+	requests chan writeRequest
+	stopped  chan struct{}
+	stopOnce sync.Once
+}
+
+type writeRequest struct {
+	db  *DB
+	fn  func(*Tx) error
+	err chan<- error
+}
+
+// run executes write requests until the writer is stopped.
+func (w *singleWriter) run() {
+	for {
+		select {
+		case req := <-w.requests:
+			req.err <- req.db.writeTransaction(req.fn)
+		case <-w.stopped:
+			return
+		}
+	}
+}
+
+// do runs fn in a write transaction on the writer goroutine and returns its result.
+func (w *singleWriter) do(db *DB, fn func(*Tx) error) error {
+	errCh := make(chan error, 1)
+	select {
+	case w.requests <- writeRequest{db: db, fn: fn, err: errCh}:
+		return <-errCh
+	case <-w.stopped:
+		return ErrDBClosed
+	}
+}
+
+func (w *singleWriter) stop() {
+	w.stopOnce.Do(func() { close(w.stopped) })
+}
+
+// errPingRollback rolls back the test write of Ping when it runs on the writer goroutine.
+var errPingRollback = errors.New("ping write rolled back")
+
+// writeTransaction is a managed transaction that takes the write lock before calling fn.
+func (db *DB) writeTransaction(fn func(*Tx) error) error {
+	return db.transaction(func(tx *Tx) error {
+		if err := tx.lockForWrite(); err != nil {
+			return err
+		}
+		return fn(tx)
+	})
+}
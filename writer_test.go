@@ -0,0 +1,44 @@
+package kvite
+
+import (
+	"context"
+	"strconv"
+	"sync"
+)
+
+func (s *KViteTestSuite) TestSingleWriter() {
+	db := s.openDB("writer.db", WithSingleWriter())
+
+	// Read-modify-write transactions would otherwise fail to upgrade their locks.
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.NoError(db.Transaction(func(tx *Tx) error {
+				b, _ := tx.CreateBucketIfNotExists("writer")
+				value, err := b.Get("counter")
+				if err != nil {
+					return err
+				}
+				n, _ := strconv.Atoi(string(value))
+				return b.Put("counter", []byte(strconv.Itoa(n+1)))
+			}))
+		}()
+	}
+	wg.Wait()
+
+	tx, err := db.Begin()
+	s.Require().NoError(err)
+	b, _ := tx.CreateBucketIfNotExists("writer")
+	value, err := b.Get("counter")
+	s.NoError(err)
+	s.Equal([]byte("20"), value)
+	s.Equal(ErrTxReadOnly, b.Put("counter", nil))
+	s.NoError(tx.Rollback())
+
+	s.NoError(db.Ping(context.Background()))
+	s.NoError(db.Close())
+	s.Equal(ErrDBClosed, db.Transaction(func(*Tx) error { return nil }))
+	s.NoError(db.Close())
+}